@@ -29,6 +29,50 @@ type Config struct {
 		Host string `json:"host"`
 		Port int    `json:"port"`
 	} `json:"server"`
+	Dispatch struct {
+		Enabled  bool   `json:"enabled"`
+		Strategy string `json:"strategy"` // round_robin, least_loaded или nearest
+		Interval int    `json:"interval"` // Период автоматического распределения в секундах
+	} `json:"dispatch"`
+	Geocoder struct {
+		Gazetteer string `json:"gazetteer"` // Файл справочника адресов офлайн-геокодера; пусто - без геокодирования
+	} `json:"geocoder"`
+	ETA struct {
+		DefaultSpeed float64            `json:"default_speed"` // Средняя скорость в км/ч для курьера без типа транспорта
+		Speeds       map[string]float64 `json:"speeds"`        // Средняя скорость в км/ч по типу транспорта: foot, bicycle, scooter, car
+		StopMinutes  int                `json:"stop_minutes"`  // Время на вручение посылки на одной остановке
+		Threshold    int                `json:"threshold"`     // Изменение ожидаемого времени в секундах, о котором сообщается клиентам
+	} `json:"eta"`
+	WebSocket struct {
+		AllowedOrigins []string `json:"allowed_origins"` // Источники браузерных страниц, с которых разрешено подключение; "*" - любые
+	} `json:"websocket"`
+	Broker struct {
+		Type string `json:"type"` // kafka или memory - брокер в памяти процесса для тестов и локального запуска без Kafka
+	} `json:"broker"`
+	Payments struct {
+		Provider          string `json:"provider"`           // Платежный провайдер: пусто - без провайдера, simulator - симулятор шлюза, http - шлюз по адресу gateway_url
+		GatewayURL        string `json:"gateway_url"`        // Адрес платежного шлюза для провайдера http
+		Timeout           int    `json:"timeout"`            // Сколько секунд ждать ответа платежного шлюза
+		ReconcileInterval int    `json:"reconcile_interval"` // Период сверки со шлюзом платежей, отмеченных к списанию, в секундах
+		Simulator         struct {
+			Addr     string `json:"addr"`     // Адрес, на котором запускается симулятор платежного шлюза
+			Scenario string `json:"scenario"` // success, decline, error или 3ds
+			DelayMs  int    `json:"delay_ms"` // Задержка ответов симулятора в миллисекундах
+		} `json:"simulator"`
+	} `json:"payments"`
+	Outbox struct {
+		Interval       int `json:"interval"`        // Период публикации событий из outbox в Kafka в секундах
+		BatchSize      int `json:"batch_size"`      // Сколько событий публикуется за один раз
+		RetentionHours int `json:"retention_hours"` // Сколько часов хранятся опубликованные события
+	} `json:"outbox"`
+	Consumers struct {
+		GroupID     string `json:"group_id"`     // Группа потребителей Kafka, общая для всех экземпляров сервиса
+		Workers     int    `json:"workers"`      // Сколько сообщений обрабатывается одновременно
+		MaxAttempts int    `json:"max_attempts"` // Сколько раз обрабатывается сообщение, прежде чем попасть в DLQ
+	} `json:"consumers"`
+	Auth struct {
+		AdminEmail string `json:"admin_email"` // Пользователь, которому при запуске назначается роль admin; пусто - не назначается
+	} `json:"auth"`
 }
 
 // Читает файл конфигурации и возвращает структуру Config
@@ -50,6 +94,11 @@ func LoadConfig(filePath string) (*Config, error) {
 		config.Database.MaxRefreshTokens = 5 // По умолчанию храним 5 токенов
 	}
 
+	// По умолчанию события публикуются в Kafka
+	if config.Broker.Type == "" {
+		config.Broker.Type = "kafka"
+	}
+
 	// Проверяем, запущено ли приложение в контейнере
 	inContainer := os.Getenv("IN_CONTAINER") == "true"
 
@@ -99,6 +148,27 @@ func LoadConfig(filePath string) (*Config, error) {
 			config.Database.MaxRefreshTokens = mt
 		}
 	}
+	if enabled := os.Getenv("DISPATCH_ENABLED"); enabled != "" {
+		config.Dispatch.Enabled = enabled == "true"
+	}
+	if strategy := os.Getenv("DISPATCH_STRATEGY"); strategy != "" {
+		config.Dispatch.Strategy = strategy
+	}
+	if broker := os.Getenv("BROKER_TYPE"); broker != "" {
+		config.Broker.Type = broker
+	}
+	if provider := os.Getenv("PAYMENT_PROVIDER"); provider != "" {
+		config.Payments.Provider = provider
+	}
+	if gatewayURL := os.Getenv("PAYMENT_GATEWAY_URL"); gatewayURL != "" {
+		config.Payments.GatewayURL = gatewayURL
+	}
+	if gazetteer := os.Getenv("GEOCODER_GAZETTEER"); gazetteer != "" {
+		config.Geocoder.Gazetteer = gazetteer
+	}
+	if adminEmail := os.Getenv("ADMIN_EMAIL"); adminEmail != "" {
+		config.Auth.AdminEmail = adminEmail
+	}
 
 	return &config, nil
 }
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"context"
+	"delivery/internal/middleware"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// RateLimitAdmin - операции управления ограничением частоты запросов
+type RateLimitAdmin interface {
+	Config() middleware.RateLimitConfig
+	UpdateConfigBy(ctx context.Context, config middleware.RateLimitConfig, changedBy int) error
+	ConfigHistory(ctx context.Context) ([]middleware.RateLimitConfigChange, error)
+	ListBlocked(ctx context.Context) ([]middleware.BlockedClient, error)
+	UnblockIP(ctx context.Context, ip string) error
+	UnblockUser(ctx context.Context, userID int) error
+}
+
+type AdminHandler struct {
+	rateLimiter RateLimitAdmin
+}
+
+func NewAdminHandler(rateLimiter RateLimitAdmin) *AdminHandler {
+	return &AdminHandler{rateLimiter: rateLimiter}
+}
+
+func (h *AdminHandler) GetRateLimitConfig(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, h.rateLimiter.Config())
+}
+
+func (h *AdminHandler) UpdateRateLimitConfig(w http.ResponseWriter, r *http.Request) {
+	var config middleware.RateLimitConfig
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+		writeError(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	if err := config.Validate(); err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	adminID := callerFromRequest(r).userID
+	if err := h.rateLimiter.UpdateConfigBy(r.Context(), config, adminID); err != nil {
+		log.Printf("Ошибка при обновлении конфигурации Rate Limiting: %v", err)
+		writeError(w, "Ошибка при обновлении конфигурации", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]string{"status": "success"})
+}
+
+func (h *AdminHandler) GetRateLimitHistory(w http.ResponseWriter, r *http.Request) {
+	history, err := h.rateLimiter.ConfigHistory(r.Context())
+	if err != nil {
+		log.Printf("Ошибка при получении журнала изменений Rate Limiting: %v", err)
+		writeError(w, "Не удалось получить журнал изменений", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, history)
+}
+
+func (h *AdminHandler) ListBlocked(w http.ResponseWriter, r *http.Request) {
+	blocked, err := h.rateLimiter.ListBlocked(r.Context())
+	if err != nil {
+		log.Printf("Ошибка при получении списка блокировок: %v", err)
+		writeError(w, "Не удалось получить список блокировок", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, blocked)
+}
+
+func (h *AdminHandler) UnblockIP(w http.ResponseWriter, r *http.Request) {
+	ip := mux.Vars(r)["ip"]
+	if net.ParseIP(ip) == nil {
+		writeError(w, "Некорректный IP-адрес", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.rateLimiter.UnblockIP(r.Context(), ip); err != nil {
+		log.Printf("Ошибка при снятии блокировки IP %s: %v", ip, err)
+		writeError(w, "Не удалось снять блокировку", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[RATE_LIMIT_ADMIN] Пользователь %d снял блокировку с IP %s", callerFromRequest(r).userID, ip)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AdminHandler) UnblockUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, "Некорректный ID пользователя", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.rateLimiter.UnblockUser(r.Context(), userID); err != nil {
+		log.Printf("Ошибка при снятии блокировки пользователя %d: %v", userID, err)
+		writeError(w, "Не удалось снять блокировку", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[RATE_LIMIT_ADMIN] Пользователь %d снял блокировку с пользователя %d", callerFromRequest(r).userID, userID)
+	w.WriteHeader(http.StatusNoContent)
+}
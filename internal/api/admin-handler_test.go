@@ -0,0 +1,129 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"delivery/internal/business/models"
+	"delivery/internal/middleware"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// fakeRateLimitAdmin запоминает вызовы административных операций
+type fakeRateLimitAdmin struct {
+	config      middleware.RateLimitConfig
+	changedBy   int
+	unblockedIP string
+	unblockedID int
+}
+
+func (f *fakeRateLimitAdmin) Config() middleware.RateLimitConfig { return f.config }
+
+func (f *fakeRateLimitAdmin) UpdateConfigBy(ctx context.Context, config middleware.RateLimitConfig, changedBy int) error {
+	f.config, f.changedBy = config, changedBy
+	return nil
+}
+
+func (f *fakeRateLimitAdmin) ConfigHistory(ctx context.Context) ([]middleware.RateLimitConfigChange, error) {
+	return []middleware.RateLimitConfigChange{{ChangedBy: f.changedBy, Config: f.config}}, nil
+}
+
+func (f *fakeRateLimitAdmin) ListBlocked(ctx context.Context) ([]middleware.BlockedClient, error) {
+	return []middleware.BlockedClient{{Type: middleware.BlockedTypeIP, ID: "10.0.0.1"}}, nil
+}
+
+func (f *fakeRateLimitAdmin) UnblockIP(ctx context.Context, ip string) error {
+	f.unblockedIP = ip
+	return nil
+}
+
+func (f *fakeRateLimitAdmin) UnblockUser(ctx context.Context, userID int) error {
+	f.unblockedID = userID
+	return nil
+}
+
+func TestAdminHandler_UpdateRateLimitConfig(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{"Корректная конфигурация",
+			`{"authenticated_limits":{"client":50},"unauthenticated_limit":20,"block_duration":2}`, http.StatusOK},
+		{"Нулевой лимит",
+			`{"authenticated_limits":{"client":0},"unauthenticated_limit":20,"block_duration":2}`, http.StatusBadRequest},
+		{"Некорректный JSON", `{`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := &fakeRateLimitAdmin{config: middleware.DefaultRateLimitConfig()}
+			handler := NewAdminHandler(limiter)
+
+			req := withUser(httptest.NewRequest("POST", "/admin/rate-limit", bytes.NewBufferString(tt.body)), 9, models.UserRoleAdmin)
+			rr := httptest.NewRecorder()
+			handler.UpdateRateLimitConfig(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("UpdateRateLimitConfig() status = %d, want %d", rr.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK && (limiter.changedBy != 9 || limiter.config.UnauthenticatedLimit != 20) {
+				t.Errorf("UpdateRateLimitConfig() сохранил изменение от %d: %+v", limiter.changedBy, limiter.config)
+			}
+		})
+	}
+}
+
+func TestAdminHandler_GetRateLimitConfig(t *testing.T) {
+	handler := NewAdminHandler(&fakeRateLimitAdmin{config: middleware.DefaultRateLimitConfig()})
+
+	rr := httptest.NewRecorder()
+	handler.GetRateLimitConfig(rr, httptest.NewRequest("GET", "/admin/rate-limit", nil))
+
+	var config middleware.RateLimitConfig
+	if err := json.NewDecoder(rr.Body).Decode(&config); err != nil {
+		t.Fatalf("не удалось декодировать ответ: %v", err)
+	}
+	if config.UnauthenticatedLimit != 15 {
+		t.Errorf("GetRateLimitConfig() = %+v", config)
+	}
+}
+
+func TestAdminHandler_Unblock(t *testing.T) {
+	limiter := &fakeRateLimitAdmin{}
+	handler := NewAdminHandler(limiter)
+
+	t.Run("Некорректный IP", func(t *testing.T) {
+		req := mux.SetURLVars(httptest.NewRequest("DELETE", "/admin/rate-limit/blocked/ip/abc", nil), map[string]string{"ip": "abc"})
+		rr := httptest.NewRecorder()
+		handler.UnblockIP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("UnblockIP() status = %d, want %d", rr.Code, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("IP", func(t *testing.T) {
+		req := mux.SetURLVars(httptest.NewRequest("DELETE", "/admin/rate-limit/blocked/ip/10.0.0.1", nil), map[string]string{"ip": "10.0.0.1"})
+		rr := httptest.NewRecorder()
+		handler.UnblockIP(rr, req)
+
+		if rr.Code != http.StatusNoContent || limiter.unblockedIP != "10.0.0.1" {
+			t.Errorf("UnblockIP() status = %d, unblocked = %q", rr.Code, limiter.unblockedIP)
+		}
+	})
+
+	t.Run("Пользователь", func(t *testing.T) {
+		req := mux.SetURLVars(httptest.NewRequest("DELETE", "/admin/rate-limit/blocked/user/42", nil), map[string]string{"id": "42"})
+		rr := httptest.NewRecorder()
+		handler.UnblockUser(rr, req)
+
+		if rr.Code != http.StatusNoContent || limiter.unblockedID != 42 {
+			t.Errorf("UnblockUser() status = %d, unblocked = %d", rr.Code, limiter.unblockedID)
+		}
+	})
+}
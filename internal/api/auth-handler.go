@@ -0,0 +1,209 @@
+package api
+
+import (
+	"delivery/internal/auth"
+	"delivery/internal/middleware"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Минимальная длина пароля при регистрации
+const minPasswordLength = 8
+
+// AuthService - операции аутентификации и управления ролями пользователей
+type AuthService interface {
+	auth.Authenticator
+	SetUserRole(userID int, role string) error
+}
+
+type AuthHandler struct {
+	service AuthService
+}
+
+func NewAuthHandler(service AuthService) *AuthHandler {
+	return &AuthHandler{service: service}
+}
+
+// Запрос на регистрацию и вход
+type credentialsRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// Запрос на обновление токенов и выход
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Запрос на изменение роли пользователя
+type roleRequest struct {
+	Role string `json:"role"`
+}
+
+// Ответ с парой токенов
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+}
+
+func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
+	var input credentialsRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, "Invalid data", http.StatusBadRequest)
+		return
+	}
+
+	input.Email = strings.TrimSpace(input.Email)
+	if input.Email == "" || !strings.Contains(input.Email, "@") {
+		writeError(w, "Invalid email", http.StatusBadRequest)
+		return
+	}
+	if len(input.Password) < minPasswordLength {
+		writeError(w, "Password is too short", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.RegisterUser(input.Email, input.Password); err != nil {
+		if errors.Is(err, auth.ErrEmailAlreadyExists) {
+			writeError(w, "User with this email already exists", http.StatusConflict)
+			return
+		}
+		log.Printf("Ошибка при регистрации пользователя: %v", err)
+		writeError(w, "Failed to register user", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(map[string]string{"email": input.Email, "status": "registered"}); err != nil {
+		log.Printf("Ошибка при кодировании ответа: %v", err)
+	}
+}
+
+func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var input credentialsRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, "Invalid data", http.StatusBadRequest)
+		return
+	}
+
+	if input.Email == "" || input.Password == "" {
+		writeError(w, "Email and password are required", http.StatusBadRequest)
+		return
+	}
+
+	accessToken, refreshToken, err := h.service.LoginUser(strings.TrimSpace(input.Email), input.Password)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			writeError(w, "Invalid email or password", http.StatusUnauthorized)
+			return
+		}
+		log.Printf("Ошибка при входе пользователя: %v", err)
+		writeError(w, "Failed to login", http.StatusInternalServerError)
+		return
+	}
+
+	writeTokens(w, accessToken, refreshToken)
+}
+
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var input refreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, "Invalid data", http.StatusBadRequest)
+		return
+	}
+
+	if input.RefreshToken == "" {
+		writeError(w, "Refresh token is required", http.StatusBadRequest)
+		return
+	}
+
+	accessToken, refreshToken, err := h.service.RefreshToken(input.RefreshToken)
+	if err != nil {
+		if errors.Is(err, auth.ErrTokenNotFound) || errors.Is(err, auth.ErrTokenExpired) {
+			writeError(w, "Invalid refresh token", http.StatusUnauthorized)
+			return
+		}
+		log.Printf("Ошибка при обновлении токенов: %v", err)
+		writeError(w, "Failed to refresh token", http.StatusInternalServerError)
+		return
+	}
+
+	writeTokens(w, accessToken, refreshToken)
+}
+
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	// Access токен кладет в контекст AuthMiddleware
+	accessToken, ok := r.Context().Value(middleware.TokenKey).(string)
+	if !ok || accessToken == "" {
+		writeError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var input refreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, "Invalid data", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.Logout(accessToken, input.RefreshToken); err != nil {
+		if errors.Is(err, auth.ErrTokenNotFound) {
+			writeError(w, "Invalid refresh token", http.StatusUnauthorized)
+			return
+		}
+		log.Printf("Ошибка при выходе пользователя: %v", err)
+		writeError(w, "Failed to logout", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UpdateUserRole изменяет роль пользователя. Доступно только администраторам
+func (h *AuthHandler) UpdateUserRole(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		writeError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var input roleRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, "Invalid data", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.SetUserRole(userID, input.Role); err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidRole):
+			writeError(w, "Invalid role", http.StatusBadRequest)
+		case errors.Is(err, auth.ErrUserNotFound):
+			writeError(w, "User not found", http.StatusNotFound)
+		default:
+			log.Printf("Ошибка при изменении роли пользователя: %v", err)
+			writeError(w, "Failed to update user role", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeTokens(w http.ResponseWriter, accessToken, refreshToken string) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(tokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+	}); err != nil {
+		log.Printf("Ошибка при кодировании ответа: %v", err)
+	}
+}
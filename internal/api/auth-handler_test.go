@@ -0,0 +1,239 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"delivery/internal/auth"
+	"delivery/internal/middleware"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// mockAuthenticator реализует интерфейс AuthService для тестирования
+type mockAuthenticator struct {
+	RegisterUserFunc func(email, password string) error
+	LoginUserFunc    func(email, password string) (string, string, error)
+	RefreshTokenFunc func(refreshToken string) (string, string, error)
+	LogoutFunc       func(accessToken, refreshToken string) error
+	SetUserRoleFunc  func(userID int, role string) error
+}
+
+func (m *mockAuthenticator) RegisterUser(email, password string) error {
+	return m.RegisterUserFunc(email, password)
+}
+
+func (m *mockAuthenticator) LoginUser(email, password string) (string, string, error) {
+	return m.LoginUserFunc(email, password)
+}
+
+func (m *mockAuthenticator) RefreshToken(refreshToken string) (string, string, error) {
+	return m.RefreshTokenFunc(refreshToken)
+}
+
+func (m *mockAuthenticator) Logout(accessToken, refreshToken string) error {
+	return m.LogoutFunc(accessToken, refreshToken)
+}
+
+func (m *mockAuthenticator) SetUserRole(userID int, role string) error {
+	return m.SetUserRoleFunc(userID, role)
+}
+
+func TestAuthHandler_Register(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		err        error
+		wantStatus int
+	}{
+		{"Успешная регистрация", `{"email":"user@example.com","password":"password123"}`, nil, http.StatusCreated},
+		{"Email уже занят", `{"email":"user@example.com","password":"password123"}`,
+			fmt.Errorf("ошибка при регистрации пользователя: %w", auth.ErrEmailAlreadyExists), http.StatusConflict},
+		{"Короткий пароль", `{"email":"user@example.com","password":"123"}`, nil, http.StatusBadRequest},
+		{"Некорректный email", `{"email":"user","password":"password123"}`, nil, http.StatusBadRequest},
+		{"Некорректный JSON", `{`, nil, http.StatusBadRequest},
+		{"Ошибка хранилища", `{"email":"user@example.com","password":"password123"}`, errors.New("db down"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewAuthHandler(&mockAuthenticator{
+				RegisterUserFunc: func(email, password string) error { return tt.err },
+			})
+
+			req := httptest.NewRequest("POST", "/auth/register", bytes.NewBufferString(tt.body))
+			rr := httptest.NewRecorder()
+			handler.Register(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("Register() status = %d, want %d", rr.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestAuthHandler_Login(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{"Успешный вход", nil, http.StatusOK},
+		{"Неверные учетные данные", auth.ErrInvalidCredentials, http.StatusUnauthorized},
+		{"Ошибка хранилища", errors.New("db down"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewAuthHandler(&mockAuthenticator{
+				LoginUserFunc: func(email, password string) (string, string, error) {
+					if tt.err != nil {
+						return "", "", tt.err
+					}
+					return "access", "refresh", nil
+				},
+			})
+
+			body := `{"email":"user@example.com","password":"password123"}`
+			req := httptest.NewRequest("POST", "/auth/login", bytes.NewBufferString(body))
+			rr := httptest.NewRecorder()
+			handler.Login(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Login() status = %d, want %d", rr.Code, tt.wantStatus)
+			}
+
+			if tt.wantStatus == http.StatusOK {
+				var resp tokenResponse
+				if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+					t.Fatalf("не удалось декодировать ответ: %v", err)
+				}
+				if resp.AccessToken != "access" || resp.RefreshToken != "refresh" || resp.TokenType != "Bearer" {
+					t.Errorf("Login() вернул неожиданные токены: %+v", resp)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthHandler_Refresh(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		err        error
+		wantStatus int
+	}{
+		{"Успешное обновление", `{"refresh_token":"old"}`, nil, http.StatusOK},
+		{"Токен не найден", `{"refresh_token":"old"}`, fmt.Errorf("wrap: %w", auth.ErrTokenNotFound), http.StatusUnauthorized},
+		{"Токен истек", `{"refresh_token":"old"}`, auth.ErrTokenExpired, http.StatusUnauthorized},
+		{"Пустой токен", `{}`, nil, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewAuthHandler(&mockAuthenticator{
+				RefreshTokenFunc: func(refreshToken string) (string, string, error) {
+					if tt.err != nil {
+						return "", "", tt.err
+					}
+					return "new-access", "new-refresh", nil
+				},
+			})
+
+			req := httptest.NewRequest("POST", "/auth/refresh", bytes.NewBufferString(tt.body))
+			rr := httptest.NewRecorder()
+			handler.Refresh(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("Refresh() status = %d, want %d", rr.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestAuthHandler_Logout(t *testing.T) {
+	var gotAccess, gotRefresh string
+	handler := NewAuthHandler(&mockAuthenticator{
+		LogoutFunc: func(accessToken, refreshToken string) error {
+			gotAccess, gotRefresh = accessToken, refreshToken
+			return nil
+		},
+	})
+
+	t.Run("Без access токена", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/auth/logout", bytes.NewBufferString(`{"refresh_token":"r"}`))
+		rr := httptest.NewRecorder()
+		handler.Logout(rr, req)
+
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("Logout() status = %d, want %d", rr.Code, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("Успешный выход", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/auth/logout", bytes.NewBufferString(`{"refresh_token":"r"}`))
+		req = req.WithContext(context.WithValue(req.Context(), middleware.TokenKey, "a"))
+		rr := httptest.NewRecorder()
+		handler.Logout(rr, req)
+
+		if rr.Code != http.StatusNoContent {
+			t.Errorf("Logout() status = %d, want %d", rr.Code, http.StatusNoContent)
+		}
+		if gotAccess != "a" || gotRefresh != "r" {
+			t.Errorf("Logout() вызван с access=%q refresh=%q", gotAccess, gotRefresh)
+		}
+	})
+
+	t.Run("Чужой refresh токен", func(t *testing.T) {
+		handler := NewAuthHandler(&mockAuthenticator{
+			LogoutFunc: func(accessToken, refreshToken string) error {
+				return auth.ErrTokenNotFound
+			},
+		})
+		req := httptest.NewRequest("POST", "/auth/logout", bytes.NewBufferString(`{"refresh_token":"other"}`))
+		req = req.WithContext(context.WithValue(req.Context(), middleware.TokenKey, "a"))
+		rr := httptest.NewRecorder()
+		handler.Logout(rr, req)
+
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("Logout() status = %d, want %d", rr.Code, http.StatusUnauthorized)
+		}
+	})
+}
+
+func TestAuthHandler_UpdateUserRole(t *testing.T) {
+	tests := []struct {
+		name       string
+		id         string
+		body       string
+		err        error
+		wantStatus int
+	}{
+		{"Успешное изменение роли", "1", `{"role":"courier"}`, nil, http.StatusNoContent},
+		{"Недопустимая роль", "1", `{"role":"superuser"}`, fmt.Errorf("wrap: %w", auth.ErrInvalidRole), http.StatusBadRequest},
+		{"Пользователь не найден", "42", `{"role":"courier"}`, auth.ErrUserNotFound, http.StatusNotFound},
+		{"Некорректный ID", "abc", `{"role":"courier"}`, nil, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewAuthHandler(&mockAuthenticator{
+				SetUserRoleFunc: func(userID int, role string) error { return tt.err },
+			})
+
+			req := httptest.NewRequest("PUT", "/admin/users/"+tt.id+"/role", bytes.NewBufferString(tt.body))
+			req = mux.SetURLVars(req, map[string]string{"id": tt.id})
+			rr := httptest.NewRecorder()
+			handler.UpdateUserRole(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("UpdateUserRole() status = %d, want %d", rr.Code, tt.wantStatus)
+			}
+		})
+	}
+}
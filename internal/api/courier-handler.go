@@ -3,9 +3,11 @@ package api
 import (
 	"delivery/internal/business/models"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 )
@@ -17,17 +19,59 @@ type CourierService interface {
 	Delete(id int) error
 	List() ([]models.Courier, error)
 	GetAvailableCouriers() ([]models.Courier, error)
+	GetAvailableCouriersInZone(zoneID int) ([]models.Courier, error)
 	UpdateCourierStatus(id int, status string) error
+	GetByUserID(userID int) (*models.Courier, error)
 }
 
+// LocationService - прием и чтение местоположений курьеров
+type LocationService interface {
+	Record(location models.CourierLocation) error
+	Latest(courierID int) (models.CourierLocation, error)
+	Track(courierID int, from, to time.Time) ([]models.CourierLocation, error)
+}
+
+// RoutePlanner - построение порядка объезда адресов доставок курьера
+type RoutePlanner interface {
+	Plan(courierID int) (models.Route, error)
+}
+
+const (
+	// defaultTrackPeriod - период маршрута, если начало не указано
+	defaultTrackPeriod = time.Hour
+	// maxTrackPeriod - наибольший период маршрута в одном запросе
+	maxTrackPeriod = 7 * 24 * time.Hour
+)
+
 type CourierHandler struct {
-	service CourierService
+	service   CourierService
+	locations LocationService
+	routes    RoutePlanner
+	ownership *Ownership
 }
 
 func NewCourierHandler(service CourierService) *CourierHandler {
 	return &CourierHandler{service: service}
 }
 
+// WithOwnership ограничивает курьеров их собственным профилем
+func (h *CourierHandler) WithOwnership(ownership *Ownership) *CourierHandler {
+	h.ownership = ownership
+	return h
+}
+
+// WithLocations подключает прием и чтение местоположений курьеров
+func (h *CourierHandler) WithLocations(locations LocationService) *CourierHandler {
+	h.locations = locations
+	return h
+}
+
+// WithRoutes подключает построение маршрута по открытым доставкам курьера
+func (h *CourierHandler) WithRoutes(routes RoutePlanner) *CourierHandler {
+	h.routes = routes
+	return h
+}
+
 func (h *CourierHandler) CreateCourier(w http.ResponseWriter, r *http.Request) {
 	var courier models.Courier
 	if err := json.NewDecoder(r.Body).Decode(&courier); err != nil {
@@ -61,6 +105,11 @@ func (h *CourierHandler) GetCourier(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := h.ownership.CheckCourier(r, id); err != nil {
+		writeAccessError(w, err)
+		return
+	}
+
 	courier, err := h.service.Get(id)
 	if err != nil {
 		writeError(w, "Courier not found", http.StatusNotFound)
@@ -81,6 +130,11 @@ func (h *CourierHandler) UpdateCourier(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := h.ownership.CheckCourier(r, id); err != nil {
+		writeAccessError(w, err)
+		return
+	}
+
 	var courier models.Courier
 	if err := json.NewDecoder(r.Body).Decode(&courier); err != nil {
 		writeError(w, "Invalid data", http.StatusBadRequest)
@@ -125,8 +179,20 @@ func (h *CourierHandler) ListCouriers(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// GetAvailableCouriers возвращает доступных курьеров; ?zone_id= оставляет только обслуживающих зону
 func (h *CourierHandler) GetAvailableCouriers(w http.ResponseWriter, r *http.Request) {
-	couriers, err := h.service.GetAvailableCouriers()
+	var couriers []models.Courier
+	var err error
+	if zone := r.URL.Query().Get("zone_id"); zone != "" {
+		zoneID, convErr := strconv.Atoi(zone)
+		if convErr != nil {
+			writeError(w, "Invalid zone ID", http.StatusBadRequest)
+			return
+		}
+		couriers, err = h.service.GetAvailableCouriersInZone(zoneID)
+	} else {
+		couriers, err = h.service.GetAvailableCouriers()
+	}
 	if err != nil {
 		writeError(w, "Failed to fetch available couriers", http.StatusInternalServerError)
 		return
@@ -144,6 +210,11 @@ func (h *CourierHandler) UpdateCourierStatus(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	if err := h.ownership.CheckCourier(r, id); err != nil {
+		writeAccessError(w, err)
+		return
+	}
+
 	var input struct {
 		Status string `json:"status"`
 	}
@@ -153,9 +224,173 @@ func (h *CourierHandler) UpdateCourierStatus(w http.ResponseWriter, r *http.Requ
 	}
 
 	if err := h.service.UpdateCourierStatus(id, input.Status); err != nil {
-		writeError(w, "Failed to update courier status", http.StatusInternalServerError)
+		writeCourierStatusError(w, err, id)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
+
+// writeCourierStatusError пишет ответ для ошибки изменения статуса курьера: 400 для неизвестного статуса,
+// 404 для неизвестного курьера, 409 для курьера с незавершенной доставкой и 500 для остальных ошибок
+func writeCourierStatusError(w http.ResponseWriter, err error, id int) {
+	switch {
+	case errors.Is(err, models.ErrInvalidCourierStatus):
+		writeError(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, models.ErrCourierNotFound):
+		writeError(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, models.ErrCourierHasOpenDelivery):
+		writeError(w, err.Error(), http.StatusConflict)
+	default:
+		log.Printf("Ошибка при изменении статуса курьера %d: %v", id, err)
+		writeError(w, "Failed to update courier status", http.StatusInternalServerError)
+	}
+}
+
+// RecordLocation принимает текущее местоположение курьера с его устройства
+func (h *CourierHandler) RecordLocation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, "Invalid courier ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.ownership.CheckCourier(r, id); err != nil {
+		writeAccessError(w, err)
+		return
+	}
+
+	var location models.CourierLocation
+	if err := json.NewDecoder(r.Body).Decode(&location); err != nil {
+		writeError(w, "Invalid data", http.StatusBadRequest)
+		return
+	}
+	location.CourierID = id
+
+	if err := h.locations.Record(location); err != nil {
+		if errors.Is(err, models.ErrInvalidLocation) {
+			writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Ошибка при сохранении местоположения курьера %d: %v", id, err)
+		writeError(w, "Failed to record location", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetLocation возвращает последнее известное местоположение курьера
+func (h *CourierHandler) GetLocation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, "Invalid courier ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.ownership.CheckCourier(r, id); err != nil {
+		writeAccessError(w, err)
+		return
+	}
+
+	location, err := h.locations.Latest(id)
+	if err != nil {
+		if errors.Is(err, models.ErrLocationNotFound) {
+			writeError(w, "Location not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Ошибка при получении местоположения курьера %d: %v", id, err)
+		writeError(w, "Failed to get location", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, location)
+}
+
+// GetTrack возвращает маршрут курьера за период ?from=&to= (RFC 3339).
+// По умолчанию - последний час
+func (h *CourierHandler) GetTrack(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, "Invalid courier ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.ownership.CheckCourier(r, id); err != nil {
+		writeAccessError(w, err)
+		return
+	}
+
+	from, to, err := trackPeriod(r)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	track, err := h.locations.Track(id, from, to)
+	if err != nil {
+		log.Printf("Ошибка при получении маршрута курьера %d: %v", id, err)
+		writeError(w, "Failed to get track", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, track)
+}
+
+// trackPeriod читает границы периода маршрута из параметров запроса
+func trackPeriod(r *http.Request) (time.Time, time.Time, error) {
+	query := r.URL.Query()
+
+	to := time.Now()
+	if v := query.Get("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("Invalid 'to' time, expected RFC 3339")
+		}
+		to = t
+	}
+
+	from := to.Add(-defaultTrackPeriod)
+	if v := query.Get("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("Invalid 'from' time, expected RFC 3339")
+		}
+		from = t
+	}
+
+	if from.After(to) {
+		return time.Time{}, time.Time{}, errors.New("'from' must not be after 'to'")
+	}
+	if to.Sub(from) > maxTrackPeriod {
+		return time.Time{}, time.Time{}, errors.New("Track period must not exceed 7 days")
+	}
+	return from, to, nil
+}
+
+// GetRoute возвращает оптимальный порядок объезда адресов открытых доставок курьера от его текущего местоположения
+func (h *CourierHandler) GetRoute(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, "Invalid courier ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.ownership.CheckCourier(r, id); err != nil {
+		writeAccessError(w, err)
+		return
+	}
+
+	route, err := h.routes.Plan(id)
+	if err != nil {
+		if errors.Is(err, models.ErrLocationNotFound) {
+			writeError(w, "Courier location not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Ошибка при построении маршрута курьера %d: %v", id, err)
+		writeError(w, "Failed to plan route", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, route)
+}
@@ -0,0 +1,207 @@
+package api
+
+import (
+	"bytes"
+	"delivery/internal/business/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// fakeLocationService хранит местоположения в памяти и запоминает запрошенный период маршрута
+type fakeLocationService struct {
+	latest   map[int]models.CourierLocation
+	from, to time.Time
+}
+
+func (f *fakeLocationService) Record(location models.CourierLocation) error {
+	if location.Lat > 90 {
+		return fmt.Errorf("%w: координаты вне допустимого диапазона", models.ErrInvalidLocation)
+	}
+	f.latest[location.CourierID] = location
+	return nil
+}
+
+func (f *fakeLocationService) Latest(courierID int) (models.CourierLocation, error) {
+	location, ok := f.latest[courierID]
+	if !ok {
+		return location, models.ErrLocationNotFound
+	}
+	return location, nil
+}
+
+func (f *fakeLocationService) Track(courierID int, from, to time.Time) ([]models.CourierLocation, error) {
+	f.from, f.to = from, to
+	return []models.CourierLocation{f.latest[courierID]}, nil
+}
+
+func TestCourierHandler_RecordLocation(t *testing.T) {
+	ownership, _, _ := newTestOwnership()
+
+	tests := []struct {
+		name       string
+		userID     int
+		courierID  string
+		body       string
+		wantStatus int
+	}{
+		{"Свое местоположение", 3, "30", `{"lat":55.75,"lon":37.62,"accuracy":5,"heading":90}`, http.StatusNoContent},
+		{"Чужой профиль", 3, "40", `{"lat":55.75,"lon":37.62}`, http.StatusForbidden},
+		{"Некорректные координаты", 3, "30", `{"lat":95,"lon":37.62}`, http.StatusBadRequest},
+		{"Некорректный JSON", 3, "30", `{`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			locations := &fakeLocationService{latest: map[int]models.CourierLocation{}}
+			handler := NewCourierHandler(&fakeCourierService{}).WithOwnership(ownership).WithLocations(locations)
+
+			req := httptest.NewRequest("POST", "/couriers/"+tt.courierID+"/location", bytes.NewBufferString(tt.body))
+			req = withUser(mux.SetURLVars(req, map[string]string{"id": tt.courierID}), tt.userID, models.UserRoleCourier)
+			rr := httptest.NewRecorder()
+			handler.RecordLocation(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("RecordLocation() status = %d, want %d", rr.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusNoContent && locations.latest[30].Heading != 90 {
+				t.Errorf("RecordLocation() сохранил %+v", locations.latest[30])
+			}
+		})
+	}
+}
+
+// statusCourierService возвращает заданную ошибку изменения статуса
+type statusCourierService struct {
+	fakeCourierService
+	err error
+}
+
+func (f *statusCourierService) UpdateCourierStatus(id int, status string) error {
+	return f.err
+}
+
+func TestCourierHandler_UpdateCourierStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{"Статус изменен", nil, http.StatusOK},
+		{"Неизвестный статус", fmt.Errorf("%w: vacation", models.ErrInvalidCourierStatus), http.StatusBadRequest},
+		{"Неизвестный курьер", fmt.Errorf("курьер не найден: %w", models.ErrCourierNotFound), http.StatusNotFound},
+		{"Незавершенная доставка", fmt.Errorf("%w: курьер 30", models.ErrCourierHasOpenDelivery), http.StatusConflict},
+		{"Ошибка БД", errors.New("connection refused"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewCourierHandler(&statusCourierService{err: tt.err})
+
+			req := httptest.NewRequest("PUT", "/couriers/30/status", bytes.NewBufferString(`{"status":"available"}`))
+			req = withUser(mux.SetURLVars(req, map[string]string{"id": "30"}), 1, models.UserRoleDispatcher)
+			rr := httptest.NewRecorder()
+			handler.UpdateCourierStatus(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("UpdateCourierStatus() status = %d, want %d", rr.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestCourierHandler_GetLocation(t *testing.T) {
+	locations := &fakeLocationService{latest: map[int]models.CourierLocation{30: {CourierID: 30, Lat: 55.75, Lon: 37.62}}}
+	handler := NewCourierHandler(&fakeCourierService{}).WithLocations(locations)
+
+	for id, want := range map[string]int{"30": http.StatusOK, "40": http.StatusNotFound} {
+		req := mux.SetURLVars(httptest.NewRequest("GET", "/couriers/"+id+"/location", nil), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		handler.GetLocation(rr, req)
+
+		if rr.Code != want {
+			t.Errorf("GetLocation(%s) status = %d, want %d", id, rr.Code, want)
+		}
+	}
+}
+
+func TestCourierHandler_GetTrack(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantPeriod time.Duration
+	}{
+		{"Период по умолчанию", "", http.StatusOK, time.Hour},
+		{"Заданный период", "?from=2026-01-01T10:00:00Z&to=2026-01-01T12:30:00Z", http.StatusOK, 150 * time.Minute},
+		{"Пустой период", "?from=2026-01-01T10:00:00Z&to=2026-01-01T10:00:00Z", http.StatusOK, 0},
+		{"Некорректное время", "?from=yesterday", http.StatusBadRequest, 0},
+		{"Начало после конца", "?from=2026-01-02T00:00:00Z&to=2026-01-01T00:00:00Z", http.StatusBadRequest, 0},
+		{"Слишком длинный период", "?from=2026-01-01T00:00:00Z&to=2026-02-01T00:00:00Z", http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			locations := &fakeLocationService{latest: map[int]models.CourierLocation{}}
+			handler := NewCourierHandler(&fakeCourierService{}).WithLocations(locations)
+
+			req := mux.SetURLVars(httptest.NewRequest("GET", "/couriers/30/track"+tt.query, nil), map[string]string{"id": "30"})
+			rr := httptest.NewRecorder()
+			handler.GetTrack(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("GetTrack() status = %d, want %d", rr.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK && locations.to.Sub(locations.from) != tt.wantPeriod {
+				t.Errorf("GetTrack() период = %v, want %v", locations.to.Sub(locations.from), tt.wantPeriod)
+			}
+		})
+	}
+}
+
+// fakeRoutePlanner строит маршрут только для курьеров с известным местоположением
+type fakeRoutePlanner map[int]models.Route
+
+func (f fakeRoutePlanner) Plan(courierID int) (models.Route, error) {
+	route, ok := f[courierID]
+	if !ok {
+		return route, models.ErrLocationNotFound
+	}
+	return route, nil
+}
+
+func TestCourierHandler_GetRoute(t *testing.T) {
+	ownership, _, _ := newTestOwnership()
+	routes := fakeRoutePlanner{30: {CourierID: 30, Stops: []models.RouteStop{{DeliveryID: 1}}}}
+	handler := NewCourierHandler(&fakeCourierService{}).WithOwnership(ownership).WithRoutes(routes)
+
+	tests := []struct {
+		name       string
+		userID     int
+		role       string
+		courierID  string
+		wantStatus int
+	}{
+		{"Свой маршрут", 3, models.UserRoleCourier, "30", http.StatusOK},
+		{"Чужой маршрут", 3, models.UserRoleCourier, "40", http.StatusForbidden},
+		{"Местоположение неизвестно", 1, models.UserRoleDispatcher, "40", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/couriers/"+tt.courierID+"/route", nil)
+			req = withUser(mux.SetURLVars(req, map[string]string{"id": tt.courierID}), tt.userID, tt.role)
+			rr := httptest.NewRecorder()
+			handler.GetRoute(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("GetRoute() status = %d, want %d", rr.Code, tt.wantStatus)
+			}
+		})
+	}
+}
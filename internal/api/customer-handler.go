@@ -15,16 +15,24 @@ type CustomerService interface {
 	Update(id int, customer *models.Customer) error
 	Delete(id int) error
 	List() ([]models.Customer, error)
+	GetByUserID(userID int) (*models.Customer, error)
 }
 
 type CustomerHandler struct {
-	service CustomerService
+	service   CustomerService
+	ownership *Ownership
 }
 
 func NewCustomerHandler(service CustomerService) *CustomerHandler {
 	return &CustomerHandler{service: service}
 }
 
+// WithOwnership ограничивает клиентов их собственным профилем
+func (h *CustomerHandler) WithOwnership(ownership *Ownership) *CustomerHandler {
+	h.ownership = ownership
+	return h
+}
+
 func (h *CustomerHandler) CreateCustomer(w http.ResponseWriter, r *http.Request) {
 	var customer models.Customer
 	if err := json.NewDecoder(r.Body).Decode(&customer); err != nil {
@@ -37,6 +45,11 @@ func (h *CustomerHandler) CreateCustomer(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// Клиент создает профиль только для своей учетной записи
+	if h.ownership.Scoped(r) {
+		customer.UserID = callerFromRequest(r).userID
+	}
+
 	if err := h.service.Create(&customer); err != nil {
 		writeError(w, "Не удалось создать клиента", http.StatusInternalServerError)
 		return
@@ -61,6 +74,11 @@ func (h *CustomerHandler) GetCustomer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := h.ownership.CheckCustomer(r, id); err != nil {
+		writeAccessError(w, err)
+		return
+	}
+
 	customer, err := h.service.Get(id)
 	if err != nil {
 		writeError(w, "Клиент не найден", http.StatusNotFound)
@@ -85,6 +103,11 @@ func (h *CustomerHandler) UpdateCustomer(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if err := h.ownership.CheckCustomer(r, id); err != nil {
+		writeAccessError(w, err)
+		return
+	}
+
 	var customer models.Customer
 	if err := json.NewDecoder(r.Body).Decode(&customer); err != nil {
 		writeError(w, "Некорректные данные", http.StatusBadRequest)
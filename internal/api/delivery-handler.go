@@ -3,40 +3,110 @@ package api
 import (
 	"delivery/internal/business/models"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
 
 	"github.com/gorilla/mux"
 )
 
+// DeliveryService - операции с доставками. Изменяющие методы принимают AuditInfo для журнала изменений
 type DeliveryService interface {
-	Create(delivery *models.Delivery) error
+	Create(delivery *models.Delivery, audit models.AuditInfo) error
 	Get(id int) (*models.Delivery, error)
-	Update(id int, delivery *models.Delivery) error
-	Delete(id int) error
+	Update(id int, delivery *models.Delivery, audit models.AuditInfo) error
+	Delete(id int, audit models.AuditInfo) error
 	GetByParcelID(parcelID int) (*models.Delivery, error)
-	AssignDelivery(courierID, parcelID int) (models.Delivery, error)
-	CompleteDelivery(deliveryID int) error
+	AssignDelivery(courierID, parcelID int, audit models.AuditInfo) (models.Delivery, error)
+	CompleteDelivery(deliveryID int, audit models.AuditInfo) error
 	GetDeliveriesByCourier(courierID int) ([]models.Delivery, error)
+	History(deliveryID int) ([]models.DeliveryEvent, error)
+}
+
+// deliveryInput - тело запроса на изменение доставки с необязательным комментарием для журнала
+type deliveryInput struct {
+	models.Delivery
+	Note string `json:"note"`
 }
 
 type DeliveryHandler struct {
-	service DeliveryService
+	service   DeliveryService
+	ownership *Ownership
 }
 
 func NewDeliveryHandler(service DeliveryService) *DeliveryHandler {
 	return &DeliveryHandler{service: service}
 }
 
+// WithOwnership ограничивает курьеров назначенными им доставками, а клиентов - доставками их посылок
+func (h *DeliveryHandler) WithOwnership(ownership *Ownership) *DeliveryHandler {
+	h.ownership = ownership
+	return h
+}
+
+// authorize проверяет, что текущий пользователь может изменять доставку
+func (h *DeliveryHandler) authorize(w http.ResponseWriter, r *http.Request, id int) bool {
+	if !h.ownership.Scoped(r) {
+		return true
+	}
+
+	delivery, err := h.service.Get(id)
+	if err != nil {
+		writeError(w, "Delivery not found", http.StatusNotFound)
+		return false
+	}
+
+	if err := h.ownership.CheckDelivery(r, delivery); err != nil {
+		writeAccessError(w, err)
+		return false
+	}
+	return true
+}
+
+// CorrelationIDHeader - заголовок, которым клиент относит запрос к своей цепочке событий.
+// События, записанные при выполнении запроса, публикуются с этим CorrelationID
+const CorrelationIDHeader = "X-Correlation-ID"
+
+// auditInfo возвращает сведения для журнала изменений: текущего пользователя, комментарий
+// и цепочку событий запроса
+func auditInfo(r *http.Request, note string) models.AuditInfo {
+	return models.AuditInfo{
+		ActorID:       callerFromRequest(r).userID,
+		Note:          note,
+		CorrelationID: r.Header.Get(CorrelationIDHeader),
+	}
+}
+
+// writeDeliveryError пишет ответ для ошибки сервиса доставок: 409 для недопустимого перехода статуса
+// и конфликта назначения, 404 для отсутствующих доставки, посылки или курьера, 400 для неизвестного статуса,
+// иначе 500 с сообщением message
+func writeDeliveryError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, models.ErrInvalidStatusTransition),
+		errors.Is(err, models.ErrParcelAlreadyAssigned),
+		errors.Is(err, models.ErrCourierUnavailable):
+		writeError(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, models.ErrDeliveryNotFound), errors.Is(err, models.ErrParcelNotFound),
+		errors.Is(err, models.ErrCourierNotFound):
+		writeError(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, models.ErrUnknownDeliveryStatus):
+		writeError(w, err.Error(), http.StatusBadRequest)
+	default:
+		writeError(w, message, http.StatusInternalServerError)
+	}
+}
+
 func (h *DeliveryHandler) CreateDelivery(w http.ResponseWriter, r *http.Request) {
-	var delivery models.Delivery
-	if err := json.NewDecoder(r.Body).Decode(&delivery); err != nil {
+	var input deliveryInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
 		writeError(w, "Invalid data", http.StatusBadRequest)
 		return
 	}
 
-	if err := h.service.Create(&delivery); err != nil {
-		writeError(w, "Failed to create delivery", http.StatusInternalServerError)
+	delivery := input.Delivery
+	if err := h.service.Create(&delivery, auditInfo(r, input.Note)); err != nil {
+		writeDeliveryError(w, err, "Failed to create delivery")
 		return
 	}
 
@@ -65,6 +135,11 @@ func (h *DeliveryHandler) GetDelivery(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := h.ownership.CheckDelivery(r, delivery); err != nil {
+		writeAccessError(w, err)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(delivery)
 }
@@ -77,14 +152,18 @@ func (h *DeliveryHandler) UpdateDelivery(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	var delivery models.Delivery
-	if err := json.NewDecoder(r.Body).Decode(&delivery); err != nil {
+	if !h.authorize(w, r, id) {
+		return
+	}
+
+	var input deliveryInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
 		writeError(w, "Invalid data", http.StatusBadRequest)
 		return
 	}
 
-	if err := h.service.Update(id, &delivery); err != nil {
-		writeError(w, "Failed to update delivery", http.StatusInternalServerError)
+	if err := h.service.Update(id, &input.Delivery, auditInfo(r, input.Note)); err != nil {
+		writeDeliveryError(w, err, "Failed to update delivery")
 		return
 	}
 
@@ -99,8 +178,8 @@ func (h *DeliveryHandler) DeleteDelivery(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := h.service.Delete(id); err != nil {
-		writeError(w, "Failed to delete delivery", http.StatusInternalServerError)
+	if err := h.service.Delete(id, auditInfo(r, r.URL.Query().Get("note"))); err != nil {
+		writeDeliveryError(w, err, "Failed to delete delivery")
 		return
 	}
 
@@ -109,17 +188,18 @@ func (h *DeliveryHandler) DeleteDelivery(w http.ResponseWriter, r *http.Request)
 
 func (h *DeliveryHandler) AssignDelivery(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		CourierID int `json:"courier_id"`
-		ParcelID  int `json:"parcel_id"`
+		CourierID int    `json:"courier_id"`
+		ParcelID  int    `json:"parcel_id"`
+		Note      string `json:"note"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
 		writeError(w, "Invalid data", http.StatusBadRequest)
 		return
 	}
 
-	delivery, err := h.service.AssignDelivery(input.CourierID, input.ParcelID)
+	delivery, err := h.service.AssignDelivery(input.CourierID, input.ParcelID, auditInfo(r, input.Note))
 	if err != nil {
-		writeError(w, "Failed to assign delivery", http.StatusInternalServerError)
+		writeDeliveryError(w, err, "Failed to assign delivery")
 		return
 	}
 
@@ -135,8 +215,23 @@ func (h *DeliveryHandler) CompleteDelivery(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	if err := h.service.CompleteDelivery(deliveryID); err != nil {
-		writeError(w, "Failed to complete delivery", http.StatusInternalServerError)
+	if !h.authorize(w, r, deliveryID) {
+		return
+	}
+
+	// Тело запроса необязательно и может содержать только комментарий
+	var input struct {
+		Note string `json:"note"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil && !errors.Is(err, io.EOF) {
+			writeError(w, "Invalid data", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := h.service.CompleteDelivery(deliveryID, auditInfo(r, input.Note)); err != nil {
+		writeDeliveryError(w, err, "Failed to complete delivery")
 		return
 	}
 
@@ -151,6 +246,11 @@ func (h *DeliveryHandler) GetDeliveriesByCourier(w http.ResponseWriter, r *http.
 		return
 	}
 
+	if err := h.ownership.CheckCourier(r, courierID); err != nil {
+		writeAccessError(w, err)
+		return
+	}
+
 	deliveries, err := h.service.GetDeliveriesByCourier(courierID)
 	if err != nil {
 		writeError(w, "Failed to fetch deliveries", http.StatusInternalServerError)
@@ -160,3 +260,25 @@ func (h *DeliveryHandler) GetDeliveriesByCourier(w http.ResponseWriter, r *http.
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(deliveries)
 }
+
+// GetDeliveryHistory возвращает журнал изменений доставки: кто, когда и как менял ее статус
+func (h *DeliveryHandler) GetDeliveryHistory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		writeError(w, "Invalid delivery ID", http.StatusBadRequest)
+		return
+	}
+
+	if !h.authorize(w, r, id) {
+		return
+	}
+
+	events, err := h.service.History(id)
+	if err != nil {
+		writeError(w, "Failed to fetch delivery history", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, events)
+}
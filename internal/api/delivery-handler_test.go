@@ -0,0 +1,143 @@
+package api
+
+import (
+	"bytes"
+	"delivery/internal/business/models"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// statusDeliveryService возвращает заданную ошибку при изменении статуса и запоминает сведения для журнала
+type statusDeliveryService struct {
+	DeliveryService
+	err   error
+	audit models.AuditInfo
+}
+
+func (s *statusDeliveryService) Update(id int, delivery *models.Delivery, audit models.AuditInfo) error {
+	s.audit = audit
+	return s.err
+}
+
+func (s *statusDeliveryService) CompleteDelivery(deliveryID int, audit models.AuditInfo) error {
+	s.audit = audit
+	return s.err
+}
+
+func (s *statusDeliveryService) Delete(id int, audit models.AuditInfo) error {
+	s.audit = audit
+	return s.err
+}
+
+func (s *statusDeliveryService) History(deliveryID int) ([]models.DeliveryEvent, error) {
+	return []models.DeliveryEvent{{DeliveryID: deliveryID, NewStatus: "assigned"}}, s.err
+}
+
+func TestDeliveryHandler_StatusErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{"Успешно", nil, http.StatusOK},
+		{"Недопустимый переход", fmt.Errorf("%w: assigned → delivered", models.ErrInvalidStatusTransition), http.StatusConflict},
+		{"Неизвестный статус", fmt.Errorf("%w: \"lost\"", models.ErrUnknownDeliveryStatus), http.StatusBadRequest},
+		{"Доставка не найдена", fmt.Errorf("Ошибка при получении доставки: %w", models.ErrDeliveryNotFound), http.StatusNotFound},
+		{"Ошибка БД", fmt.Errorf("connection refused"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewDeliveryHandler(&statusDeliveryService{err: tt.err})
+
+			req := mux.SetURLVars(httptest.NewRequest("PUT", "/deliveries/1", bytes.NewBufferString(`{"status":"delivered"}`)), map[string]string{"id": "1"})
+			rr := httptest.NewRecorder()
+			handler.UpdateDelivery(rr, req)
+			if rr.Code != tt.wantStatus {
+				t.Errorf("UpdateDelivery() status = %d, want %d", rr.Code, tt.wantStatus)
+			}
+
+			req = mux.SetURLVars(httptest.NewRequest("PUT", "/deliveries/1/complete", nil), map[string]string{"id": "1"})
+			rr = httptest.NewRecorder()
+			handler.CompleteDelivery(rr, req)
+			if rr.Code != tt.wantStatus {
+				t.Errorf("CompleteDelivery() status = %d, want %d", rr.Code, tt.wantStatus)
+			}
+
+			wantDelete := tt.wantStatus
+			if tt.err == nil {
+				wantDelete = http.StatusNoContent
+			}
+			req = mux.SetURLVars(httptest.NewRequest("DELETE", "/deliveries/1", nil), map[string]string{"id": "1"})
+			rr = httptest.NewRecorder()
+			handler.DeleteDelivery(rr, req)
+			if rr.Code != wantDelete {
+				t.Errorf("DeleteDelivery() status = %d, want %d", rr.Code, wantDelete)
+			}
+		})
+	}
+}
+
+func TestDeliveryHandler_AuditInfo(t *testing.T) {
+	service := &statusDeliveryService{}
+	handler := NewDeliveryHandler(service)
+
+	body := bytes.NewBufferString(`{"status":"picked_up","note":"Забрал со склада"}`)
+	req := mux.SetURLVars(withUser(httptest.NewRequest("PUT", "/deliveries/1", body), 3, models.UserRoleCourier), map[string]string{"id": "1"})
+	handler.UpdateDelivery(httptest.NewRecorder(), req)
+
+	if service.audit != (models.AuditInfo{ActorID: 3, Note: "Забрал со склада"}) {
+		t.Errorf("UpdateDelivery() audit = %+v", service.audit)
+	}
+
+	// Тело запроса на завершение необязательно
+	req = mux.SetURLVars(withUser(httptest.NewRequest("PUT", "/deliveries/1/complete", nil), 3, models.UserRoleCourier), map[string]string{"id": "1"})
+	rr := httptest.NewRecorder()
+	handler.CompleteDelivery(rr, req)
+
+	if rr.Code != http.StatusOK || service.audit != (models.AuditInfo{ActorID: 3}) {
+		t.Errorf("CompleteDelivery() status = %d, audit = %+v", rr.Code, service.audit)
+	}
+}
+
+func TestDeliveryHandler_History(t *testing.T) {
+	ownership, _, deliveries := newTestOwnership()
+	handler := NewDeliveryHandler(&historyDeliveryService{fakeDeliveryService: deliveries}).WithOwnership(ownership)
+
+	tests := []struct {
+		name       string
+		userID     int
+		role       string
+		wantStatus int
+	}{
+		{"Владелец посылки", 1, models.UserRoleClient, http.StatusOK},
+		{"Другой клиент", 2, models.UserRoleClient, http.StatusForbidden},
+		{"Диспетчер", 5, models.UserRoleDispatcher, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/deliveries/1000/history", nil)
+			req = mux.SetURLVars(withUser(req, tt.userID, tt.role), map[string]string{"id": "1000"})
+			rr := httptest.NewRecorder()
+			handler.GetDeliveryHistory(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("GetDeliveryHistory() status = %d, want %d", rr.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+// historyDeliveryService добавляет журнал к тестовым доставкам
+type historyDeliveryService struct {
+	*fakeDeliveryService
+}
+
+func (s *historyDeliveryService) History(deliveryID int) ([]models.DeliveryEvent, error) {
+	return []models.DeliveryEvent{{DeliveryID: deliveryID, NewStatus: "assigned"}}, nil
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"delivery/internal/events"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// EventSchemaHandler - описание доменных событий, которые сервис публикует в Kafka,
+// для разработчиков получателей. Доступно без аутентификации
+type EventSchemaHandler struct {
+	registry *events.Registry
+}
+
+func NewEventSchemaHandler(registry *events.Registry) *EventSchemaHandler {
+	return &EventSchemaHandler{registry: registry}
+}
+
+// eventSchemaInfo - версия события в списке
+type eventSchemaInfo struct {
+	Type        string `json:"type"`
+	Version     int    `json:"version"`
+	Topic       string `json:"topic"`
+	Description string `json:"description,omitempty"`
+	Current     bool   `json:"current"` // Версия, в которой событие публикуется сейчас
+}
+
+// ListSchemas возвращает все версии всех событий
+func (h *EventSchemaHandler) ListSchemas(w http.ResponseWriter, r *http.Request) {
+	schemas := h.registry.Schemas()
+	response := make([]eventSchemaInfo, len(schemas))
+	for i, s := range schemas {
+		current, _ := h.registry.Current(s.Type)
+		response[i] = eventSchemaInfo{
+			Type:        s.Type,
+			Version:     s.Version,
+			Topic:       s.Topic,
+			Description: s.Description,
+			Current:     s.Version == current.Version,
+		}
+	}
+	writeJSON(w, response)
+}
+
+// GetSchema возвращает JSON Schema сообщения с событием: текущей версии или версии из параметра version
+func (h *EventSchemaHandler) GetSchema(w http.ResponseWriter, r *http.Request) {
+	eventType := mux.Vars(r)["type"]
+
+	current, ok := h.registry.Current(eventType)
+	if !ok {
+		writeError(w, "Event type not found", http.StatusNotFound)
+		return
+	}
+	version := current.Version
+	if value := r.URL.Query().Get("version"); value != "" {
+		v, err := strconv.Atoi(value)
+		if err != nil || v <= 0 {
+			writeError(w, "Invalid version", http.StatusBadRequest)
+			return
+		}
+		version = v
+	}
+
+	schema, err := h.registry.JSONSchema(eventType, version)
+	if err != nil {
+		writeError(w, "Event version not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/schema+json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(schema); err != nil {
+		log.Printf("Ошибка при кодировании схемы события: %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"delivery/internal/business/models"
+	"delivery/internal/events"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+type schemaPayloadV1 struct {
+	Status string `json:"status"`
+}
+
+type schemaPayloadV2 struct {
+	NewStatus string `json:"new_status"`
+}
+
+func TestEventSchemaHandler(t *testing.T) {
+	registry := events.NewRegistry().
+		Register(events.Schema{Type: models.EventDeliveryStatusChanged, Version: 1, Topic: models.TopicDeliveries, Payload: schemaPayloadV1{}}).
+		Register(events.Schema{Type: models.EventDeliveryStatusChanged, Version: 2, Topic: models.TopicDeliveries, Payload: schemaPayloadV2{}})
+	r := mux.NewRouter()
+	handler := NewEventSchemaHandler(registry)
+	r.HandleFunc("/events/schemas", handler.ListSchemas)
+	r.HandleFunc("/events/schemas/{type}", handler.GetSchema)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	rec := get("/events/schemas")
+	var list []eventSchemaInfo
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if len(list) != 2 || list[0].Current || !list[1].Current || list[1].Topic != models.TopicDeliveries {
+		t.Errorf("список = %+v", list)
+	}
+
+	tests := []struct {
+		path    string
+		status  int
+		payload string // Обязательное поле данных события в схеме
+	}{
+		{path: "/events/schemas/DeliveryStatusChanged", status: http.StatusOK, payload: "new_status"},
+		{path: "/events/schemas/DeliveryStatusChanged?version=1", status: http.StatusOK, payload: "status"},
+		{path: "/events/schemas/DeliveryStatusChanged?version=3", status: http.StatusNotFound},
+		{path: "/events/schemas/DeliveryStatusChanged?version=x", status: http.StatusBadRequest},
+		{path: "/events/schemas/ParcelLost", status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rec := get(tt.path)
+		if rec.Code != tt.status {
+			t.Errorf("%s: статус = %d, want %d", tt.path, rec.Code, tt.status)
+			continue
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+
+		var schema struct {
+			Properties struct {
+				Payload struct {
+					Required []string `json:"required"`
+				} `json:"payload"`
+			} `json:"properties"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&schema); err != nil {
+			t.Fatalf("%s: Decode() error = %v", tt.path, err)
+		}
+		if required := schema.Properties.Payload.Required; len(required) != 1 || required[0] != tt.payload {
+			t.Errorf("%s: обязательные поля данных = %v, want [%s]", tt.path, required, tt.payload)
+		}
+	}
+}
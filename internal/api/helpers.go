@@ -1,7 +1,18 @@
 package api
 
-import "net/http"
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
 
 func writeError(w http.ResponseWriter, message string, statusCode int) {
 	http.Error(w, message, statusCode)
 }
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Ошибка при кодировании ответа: %v", err)
+	}
+}
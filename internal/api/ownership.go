@@ -0,0 +1,181 @@
+package api
+
+import (
+	"delivery/internal/business/models"
+	"delivery/internal/middleware"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// ErrAccessDenied - запись не принадлежит текущему пользователю
+var ErrAccessDenied = errors.New("доступ к записи запрещен")
+
+// Ownership проверяет, что клиент или курьер работает только со своими записями.
+// Диспетчеры и администраторы имеют доступ ко всем записям.
+//
+// Учетная запись пользователя связана с профилем клиента или курьера через колонку user_id.
+// Nil-значение Ownership не ограничивает доступ.
+type Ownership struct {
+	customers  CustomerService
+	couriers   CourierService
+	parcels    ParcelService
+	deliveries DeliveryService
+}
+
+func NewOwnership(customers CustomerService, couriers CourierService, parcels ParcelService, deliveries DeliveryService) *Ownership {
+	return &Ownership{
+		customers:  customers,
+		couriers:   couriers,
+		parcels:    parcels,
+		deliveries: deliveries,
+	}
+}
+
+// caller - пользователь, выполняющий запрос
+type caller struct {
+	userID int
+	role   string
+}
+
+func callerFromRequest(r *http.Request) caller {
+	userID, _ := r.Context().Value(middleware.UserIDKey).(int)
+	role, _ := r.Context().Value(middleware.UserRoleKey).(string)
+	return caller{userID: userID, role: role}
+}
+
+// hasGlobalAccess сообщает, видит ли пользователь все записи
+func (c caller) hasGlobalAccess() bool {
+	return c.role == models.UserRoleDispatcher || c.role == models.UserRoleAdmin
+}
+
+// Scoped сообщает, нужно ли ограничивать запрос записями текущего пользователя
+func (o *Ownership) Scoped(r *http.Request) bool {
+	return o != nil && !callerFromRequest(r).hasGlobalAccess()
+}
+
+// CustomerID возвращает ID профиля клиента текущего пользователя
+func (o *Ownership) CustomerID(r *http.Request) (int, error) {
+	c := callerFromRequest(r)
+	if c.role != models.UserRoleClient {
+		return 0, ErrAccessDenied
+	}
+
+	customer, err := o.customers.GetByUserID(c.userID)
+	if err != nil {
+		log.Printf("Профиль клиента для пользователя %d не найден: %v", c.userID, err)
+		return 0, ErrAccessDenied
+	}
+	return customer.ID, nil
+}
+
+// CourierID возвращает ID профиля курьера текущего пользователя
+func (o *Ownership) CourierID(r *http.Request) (int, error) {
+	c := callerFromRequest(r)
+	if c.role != models.UserRoleCourier {
+		return 0, ErrAccessDenied
+	}
+
+	courier, err := o.couriers.GetByUserID(c.userID)
+	if err != nil {
+		log.Printf("Профиль курьера для пользователя %d не найден: %v", c.userID, err)
+		return 0, ErrAccessDenied
+	}
+	return courier.ID, nil
+}
+
+// CheckCustomer проверяет доступ к профилю клиента
+func (o *Ownership) CheckCustomer(r *http.Request, customerID int) error {
+	if !o.Scoped(r) {
+		return nil
+	}
+
+	ownID, err := o.CustomerID(r)
+	if err != nil {
+		return err
+	}
+	if ownID != customerID {
+		return ErrAccessDenied
+	}
+	return nil
+}
+
+// CheckCourier проверяет доступ к профилю курьера
+func (o *Ownership) CheckCourier(r *http.Request, courierID int) error {
+	if !o.Scoped(r) {
+		return nil
+	}
+
+	ownID, err := o.CourierID(r)
+	if err != nil {
+		return err
+	}
+	if ownID != courierID {
+		return ErrAccessDenied
+	}
+	return nil
+}
+
+// CheckParcel проверяет доступ к посылке: клиенту - свои посылки,
+// курьеру - посылки из назначенных ему доставок
+func (o *Ownership) CheckParcel(r *http.Request, parcel *models.Parcel) error {
+	if !o.Scoped(r) {
+		return nil
+	}
+
+	switch callerFromRequest(r).role {
+	case models.UserRoleClient:
+		return o.CheckCustomer(r, parcel.ClientID)
+	case models.UserRoleCourier:
+		delivery, err := o.deliveries.GetByParcelID(parcel.ID)
+		if err != nil {
+			return ErrAccessDenied
+		}
+		return o.CheckCourier(r, delivery.CourierID)
+	}
+	return ErrAccessDenied
+}
+
+// CheckParcelID проверяет доступ к посылке по ее ID
+func (o *Ownership) CheckParcelID(r *http.Request, parcelID int) error {
+	if !o.Scoped(r) {
+		return nil
+	}
+
+	parcel, err := o.parcels.Get(parcelID)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrAccessDenied, err)
+	}
+	return o.CheckParcel(r, parcel)
+}
+
+// CheckDelivery проверяет доступ к доставке: курьеру - назначенные ему доставки,
+// клиенту - доставки его посылок
+func (o *Ownership) CheckDelivery(r *http.Request, delivery *models.Delivery) error {
+	if !o.Scoped(r) {
+		return nil
+	}
+
+	switch callerFromRequest(r).role {
+	case models.UserRoleCourier:
+		return o.CheckCourier(r, delivery.CourierID)
+	case models.UserRoleClient:
+		parcel, err := o.parcels.Get(delivery.ParcelID)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrAccessDenied, err)
+		}
+		return o.CheckCustomer(r, parcel.ClientID)
+	}
+	return ErrAccessDenied
+}
+
+// writeAccessError пишет ответ для ошибки проверки владельца
+func writeAccessError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrAccessDenied) {
+		writeError(w, "Access denied", http.StatusForbidden)
+		return
+	}
+	log.Printf("Ошибка при проверке владельца записи: %v", err)
+	writeError(w, "Failed to check access", http.StatusInternalServerError)
+}
@@ -0,0 +1,273 @@
+package api
+
+import (
+	"context"
+	"delivery/internal/business/models"
+	"delivery/internal/middleware"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// Встраивание интерфейсов позволяет переопределить только нужные в тесте методы
+
+type fakeCustomerService struct {
+	CustomerService
+	byUser map[int]models.Customer
+}
+
+func (f *fakeCustomerService) GetByUserID(userID int) (*models.Customer, error) {
+	c, ok := f.byUser[userID]
+	if !ok {
+		return nil, errors.New("customer not found")
+	}
+	return &c, nil
+}
+
+type fakeCourierService struct {
+	CourierService
+	byUser map[int]models.Courier
+}
+
+func (f *fakeCourierService) GetByUserID(userID int) (*models.Courier, error) {
+	c, ok := f.byUser[userID]
+	if !ok {
+		return nil, errors.New("courier not found")
+	}
+	return &c, nil
+}
+
+type fakeParcelService struct {
+	ParcelService
+	parcels map[int]models.Parcel
+}
+
+func (f *fakeParcelService) Get(id int) (*models.Parcel, error) {
+	p, ok := f.parcels[id]
+	if !ok {
+		return nil, errors.New("parcel not found")
+	}
+	return &p, nil
+}
+
+func (f *fakeParcelService) List(clientID int) ([]models.Parcel, error) {
+	var result []models.Parcel
+	for _, p := range f.parcels {
+		if p.ClientID == clientID {
+			result = append(result, p)
+		}
+	}
+	return result, nil
+}
+
+type fakeDeliveryService struct {
+	DeliveryService
+	deliveries map[int]models.Delivery
+}
+
+func (f *fakeDeliveryService) Get(id int) (*models.Delivery, error) {
+	d, ok := f.deliveries[id]
+	if !ok {
+		return nil, errors.New("delivery not found")
+	}
+	return &d, nil
+}
+
+func (f *fakeDeliveryService) GetByParcelID(parcelID int) (*models.Delivery, error) {
+	for _, d := range f.deliveries {
+		if d.ParcelID == parcelID {
+			return &d, nil
+		}
+	}
+	return nil, errors.New("delivery not found")
+}
+
+func (f *fakeDeliveryService) GetDeliveriesByCourier(courierID int) ([]models.Delivery, error) {
+	var result []models.Delivery
+	for _, d := range f.deliveries {
+		if d.CourierID == courierID {
+			result = append(result, d)
+		}
+	}
+	return result, nil
+}
+
+// Пользователи: 1 - клиент (customer 10), 2 - клиент (customer 20),
+// 3 - курьер (courier 30), 4 - курьер (courier 40), 5 - диспетчер
+func newTestOwnership() (*Ownership, *fakeParcelService, *fakeDeliveryService) {
+	parcels := &fakeParcelService{parcels: map[int]models.Parcel{
+		100: {ID: 100, ClientID: 10},
+		200: {ID: 200, ClientID: 20},
+	}}
+	deliveries := &fakeDeliveryService{deliveries: map[int]models.Delivery{
+		1000: {ID: 1000, ParcelID: 100, CourierID: 30},
+	}}
+	ownership := NewOwnership(
+		&fakeCustomerService{byUser: map[int]models.Customer{1: {ID: 10}, 2: {ID: 20}}},
+		&fakeCourierService{byUser: map[int]models.Courier{3: {ID: 30}, 4: {ID: 40}}},
+		parcels,
+		deliveries,
+	)
+	return ownership, parcels, deliveries
+}
+
+func withUser(req *http.Request, userID int, role string) *http.Request {
+	ctx := context.WithValue(req.Context(), middleware.UserIDKey, userID)
+	ctx = context.WithValue(ctx, middleware.UserRoleKey, role)
+	return req.WithContext(ctx)
+}
+
+func TestParcelHandler_Ownership(t *testing.T) {
+	ownership, parcels, _ := newTestOwnership()
+	handler := NewParcelHandler(parcels).WithOwnership(ownership)
+
+	tests := []struct {
+		name       string
+		parcelID   string
+		userID     int
+		role       string
+		wantStatus int
+	}{
+		{"Клиент видит свою посылку", "100", 1, models.UserRoleClient, http.StatusOK},
+		{"Клиент не видит чужую посылку", "200", 1, models.UserRoleClient, http.StatusForbidden},
+		{"Курьер видит посылку из своей доставки", "100", 3, models.UserRoleCourier, http.StatusOK},
+		{"Курьер не видит чужую посылку", "100", 4, models.UserRoleCourier, http.StatusForbidden},
+		{"Диспетчер видит любую посылку", "200", 5, models.UserRoleDispatcher, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/parcels/"+tt.parcelID, nil)
+			req = mux.SetURLVars(withUser(req, tt.userID, tt.role), map[string]string{"id": tt.parcelID})
+			rr := httptest.NewRecorder()
+			handler.GetParcel(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("GetParcel() status = %d, want %d", rr.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestParcelHandler_ListOwnership(t *testing.T) {
+	ownership, parcels, _ := newTestOwnership()
+	handler := NewParcelHandler(parcels).WithOwnership(ownership)
+
+	tests := []struct {
+		name       string
+		query      string
+		userID     int
+		role       string
+		wantStatus int
+	}{
+		{"Клиент без client_id получает свои посылки", "", 1, models.UserRoleClient, http.StatusOK},
+		{"Клиент со своим client_id", "?client_id=10", 1, models.UserRoleClient, http.StatusOK},
+		{"Клиент с чужим client_id", "?client_id=20", 1, models.UserRoleClient, http.StatusForbidden},
+		{"Диспетчер с любым client_id", "?client_id=20", 5, models.UserRoleDispatcher, http.StatusOK},
+		{"Диспетчер без client_id", "", 5, models.UserRoleDispatcher, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := withUser(httptest.NewRequest("GET", "/parcels"+tt.query, nil), tt.userID, tt.role)
+			rr := httptest.NewRecorder()
+			handler.ListParcels(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("ListParcels() status = %d, want %d", rr.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestDeliveryHandler_Ownership(t *testing.T) {
+	ownership, _, deliveries := newTestOwnership()
+	handler := NewDeliveryHandler(deliveries).WithOwnership(ownership)
+
+	t.Run("Доставка", func(t *testing.T) {
+		tests := []struct {
+			name       string
+			userID     int
+			role       string
+			wantStatus int
+		}{
+			{"Назначенный курьер", 3, models.UserRoleCourier, http.StatusOK},
+			{"Другой курьер", 4, models.UserRoleCourier, http.StatusForbidden},
+			{"Владелец посылки", 1, models.UserRoleClient, http.StatusOK},
+			{"Другой клиент", 2, models.UserRoleClient, http.StatusForbidden},
+			{"Администратор", 5, models.UserRoleAdmin, http.StatusOK},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				req := httptest.NewRequest("GET", "/deliveries/1000", nil)
+				req = mux.SetURLVars(withUser(req, tt.userID, tt.role), map[string]string{"id": "1000"})
+				rr := httptest.NewRecorder()
+				handler.GetDelivery(rr, req)
+
+				if rr.Code != tt.wantStatus {
+					t.Errorf("GetDelivery() status = %d, want %d", rr.Code, tt.wantStatus)
+				}
+			})
+		}
+	})
+
+	t.Run("Доставки курьера", func(t *testing.T) {
+		tests := []struct {
+			name       string
+			courierID  string
+			userID     int
+			wantStatus int
+		}{
+			{"Свои доставки", "30", 3, http.StatusOK},
+			{"Чужие доставки", "30", 4, http.StatusForbidden},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				req := httptest.NewRequest("GET", "/deliveries/courier/"+tt.courierID, nil)
+				req = mux.SetURLVars(withUser(req, tt.userID, models.UserRoleCourier), map[string]string{"id": tt.courierID})
+				rr := httptest.NewRecorder()
+				handler.GetDeliveriesByCourier(rr, req)
+
+				if rr.Code != tt.wantStatus {
+					t.Errorf("GetDeliveriesByCourier() status = %d, want %d", rr.Code, tt.wantStatus)
+				}
+			})
+		}
+	})
+}
+
+func TestOwnership_CheckParcelID(t *testing.T) {
+	ownership, _, _ := newTestOwnership()
+
+	tests := []struct {
+		name     string
+		parcelID int
+		userID   int
+		role     string
+		wantErr  bool
+	}{
+		{"Своя посылка", 100, 1, models.UserRoleClient, false},
+		{"Чужая посылка", 200, 1, models.UserRoleClient, true},
+		{"Неизвестная посылка", 300, 1, models.UserRoleClient, true},
+		{"Посылка из доставки курьера", 100, 3, models.UserRoleCourier, false},
+		{"Диспетчер", 300, 5, models.UserRoleDispatcher, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := withUser(httptest.NewRequest("GET", "/", nil), tt.userID, tt.role)
+			err := ownership.CheckParcelID(req, tt.parcelID)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckParcelID() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrAccessDenied) {
+				t.Errorf("CheckParcelID() error = %v, want ErrAccessDenied", err)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package api
 import (
 	"delivery/internal/business/models"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -13,20 +14,56 @@ type ParcelService interface {
 	Register(parcel *models.Parcel) error
 	Get(id int) (*models.Parcel, error)
 	Update(id int, parcel *models.Parcel) error
-	UpdateStatus(id int, status string) error
-	UpdateAddress(id int, address string) error
+	UpdateAddress(id int, address string, destination *models.Address) error
 	Delete(id int) error
 	List(clientID int) ([]models.Parcel, error)
+	GetByTrackingNumber(trackingNumber string) (*models.Parcel, error)
 }
 
 type ParcelHandler struct {
-	service ParcelService
+	service   ParcelService
+	ownership *Ownership
 }
 
 func NewParcelHandler(service ParcelService) *ParcelHandler {
 	return &ParcelHandler{service: service}
 }
 
+// WithOwnership ограничивает клиентов и курьеров их собственными посылками
+func (h *ParcelHandler) WithOwnership(ownership *Ownership) *ParcelHandler {
+	h.ownership = ownership
+	return h
+}
+
+// authorize проверяет, что текущий пользователь может изменять посылку.
+// Возвращает текущее состояние посылки, если проверка выполнялась
+func (h *ParcelHandler) authorize(w http.ResponseWriter, r *http.Request, id int) (*models.Parcel, bool) {
+	if !h.ownership.Scoped(r) {
+		return nil, true
+	}
+
+	parcel, err := h.service.Get(id)
+	if err != nil {
+		writeError(w, "Parcel not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	if err := h.ownership.CheckParcel(r, parcel); err != nil {
+		writeAccessError(w, err)
+		return nil, false
+	}
+	return parcel, true
+}
+
+// writeParcelError отвечает 422, если адрес не удалось геокодировать, и 500 для остальных ошибок
+func writeParcelError(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, models.ErrAddressNotFound) {
+		writeError(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	writeError(w, message, http.StatusInternalServerError)
+}
+
 func (h *ParcelHandler) CreateParcel(w http.ResponseWriter, r *http.Request) {
 	var parcel models.Parcel
 	if err := json.NewDecoder(r.Body).Decode(&parcel); err != nil {
@@ -34,8 +71,18 @@ func (h *ParcelHandler) CreateParcel(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Клиент регистрирует посылки только от своего имени
+	if h.ownership.Scoped(r) {
+		clientID, err := h.ownership.CustomerID(r)
+		if err != nil {
+			writeAccessError(w, err)
+			return
+		}
+		parcel.ClientID = clientID
+	}
+
 	if err := h.service.Register(&parcel); err != nil {
-		writeError(w, "Failed to register parcel", http.StatusInternalServerError)
+		writeParcelError(w, err, "Failed to register parcel")
 		return
 	}
 
@@ -64,6 +111,11 @@ func (h *ParcelHandler) GetParcel(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := h.ownership.CheckParcel(r, parcel); err != nil {
+		writeAccessError(w, err)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(parcel)
 }
@@ -76,21 +128,31 @@ func (h *ParcelHandler) UpdateParcel(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	current, ok := h.authorize(w, r, id)
+	if !ok {
+		return
+	}
+
 	var parcel models.Parcel
 	if err := json.NewDecoder(r.Body).Decode(&parcel); err != nil {
 		writeError(w, "Invalid data", http.StatusBadRequest)
 		return
 	}
 
+	// Клиент не может передать свою посылку другому клиенту
+	if current != nil {
+		parcel.ClientID = current.ClientID
+	}
+
 	if err := h.service.Update(id, &parcel); err != nil {
-		writeError(w, "Failed to update parcel", http.StatusInternalServerError)
+		writeParcelError(w, err, "Failed to update parcel")
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
-func (h *ParcelHandler) UpdateParcelStatus(w http.ResponseWriter, r *http.Request) {
+func (h *ParcelHandler) UpdateParcelAddress(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
@@ -98,40 +160,22 @@ func (h *ParcelHandler) UpdateParcelStatus(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	var status struct {
-		Status string `json:"status"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&status); err != nil {
-		writeError(w, "Invalid data", http.StatusBadRequest)
-		return
-	}
-
-	if err := h.service.UpdateStatus(id, status.Status); err != nil {
-		writeError(w, "Failed to update status", http.StatusInternalServerError)
-		return
-	}
-
-	w.WriteHeader(http.StatusOK)
-}
-
-func (h *ParcelHandler) UpdateParcelAddress(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id, err := strconv.Atoi(vars["id"])
-	if err != nil {
-		writeError(w, "Invalid parcel ID", http.StatusBadRequest)
+	if _, ok := h.authorize(w, r, id); !ok {
 		return
 	}
 
+	// Адрес передается строкой или структурой: улица, город, индекс, страна
 	var address struct {
-		Address string `json:"address"`
+		Address     string          `json:"address"`
+		Destination *models.Address `json:"destination"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&address); err != nil {
 		writeError(w, "Invalid data", http.StatusBadRequest)
 		return
 	}
 
-	if err := h.service.UpdateAddress(id, address.Address); err != nil {
-		writeError(w, "Failed to update address", http.StatusInternalServerError)
+	if err := h.service.UpdateAddress(id, address.Address, address.Destination); err != nil {
+		writeParcelError(w, err, "Failed to update address")
 		return
 	}
 
@@ -146,6 +190,10 @@ func (h *ParcelHandler) DeleteParcel(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if _, ok := h.authorize(w, r, id); !ok {
+		return
+	}
+
 	if err := h.service.Delete(id); err != nil {
 		writeError(w, "Failed to delete parcel", http.StatusInternalServerError)
 		return
@@ -155,10 +203,32 @@ func (h *ParcelHandler) DeleteParcel(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *ParcelHandler) ListParcels(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	clientID, err := strconv.Atoi(vars["clientId"])
-	if err != nil {
-		writeError(w, "Invalid client ID", http.StatusBadRequest)
+	var clientID int
+	if clientIDStr := r.URL.Query().Get("client_id"); clientIDStr != "" {
+		id, err := strconv.Atoi(clientIDStr)
+		if err != nil {
+			writeError(w, "Invalid client ID", http.StatusBadRequest)
+			return
+		}
+		clientID = id
+	}
+
+	// Клиент видит только свои посылки; client_id по умолчанию - его собственный
+	if h.ownership.Scoped(r) {
+		ownID, err := h.ownership.CustomerID(r)
+		if err != nil {
+			writeAccessError(w, err)
+			return
+		}
+		if clientID != 0 && clientID != ownID {
+			writeAccessError(w, ErrAccessDenied)
+			return
+		}
+		clientID = ownID
+	}
+
+	if clientID == 0 {
+		writeError(w, "Client ID is required", http.StatusBadRequest)
 		return
 	}
 
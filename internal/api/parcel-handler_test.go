@@ -0,0 +1,58 @@
+package api
+
+import (
+	"bytes"
+	"delivery/internal/business/models"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// addressParcelService запоминает переданный адрес и не находит адреса вне Москвы
+type addressParcelService struct {
+	ParcelService
+	address     string
+	destination *models.Address
+}
+
+func (s *addressParcelService) UpdateAddress(id int, address string, destination *models.Address) error {
+	s.address, s.destination = address, destination
+	if destination != nil && destination.City != "Москва" {
+		return fmt.Errorf("%w: %s", models.ErrAddressNotFound, destination.City)
+	}
+	return nil
+}
+
+func TestParcelHandler_UpdateParcelAddress(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{"Адрес строкой", `{"address":"Москва, ул. Ленина, 1"}`, http.StatusOK},
+		{"Структурированный адрес", `{"destination":{"street":"ул. Ленина, 1","city":"Москва"}}`, http.StatusOK},
+		{"Адрес не найден", `{"destination":{"street":"ул. Баумана, 1","city":"Казань"}}`, http.StatusUnprocessableEntity},
+		{"Некорректный JSON", `{`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &addressParcelService{}
+			handler := NewParcelHandler(service)
+
+			req := mux.SetURLVars(httptest.NewRequest("PUT", "/parcels/1/address", bytes.NewBufferString(tt.body)), map[string]string{"id": "1"})
+			rr := httptest.NewRecorder()
+			handler.UpdateParcelAddress(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("UpdateParcelAddress() status = %d, want %d", rr.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK && service.address == "" && service.destination == nil {
+				t.Error("UpdateParcelAddress() не передал адрес сервису")
+			}
+		})
+	}
+}
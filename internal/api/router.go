@@ -3,10 +3,11 @@ package api
 import (
 	"context"
 	"delivery/internal/auth"
+	"delivery/internal/business/models"
 	"delivery/internal/cache"
+	"delivery/internal/events"
 	"delivery/internal/middleware"
 
-	"encoding/json"
 	"log"
 	"net/http"
 
@@ -14,12 +15,24 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// PaymentHandler - обработчики платежей. Владельца платежа обработчики проверяют сами через Ownership
+type PaymentHandler interface {
+	CreatePayment(w http.ResponseWriter, r *http.Request)
+	GetPayment(w http.ResponseWriter, r *http.Request)
+	CancelPayment(w http.ResponseWriter, r *http.Request)
+	RefundPayment(w http.ResponseWriter, r *http.Request)
+}
+
 // Маршрутизатор с зарегистрированными маршрутами
 func NewRouter(
 	parcelHandler *ParcelHandler,
 	customerHandler *CustomerHandler,
 	deliveryHandler *DeliveryHandler,
 	courierHandler *CourierHandler,
+	authHandler *AuthHandler,
+	trackingHandler *TrackingHandler,
+	zoneHandler *ZoneHandler,
+	paymentHandler PaymentHandler,
 	authService *auth.AuthService,
 	redisClient *cache.RedisClient,
 	wsManager *WebSocketManager,
@@ -46,67 +59,101 @@ func NewRouter(
 	r.Use(authMiddleware.Middleware())
 	r.Use(rateLimiter.Middleware())
 
+	// Регистрирация маршрутов для аутентификации
+	r.HandleFunc("/auth/register", authHandler.Register).Methods("POST")
+	r.HandleFunc("/auth/login", authHandler.Login).Methods("POST")
+	r.HandleFunc("/auth/refresh", authHandler.Refresh).Methods("POST")
+	r.HandleFunc("/auth/logout", authHandler.Logout).Methods("POST")
+
+	// Публичное отслеживание посылки: доступно без токена, ограничивается общим Rate Limiting
+	r.HandleFunc("/track/{trackingNumber}", trackingHandler.Track).Methods("GET")
+
+	// Роли, которым разрешен доступ к маршруту. Администратор имеет доступ ко всем маршрутам,
+	// поэтому отдельно указывается только там, где доступ есть исключительно у него
+	client, courier, dispatcher, admin := models.UserRoleClient, models.UserRoleCourier,
+		models.UserRoleDispatcher, models.UserRoleAdmin
+
 	// Регистрирация маршрутов для посылок
-	r.HandleFunc("/parcels", parcelHandler.CreateParcel).Methods("POST")
-	r.HandleFunc("/parcels", parcelHandler.ListParcels).Methods("GET")
-	r.HandleFunc("/parcels/{id}", parcelHandler.GetParcel).Methods("GET")
-	r.HandleFunc("/parcels/{id}", parcelHandler.UpdateParcel).Methods("PUT")
-	r.HandleFunc("/parcels/{id}/status", parcelHandler.UpdateParcelStatus).Methods("PUT")
-	r.HandleFunc("/parcels/{id}/address", parcelHandler.UpdateParcelAddress).Methods("PUT")
-	r.HandleFunc("/parcels/{id}", parcelHandler.DeleteParcel).Methods("DELETE")
+	r.Handle("/parcels", withRoles(parcelHandler.CreateParcel, client, dispatcher)).Methods("POST")
+	r.Handle("/parcels", withRoles(parcelHandler.ListParcels, client, dispatcher)).Methods("GET")
+	r.Handle("/parcels/{id}", withRoles(parcelHandler.GetParcel, client, courier, dispatcher)).Methods("GET")
+	r.Handle("/parcels/{id}", withRoles(parcelHandler.UpdateParcel, client, dispatcher)).Methods("PUT")
+	r.Handle("/parcels/{id}/address", withRoles(parcelHandler.UpdateParcelAddress, client, dispatcher)).Methods("PUT")
+	r.Handle("/parcels/{id}", withRoles(parcelHandler.DeleteParcel, dispatcher)).Methods("DELETE")
 
 	// Регистрирация маршрутов для клиентов
-	r.HandleFunc("/customers", customerHandler.CreateCustomer).Methods("POST")
-	r.HandleFunc("/customers", customerHandler.ListCustomers).Methods("GET")
-	r.HandleFunc("/customers/{id}", customerHandler.GetCustomer).Methods("GET")
-	r.HandleFunc("/customers/{id}", customerHandler.UpdateCustomer).Methods("PUT")
-	r.HandleFunc("/customers/{id}", customerHandler.DeleteCustomer).Methods("DELETE")
+	r.Handle("/customers", withRoles(customerHandler.CreateCustomer, client, dispatcher)).Methods("POST")
+	r.Handle("/customers", withRoles(customerHandler.ListCustomers, dispatcher)).Methods("GET")
+	r.Handle("/customers/{id}", withRoles(customerHandler.GetCustomer, client, dispatcher)).Methods("GET")
+	r.Handle("/customers/{id}", withRoles(customerHandler.UpdateCustomer, client, dispatcher)).Methods("PUT")
+	r.Handle("/customers/{id}", withRoles(customerHandler.DeleteCustomer, admin)).Methods("DELETE")
 
 	// Регистрирация маршрутов для доставок
-	r.HandleFunc("/deliveries", deliveryHandler.CreateDelivery).Methods("POST")
-	r.HandleFunc("/deliveries/assign", deliveryHandler.AssignDelivery).Methods("POST")
-	r.HandleFunc("/deliveries/courier/{id}", deliveryHandler.GetDeliveriesByCourier).Methods("GET")
-	r.HandleFunc("/deliveries/{id}", deliveryHandler.GetDelivery).Methods("GET")
-	r.HandleFunc("/deliveries/{id}", deliveryHandler.UpdateDelivery).Methods("PUT")
-	r.HandleFunc("/deliveries/{id}/complete", deliveryHandler.CompleteDelivery).Methods("PUT")
-	r.HandleFunc("/deliveries/{id}", deliveryHandler.DeleteDelivery).Methods("DELETE")
+	r.Handle("/deliveries", withRoles(deliveryHandler.CreateDelivery, dispatcher)).Methods("POST")
+	r.Handle("/deliveries/assign", withRoles(deliveryHandler.AssignDelivery, dispatcher)).Methods("POST")
+	r.Handle("/deliveries/courier/{id}", withRoles(deliveryHandler.GetDeliveriesByCourier, courier, dispatcher)).Methods("GET")
+	r.Handle("/deliveries/{id}", withRoles(deliveryHandler.GetDelivery, client, courier, dispatcher)).Methods("GET")
+	r.Handle("/deliveries/{id}", withRoles(deliveryHandler.UpdateDelivery, courier, dispatcher)).Methods("PUT")
+	r.Handle("/deliveries/{id}/complete", withRoles(deliveryHandler.CompleteDelivery, courier)).Methods("PUT")
+	r.Handle("/deliveries/{id}/history", withRoles(deliveryHandler.GetDeliveryHistory, client, courier, dispatcher)).Methods("GET")
+	r.Handle("/deliveries/{id}", withRoles(deliveryHandler.DeleteDelivery, admin)).Methods("DELETE")
 
 	// Регистрирация маршрутов для курьеров
-	r.HandleFunc("/couriers", courierHandler.CreateCourier).Methods("POST")
-	r.HandleFunc("/couriers", courierHandler.ListCouriers).Methods("GET")
-	r.HandleFunc("/couriers/available", courierHandler.GetAvailableCouriers).Methods("GET")
-	r.HandleFunc("/couriers/{id}", courierHandler.GetCourier).Methods("GET")
-	r.HandleFunc("/couriers/{id}", courierHandler.UpdateCourier).Methods("PUT")
-	r.HandleFunc("/couriers/{id}/status", courierHandler.UpdateCourierStatus).Methods("PUT")
-	r.HandleFunc("/couriers/{id}", courierHandler.DeleteCourier).Methods("DELETE")
+	r.Handle("/couriers", withRoles(courierHandler.CreateCourier, dispatcher)).Methods("POST")
+	r.Handle("/couriers", withRoles(courierHandler.ListCouriers, dispatcher)).Methods("GET")
+	r.Handle("/couriers/available", withRoles(courierHandler.GetAvailableCouriers, dispatcher)).Methods("GET")
+	r.Handle("/couriers/{id}", withRoles(courierHandler.GetCourier, courier, dispatcher)).Methods("GET")
+	r.Handle("/couriers/{id}", withRoles(courierHandler.UpdateCourier, courier, dispatcher)).Methods("PUT")
+	r.Handle("/couriers/{id}/status", withRoles(courierHandler.UpdateCourierStatus, courier, dispatcher)).Methods("PUT")
+	r.Handle("/couriers/{id}/location", withRoles(courierHandler.RecordLocation, courier)).Methods("POST")
+	r.Handle("/couriers/{id}/location", withRoles(courierHandler.GetLocation, courier, dispatcher)).Methods("GET")
+	r.Handle("/couriers/{id}/track", withRoles(courierHandler.GetTrack, courier, dispatcher)).Methods("GET")
+	r.Handle("/couriers/{id}/route", withRoles(courierHandler.GetRoute, courier, dispatcher)).Methods("GET")
+	r.Handle("/couriers/{id}", withRoles(courierHandler.DeleteCourier, admin)).Methods("DELETE")
+
+	// Регистрирация маршрутов для зон доставки
+	r.Handle("/zones", withRoles(zoneHandler.CreateZone, dispatcher)).Methods("POST")
+	r.Handle("/zones", withRoles(zoneHandler.ListZones, courier, dispatcher)).Methods("GET")
+	r.Handle("/zones/{id}", withRoles(zoneHandler.GetZone, courier, dispatcher)).Methods("GET")
+	r.Handle("/zones/{id}", withRoles(zoneHandler.UpdateZone, dispatcher)).Methods("PUT")
+	r.Handle("/zones/{id}", withRoles(zoneHandler.DeleteZone, dispatcher)).Methods("DELETE")
+	r.Handle("/couriers/{id}/zones", withRoles(zoneHandler.GetCourierZones, courier, dispatcher)).Methods("GET")
+	r.Handle("/couriers/{id}/zones", withRoles(zoneHandler.SetCourierZones, dispatcher)).Methods("PUT")
+
+	// Регистрирация маршрутов для платежей
+	r.Handle("/payments", withRoles(paymentHandler.CreatePayment, client, dispatcher)).Methods("POST")
+	r.Handle("/payments/{id}", withRoles(paymentHandler.GetPayment, client, dispatcher)).Methods("GET")
+	r.Handle("/payments/{id}/cancel", withRoles(paymentHandler.CancelPayment, client, dispatcher)).Methods("POST")
+	r.Handle("/payments/{id}/refund", withRoles(paymentHandler.RefundPayment, dispatcher)).Methods("POST")
+
+	// Регистрирация маршрутов для администрирования пользователей
+	r.Handle("/admin/users/{id}/role", withRoles(authHandler.UpdateUserRole, admin)).Methods("PUT")
 
 	// Добавляем маршрут для WebSocket соединений
-	// Этот маршрут не требует аутентификации, поэтому добавляем его отдельно
+	// Токен проверяет сам WebSocketManager: браузер не может передать его в заголовке Authorization,
+	// поэтому роли не проверяются, а маршрут добавляется отдельно
 	wsRouter := r.PathPrefix("/ws").Subrouter()
 	wsRouter.Use(rateLimiter.Middleware()) // Применяем только ограничение скорости
 	wsRouter.HandleFunc("/orders", wsManager.WebSocketHandler)
 
-	// Добавляем маршрут для обновления конфигурации Rate Limiting
-	r.HandleFunc("/admin/rate-limit", func(w http.ResponseWriter, r *http.Request) {
-		// Этот маршрут должен быть защищен дополнительной аутентификацией
-		// TODO: Добавить проверку прав администратора
-
-		var config middleware.RateLimitConfig
-		if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
-			http.Error(w, "Неверный формат данных", http.StatusBadRequest)
-			return
-		}
-
-		if err := rateLimiter.UpdateConfig(config); err != nil {
-			http.Error(w, "Ошибка при обновлении конфигурации", http.StatusInternalServerError)
-			return
-		}
-
-		w.WriteHeader(http.StatusOK)
-		if err := json.NewEncoder(w).Encode(map[string]string{"status": "success"}); err != nil {
-			log.Printf("Ошибка при кодировании ответа: %v", err)
-		}
-	}).Methods("POST")
+	// Поток тех же уведомлений в формате Server-Sent Events. Токен и доступ к заказам
+	// тоже проверяет WebSocketManager: EventSource передает токен в параметре token
+	r.HandleFunc("/events/orders", wsManager.OrderEvents).Methods("GET")
+	r.HandleFunc("/events/orders/{id}", wsManager.OrderEvents).Methods("GET")
+
+	// Схемы доменных событий, которые сервис публикует в Kafka, доступны без токена
+	eventSchemaHandler := NewEventSchemaHandler(events.Domain)
+	r.HandleFunc("/events/schemas", eventSchemaHandler.ListSchemas).Methods("GET")
+	r.HandleFunc("/events/schemas/{type}", eventSchemaHandler.GetSchema).Methods("GET")
+
+	// Регистрирация маршрутов для управления Rate Limiting (только администраторы)
+	adminHandler := NewAdminHandler(rateLimiter)
+	r.Handle("/admin/rate-limit", withRoles(adminHandler.GetRateLimitConfig, admin)).Methods("GET")
+	r.Handle("/admin/rate-limit", withRoles(adminHandler.UpdateRateLimitConfig, admin)).Methods("POST")
+	r.Handle("/admin/rate-limit/history", withRoles(adminHandler.GetRateLimitHistory, admin)).Methods("GET")
+	r.Handle("/admin/rate-limit/blocked", withRoles(adminHandler.ListBlocked, admin)).Methods("GET")
+	r.Handle("/admin/rate-limit/blocked/ip/{ip}", withRoles(adminHandler.UnblockIP, admin)).Methods("DELETE")
+	r.Handle("/admin/rate-limit/blocked/user/{id}", withRoles(adminHandler.UnblockUser, admin)).Methods("DELETE")
 
 	// Добавляем эндпоинт для метрик Prometheus
 	// Этот эндпоинт не требует аутентификации
@@ -115,3 +162,8 @@ func NewRouter(
 
 	return r
 }
+
+// withRoles ограничивает доступ к обработчику пользователями с указанными ролями
+func withRoles(handler http.HandlerFunc, roles ...string) http.Handler {
+	return middleware.RequireRoles(roles...)(handler)
+}
@@ -0,0 +1,177 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// OrderEvents передает уведомления о заказах в формате Server-Sent Events - альтернатива WebSocket
+// для клиентов, которым достаточно получать обновления. GET /events/orders/{id} - один заказ,
+// GET /events/orders?ids=1,2,3 - несколько заказов. Сообщения те же, что и в WebSocket,
+// а номер сообщения передается в поле id, поэтому после переподключения EventSource
+// сам запрашивает пропущенные обновления через заголовок Last-Event-ID
+func (manager *WebSocketManager) OrderEvents(w http.ResponseWriter, r *http.Request) {
+	ids, err := eventOrderIDs(r)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	afterSeq, resume, err := lastEventID(r)
+	if err != nil {
+		writeError(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+		return
+	}
+
+	// EventSource не позволяет задать заголовки, поэтому токен можно передать в параметре token
+	client, err := manager.authenticate(r)
+	if err != nil {
+		log.Printf("Подключение к потоку событий отклонено: %v", err)
+		writeError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	for _, id := range ids {
+		if err := manager.authorizeSubscription(client, ChannelOrder, id); err != nil {
+			writeAccessError(w, err)
+			return
+		}
+		client.topics[topic(ChannelOrder, id)] = true
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Отключаем буферизацию в nginx
+	rc := http.NewResponseController(w)
+	if err := rc.Flush(); err != nil {
+		log.Printf("Поток событий не поддерживается: %v", err)
+		writeError(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	client.send = make(chan outgoingMessage, manager.sendQueueSize)
+	manager.register <- client
+	// Повтор не переполняет очередь: если пропущенное не поместилось, поток не закрывается,
+	// а ответ RESUMED с truncated сообщает клиенту, что состояние заказов нужно запросить заново.
+	// Иначе EventSource переподключался бы с тем же Last-Event-ID и снова отключался
+	if resume {
+		manager.resume <- resumeRequest{client: client, afterSeq: afterSeq}
+	}
+
+	done := make(chan struct{})
+	closed := make(chan string, 1)
+	defer func() {
+		close(done)
+		manager.unregister <- client
+	}()
+	if manager.tokens != nil {
+		go manager.watchToken(client, done, func(reason string) {
+			closed <- reason
+		})
+	}
+
+	ticker := time.NewTicker(manager.pingPeriod)
+	defer ticker.Stop()
+
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case reason := <-closed:
+			// Поток закрывается после сообщения об ошибке, чтобы клиент запросил новый токен
+			if data, err := json.Marshal(controlReply{Type: "ERROR", Error: reason}); err == nil {
+				writeEvent(rc, w, outgoingMessage{data: data})
+			}
+			return
+		case message, ok := <-client.send:
+			if !ok {
+				// Клиент не успевал получать сообщения и отключен, EventSource переподключится сам
+				return
+			}
+			err = writeEvent(rc, w, message)
+		case <-ticker.C:
+			// Комментарий не виден клиенту, но не дает прокси закрыть простаивающее соединение
+			err = writeComment(rc, w, "ping")
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// writeEvent записывает сообщение в поток событий. Сообщения без номера передаются без поля id,
+// чтобы не сбрасывать Last-Event-ID клиента
+func writeEvent(rc *http.ResponseController, w http.ResponseWriter, message outgoingMessage) error {
+	rc.SetWriteDeadline(time.Now().Add(writeWait))
+
+	var b strings.Builder
+	if message.seq > 0 {
+		fmt.Fprintf(&b, "id: %d\n", message.seq)
+	}
+	fmt.Fprintf(&b, "data: %s\n\n", message.data)
+	if _, err := w.Write([]byte(b.String())); err != nil {
+		return err
+	}
+	return rc.Flush()
+}
+
+// writeComment записывает в поток событий комментарий
+func writeComment(rc *http.ResponseController, w http.ResponseWriter, text string) error {
+	rc.SetWriteDeadline(time.Now().Add(writeWait))
+
+	if _, err := fmt.Fprintf(w, ": %s\n\n", text); err != nil {
+		return err
+	}
+	return rc.Flush()
+}
+
+// eventOrderIDs возвращает заказы потока событий из пути или параметра ids
+func eventOrderIDs(r *http.Request) ([]int, error) {
+	values := []string{mux.Vars(r)["id"]}
+	if values[0] == "" {
+		values = strings.Split(r.URL.Query().Get("ids"), ",")
+	}
+
+	seen := make(map[int]bool)
+	ids := make([]int, 0, len(values))
+	for _, value := range values {
+		id, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || id <= 0 {
+			return nil, errors.New("Invalid order ID")
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) > maxSubscriptions {
+		return nil, errors.New("Too many orders")
+	}
+	return ids, nil
+}
+
+// lastEventID возвращает номер последнего полученного сообщения из заголовка Last-Event-ID,
+// который EventSource передает при переподключении, или из параметра last_event_id
+func lastEventID(r *http.Request) (int64, bool, error) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("last_event_id")
+	}
+	if value == "" {
+		return 0, false, nil
+	}
+
+	seq, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seq < 0 {
+		return 0, false, errors.New("некорректный Last-Event-ID")
+	}
+	return seq, true, nil
+}
@@ -0,0 +1,234 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"delivery/internal/business/models"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// sseEvent - событие потока: номер сообщения и данные
+type sseEvent struct {
+	id   string
+	data string
+}
+
+// newEventsServer запускает обработчик потока событий на маршрутах роутера
+func newEventsServer(t *testing.T, manager *WebSocketManager) string {
+	t.Helper()
+	r := mux.NewRouter()
+	r.HandleFunc("/events/orders", manager.OrderEvents)
+	r.HandleFunc("/events/orders/{id}", manager.OrderEvents)
+
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+// openEvents подключается к потоку событий и возвращает ответ сервера
+func openEvents(t *testing.T, url string, header http.Header) *http.Response {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// readEvent читает из потока следующее событие, пропуская комментарии
+func readEvent(t *testing.T, reader *bufio.Reader) sseEvent {
+	t.Helper()
+	var event sseEvent
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("ReadString() error = %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && event.data != "":
+			return event
+		case strings.HasPrefix(line, "id: "):
+			event.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			event.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestOrderEvents_StreamsUpdates(t *testing.T) {
+	manager := NewWebSocketManager()
+	go manager.Run()
+	url := newEventsServer(t, manager)
+
+	resp := openEvents(t, url+"/events/orders/5", nil)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("ответ = %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	waitFor(t, func() bool { return manager.GetActiveConnectionsCount() == 1 })
+
+	manager.BroadcastOrderStatusUpdate(OrderTarget{OrderID: 6}, "assigned")
+	manager.BroadcastOrderStatusUpdate(OrderTarget{OrderID: 5, CourierID: 3}, "in_transit")
+
+	event := readEvent(t, bufio.NewReader(resp.Body))
+	var update OrderStatusUpdate
+	if err := json.Unmarshal([]byte(event.data), &update); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if event.id != "2" || update.Seq != 2 || update.OrderID != "5" || update.NewStatus != "in_transit" {
+		t.Errorf("событие = %+v, обновление = %+v", event, update)
+	}
+}
+
+func TestOrderEvents_MultipleOrders(t *testing.T) {
+	manager := NewWebSocketManager()
+	go manager.Run()
+	url := newEventsServer(t, manager)
+
+	resp := openEvents(t, url+"/events/orders?ids=5,7", nil)
+	waitFor(t, func() bool { return manager.GetActiveConnectionsCount() == 1 })
+
+	manager.BroadcastOrderStatusUpdate(OrderTarget{OrderID: 5}, "in_transit")
+	manager.BroadcastOrderStatusUpdate(OrderTarget{OrderID: 6}, "in_transit")
+	manager.BroadcastETAUpdate(OrderTarget{OrderID: 7}, time.Now().Add(time.Hour))
+
+	reader := bufio.NewReader(resp.Body)
+	if event := readEvent(t, reader); !strings.Contains(event.data, `"ORDER_STATUS_UPDATE"`) || event.id != "1" {
+		t.Errorf("первое событие = %+v", event)
+	}
+	if event := readEvent(t, reader); !strings.Contains(event.data, `"ETA_UPDATE"`) || event.id != "3" {
+		t.Errorf("второе событие = %+v", event)
+	}
+}
+
+func TestOrderEvents_LastEventID(t *testing.T) {
+	manager := NewWebSocketManager()
+	go manager.Run()
+	url := newEventsServer(t, manager)
+
+	for _, status := range []string{"assigned", "picked_up", "in_transit"} {
+		manager.BroadcastOrderStatusUpdate(OrderTarget{OrderID: 5}, status)
+	}
+
+	// EventSource передает номер последнего полученного события при переподключении
+	resp := openEvents(t, url+"/events/orders/5", http.Header{"Last-Event-ID": {"1"}})
+	reader := bufio.NewReader(resp.Body)
+	for _, want := range []string{"2", "3"} {
+		if event := readEvent(t, reader); event.id != want {
+			t.Errorf("повтор = %+v, want id %s", event, want)
+		}
+	}
+
+	event := readEvent(t, reader)
+	var reply controlReply
+	if err := json.Unmarshal([]byte(event.data), &reply); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if want := (controlReply{Type: "RESUMED", Seq: 3, Replayed: 2}); reply != want || event.id != "" {
+		t.Errorf("ответ = %+v (id %q), want %+v", reply, event.id, want)
+	}
+}
+
+func TestOrderEvents_LastEventIDOverflow(t *testing.T) {
+	manager := NewWebSocketManager()
+	go manager.Run()
+	url := newEventsServer(t, manager)
+
+	// EventSource пропустил больше обновлений, чем помещается в очередь потока
+	missed := 3 * sendQueueSize
+	for i := 0; i < missed; i++ {
+		manager.BroadcastOrderStatusUpdate(OrderTarget{OrderID: 5}, "in_transit")
+	}
+
+	resp := openEvents(t, url+"/events/orders/5", http.Header{"Last-Event-ID": {"0"}})
+	reader := bufio.NewReader(resp.Body)
+	replayed := sendQueueSize - 1
+	for i := 0; i < replayed; i++ {
+		if event, want := readEvent(t, reader), strconv.Itoa(missed-replayed+1+i); event.id != want {
+			t.Fatalf("повтор = %+v, want id %s", event, want)
+		}
+	}
+
+	event := readEvent(t, reader)
+	var reply controlReply
+	if err := json.Unmarshal([]byte(event.data), &reply); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if want := (controlReply{Type: "RESUMED", Seq: int64(missed), Replayed: replayed, Truncated: true}); reply != want {
+		t.Errorf("ответ = %+v, want %+v", reply, want)
+	}
+
+	// Поток не закрыт, поэтому EventSource не переподключается с тем же Last-Event-ID
+	manager.BroadcastOrderStatusUpdate(OrderTarget{OrderID: 5}, "delivered")
+	if event, want := readEvent(t, reader), strconv.Itoa(missed+1); event.id != want {
+		t.Errorf("после повтора = %+v, want id %s", event, want)
+	}
+}
+
+func TestOrderEvents_InvalidRequest(t *testing.T) {
+	manager := NewWebSocketManager()
+	go manager.Run()
+	url := newEventsServer(t, manager)
+
+	for _, path := range []string{"/events/orders/abc", "/events/orders", "/events/orders?ids=5,x", "/events/orders/5?last_event_id=-1"} {
+		if resp := openEvents(t, url+path, nil); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: статус = %d, want %d", path, resp.StatusCode, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestOrderEvents_Authorization(t *testing.T) {
+	ownership, _, _ := newTestOwnership()
+	tokens := newFakeTokens()
+	tokens.issue("client.token.sig", 1, models.UserRoleClient, time.Now().Add(time.Hour))
+	tokens.issue("other.token.sig", 2, models.UserRoleClient, time.Now().Add(time.Hour))
+	manager := NewWebSocketManager().WithAuth(tokens, ownership)
+	manager.recheckInterval = 20 * time.Millisecond
+	go manager.Run()
+	url := newEventsServer(t, manager)
+
+	tests := []struct {
+		name string
+		path string
+		want int
+	}{
+		{name: "без токена", path: "/events/orders/1000", want: http.StatusUnauthorized},
+		{name: "чужой заказ", path: "/events/orders/1000?token=other.token.sig", want: http.StatusForbidden},
+		{name: "один из заказов чужой", path: "/events/orders?ids=1000,2000&token=client.token.sig", want: http.StatusForbidden},
+		{name: "свой заказ", path: "/events/orders/1000?token=client.token.sig", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if resp := openEvents(t, url+tt.path, nil); resp.StatusCode != tt.want {
+				t.Errorf("статус = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+
+	// После отзыва токена поток завершается сообщением об ошибке
+	resp := openEvents(t, url+"/events/orders/1000", http.Header{"Authorization": {"Bearer client.token.sig"}})
+	tokens.revoke("client.token.sig")
+	event := readEvent(t, bufio.NewReader(resp.Body))
+	if event.data != `{"type":"ERROR","error":"token revoked"}` {
+		t.Errorf("событие = %+v", event)
+	}
+}
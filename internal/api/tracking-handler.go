@@ -0,0 +1,83 @@
+package api
+
+import (
+	"delivery/internal/business/models"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// deletedEventStatus - статус в журнале доставки, которым отмечается ее удаление
+const deletedEventStatus = "deleted"
+
+// etaStatuses - статусы доставки, в которых посылка еще в пути и показывается ожидаемое время доставки
+var etaStatuses = map[string]bool{"assigned": true, "picked_up": true, "in_transit": true}
+
+// TrackingHandler - публичное отслеживание посылки по номеру без аутентификации
+type TrackingHandler struct {
+	parcels    ParcelService
+	deliveries DeliveryService
+}
+
+func NewTrackingHandler(parcels ParcelService, deliveries DeliveryService) *TrackingHandler {
+	return &TrackingHandler{parcels: parcels, deliveries: deliveries}
+}
+
+// trackingEvent - этап в истории посылки
+type trackingEvent struct {
+	Status string    `json:"status"`
+	At     time.Time `json:"at"`
+}
+
+// trackingResponse содержит только статусы и время: без адреса, данных клиента, курьера и комментариев
+type trackingResponse struct {
+	TrackingNumber string          `json:"tracking_number"`
+	Status         string          `json:"status"`
+	DeliveryStatus string          `json:"delivery_status,omitempty"`
+	ETA            *time.Time      `json:"eta,omitempty"` // Ожидаемое время доставки
+	Timeline       []trackingEvent `json:"timeline"`
+}
+
+func (h *TrackingHandler) Track(w http.ResponseWriter, r *http.Request) {
+	trackingNumber := mux.Vars(r)["trackingNumber"]
+
+	parcel, err := h.parcels.GetByTrackingNumber(trackingNumber)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidTrackingNumber) {
+			writeError(w, "Invalid tracking number", http.StatusBadRequest)
+			return
+		}
+		writeError(w, "Parcel not found", http.StatusNotFound)
+		return
+	}
+
+	response := trackingResponse{
+		TrackingNumber: parcel.TrackingNumber,
+		Status:         parcel.Status,
+		Timeline:       []trackingEvent{{Status: models.ParcelStatusRegistered, At: parcel.CreatedAt}},
+	}
+
+	// Посылка без доставки еще не передана курьеру
+	if delivery, err := h.deliveries.GetByParcelID(parcel.ID); err == nil {
+		response.DeliveryStatus = delivery.Status
+		if etaStatuses[delivery.Status] {
+			response.ETA = delivery.ETA
+		}
+
+		events, err := h.deliveries.History(delivery.ID)
+		if err != nil {
+			writeError(w, "Failed to fetch tracking history", http.StatusInternalServerError)
+			return
+		}
+		for _, e := range events {
+			if e.NewStatus == deletedEventStatus || e.NewStatus == e.OldStatus {
+				continue
+			}
+			response.Timeline = append(response.Timeline, trackingEvent{Status: e.NewStatus, At: e.CreatedAt})
+		}
+	}
+
+	writeJSON(w, response)
+}
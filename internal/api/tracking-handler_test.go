@@ -0,0 +1,106 @@
+package api
+
+import (
+	"delivery/internal/business/models"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type trackingParcelService struct {
+	ParcelService
+	parcel models.Parcel
+}
+
+func (s *trackingParcelService) GetByTrackingNumber(trackingNumber string) (*models.Parcel, error) {
+	if trackingNumber == "bad" {
+		return nil, models.ErrInvalidTrackingNumber
+	}
+	if trackingNumber != s.parcel.TrackingNumber {
+		return nil, errors.New("parcel not found")
+	}
+	return &s.parcel, nil
+}
+
+type trackingDeliveryService struct {
+	*fakeDeliveryService
+	events []models.DeliveryEvent
+}
+
+func (s *trackingDeliveryService) History(deliveryID int) ([]models.DeliveryEvent, error) {
+	return s.events, nil
+}
+
+func TestTrackingHandler_Track(t *testing.T) {
+	created := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	eta := created.Add(4 * time.Hour)
+	parcels := &trackingParcelService{parcel: models.Parcel{
+		ID: 100, TrackingNumber: "DL123456789015", ClientID: 10,
+		Address: "ул. Ленина, 1", Status: models.ParcelStatusInTransit, CreatedAt: created,
+	}}
+	deliveries := &trackingDeliveryService{
+		fakeDeliveryService: &fakeDeliveryService{deliveries: map[int]models.Delivery{
+			1000: {ID: 1000, ParcelID: 100, CourierID: 30, Status: "picked_up", ETA: &eta},
+		}},
+		events: []models.DeliveryEvent{
+			{NewStatus: "assigned", ActorID: 5, CreatedAt: created.Add(time.Hour)},
+			{OldStatus: "assigned", NewStatus: "assigned", ActorID: 5, Note: "Смена курьера", CreatedAt: created.Add(2 * time.Hour)},
+			{OldStatus: "assigned", NewStatus: "picked_up", ActorID: 3, Note: "Клиент Иванов, кв. 5", CreatedAt: created.Add(3 * time.Hour)},
+		},
+	}
+	handler := NewTrackingHandler(parcels, deliveries)
+
+	track := func(number string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest("GET", "/track/"+number, nil), map[string]string{"trackingNumber": number})
+		rr := httptest.NewRecorder()
+		handler.Track(rr, req)
+		return rr
+	}
+
+	t.Run("Посылка найдена", func(t *testing.T) {
+		rr := track("DL123456789015")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Track() status = %d, want %d", rr.Code, http.StatusOK)
+		}
+
+		body := rr.Body.String()
+		for _, pii := range []string{"Ленина", "Иванов", "client_id", "courier_id", "actor_id", "note"} {
+			if strings.Contains(body, pii) {
+				t.Errorf("Track() раскрывает %q: %s", pii, body)
+			}
+		}
+
+		var response trackingResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("не удалось декодировать ответ: %v", err)
+		}
+		var statuses []string
+		for _, e := range response.Timeline {
+			statuses = append(statuses, e.Status)
+		}
+		if strings.Join(statuses, ",") != "registered,assigned,picked_up" || response.DeliveryStatus != "picked_up" {
+			t.Errorf("Track() = %+v", response)
+		}
+		if response.ETA == nil || !response.ETA.Equal(eta) {
+			t.Errorf("Track() eta = %v, want %v", response.ETA, eta)
+		}
+	})
+
+	t.Run("Неверная контрольная цифра", func(t *testing.T) {
+		if rr := track("bad"); rr.Code != http.StatusBadRequest {
+			t.Errorf("Track() status = %d, want %d", rr.Code, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("Посылка не найдена", func(t *testing.T) {
+		if rr := track("DL000000000000"); rr.Code != http.StatusNotFound {
+			t.Errorf("Track() status = %d, want %d", rr.Code, http.StatusNotFound)
+		}
+	})
+}
@@ -2,40 +2,172 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Разрешаем подключения с любых источников (в продакшене лучше настроить более строго)
-	},
+// Каналы, на которые может подписаться клиент WebSocket
+const (
+	ChannelOrder   = "order"   // Обновления одного заказа (доставки)
+	ChannelCourier = "courier" // Обновления всех заказов курьера
+	ChannelZone    = "zone"    // Обновления всех заказов в зоне доставки
+)
+
+const (
+	// maxSubscriptions - наибольшее число подписок одного клиента
+	maxSubscriptions = 100
+	// maxControlMessageSize - наибольший размер управляющего сообщения клиента в байтах
+	maxControlMessageSize = 4096
+	// sendQueueSize - сколько сообщений может ждать отправки клиенту. Клиент, который не успевает
+	// их принимать, отключается, чтобы не задерживать остальных
+	sendQueueSize = 64
+	// writeWait - время на отправку одного сообщения
+	writeWait = 10 * time.Second
+	// pongWait - сколько ждать ответа на ping, прежде чем считать соединение потерянным
+	pongWait = 60 * time.Second
+	// pingPeriod - период отправки ping, меньше pongWait
+	pingPeriod = pongWait * 9 / 10
+)
+
+// OrderTarget - кому адресовано сообщение о заказе: подписчикам заказа, его курьера и зоны доставки
+type OrderTarget struct {
+	OrderID   int
+	CourierID int // 0 - курьер не назначен
+	ZoneID    int // 0 - адрес вне зон доставки
+}
+
+// topics возвращает каналы, подписчики которых получают сообщение о заказе
+func (t OrderTarget) topics() []string {
+	topics := []string{topic(ChannelOrder, t.OrderID)}
+	if t.CourierID != 0 {
+		topics = append(topics, topic(ChannelCourier, t.CourierID))
+	}
+	if t.ZoneID != 0 {
+		topics = append(topics, topic(ChannelZone, t.ZoneID))
+	}
+	return topics
+}
+
+func topic(channel string, id int) string {
+	return fmt.Sprintf("%s:%d", channel, id)
+}
+
+// wsClient - подключение и каналы, на которые подписан клиент
+type wsClient struct {
+	conn    *websocket.Conn
+	request *http.Request // Запрос на подключение с пользователем из токена в контексте
+	userID  int
+	topics  map[string]bool // Изменяются под clientsMu менеджера
+
+	// send - очередь сообщений клиенту. Закрывается только в Run при отключении клиента,
+	// после чего writePump закрывает соединение с кодом closeCode
+	send      chan outgoingMessage
+	closeCode int
+	closeText string
+
+	tokenMu   sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+func (c *wsClient) setToken(token string, expiresAt time.Time) {
+	c.tokenMu.Lock()
+	c.token, c.expiresAt = token, expiresAt
+	c.tokenMu.Unlock()
+}
+
+func (c *wsClient) currentToken() (string, time.Time) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+	return c.token, c.expiresAt
+}
+
+// outgoingMessage - сообщение и каналы, подписчикам которых оно адресовано
+type outgoingMessage struct {
+	seq    int64 // 0 - сообщение без номера, не сохраняется для повтора
+	topics []string
+	data   []byte
+}
+
+// directMessage - ответ одному клиенту на его управляющее сообщение
+type directMessage struct {
+	client *wsClient
+	data   []byte
 }
 
 // Структура для хранения клиентов WebSocket
 type WebSocketManager struct {
-	clients    map[*websocket.Conn]bool
+	clients    map[*wsClient]bool
 	clientsMu  sync.RWMutex
-	broadcast  chan []byte
-	register   chan *websocket.Conn
-	unregister chan *websocket.Conn
+	broadcast  chan outgoingMessage
+	direct     chan directMessage
+	register   chan *wsClient
+	unregister chan *wsClient
+	resume     chan resumeRequest
+
+	history     []outgoingMessage // Последние сообщения для повтора; изменяется только в Run
+	historySize int
+	lastSeq     int64 // Наибольший номер полученного сообщения; изменяется только в Run
+	localSeq    int64 // Счетчик номеров без брокера
+
+	sendQueueSize int
+	pingPeriod    time.Duration
+	pongWait      time.Duration
+
+	broker Broker // nil - сообщения доставляются только клиентам этого экземпляра
+
+	upgrader        websocket.Upgrader
+	tokens          TokenParser
+	ownership       *Ownership
+	recheckInterval time.Duration
 }
 
-// Создаем новый менеджер WebSocket
+// Создаем новый менеджер WebSocket. По умолчанию подключения принимаются без токена
+// и только с того же хоста, что и сервер
 func NewWebSocketManager() *WebSocketManager {
 	return &WebSocketManager{
-		clients:    make(map[*websocket.Conn]bool),
-		broadcast:  make(chan []byte),
-		register:   make(chan *websocket.Conn),
-		unregister: make(chan *websocket.Conn),
+		clients:    make(map[*wsClient]bool),
+		broadcast:  make(chan outgoingMessage),
+		direct:     make(chan directMessage),
+		register:   make(chan *wsClient),
+		unregister: make(chan *wsClient),
+		resume:     make(chan resumeRequest),
+
+		historySize:   historySize,
+		sendQueueSize: sendQueueSize,
+		pingPeriod:    pingPeriod,
+		pongWait:      pongWait,
+
+		upgrader: websocket.Upgrader{
+			CheckOrigin:  checkOrigin(nil),
+			Subprotocols: []string{wsBearerProtocol},
+		},
+		recheckInterval: tokenRecheckInterval,
 	}
 }
 
-// Запускаем обработку сообщений в отдельной горутине
+// WithAuth включает проверку токена при подключении и проверку доступа к каналам при подписке
+func (manager *WebSocketManager) WithAuth(tokens TokenParser, ownership *Ownership) *WebSocketManager {
+	manager.tokens = tokens
+	manager.ownership = ownership
+	return manager
+}
+
+// WithAllowedOrigins разрешает подключения со страниц указанных источников, "*" - с любых
+func (manager *WebSocketManager) WithAllowedOrigins(origins []string) *WebSocketManager {
+	manager.upgrader.CheckOrigin = checkOrigin(origins)
+	return manager
+}
+
+// Запускаем обработку сообщений в отдельной горутине. Run только ставит сообщения в очереди клиентов,
+// а в соединения пишут их writePump, поэтому медленный клиент не задерживает остальных
 func (manager *WebSocketManager) Run() {
 	for {
 		select {
@@ -45,53 +177,241 @@ func (manager *WebSocketManager) Run() {
 			manager.clientsMu.Unlock()
 		case client := <-manager.unregister:
 			manager.clientsMu.Lock()
-			if _, ok := manager.clients[client]; ok {
-				delete(manager.clients, client)
-				client.Close()
-			}
+			manager.drop(client, websocket.CloseNormalClosure, "")
+			manager.clientsMu.Unlock()
+		case message := <-manager.direct:
+			manager.clientsMu.Lock()
+			manager.enqueue(message.client, outgoingMessage{data: message.data})
+			manager.clientsMu.Unlock()
+		case request := <-manager.resume:
+			manager.clientsMu.Lock()
+			manager.replay(request)
 			manager.clientsMu.Unlock()
 		case message := <-manager.broadcast:
-			manager.clientsMu.RLock()
+			manager.remember(message)
+			manager.clientsMu.Lock()
 			for client := range manager.clients {
-				err := client.WriteMessage(websocket.TextMessage, message)
-				if err != nil {
-					log.Printf("Ошибка при отправке сообщения: %v", err)
-					client.Close()
-					delete(manager.clients, client)
+				if client.subscribed(message.topics) {
+					manager.enqueue(client, message)
 				}
 			}
-			manager.clientsMu.RUnlock()
+			manager.clientsMu.Unlock()
 		}
 	}
 }
 
+// enqueue ставит сообщение в очередь клиента, а если очередь заполнена - отключает клиента.
+// Вызывается из Run под clientsMu
+func (manager *WebSocketManager) enqueue(client *wsClient, message outgoingMessage) {
+	if !manager.clients[client] {
+		return
+	}
+	select {
+	case client.send <- message:
+	default:
+		log.Printf("Клиент уведомлений пользователя %d не успевает принимать сообщения и отключен", client.userID)
+		manager.drop(client, websocket.CloseTryAgainLater, "too slow")
+	}
+}
+
+// drop удаляет клиента и закрывает его очередь. Вызывается из Run под clientsMu
+func (manager *WebSocketManager) drop(client *wsClient, code int, text string) {
+	if !manager.clients[client] {
+		return
+	}
+	delete(manager.clients, client)
+	client.closeCode, client.closeText = code, text
+	close(client.send)
+}
+
+// subscribed сообщает, что клиент подписан хотя бы на один из каналов
+func (c *wsClient) subscribed(topics []string) bool {
+	for _, t := range topics {
+		if c.topics[t] {
+			return true
+		}
+	}
+	return false
+}
+
 // Обработчик WebSocket соединений
 func (manager *WebSocketManager) WebSocketHandler(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+	// Токен проверяется до установки соединения, чтобы ответить обычным HTTP 401
+	client, err := manager.authenticate(r)
+	if err != nil {
+		log.Printf("WebSocket подключение отклонено: %v", err)
+		writeError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	conn, err := manager.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("Ошибка при установке WebSocket соединения: %v", err)
 		return
 	}
 
-	// Регистрируем нового клиента
-	manager.register <- conn
+	// Регистрируем нового клиента. Пока клиент не подписался ни на один канал, сообщения ему не отправляются
+	client.conn = conn
+	client.send = make(chan outgoingMessage, manager.sendQueueSize)
+	manager.register <- client
 
-	// Обрабатываем сообщения от клиента
-	go func() {
-		defer func() {
-			manager.unregister <- conn
-		}()
+	done := make(chan struct{})
+	if manager.tokens != nil {
+		go manager.watchToken(client, done, func(reason string) {
+			message := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, reason)
+			conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+			conn.Close()
+		})
+	}
 
-		for {
-			_, _, err := conn.ReadMessage()
-			if err != nil {
-				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-					log.Printf("Ошибка при чтении сообщения: %v", err)
-				}
-				break
+	go manager.writePump(client)
+	go manager.readPump(client, done)
+}
+
+// readPump обрабатывает управляющие сообщения клиента и ответы на ping.
+// Соединение считается потерянным, если клиент не отвечает дольше pongWait
+func (manager *WebSocketManager) readPump(client *wsClient, done chan struct{}) {
+	defer func() {
+		close(done)
+		manager.unregister <- client
+	}()
+
+	conn := client.conn
+	conn.SetReadLimit(maxControlMessageSize)
+	conn.SetReadDeadline(time.Now().Add(manager.pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(manager.pongWait))
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				log.Printf("Ошибка при чтении сообщения: %v", err)
 			}
+			return
 		}
+		manager.reply(client, manager.handleControl(client, data))
+	}
+}
+
+// writePump - единственная горутина, которая пишет сообщения в соединение клиента.
+// Она отправляет сообщения из очереди и ping, а после закрытия очереди закрывает соединение
+func (manager *WebSocketManager) writePump(client *wsClient) {
+	ticker := time.NewTicker(manager.pingPeriod)
+	defer func() {
+		ticker.Stop()
+		client.conn.Close()
 	}()
+
+	for {
+		select {
+		case message, ok := <-client.send:
+			client.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				message := websocket.FormatCloseMessage(client.closeCode, client.closeText)
+				client.conn.WriteMessage(websocket.CloseMessage, message)
+				return
+			}
+			if err := client.conn.WriteMessage(websocket.TextMessage, message.data); err != nil {
+				log.Printf("Ошибка при отправке сообщения: %v", err)
+				return
+			}
+		case <-ticker.C:
+			client.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := client.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// controlMessage - управляющее сообщение клиента: {"action": "subscribe", "channel": "order", "id": 42},
+// {"action": "authenticate", "token": "..."} или {"action": "resume", "seq": 17}
+type controlMessage struct {
+	Action  string `json:"action"` // subscribe, unsubscribe, authenticate или resume
+	Channel string `json:"channel"`
+	ID      int    `json:"id"`
+	Token   string `json:"token"`
+	Seq     int64  `json:"seq"`
+}
+
+// controlReply - ответ на управляющее сообщение
+type controlReply struct {
+	Type      string `json:"type"` // SUBSCRIBED, UNSUBSCRIBED, AUTHENTICATED, RESUMED или ERROR
+	Channel   string `json:"channel,omitempty"`
+	ID        int    `json:"id,omitempty"`
+	Seq       int64  `json:"seq,omitempty"`       // Номер последнего сообщения, известного серверу
+	Replayed  int    `json:"replayed,omitempty"`  // Сколько пропущенных сообщений отправлено повторно
+	Truncated bool   `json:"truncated,omitempty"` // Часть пропущенных сообщений не отправлена: не хранится или не поместилась в очередь
+	Error     string `json:"error,omitempty"`
+}
+
+// handleControl выполняет управляющее сообщение клиента и возвращает ответ на него
+func (manager *WebSocketManager) handleControl(client *wsClient, data []byte) controlReply {
+	var msg controlMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return controlReply{Type: "ERROR", Error: "invalid message"}
+	}
+	switch msg.Action {
+	case "authenticate":
+		return manager.reauthenticate(client, msg.Token)
+	case "resume":
+		if msg.Seq < 0 {
+			return controlReply{Type: "ERROR", Error: "invalid seq"}
+		}
+		// Пропущенные сообщения и ответ RESUMED отправляет Run, чтобы они не перемешались с новыми сообщениями
+		manager.resume <- resumeRequest{client: client, afterSeq: msg.Seq}
+		return controlReply{}
+	}
+
+	switch msg.Channel {
+	case ChannelOrder, ChannelCourier, ChannelZone:
+	default:
+		return controlReply{Type: "ERROR", Error: "unknown channel"}
+	}
+	if msg.ID <= 0 {
+		return controlReply{Type: "ERROR", Error: "invalid id"}
+	}
+
+	if msg.Action == "subscribe" {
+		if err := manager.authorizeSubscription(client, msg.Channel, msg.ID); err != nil {
+			if !errors.Is(err, ErrAccessDenied) {
+				log.Printf("Ошибка при проверке доступа к каналу %s:%d: %v", msg.Channel, msg.ID, err)
+			}
+			return controlReply{Type: "ERROR", Channel: msg.Channel, ID: msg.ID, Error: "access denied"}
+		}
+	}
+
+	key := topic(msg.Channel, msg.ID)
+	manager.clientsMu.Lock()
+	defer manager.clientsMu.Unlock()
+
+	switch msg.Action {
+	case "subscribe":
+		if !client.topics[key] && len(client.topics) >= maxSubscriptions {
+			return controlReply{Type: "ERROR", Error: "too many subscriptions"}
+		}
+		client.topics[key] = true
+		return controlReply{Type: "SUBSCRIBED", Channel: msg.Channel, ID: msg.ID}
+	case "unsubscribe":
+		delete(client.topics, key)
+		return controlReply{Type: "UNSUBSCRIBED", Channel: msg.Channel, ID: msg.ID}
+	}
+	return controlReply{Type: "ERROR", Error: "unknown action"}
+}
+
+// reply отправляет ответ клиенту через Run. Пустой ответ не отправляется
+func (manager *WebSocketManager) reply(client *wsClient, reply controlReply) {
+	if reply.Type == "" {
+		return
+	}
+	data, err := json.Marshal(reply)
+	if err != nil {
+		log.Printf("Ошибка при сериализации ответа: %v", err)
+		return
+	}
+	manager.direct <- directMessage{client: client, data: data}
 }
 
 // GetActiveConnectionsCount возвращает количество активных WebSocket соединений
@@ -103,20 +423,22 @@ func (manager *WebSocketManager) GetActiveConnectionsCount() int {
 
 // Структура для сообщения об обновлении статуса заказа
 type OrderStatusUpdate struct {
+	Seq       int64  `json:"seq,omitempty"` // Номер сообщения для повтора пропущенных
 	Type      string `json:"type"`
 	OrderID   string `json:"orderId"`
 	NewStatus string `json:"newStatus"`
 	Timestamp int64  `json:"timestamp"`
 }
 
-// Отправляет обновление статуса заказа всем подключенным клиентам
-func (manager *WebSocketManager) BroadcastOrderStatusUpdate(orderID, newStatus string) {
+// Отправляет обновление статуса заказа подписчикам заказа, его курьера и зоны
+func (manager *WebSocketManager) BroadcastOrderStatusUpdate(target OrderTarget, newStatus string) {
 	update := OrderStatusUpdate{
 		Type:      "ORDER_STATUS_UPDATE",
-		OrderID:   orderID,
+		OrderID:   strconv.Itoa(target.OrderID),
 		NewStatus: newStatus,
 		Timestamp: GetCurrentTimestamp(),
 	}
+	update.Seq = manager.nextSequence()
 
 	jsonData, err := json.Marshal(update)
 	if err != nil {
@@ -124,7 +446,35 @@ func (manager *WebSocketManager) BroadcastOrderStatusUpdate(orderID, newStatus s
 		return
 	}
 
-	manager.broadcast <- jsonData
+	manager.publish(outgoingMessage{seq: update.Seq, topics: target.topics(), data: jsonData})
+}
+
+// Структура для сообщения об изменении ожидаемого времени доставки заказа
+type ETAUpdate struct {
+	Seq       int64  `json:"seq,omitempty"` // Номер сообщения для повтора пропущенных
+	Type      string `json:"type"`
+	OrderID   string `json:"orderId"`
+	ETA       int64  `json:"eta"` // Ожидаемое время доставки в миллисекундах
+	Timestamp int64  `json:"timestamp"`
+}
+
+// Отправляет новое ожидаемое время доставки заказа подписчикам заказа, его курьера и зоны
+func (manager *WebSocketManager) BroadcastETAUpdate(target OrderTarget, eta time.Time) {
+	update := ETAUpdate{
+		Type:      "ETA_UPDATE",
+		OrderID:   strconv.Itoa(target.OrderID),
+		ETA:       eta.UnixMilli(),
+		Timestamp: GetCurrentTimestamp(),
+	}
+	update.Seq = manager.nextSequence()
+
+	jsonData, err := json.Marshal(update)
+	if err != nil {
+		log.Printf("Ошибка при сериализации ожидаемого времени доставки: %v", err)
+		return
+	}
+
+	manager.publish(outgoingMessage{seq: update.Seq, topics: target.topics(), data: jsonData})
 }
 
 // Получение текущего времени в миллисекундах
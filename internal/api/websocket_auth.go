@@ -0,0 +1,224 @@
+package api
+
+import (
+	"context"
+	"delivery/internal/auth"
+	"delivery/internal/middleware"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// TokenParser проверяет access токен и возвращает его claims. Отозванный токен считается недействительным
+type TokenParser interface {
+	ParseToken(tokenString string) (*auth.Claims, error)
+}
+
+// wsBearerProtocol - подпротокол, в паре с которым браузерный клиент передает токен:
+// Sec-WebSocket-Protocol: bearer, <token>
+const wsBearerProtocol = "bearer"
+
+// tokenRecheckInterval - как часто токен открытого соединения проверяется на отзыв
+const tokenRecheckInterval = 30 * time.Second
+
+// errTokenMismatch - новый токен соединения выпущен для другого пользователя
+var errTokenMismatch = errors.New("токен выпущен для другого пользователя")
+
+// tokenFromRequest извлекает токен из заголовка Authorization, параметра token
+// или заголовка Sec-WebSocket-Protocol (браузерный WebSocket не позволяет задать Authorization)
+func tokenFromRequest(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimPrefix(header, "Bearer ")
+	}
+	if token := r.URL.Query().Get("token"); token != "" {
+		return token
+	}
+
+	protocols := websocket.Subprotocols(r)
+	for i := 0; i+1 < len(protocols); i++ {
+		if protocols[i] == wsBearerProtocol {
+			return protocols[i+1]
+		}
+	}
+	return ""
+}
+
+// errMalformedToken - токен пустой или не похож на JWT
+var errMalformedToken = errors.New("токен имеет неверный формат")
+
+// wellFormedToken проверяет, что токен имеет вид JWT: три непустые части из символов base64url,
+// разделенные точками. Так произвольные строки из сети не доходят до проверки подписи
+func wellFormedToken(token string) bool {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	for _, part := range parts {
+		if part == "" {
+			return false
+		}
+		for _, c := range part {
+			if !(c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// parseToken проверяет формат токена и затем сам токен
+func (manager *WebSocketManager) parseToken(token string) (*auth.Claims, error) {
+	if !wellFormedToken(token) {
+		return nil, errMalformedToken
+	}
+	return manager.tokens.ParseToken(token)
+}
+
+// withClaims добавляет пользователя из токена в контекст запроса, как это делает AuthMiddleware
+func withClaims(r *http.Request, claims *auth.Claims, token string) *http.Request {
+	ctx := context.WithValue(r.Context(), middleware.UserIDKey, claims.UserID)
+	ctx = context.WithValue(ctx, middleware.UserRoleKey, claims.Role)
+	ctx = context.WithValue(ctx, middleware.TokenKey, token)
+	return r.WithContext(ctx)
+}
+
+// tokenExpiry возвращает время истечения токена или нулевое время, если срок не задан
+func tokenExpiry(claims *auth.Claims) time.Time {
+	if claims.ExpiresAt == nil {
+		return time.Time{}
+	}
+	return claims.ExpiresAt.Time
+}
+
+// checkOrigin разрешает подключения с источников из списка ("*" - с любых), с того же хоста,
+// что и сервер, и без заголовка Origin (не из браузера)
+func checkOrigin(allowed []string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		for _, a := range allowed {
+			if a == "*" || strings.EqualFold(strings.TrimSuffix(a, "/"), origin) {
+				return true
+			}
+		}
+
+		u, err := url.Parse(origin)
+		if err != nil {
+			return false
+		}
+		if !strings.EqualFold(u.Host, r.Host) {
+			log.Printf("WebSocket подключение с источника %s отклонено", origin)
+			return false
+		}
+		return true
+	}
+}
+
+// authenticate проверяет токен запроса на подключение. Без настроенной проверки токенов доступ не ограничивается
+func (manager *WebSocketManager) authenticate(r *http.Request) (*wsClient, error) {
+	client := &wsClient{request: r, topics: make(map[string]bool)}
+	if manager.tokens == nil {
+		return client, nil
+	}
+
+	token := tokenFromRequest(r)
+	if token == "" {
+		return nil, errors.New("токен не передан")
+	}
+	claims, err := manager.parseToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	client.request = withClaims(r, claims, token)
+	client.userID = claims.UserID
+	client.setToken(token, tokenExpiry(claims))
+	return client, nil
+}
+
+// reauthenticate заменяет токен соединения новым токеном того же пользователя, например после refresh
+func (manager *WebSocketManager) reauthenticate(client *wsClient, token string) controlReply {
+	if manager.tokens == nil {
+		return controlReply{Type: "ERROR", Error: "authentication is not enabled"}
+	}
+
+	claims, err := manager.parseToken(token)
+	if err == nil && claims.UserID != client.userID {
+		err = errTokenMismatch
+	}
+	if err != nil {
+		log.Printf("Ошибка при обновлении токена WebSocket соединения: %v", err)
+		return controlReply{Type: "ERROR", Error: "invalid token"}
+	}
+
+	// Запрос меняется только в горутине чтения сообщений клиента, поэтому блокировка не нужна
+	client.request = withClaims(client.request, claims, token)
+	client.setToken(token, tokenExpiry(claims))
+	return controlReply{Type: "AUTHENTICATED"}
+}
+
+// watchToken вызывает closeConn с причиной, когда токен истекает или отзывается
+func (manager *WebSocketManager) watchToken(client *wsClient, done <-chan struct{}, closeConn func(reason string)) {
+	for {
+		token, expiresAt := client.currentToken()
+		wait := manager.recheckInterval
+		if !expiresAt.IsZero() && time.Until(expiresAt) < wait {
+			wait = time.Until(expiresAt)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-done:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		// Токен могли обновить, пока истекал таймер
+		if current, _ := client.currentToken(); current != token {
+			continue
+		}
+
+		reason := ""
+		if !expiresAt.IsZero() && !time.Now().Before(expiresAt) {
+			reason = "token expired"
+		} else if _, err := manager.parseToken(token); err != nil {
+			reason = "token revoked"
+		}
+		if reason != "" {
+			log.Printf("Соединение пользователя %d для уведомлений закрыто: %s", client.userID, reason)
+			closeConn(reason)
+			return
+		}
+	}
+}
+
+// authorizeSubscription проверяет, что пользователь может получать обновления канала:
+// клиент - только своих заказов, курьер - своих заказов и своего канала курьера,
+// зоны доставки доступны только диспетчерам и администраторам
+func (manager *WebSocketManager) authorizeSubscription(client *wsClient, channel string, id int) error {
+	o := manager.ownership
+	if !o.Scoped(client.request) {
+		return nil
+	}
+
+	switch channel {
+	case ChannelOrder:
+		delivery, err := o.deliveries.Get(id)
+		if err != nil {
+			// Несуществующий заказ неотличим от чужого
+			return ErrAccessDenied
+		}
+		return o.CheckDelivery(client.request, delivery)
+	case ChannelCourier:
+		return o.CheckCourier(client.request, id)
+	}
+	return ErrAccessDenied
+}
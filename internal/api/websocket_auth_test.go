@@ -0,0 +1,261 @@
+package api
+
+import (
+	"delivery/internal/auth"
+	"delivery/internal/business/models"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+)
+
+// fakeTokens - токены, выпущенные в тесте. Отозванные токены считаются недействительными
+type fakeTokens struct {
+	mu      sync.Mutex
+	claims  map[string]*auth.Claims
+	revoked map[string]bool
+}
+
+func newFakeTokens() *fakeTokens {
+	return &fakeTokens{claims: map[string]*auth.Claims{}, revoked: map[string]bool{}}
+}
+
+func (f *fakeTokens) issue(token string, userID int, role string, expiresAt time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.claims[token] = &auth.Claims{
+		UserID:           userID,
+		Role:             role,
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: &jwt.NumericDate{Time: expiresAt}}, // Без округления до секунд
+	}
+}
+
+func (f *fakeTokens) revoke(token string) {
+	f.mu.Lock()
+	f.revoked[token] = true
+	f.mu.Unlock()
+}
+
+func (f *fakeTokens) ParseToken(token string) (*auth.Claims, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	claims, ok := f.claims[token]
+	if !ok || f.revoked[token] {
+		return nil, errors.New("недействительный токен")
+	}
+	return claims, nil
+}
+
+// newAuthWebSocketServer запускает менеджер с проверкой токенов и доступа по newTestOwnership
+func newAuthWebSocketServer(t *testing.T) (*WebSocketManager, *fakeTokens, string) {
+	t.Helper()
+	ownership, _, _ := newTestOwnership()
+	tokens := newFakeTokens()
+	expiresAt := time.Now().Add(time.Hour)
+	tokens.issue("client.token.sig", 1, models.UserRoleClient, expiresAt)
+	tokens.issue("courier.token.sig", 3, models.UserRoleCourier, expiresAt)
+	tokens.issue("dispatcher.token.sig", 5, models.UserRoleDispatcher, expiresAt)
+
+	// Токены перепроверяются чаще, чтобы тест отзыва не ждал
+	manager := NewWebSocketManager().WithAuth(tokens, ownership)
+	manager.recheckInterval = 20 * time.Millisecond
+	go manager.Run()
+
+	server := httptest.NewServer(http.HandlerFunc(manager.WebSocketHandler))
+	t.Cleanup(server.Close)
+	return manager, tokens, "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+// dialWithToken подключается с токеном в заголовке Authorization
+func dialWithToken(t *testing.T, url, token string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Authorization": {"Bearer " + token}})
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// expectClosed проверяет, что сервер закрыл соединение с указанной причиной
+func expectClosed(t *testing.T, conn *websocket.Conn, reason string) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err := conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.ClosePolicyViolation || closeErr.Text != reason {
+		t.Errorf("ReadMessage() error = %v, want закрытие %q", err, reason)
+	}
+}
+
+func TestTokenFromRequest(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		header http.Header
+		want   string
+	}{
+		{"заголовок Authorization", "/ws/orders", http.Header{"Authorization": {"Bearer abc"}}, "abc"},
+		{"параметр запроса", "/ws/orders?token=abc", nil, "abc"},
+		{"подпротокол", "/ws/orders", http.Header{"Sec-Websocket-Protocol": {"bearer, abc"}}, "abc"},
+		{"подпротокол без токена", "/ws/orders", http.Header{"Sec-Websocket-Protocol": {"bearer"}}, ""},
+		{"без токена", "/ws/orders", nil, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		for k, v := range tt.header {
+			req.Header[k] = v
+		}
+		if got := tokenFromRequest(req); got != tt.want {
+			t.Errorf("%s: tokenFromRequest() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		origin  string
+		want    bool
+	}{
+		{"без Origin", nil, "", true},
+		{"тот же хост", nil, "http://example.com", true},
+		{"чужой источник", nil, "http://evil.com", false},
+		{"источник из списка", []string{"http://localhost:3000/"}, "http://localhost:3000", true},
+		{"источник не из списка", []string{"http://localhost:3000"}, "http://evil.com", false},
+		{"любой источник", []string{"*"}, "http://evil.com", true},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/ws/orders", nil)
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		if got := checkOrigin(tt.allowed)(req); got != tt.want {
+			t.Errorf("%s: checkOrigin() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWebSocketManager_RequiresToken(t *testing.T) {
+	_, _, url := newAuthWebSocketServer(t)
+
+	for name, target := range map[string]string{"без токена": url, "неизвестный токен": url + "?token=unknown.token.sig"} {
+		_, resp, err := websocket.DefaultDialer.Dial(target, nil)
+		if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%s: Dial() error = %v, want 401", name, err)
+		}
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(url+"?token=client.token.sig", nil)
+	if err != nil {
+		t.Fatalf("токен в параметре: Dial() error = %v", err)
+	}
+	conn.Close()
+
+	conn, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"Sec-Websocket-Protocol": {"bearer, client.token.sig"}})
+	if err != nil {
+		t.Fatalf("токен в подпротоколе: Dial() error = %v", err)
+	}
+	defer conn.Close()
+	if got := resp.Header.Get("Sec-Websocket-Protocol"); got != wsBearerProtocol {
+		t.Errorf("выбранный подпротокол = %q, want %q", got, wsBearerProtocol)
+	}
+}
+
+func TestWebSocketManager_AuthorizeSubscription(t *testing.T) {
+	_, _, url := newAuthWebSocketServer(t)
+
+	tests := []struct {
+		token   string
+		channel string
+		id      int
+		want    string
+	}{
+		{"client.token.sig", ChannelOrder, 1000, "SUBSCRIBED"}, // Доставка посылки клиента
+		{"client.token.sig", ChannelOrder, 2000, "ERROR"},      // Несуществующая доставка
+		{"client.token.sig", ChannelCourier, 30, "ERROR"},
+		{"client.token.sig", ChannelZone, 1, "ERROR"},
+		{"courier.token.sig", ChannelOrder, 1000, "SUBSCRIBED"}, // Назначенная курьеру доставка
+		{"courier.token.sig", ChannelCourier, 30, "SUBSCRIBED"},
+		{"courier.token.sig", ChannelCourier, 40, "ERROR"},
+		{"courier.token.sig", ChannelZone, 1, "ERROR"},
+		{"dispatcher.token.sig", ChannelCourier, 40, "SUBSCRIBED"},
+		{"dispatcher.token.sig", ChannelZone, 1, "SUBSCRIBED"},
+	}
+	conns := map[string]*websocket.Conn{}
+	for _, tt := range tests {
+		conn, ok := conns[tt.token]
+		if !ok {
+			conn = dialWithToken(t, url, tt.token)
+			conns[tt.token] = conn
+		}
+
+		message := `{"action":"subscribe","channel":"` + tt.channel + `","id":` + strconv.Itoa(tt.id) + `}`
+		if reply := control(t, conn, message); reply.Type != tt.want {
+			t.Errorf("%s: подписка на %s:%d = %+v, want %s", tt.token, tt.channel, tt.id, reply, tt.want)
+		}
+	}
+}
+
+func TestWebSocketManager_ClosesOnRevokedToken(t *testing.T) {
+	_, tokens, url := newAuthWebSocketServer(t)
+	conn := dialWithToken(t, url, "client.token.sig")
+
+	tokens.revoke("client.token.sig")
+	expectClosed(t, conn, "token revoked")
+}
+
+func TestWebSocketManager_TokenExpiry(t *testing.T) {
+	_, tokens, url := newAuthWebSocketServer(t)
+	tokens.issue("short.token.sig", 1, models.UserRoleClient, time.Now().Add(200*time.Millisecond))
+	tokens.issue("renewed.token.sig", 1, models.UserRoleClient, time.Now().Add(time.Hour))
+
+	// Соединение, токен которого не обновили, закрывается по истечении токена
+	expiring := dialWithToken(t, url, "short.token.sig")
+
+	// Токен можно заменить новым токеном того же пользователя, но не токеном другого пользователя
+	renewed := dialWithToken(t, url, "short.token.sig")
+	if reply := control(t, renewed, `{"action":"authenticate","token":"courier.token.sig"}`); reply.Type != "ERROR" {
+		t.Errorf("токен другого пользователя: ответ = %+v, want ERROR", reply)
+	}
+	if reply := control(t, renewed, `{"action":"authenticate","token":"renewed.token.sig"}`); reply.Type != "AUTHENTICATED" {
+		t.Fatalf("обновление токена: ответ = %+v", reply)
+	}
+
+	expectClosed(t, expiring, "token expired")
+
+	// Соединение с обновленным токеном продолжает работать
+	if reply := control(t, renewed, `{"action":"subscribe","channel":"order","id":1000}`); reply.Type != "SUBSCRIBED" {
+		t.Errorf("подписка после обновления токена: ответ = %+v", reply)
+	}
+}
+
+func TestWebSocketManager_MalformedToken(t *testing.T) {
+	_, _, url := newAuthWebSocketServer(t)
+
+	for name, target := range map[string]string{"короткий токен": url + "?token=x", "не JWT": url + "?token=a.b"} {
+		_, resp, err := websocket.DefaultDialer.Dial(target, nil)
+		if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%s: Dial() error = %v, want 401", name, err)
+		}
+	}
+
+	// Короткий или пустой токен в управляющем сообщении отклоняется, соединение продолжает работать
+	conn := dialWithToken(t, url, "client.token.sig")
+	for _, token := range []string{"x", "", "a..b"} {
+		if reply := control(t, conn, `{"action":"authenticate","token":"`+token+`"}`); reply.Type != "ERROR" {
+			t.Errorf("токен %q: ответ = %+v, want ERROR", token, reply)
+		}
+	}
+	if reply := control(t, conn, `{"action":"subscribe","channel":"order","id":1000}`); reply.Type != "SUBSCRIBED" {
+		t.Errorf("подписка после отклоненного токена: ответ = %+v", reply)
+	}
+}
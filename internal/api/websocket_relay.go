@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Broker пересылает сообщения между экземплярами приложения (Redis Pub/Sub)
+// и выдает общие для них номера сообщений
+type Broker interface {
+	Publish(ctx context.Context, channel string, message []byte) error
+	Subscribe(ctx context.Context, channel string) (<-chan []byte, error)
+	Incr(ctx context.Context, key string) (int64, error)
+}
+
+// wsRelayChannel - канал брокера, через который экземпляры обмениваются уведомлениями WebSocket
+const wsRelayChannel = "ws:orders"
+
+// publishTimeout ограничивает ожидание брокера, чтобы недоступный Redis не задерживал изменение заказа
+const publishTimeout = 2 * time.Second
+
+// relayMessage - уведомление в канале брокера: сообщение для клиентов и каналы подписки
+type relayMessage struct {
+	Seq    int64           `json:"seq,omitempty"`
+	Topics []string        `json:"topics"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// WithBroker включает пересылку уведомлений через брокер: сообщение получают подписчики
+// на всех экземплярах приложения. Пересылку запускает StartRelay
+func (manager *WebSocketManager) WithBroker(broker Broker) *WebSocketManager {
+	manager.broker = broker
+	return manager
+}
+
+// StartRelay подписывается на канал брокера и доставляет полученные уведомления клиентам
+// этого экземпляра. Если подписаться не удалось, уведомления доставляются только локально
+func (manager *WebSocketManager) StartRelay(ctx context.Context) error {
+	if manager.broker == nil {
+		return nil
+	}
+
+	messages, err := manager.broker.Subscribe(ctx, wsRelayChannel)
+	if err != nil {
+		manager.broker = nil
+		return fmt.Errorf("ошибка подписки на уведомления других экземпляров: %w", err)
+	}
+
+	go func() {
+		for data := range messages {
+			var msg relayMessage
+			if err := json.Unmarshal(data, &msg); err != nil {
+				log.Printf("Некорректное уведомление в канале %s: %v", wsRelayChannel, err)
+				continue
+			}
+			manager.broadcast <- outgoingMessage{seq: msg.Seq, topics: msg.Topics, data: msg.Data}
+		}
+	}()
+	return nil
+}
+
+// publish отправляет сообщение подписчикам. С брокером сообщение доставляется через его канал,
+// в том числе клиентам этого экземпляра; если брокер недоступен - только клиентам этого экземпляра
+func (manager *WebSocketManager) publish(message outgoingMessage) {
+	if manager.broker != nil {
+		data, err := json.Marshal(relayMessage{Seq: message.seq, Topics: message.topics, Data: message.data})
+		if err == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+			err = manager.broker.Publish(ctx, wsRelayChannel, data)
+			cancel()
+		}
+		if err == nil {
+			return
+		}
+		log.Printf("Ошибка при публикации уведомления в %s, доставляем только локально: %v", wsRelayChannel, err)
+	}
+
+	manager.broadcast <- message
+}
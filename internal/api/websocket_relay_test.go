@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// memoryBroker - брокер в памяти, общий для нескольких менеджеров, как Redis для экземпляров приложения
+type memoryBroker struct {
+	mu           sync.Mutex
+	subscribers  []chan []byte
+	seq          int64
+	publishErr   error
+	subscribeErr error
+}
+
+func (b *memoryBroker) Publish(ctx context.Context, channel string, message []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.publishErr != nil {
+		return b.publishErr
+	}
+	for _, s := range b.subscribers {
+		s <- message
+	}
+	return nil
+}
+
+func (b *memoryBroker) Subscribe(ctx context.Context, channel string) (<-chan []byte, error) {
+	if b.subscribeErr != nil {
+		return nil, b.subscribeErr
+	}
+	messages := make(chan []byte, 10)
+	b.mu.Lock()
+	b.subscribers = append(b.subscribers, messages)
+	b.mu.Unlock()
+	return messages, nil
+}
+
+func (b *memoryBroker) Incr(ctx context.Context, key string) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq++
+	return b.seq, nil
+}
+
+func (b *memoryBroker) failPublish(err error) {
+	b.mu.Lock()
+	b.publishErr = err
+	b.mu.Unlock()
+}
+
+// startReplica запускает менеджер, пересылающий уведомления через брокер
+func startReplica(t *testing.T, broker Broker) *WebSocketManager {
+	t.Helper()
+	manager := NewWebSocketManager().WithBroker(broker)
+	go manager.Run()
+	if err := manager.StartRelay(context.Background()); err != nil {
+		t.Fatalf("StartRelay() error = %v", err)
+	}
+	return manager
+}
+
+func TestWebSocketManager_RelaysBetweenReplicas(t *testing.T) {
+	broker := &memoryBroker{}
+	replicaA := startReplica(t, broker)
+	replicaB := startReplica(t, broker)
+
+	connA := dialWebSocket(t, replicaA)
+	connB := dialWebSocket(t, replicaB)
+	control(t, connA, `{"action":"subscribe","channel":"order","id":5}`)
+	control(t, connB, `{"action":"subscribe","channel":"order","id":5}`)
+
+	// Обновление обработано экземпляром A, но доходит до подписчиков обоих экземпляров ровно один раз
+	replicaA.BroadcastOrderStatusUpdate(OrderTarget{OrderID: 5}, "in_transit")
+
+	for name, conn := range map[string]*websocket.Conn{"A": connA, "B": connB} {
+		var update OrderStatusUpdate
+		if err := conn.ReadJSON(&update); err != nil {
+			t.Fatalf("экземпляр %s: ReadJSON() error = %v", name, err)
+		}
+		// Номер общий для экземпляров, поэтому клиент может продолжить с любого из них
+		if update.OrderID != "5" || update.NewStatus != "in_transit" || update.Seq != 1 {
+			t.Errorf("экземпляр %s: получено %+v", name, update)
+		}
+	}
+	expectNoMessage(t, connA)
+}
+
+func TestWebSocketManager_LocalFallback(t *testing.T) {
+	broker := &memoryBroker{}
+	manager := startReplica(t, broker)
+	conn := dialWebSocket(t, manager)
+	control(t, conn, `{"action":"subscribe","channel":"courier","id":3}`)
+
+	// Брокер недоступен: уведомление доставляется клиентам этого экземпляра
+	broker.failPublish(errors.New("redis down"))
+	eta := time.Date(2026, 5, 1, 12, 30, 0, 0, time.UTC)
+	manager.BroadcastETAUpdate(OrderTarget{OrderID: 7, CourierID: 3}, eta)
+
+	var update ETAUpdate
+	if err := conn.ReadJSON(&update); err != nil {
+		t.Fatalf("ReadJSON() error = %v", err)
+	}
+	if update.OrderID != "7" || update.ETA != eta.UnixMilli() {
+		t.Errorf("получено %+v", update)
+	}
+}
+
+func TestWebSocketManager_StartRelayFailure(t *testing.T) {
+	manager := NewWebSocketManager().WithBroker(&memoryBroker{subscribeErr: errors.New("redis down")})
+	go manager.Run()
+
+	if err := manager.StartRelay(context.Background()); err == nil {
+		t.Fatal("StartRelay() error = nil, want ошибку подписки")
+	}
+
+	// Без подписки на брокер уведомления доставляются локально, а не теряются в канале брокера
+	conn := dialWebSocket(t, manager)
+	control(t, conn, `{"action":"subscribe","channel":"order","id":5}`)
+	manager.BroadcastOrderStatusUpdate(OrderTarget{OrderID: 5}, "delivered")
+
+	var update OrderStatusUpdate
+	if err := conn.ReadJSON(&update); err != nil || update.NewStatus != "delivered" {
+		t.Errorf("ReadJSON() = %+v, %v", update, err)
+	}
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync/atomic"
+)
+
+// historySize - сколько последних сообщений хранится для повтора после переподключения
+const historySize = 1000
+
+// wsSequenceKey - счетчик номеров сообщений в брокере, общий для всех экземпляров
+const wsSequenceKey = "ws:orders:seq"
+
+// resumeRequest - запрос клиента на повтор сообщений с номером больше afterSeq
+type resumeRequest struct {
+	client   *wsClient
+	afterSeq int64
+}
+
+// nextSequence возвращает номер следующего сообщения. С брокером номера общие для всех экземпляров,
+// поэтому клиент может продолжить с любого из них. Если брокер недоступен, сообщение остается без номера
+func (manager *WebSocketManager) nextSequence() int64 {
+	if manager.broker == nil {
+		return atomic.AddInt64(&manager.localSeq, 1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+	seq, err := manager.broker.Incr(ctx, wsSequenceKey)
+	if err != nil {
+		log.Printf("Ошибка при получении номера уведомления: %v", err)
+		return 0
+	}
+	return seq
+}
+
+// remember сохраняет сообщение для повтора, вытесняя самые старые. Вызывается только из Run
+func (manager *WebSocketManager) remember(message outgoingMessage) {
+	if message.seq == 0 {
+		return
+	}
+	if message.seq > manager.lastSeq {
+		manager.lastSeq = message.seq
+	}
+
+	if len(manager.history) >= manager.historySize {
+		n := copy(manager.history, manager.history[len(manager.history)-manager.historySize+1:])
+		manager.history = manager.history[:n]
+	}
+	manager.history = append(manager.history, message)
+}
+
+// replay повторно отправляет клиенту сохраненные сообщения его подписок с номером больше afterSeq,
+// а затем ответ RESUMED. Повтор не должен переполнить очередь клиента, иначе клиент будет отключен
+// как медленный, поэтому отправляются только последние сообщения, которые в ней помещаются,
+// а ответ сообщает, что часть пропущена. Вызывается из Run под clientsMu
+func (manager *WebSocketManager) replay(request resumeRequest) {
+	client := request.client
+	if !manager.clients[client] {
+		return
+	}
+
+	var missed []outgoingMessage
+	for _, message := range manager.history {
+		if message.seq > request.afterSeq && client.subscribed(message.topics) {
+			missed = append(missed, message)
+		}
+	}
+
+	// Одно место в очереди остается для ответа RESUMED
+	free := cap(client.send) - len(client.send) - 1
+	if free < 0 {
+		free = 0
+	}
+	overflow := len(missed) > free
+	if overflow {
+		missed = missed[len(missed)-free:]
+	}
+	for _, message := range missed {
+		manager.enqueue(client, message)
+	}
+
+	// Сообщения не поместились в очередь, старше сохраненных вытеснены, а номер больше известного
+	// остался от прежнего запуска сервера: во всех случаях клиенту нужно заново запросить состояние заказов
+	oldest := manager.lastSeq + 1
+	if len(manager.history) > 0 {
+		oldest = manager.history[0].seq
+	}
+	reply := controlReply{
+		Type:      "RESUMED",
+		Seq:       manager.lastSeq,
+		Replayed:  len(missed),
+		Truncated: overflow || request.afterSeq+1 < oldest || request.afterSeq > manager.lastSeq,
+	}
+
+	data, err := json.Marshal(reply)
+	if err != nil {
+		log.Printf("Ошибка при сериализации ответа: %v", err)
+		return
+	}
+	manager.enqueue(client, outgoingMessage{data: data})
+}
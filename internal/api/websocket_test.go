@@ -0,0 +1,320 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// dialWebSocket подключает клиента к менеджеру через тестовый сервер
+func dialWebSocket(t *testing.T, manager *WebSocketManager) *websocket.Conn {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(manager.WebSocketHandler))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// control отправляет управляющее сообщение и возвращает ответ на него
+func control(t *testing.T, conn *websocket.Conn, message string) controlReply {
+	t.Helper()
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(message)); err != nil {
+		t.Fatalf("WriteMessage() error = %v", err)
+	}
+	var reply controlReply
+	if err := conn.ReadJSON(&reply); err != nil {
+		t.Fatalf("ReadJSON() error = %v", err)
+	}
+	return reply
+}
+
+// expectNoMessage проверяет, что клиенту ничего не пришло
+func expectNoMessage(t *testing.T, conn *websocket.Conn) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, message, err := conn.ReadMessage(); err == nil {
+		t.Errorf("получено лишнее сообщение %s", message)
+	}
+}
+
+func TestOrderTarget_Topics(t *testing.T) {
+	got := OrderTarget{OrderID: 5, CourierID: 3, ZoneID: 2}.topics()
+	want := []string{"order:5", "courier:3", "zone:2"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("topics() = %v, want %v", got, want)
+	}
+
+	if got := (OrderTarget{OrderID: 5}).topics(); len(got) != 1 || got[0] != "order:5" {
+		t.Errorf("topics() без курьера и зоны = %v", got)
+	}
+}
+
+func TestWebSocketManager_RoutesToSubscribers(t *testing.T) {
+	manager := NewWebSocketManager()
+	go manager.Run()
+
+	orderClient := dialWebSocket(t, manager)
+	zoneClient := dialWebSocket(t, manager)
+	otherClient := dialWebSocket(t, manager)
+
+	if reply := control(t, orderClient, `{"action":"subscribe","channel":"order","id":5}`); reply.Type != "SUBSCRIBED" || reply.Channel != ChannelOrder || reply.ID != 5 {
+		t.Fatalf("ответ на подписку = %+v", reply)
+	}
+	control(t, zoneClient, `{"action":"subscribe","channel":"zone","id":2}`)
+	control(t, otherClient, `{"action":"subscribe","channel":"order","id":6}`)
+
+	manager.BroadcastOrderStatusUpdate(OrderTarget{OrderID: 5, CourierID: 3, ZoneID: 2}, "in_transit")
+
+	for name, conn := range map[string]*websocket.Conn{"заказ": orderClient, "зона": zoneClient} {
+		var update OrderStatusUpdate
+		if err := conn.ReadJSON(&update); err != nil {
+			t.Fatalf("%s: ReadJSON() error = %v", name, err)
+		}
+		if update.OrderID != "5" || update.NewStatus != "in_transit" {
+			t.Errorf("%s: получено %+v", name, update)
+		}
+	}
+	expectNoMessage(t, otherClient)
+}
+
+func TestWebSocketManager_Unsubscribe(t *testing.T) {
+	manager := NewWebSocketManager()
+	go manager.Run()
+	conn := dialWebSocket(t, manager)
+
+	control(t, conn, `{"action":"subscribe","channel":"courier","id":3}`)
+	if reply := control(t, conn, `{"action":"unsubscribe","channel":"courier","id":3}`); reply.Type != "UNSUBSCRIBED" {
+		t.Fatalf("ответ на отписку = %+v", reply)
+	}
+
+	manager.BroadcastETAUpdate(OrderTarget{OrderID: 5, CourierID: 3}, time.Now())
+	expectNoMessage(t, conn)
+}
+
+func TestWebSocketManager_InvalidControl(t *testing.T) {
+	manager := NewWebSocketManager()
+	go manager.Run()
+	conn := dialWebSocket(t, manager)
+
+	tests := map[string]string{
+		"не JSON":              `subscribe order 5`,
+		"неизвестный канал":    `{"action":"subscribe","channel":"customer","id":5}`,
+		"неизвестное действие": `{"action":"listen","channel":"order","id":5}`,
+		"некорректный ID":      `{"action":"subscribe","channel":"order","id":0}`,
+	}
+	for name, message := range tests {
+		if reply := control(t, conn, message); reply.Type != "ERROR" || reply.Error == "" {
+			t.Errorf("%s: ответ = %+v, want ERROR", name, reply)
+		}
+	}
+}
+
+func TestWebSocketManager_SubscriptionLimit(t *testing.T) {
+	manager := NewWebSocketManager()
+	client := &wsClient{topics: make(map[string]bool)}
+	for i := 1; i <= maxSubscriptions; i++ {
+		client.topics[topic(ChannelOrder, i)] = true
+	}
+
+	if reply := manager.handleControl(client, []byte(`{"action":"subscribe","channel":"order","id":1000}`)); reply.Type != "ERROR" {
+		t.Errorf("подписка сверх лимита: ответ = %+v", reply)
+	}
+	// Повторная подписка на тот же канал не увеличивает число подписок
+	if reply := manager.handleControl(client, []byte(`{"action":"subscribe","channel":"order","id":1}`)); reply.Type != "SUBSCRIBED" {
+		t.Errorf("повторная подписка: ответ = %+v", reply)
+	}
+}
+
+// waitFor ждет выполнения условия, которое меняется в горутине Run
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("условие не выполнилось за секунду")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWebSocketManager_DropsSlowClient(t *testing.T) {
+	manager := NewWebSocketManager()
+	go manager.Run()
+
+	// Клиент без writePump не забирает сообщения из очереди
+	slow := &wsClient{topics: map[string]bool{"order:5": true}, send: make(chan outgoingMessage, 1)}
+	manager.register <- slow
+
+	manager.BroadcastOrderStatusUpdate(OrderTarget{OrderID: 5}, "picked_up")
+	manager.BroadcastOrderStatusUpdate(OrderTarget{OrderID: 5}, "in_transit")
+	waitFor(t, func() bool { return manager.GetActiveConnectionsCount() == 0 })
+
+	if _, ok := <-slow.send; !ok {
+		t.Fatal("первое сообщение не поставлено в очередь")
+	}
+	if _, ok := <-slow.send; ok {
+		t.Fatal("очередь медленного клиента не закрыта")
+	}
+	if slow.closeCode != websocket.CloseTryAgainLater {
+		t.Errorf("код закрытия = %d, want %d", slow.closeCode, websocket.CloseTryAgainLater)
+	}
+}
+
+func TestWebSocketManager_Ping(t *testing.T) {
+	manager := NewWebSocketManager()
+	manager.pingPeriod = 20 * time.Millisecond
+	go manager.Run()
+	conn := dialWebSocket(t, manager)
+
+	pings := make(chan struct{}, 1)
+	conn.SetPingHandler(func(string) error {
+		select {
+		case pings <- struct{}{}:
+		default:
+		}
+		return nil
+	})
+	// Обработчик ping вызывается при чтении
+	go conn.ReadMessage()
+
+	select {
+	case <-pings:
+	case <-time.After(time.Second):
+		t.Fatal("сервер не отправил ping")
+	}
+}
+
+func TestWebSocketManager_Resume(t *testing.T) {
+	manager := NewWebSocketManager()
+	go manager.Run()
+
+	first := dialWebSocket(t, manager)
+	control(t, first, `{"action":"subscribe","channel":"order","id":5}`)
+	for _, status := range []string{"assigned", "picked_up", "in_transit"} {
+		manager.BroadcastOrderStatusUpdate(OrderTarget{OrderID: 5}, status)
+		manager.BroadcastOrderStatusUpdate(OrderTarget{OrderID: 6}, status)
+	}
+
+	var update OrderStatusUpdate
+	if err := first.ReadJSON(&update); err != nil || update.Seq != 1 {
+		t.Fatalf("первое сообщение = %+v, %v", update, err)
+	}
+
+	// Клиент переподключился, получив только первое сообщение: повторяются пропущенные сообщения его подписок
+	second := dialWebSocket(t, manager)
+	control(t, second, `{"action":"subscribe","channel":"order","id":5}`)
+	if err := second.WriteMessage(websocket.TextMessage, []byte(`{"action":"resume","seq":1}`)); err != nil {
+		t.Fatalf("WriteMessage() error = %v", err)
+	}
+
+	for _, want := range []OrderStatusUpdate{{Seq: 3, NewStatus: "picked_up"}, {Seq: 5, NewStatus: "in_transit"}} {
+		var got OrderStatusUpdate
+		if err := second.ReadJSON(&got); err != nil {
+			t.Fatalf("ReadJSON() error = %v", err)
+		}
+		if got.Seq != want.Seq || got.NewStatus != want.NewStatus || got.OrderID != "5" {
+			t.Errorf("повтор = %+v, want seq %d %s", got, want.Seq, want.NewStatus)
+		}
+	}
+
+	var reply controlReply
+	if err := second.ReadJSON(&reply); err != nil {
+		t.Fatalf("ReadJSON() error = %v", err)
+	}
+	if want := (controlReply{Type: "RESUMED", Seq: 6, Replayed: 2}); reply != want {
+		t.Errorf("ответ = %+v, want %+v", reply, want)
+	}
+}
+
+func TestWebSocketManager_ResumeTruncated(t *testing.T) {
+	manager := NewWebSocketManager()
+	manager.historySize = 2
+	go manager.Run()
+	conn := dialWebSocket(t, manager)
+	control(t, conn, `{"action":"subscribe","channel":"order","id":5}`)
+
+	for i := 0; i < 4; i++ {
+		manager.BroadcastOrderStatusUpdate(OrderTarget{OrderID: 5}, "in_transit")
+	}
+	for i := 0; i < 4; i++ {
+		conn.ReadMessage()
+	}
+
+	tests := []struct {
+		seq       int64
+		replayed  int
+		truncated bool
+	}{
+		{seq: 1, replayed: 2, truncated: true}, // Сообщение 2 вытеснено
+		{seq: 2, replayed: 2, truncated: false},
+		{seq: 4, replayed: 0, truncated: false},
+		{seq: 10, replayed: 0, truncated: true}, // Номер от прежнего запуска сервера
+	}
+	for _, tt := range tests {
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"action":"resume","seq":`+strconv.FormatInt(tt.seq, 10)+`}`))
+		for i := 0; i < tt.replayed; i++ {
+			conn.ReadMessage()
+		}
+		var reply controlReply
+		if err := conn.ReadJSON(&reply); err != nil {
+			t.Fatalf("ReadJSON() error = %v", err)
+		}
+		if reply.Type != "RESUMED" || reply.Replayed != tt.replayed || reply.Truncated != tt.truncated {
+			t.Errorf("resume после %d: ответ = %+v, want replayed %d truncated %v", tt.seq, reply, tt.replayed, tt.truncated)
+		}
+	}
+}
+
+func TestWebSocketManager_ResumeOverflow(t *testing.T) {
+	manager := NewWebSocketManager()
+	go manager.Run()
+
+	// Клиент пропустил больше сообщений, чем помещается в его очередь
+	missed := 3 * sendQueueSize
+	for i := 0; i < missed; i++ {
+		manager.BroadcastOrderStatusUpdate(OrderTarget{OrderID: 5}, "in_transit")
+	}
+
+	conn := dialWebSocket(t, manager)
+	control(t, conn, `{"action":"subscribe","channel":"order","id":5}`)
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"action":"resume","seq":0}`)); err != nil {
+		t.Fatalf("WriteMessage() error = %v", err)
+	}
+
+	// Повторяются последние сообщения, которые помещаются в очередь вместе с ответом
+	replayed := sendQueueSize - 1
+	for i := 0; i < replayed; i++ {
+		var update OrderStatusUpdate
+		if err := conn.ReadJSON(&update); err != nil {
+			t.Fatalf("ReadJSON() error = %v", err)
+		}
+		if want := int64(missed - replayed + 1 + i); update.Seq != want {
+			t.Fatalf("повтор seq = %d, want %d", update.Seq, want)
+		}
+	}
+
+	var reply controlReply
+	if err := conn.ReadJSON(&reply); err != nil {
+		t.Fatalf("ReadJSON() error = %v", err)
+	}
+	if want := (controlReply{Type: "RESUMED", Seq: int64(missed), Replayed: replayed, Truncated: true}); reply != want {
+		t.Errorf("ответ = %+v, want %+v", reply, want)
+	}
+
+	// Клиент не отключен и получает новые сообщения
+	manager.BroadcastOrderStatusUpdate(OrderTarget{OrderID: 5}, "delivered")
+	var update OrderStatusUpdate
+	if err := conn.ReadJSON(&update); err != nil || update.NewStatus != "delivered" {
+		t.Errorf("после повтора = %+v, %v", update, err)
+	}
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"delivery/internal/business/models"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// ZoneService - управление зонами доставки и привязкой к ним курьеров
+type ZoneService interface {
+	Create(zone *models.Zone) error
+	Get(id int) (*models.Zone, error)
+	List() ([]models.Zone, error)
+	Update(id int, zone *models.Zone) error
+	Delete(id int) error
+	SetCourierZones(courierID int, zoneIDs []int) error
+	CourierZones(courierID int) ([]int, error)
+}
+
+type ZoneHandler struct {
+	service   ZoneService
+	ownership *Ownership
+}
+
+func NewZoneHandler(service ZoneService) *ZoneHandler {
+	return &ZoneHandler{service: service}
+}
+
+// WithOwnership ограничивает курьеров просмотром собственных зон
+func (h *ZoneHandler) WithOwnership(ownership *Ownership) *ZoneHandler {
+	h.ownership = ownership
+	return h
+}
+
+// courierZones - зоны доставки, которые обслуживает курьер
+type courierZones struct {
+	CourierID int   `json:"courier_id"`
+	ZoneIDs   []int `json:"zone_ids"`
+}
+
+func writeZoneError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, models.ErrZoneNotFound), errors.Is(err, models.ErrCourierNotFound):
+		writeError(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, models.ErrZoneNameTaken):
+		writeError(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, models.ErrInvalidZone):
+		writeError(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("%s: %v", message, err)
+		writeError(w, message, http.StatusInternalServerError)
+	}
+}
+
+func (h *ZoneHandler) CreateZone(w http.ResponseWriter, r *http.Request) {
+	var zone models.Zone
+	if err := json.NewDecoder(r.Body).Decode(&zone); err != nil {
+		writeError(w, "Invalid data", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.Create(&zone); err != nil {
+		writeZoneError(w, err, "Failed to create zone")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(zone); err != nil {
+		log.Printf("Ошибка при кодировании ответа: %v", err)
+	}
+}
+
+func (h *ZoneHandler) ListZones(w http.ResponseWriter, r *http.Request) {
+	zones, err := h.service.List()
+	if err != nil {
+		writeZoneError(w, err, "Failed to get zones")
+		return
+	}
+
+	writeJSON(w, zones)
+}
+
+func (h *ZoneHandler) GetZone(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, "Invalid zone ID", http.StatusBadRequest)
+		return
+	}
+
+	zone, err := h.service.Get(id)
+	if err != nil {
+		writeZoneError(w, err, "Failed to get zone")
+		return
+	}
+
+	writeJSON(w, zone)
+}
+
+func (h *ZoneHandler) UpdateZone(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, "Invalid zone ID", http.StatusBadRequest)
+		return
+	}
+
+	var zone models.Zone
+	if err := json.NewDecoder(r.Body).Decode(&zone); err != nil {
+		writeError(w, "Invalid data", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.Update(id, &zone); err != nil {
+		writeZoneError(w, err, "Failed to update zone")
+		return
+	}
+
+	writeJSON(w, zone)
+}
+
+func (h *ZoneHandler) DeleteZone(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, "Invalid zone ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.Delete(id); err != nil {
+		writeZoneError(w, err, "Failed to delete zone")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetCourierZones возвращает зоны, которые обслуживает курьер
+func (h *ZoneHandler) GetCourierZones(w http.ResponseWriter, r *http.Request) {
+	courierID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, "Invalid courier ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.ownership.CheckCourier(r, courierID); err != nil {
+		writeAccessError(w, err)
+		return
+	}
+
+	zoneIDs, err := h.service.CourierZones(courierID)
+	if err != nil {
+		writeZoneError(w, err, "Failed to get courier zones")
+		return
+	}
+
+	writeJSON(w, courierZones{CourierID: courierID, ZoneIDs: zoneIDs})
+}
+
+// SetCourierZones заменяет список зон, которые обслуживает курьер
+func (h *ZoneHandler) SetCourierZones(w http.ResponseWriter, r *http.Request) {
+	courierID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, "Invalid courier ID", http.StatusBadRequest)
+		return
+	}
+
+	var input courierZones
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, "Invalid data", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.SetCourierZones(courierID, input.ZoneIDs); err != nil {
+		writeZoneError(w, err, "Failed to update courier zones")
+		return
+	}
+
+	log.Printf("[ZONES] Пользователь %d изменил зоны курьера %d: %v", callerFromRequest(r).userID, courierID, input.ZoneIDs)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"bytes"
+	"delivery/internal/business/models"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// fakeZoneService хранит привязку курьеров к зонам в памяти и возвращает заданную ошибку при изменении зон
+type fakeZoneService struct {
+	ZoneService
+	couriers map[int][]int
+	err      error
+}
+
+func (f *fakeZoneService) Create(zone *models.Zone) error {
+	if f.err != nil {
+		return f.err
+	}
+	zone.ID = 1
+	return nil
+}
+
+func (f *fakeZoneService) Get(id int) (*models.Zone, error) {
+	if id != 1 {
+		return nil, models.ErrZoneNotFound
+	}
+	return &models.Zone{ID: 1, Name: "Центр"}, nil
+}
+
+func (f *fakeZoneService) SetCourierZones(courierID int, zoneIDs []int) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.couriers[courierID] = zoneIDs
+	return nil
+}
+
+func (f *fakeZoneService) CourierZones(courierID int) ([]int, error) {
+	return f.couriers[courierID], nil
+}
+
+func TestZoneHandler_CreateZone(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{"Успешно", nil, http.StatusCreated},
+		{"Некорректные границы", models.ErrInvalidZone, http.StatusBadRequest},
+		{"Название занято", models.ErrZoneNameTaken, http.StatusConflict},
+		{"Ошибка хранилища", errors.New("connection refused"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewZoneHandler(&fakeZoneService{err: tt.err})
+
+			body := `{"name":"Центр","geometry":{"type":"Polygon","coordinates":[]}}`
+			rr := httptest.NewRecorder()
+			handler.CreateZone(rr, httptest.NewRequest("POST", "/zones", bytes.NewBufferString(body)))
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("CreateZone() status = %d, want %d", rr.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestZoneHandler_GetZone(t *testing.T) {
+	handler := NewZoneHandler(&fakeZoneService{})
+
+	for id, want := range map[string]int{"1": http.StatusOK, "2": http.StatusNotFound, "abc": http.StatusBadRequest} {
+		req := mux.SetURLVars(httptest.NewRequest("GET", "/zones/"+id, nil), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		handler.GetZone(rr, req)
+
+		if rr.Code != want {
+			t.Errorf("GetZone(%s) status = %d, want %d", id, rr.Code, want)
+		}
+	}
+}
+
+func TestZoneHandler_GetCourierZones(t *testing.T) {
+	ownership, _, _ := newTestOwnership()
+	service := &fakeZoneService{couriers: map[int][]int{30: {1, 2}, 40: {3}}}
+	handler := NewZoneHandler(service).WithOwnership(ownership)
+
+	tests := []struct {
+		name       string
+		userID     int
+		role       string
+		courierID  string
+		wantStatus int
+	}{
+		{"Свои зоны", 3, models.UserRoleCourier, "30", http.StatusOK},
+		{"Чужие зоны", 3, models.UserRoleCourier, "40", http.StatusForbidden},
+		{"Диспетчер", 1, models.UserRoleDispatcher, "40", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/couriers/"+tt.courierID+"/zones", nil)
+			req = withUser(mux.SetURLVars(req, map[string]string{"id": tt.courierID}), tt.userID, tt.role)
+			rr := httptest.NewRecorder()
+			handler.GetCourierZones(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("GetCourierZones() status = %d, want %d", rr.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK {
+				var got courierZones
+				if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+					t.Fatalf("ошибка разбора ответа: %v", err)
+				}
+				if len(got.ZoneIDs) != len(service.couriers[got.CourierID]) {
+					t.Errorf("GetCourierZones() = %+v", got)
+				}
+			}
+		})
+	}
+}
+
+func TestZoneHandler_SetCourierZones(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{"Успешно", nil, http.StatusNoContent},
+		{"Курьер не найден", models.ErrCourierNotFound, http.StatusNotFound},
+		{"Зона не найдена", models.ErrZoneNotFound, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &fakeZoneService{couriers: map[int][]int{}, err: tt.err}
+			handler := NewZoneHandler(service)
+
+			req := httptest.NewRequest("PUT", "/couriers/30/zones", bytes.NewBufferString(`{"zone_ids":[1,2]}`))
+			req = withUser(mux.SetURLVars(req, map[string]string{"id": "30"}), 1, models.UserRoleDispatcher)
+			rr := httptest.NewRecorder()
+			handler.SetCourierZones(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("SetCourierZones() status = %d, want %d", rr.Code, tt.wantStatus)
+			}
+			if tt.err == nil && len(service.couriers[30]) != 2 {
+				t.Errorf("SetCourierZones() сохранил %v", service.couriers[30])
+			}
+		})
+	}
+}
@@ -27,8 +27,9 @@ var disableTokenCleanup = false
 
 // Claims представляет данные, которые будут храниться в JWT токене
 type Claims struct {
-	UserID               int `json:"user_id"` // ID пользователя
-	jwt.RegisteredClaims     // Стандартные зарегистрированные поля JWT
+	UserID               int    `json:"user_id"`        // ID пользователя
+	Role                 string `json:"role,omitempty"` // Роль пользователя
+	jwt.RegisteredClaims        // Стандартные зарегистрированные поля JWT
 }
 
 // UserStoreInterface определяет интерфейс для хранилища пользователей
@@ -49,6 +50,8 @@ type UserStoreInterface interface {
 	DeleteExpiredRefreshTokens() error
 	// Получение всех refresh токенов пользователя
 	GetUserRefreshTokens(userID int) ([]models.RefreshToken, error)
+	// Изменение роли пользователя
+	UpdateUserRole(userID int, role string) error
 }
 
 // AuthService представляет сервис аутентификации
@@ -152,10 +155,26 @@ func (s *AuthService) RegisterUser(email, password string) error {
 
 // Генерация токенов для пользователя
 func (s *AuthService) GenerateTokens(userID int) (string, string, error) {
+	// Роль берем из БД, чтобы токен отражал актуальные права пользователя
+	user, err := s.store.GetUserByID(userID)
+	if err != nil {
+		return "", "", fmt.Errorf("ошибка при получении пользователя: %w", err)
+	}
+
+	return s.generateTokens(user.ID, user.Role)
+}
+
+// generateTokens создает пару токенов с ролью пользователя в claims
+func (s *AuthService) generateTokens(userID int, role string) (string, string, error) {
+	if role == "" {
+		role = models.UserRoleClient
+	}
+
 	// Генерация access токена
 	accessTokenExpiry := time.Now().Add(accessTokenTTL)
 	accessClaims := &Claims{
 		UserID: userID,
+		Role:   role,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(accessTokenExpiry),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -195,17 +214,21 @@ func (s *AuthService) LoginUser(email, password string) (string, string, error)
 	// Получение пользователя по email
 	user, err := s.store.GetUserByEmail(email)
 	if err != nil {
+		// Не раскрываем, существует ли пользователь с таким email
+		if errors.Is(err, ErrUserNotFound) {
+			return "", "", ErrInvalidCredentials
+		}
 		return "", "", fmt.Errorf("ошибка при аутентификации: %w", err)
 	}
 
 	// Проверка пароля
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
 	if err != nil {
-		return "", "", errors.New("неверный пароль")
+		return "", "", ErrInvalidCredentials
 	}
 
 	// Генерация токенов
-	accessToken, refreshToken, err := s.GenerateTokens(user.ID)
+	accessToken, refreshToken, err := s.generateTokens(user.ID, user.Role)
 	if err != nil {
 		return "", "", fmt.Errorf("ошибка при генерации токенов: %w", err)
 	}
@@ -221,9 +244,13 @@ func (s *AuthService) RefreshToken(refreshToken string) (string, string, error)
 		return "", "", fmt.Errorf("ошибка при получении refresh токена: %w", err)
 	}
 
-	// Удаляем старый refresh токен
+	// Удаляем старый refresh токен (ротация: каждый refresh токен одноразовый)
 	err = s.store.DeleteRefreshToken(refreshToken)
 	if err != nil {
+		if errors.Is(err, ErrTokenNotFound) {
+			// Токен уже был использован параллельным запросом
+			return "", "", fmt.Errorf("ошибка при получении refresh токена: %w", err)
+		}
 		log.Printf("Ошибка при удалении старого refresh токена: %v", err)
 		// Продолжаем выполнение, так как это не критическая ошибка
 	}
@@ -237,13 +264,29 @@ func (s *AuthService) RefreshToken(refreshToken string) (string, string, error)
 	return accessToken, newRefreshToken, nil
 }
 
-// Выход пользователя (логаут)
+// Выход пользователя (логаут). Refresh токен отзывается, только если он принадлежит владельцу
+// access токена: чужой токен считается ненайденным, чтобы не раскрывать, что он существует
 func (s *AuthService) Logout(accessToken, refreshToken string) error {
-	// Удаляем refresh токен из БД
-	err := s.store.DeleteRefreshToken(refreshToken)
+	claims, err := s.ParseToken(accessToken)
 	if err != nil {
-		log.Printf("Ошибка при удалении refresh токена: %v", err)
-		// Продолжаем выполнение, так как это не критическая ошибка
+		return fmt.Errorf("недействительный access токен: %w", err)
+	}
+
+	token, err := s.store.GetRefreshToken(refreshToken)
+	switch {
+	case errors.Is(err, ErrTokenExpired):
+		// Истекший токен хранилище уже удалило, остается отозвать access токен
+	case err != nil:
+		return err
+	case token.UserID != claims.UserID:
+		log.Printf("[LOGOUT_REJECTED] Пользователь %d пытался отозвать чужой refresh токен", claims.UserID)
+		return ErrTokenNotFound
+	default:
+		// Удаляем refresh токен из БД
+		if err := s.store.DeleteRefreshToken(refreshToken); err != nil {
+			log.Printf("Ошибка при удалении refresh токена: %v", err)
+			// Продолжаем выполнение, так как это не критическая ошибка
+		}
 	}
 
 	// Добавляем access токен в черный список, если доступен Redis
@@ -270,7 +313,7 @@ func (s *AuthService) Logout(accessToken, refreshToken string) error {
 
 			// Логируем блокировку токена с дополнительной информацией
 			log.Printf("[TOKEN_BLACKLIST] Токен %s добавлен в черный список на %v (не удалось распарсить токен: %v)",
-				accessToken[:10]+"...", accessTokenTTL, err)
+				tokenPrefix(accessToken), accessTokenTTL, err)
 			return nil
 		}
 
@@ -282,7 +325,7 @@ func (s *AuthService) Logout(accessToken, refreshToken string) error {
 			if ttl < 0 {
 				// Если токен уже истек, нет необходимости добавлять его в черный список
 				log.Printf("[TOKEN_EXPIRED] Токен %s уже истек, не добавляем в черный список",
-					accessToken[:10]+"...")
+					tokenPrefix(accessToken))
 				return nil
 			}
 
@@ -299,7 +342,7 @@ func (s *AuthService) Logout(accessToken, refreshToken string) error {
 
 			// Логируем блокировку токена с дополнительной информацией
 			log.Printf("[TOKEN_BLACKLIST] Токен %s для пользователя %d добавлен в черный список на %v (истекает %v)",
-				accessToken[:10]+"...", claims.UserID, ttl, expiresAt.Format(time.RFC3339))
+				tokenPrefix(accessToken), claims.UserID, ttl, expiresAt.Format(time.RFC3339))
 
 			// Получаем статистику черного списка
 			if stats, err := s.getBlacklistStats(ctx); err == nil {
@@ -341,6 +384,15 @@ func (s *AuthService) getBlacklistStats(ctx context.Context) (int64, error) {
 
 // ValidateToken проверяет валидность токена и возвращает ID пользователя
 func (s *AuthService) ValidateToken(tokenString string) (int, error) {
+	claims, err := s.ParseToken(tokenString)
+	if err != nil {
+		return 0, err
+	}
+	return claims.UserID, nil
+}
+
+// ParseToken проверяет валидность токена и возвращает его claims (ID и роль пользователя)
+func (s *AuthService) ParseToken(tokenString string) (*Claims, error) {
 	// Проверяем, находится ли токен в черном списке
 	if s.cacheClient != nil {
 		ctx := context.Background()
@@ -349,8 +401,8 @@ func (s *AuthService) ValidateToken(tokenString string) (int, error) {
 		if err == nil {
 			// Токен найден в черном списке
 			log.Printf("[TOKEN_REJECTED] Токен %s отклонен (найден в черном списке: %s)",
-				tokenString[:10]+"...", blacklistValue)
-			return 0, errors.New("токен отозван")
+				tokenPrefix(tokenString), blacklistValue)
+			return nil, errors.New("токен отозван")
 		}
 	}
 
@@ -365,19 +417,54 @@ func (s *AuthService) ValidateToken(tokenString string) (int, error) {
 
 	if err != nil {
 		log.Printf("[TOKEN_INVALID] Ошибка при проверке токена %s: %v",
-			tokenString[:10]+"...", err)
-		return 0, fmt.Errorf("ошибка при проверке токена: %w", err)
+			tokenPrefix(tokenString), err)
+		return nil, fmt.Errorf("ошибка при проверке токена: %w", err)
 	}
 
 	// Проверяем валидность токена
 	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		log.Printf("[TOKEN_VALID] Токен %s успешно проверен для пользователя %d",
-			tokenString[:10]+"...", claims.UserID)
-		return claims.UserID, nil
+		// Токены, выпущенные до появления ролей, считаем клиентскими
+		if claims.Role == "" {
+			claims.Role = models.UserRoleClient
+		}
+		log.Printf("[TOKEN_VALID] Токен %s успешно проверен для пользователя %d (роль %s)",
+			tokenPrefix(tokenString), claims.UserID, claims.Role)
+		return claims, nil
 	}
 
-	log.Printf("[TOKEN_INVALID] Токен %s недействителен", tokenString[:10]+"...")
-	return 0, errors.New("недействительный токен")
+	log.Printf("[TOKEN_INVALID] Токен %s недействителен", tokenPrefix(tokenString))
+	return nil, errors.New("недействительный токен")
+}
+
+// SetUserRole изменяет роль пользователя. Новая роль попадет в токены при следующем входе или refresh
+func (s *AuthService) SetUserRole(userID int, role string) error {
+	if !models.IsValidUserRole(role) {
+		return fmt.Errorf("%w: %s", ErrInvalidRole, role)
+	}
+
+	if err := s.store.UpdateUserRole(userID, role); err != nil {
+		return fmt.Errorf("ошибка при изменении роли пользователя: %w", err)
+	}
+
+	return nil
+}
+
+// EnsureAdmin назначает роль admin зарегистрированному пользователю с указанным email.
+// Так создается первый администратор: назначать роли через API может только администратор
+func (s *AuthService) EnsureAdmin(email string) error {
+	user, err := s.store.GetUserByEmail(email)
+	if err != nil {
+		return fmt.Errorf("ошибка при получении администратора %s: %w", email, err)
+	}
+	if user.Role == models.UserRoleAdmin {
+		return nil
+	}
+
+	if err := s.SetUserRole(user.ID, models.UserRoleAdmin); err != nil {
+		return err
+	}
+	log.Printf("[ADMIN_ASSIGNED] Пользователю %d (%s) назначена роль admin", user.ID, email)
+	return nil
 }
 
 // Close закрывает ресурсы, используемые сервисом
@@ -390,3 +477,12 @@ func (s *AuthService) Close() {
 		s.cleanupDone <- true
 	}
 }
+
+// tokenPrefix возвращает начало токена для журнала; короткий токен не выводится целиком
+func tokenPrefix(token string) string {
+	const visible = 10
+	if len(token) <= visible {
+		return "***"
+	}
+	return token[:visible] + "..."
+}
@@ -61,6 +61,11 @@ func (m *MockUserStore) DeleteExpiredRefreshTokens() error {
 	return args.Error(0)
 }
 
+func (m *MockUserStore) UpdateUserRole(userID int, role string) error {
+	args := m.Called(userID, role)
+	return args.Error(0)
+}
+
 func (m *MockUserStore) GetUserRefreshTokens(userID int) ([]models.RefreshToken, error) {
 	args := m.Called(userID)
 	return args.Get(0).([]models.RefreshToken), args.Error(1)
@@ -252,6 +257,9 @@ func TestRefreshToken(t *testing.T) {
 				// Настраиваем мок для удаления старого refresh токена
 				mockStore.On("DeleteRefreshToken", validToken).Return(nil)
 
+				// Настраиваем мок для получения актуальной роли пользователя
+				mockStore.On("GetUserByID", userID).Return(models.User{ID: userID, Role: models.UserRoleCourier}, nil)
+
 				// Настраиваем мок для сохранения нового refresh токена
 				mockStore.On("SaveRefreshToken", mock.AnythingOfType("models.RefreshToken")).Return(nil)
 			},
@@ -408,37 +416,65 @@ func TestLogout(t *testing.T) {
 	// Создаем тестовый refresh токен
 	refreshToken := "valid-refresh-token"
 
+	tokenColumns := []string{"user_id", "token", "expires_at", "created_at"}
+	expectOwner := func(userID int) {
+		mock.ExpectQuery(`SELECT user_id, token, expires_at, created_at FROM refresh_tokens WHERE token = \$1`).
+			WithArgs(refreshToken).
+			WillReturnRows(sqlmock.NewRows(tokenColumns).AddRow(userID, refreshToken, time.Now().Add(time.Hour), time.Now()))
+	}
+
 	tests := []struct {
 		name         string
 		token        string
 		refreshToken string
 		mock         func()
-		wantErr      bool
+		wantErr      error
 	}{
 		{
 			name:         "Valid tokens",
 			token:        tokenString,
 			refreshToken: refreshToken,
 			mock: func() {
+				expectOwner(1)
 				// Удаление refresh токена
 				mock.ExpectExec(`DELETE FROM refresh_tokens WHERE token = \$1`).
 					WithArgs(refreshToken).
 					WillReturnResult(sqlmock.NewResult(0, 1))
 			},
-			wantErr: false,
 		},
 		{
 			name:         "Error deleting refresh token",
 			token:        tokenString,
 			refreshToken: refreshToken,
 			mock: func() {
+				expectOwner(1)
 				// Ошибка при удалении refresh токена
 				mock.ExpectExec(`DELETE FROM refresh_tokens WHERE token = \$1`).
 					WithArgs(refreshToken).
 					WillReturnError(errors.New("database error"))
 			},
 			// Метод Logout не возвращает ошибку, даже если не удалось удалить refresh токен
-			wantErr: false,
+		},
+		{
+			name:         "Refresh token of another user",
+			token:        tokenString,
+			refreshToken: refreshToken,
+			mock: func() {
+				// Токен принадлежит пользователю 2 и не удаляется
+				expectOwner(2)
+			},
+			wantErr: ErrTokenNotFound,
+		},
+		{
+			name:         "Unknown refresh token",
+			token:        tokenString,
+			refreshToken: refreshToken,
+			mock: func() {
+				mock.ExpectQuery(`SELECT user_id, token, expires_at, created_at FROM refresh_tokens WHERE token = \$1`).
+					WithArgs(refreshToken).
+					WillReturnError(sql.ErrNoRows)
+			},
+			wantErr: ErrTokenNotFound,
 		},
 	}
 
@@ -449,7 +485,7 @@ func TestLogout(t *testing.T) {
 			}
 
 			err := authService.Logout(tt.token, tt.refreshToken)
-			if (err != nil) != tt.wantErr {
+			if !errors.Is(err, tt.wantErr) {
 				t.Errorf("Logout() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
@@ -460,3 +496,119 @@ func TestLogout(t *testing.T) {
 		})
 	}
 }
+
+func TestParseToken_Role(t *testing.T) {
+	mockStore := new(MockUserStore)
+	mockStore.On("SaveRefreshToken", mock.AnythingOfType("models.RefreshToken")).Return(nil)
+	authService := &AuthService{store: mockStore}
+
+	// Токен с ролью, выпущенный сервисом
+	accessToken, _, err := authService.generateTokens(1, models.UserRoleDispatcher)
+	if err != nil {
+		t.Fatalf("generateTokens() error = %v", err)
+	}
+
+	claims, err := authService.ParseToken(accessToken)
+	if err != nil {
+		t.Fatalf("ParseToken() error = %v", err)
+	}
+	if claims.UserID != 1 || claims.Role != models.UserRoleDispatcher {
+		t.Errorf("ParseToken() = %+v, want user 1 с ролью %s", claims, models.UserRoleDispatcher)
+	}
+
+	// Токен без роли (выпущен до введения ролей) считается клиентским
+	legacy := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": 2,
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	})
+	legacyString, _ := legacy.SignedString([]byte(jwtSecret))
+
+	claims, err = authService.ParseToken(legacyString)
+	if err != nil {
+		t.Fatalf("ParseToken() error = %v", err)
+	}
+	if claims.Role != models.UserRoleClient {
+		t.Errorf("ParseToken() role = %q, want %q", claims.Role, models.UserRoleClient)
+	}
+}
+
+func TestSetUserRole(t *testing.T) {
+	t.Run("Допустимая роль", func(t *testing.T) {
+		mockStore := new(MockUserStore)
+		mockStore.On("UpdateUserRole", 1, models.UserRoleCourier).Return(nil)
+		authService := &AuthService{store: mockStore}
+
+		if err := authService.SetUserRole(1, models.UserRoleCourier); err != nil {
+			t.Errorf("SetUserRole() error = %v", err)
+		}
+		mockStore.AssertExpectations(t)
+	})
+
+	t.Run("Недопустимая роль", func(t *testing.T) {
+		mockStore := new(MockUserStore)
+		authService := &AuthService{store: mockStore}
+
+		err := authService.SetUserRole(1, "superuser")
+		if !errors.Is(err, ErrInvalidRole) {
+			t.Errorf("SetUserRole() error = %v, want %v", err, ErrInvalidRole)
+		}
+		mockStore.AssertNotCalled(t, "UpdateUserRole", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Пользователь не найден", func(t *testing.T) {
+		mockStore := new(MockUserStore)
+		mockStore.On("UpdateUserRole", 42, models.UserRoleAdmin).Return(ErrUserNotFound)
+		authService := &AuthService{store: mockStore}
+
+		if err := authService.SetUserRole(42, models.UserRoleAdmin); !errors.Is(err, ErrUserNotFound) {
+			t.Errorf("SetUserRole() error = %v, want %v", err, ErrUserNotFound)
+		}
+	})
+}
+
+func TestEnsureAdmin(t *testing.T) {
+	t.Run("Пользователь получает роль admin", func(t *testing.T) {
+		mockStore := new(MockUserStore)
+		mockStore.On("GetUserByEmail", "admin@example.com").Return(models.User{ID: 1, Role: models.UserRoleClient}, nil)
+		mockStore.On("UpdateUserRole", 1, models.UserRoleAdmin).Return(nil)
+		authService := &AuthService{store: mockStore}
+
+		if err := authService.EnsureAdmin("admin@example.com"); err != nil {
+			t.Errorf("EnsureAdmin() error = %v", err)
+		}
+		mockStore.AssertExpectations(t)
+	})
+
+	t.Run("Пользователь уже администратор", func(t *testing.T) {
+		mockStore := new(MockUserStore)
+		mockStore.On("GetUserByEmail", "admin@example.com").Return(models.User{ID: 1, Role: models.UserRoleAdmin}, nil)
+		authService := &AuthService{store: mockStore}
+
+		if err := authService.EnsureAdmin("admin@example.com"); err != nil {
+			t.Errorf("EnsureAdmin() error = %v", err)
+		}
+		mockStore.AssertNotCalled(t, "UpdateUserRole", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Пользователь не зарегистрирован", func(t *testing.T) {
+		mockStore := new(MockUserStore)
+		mockStore.On("GetUserByEmail", "admin@example.com").Return(models.User{}, ErrUserNotFound)
+		authService := &AuthService{store: mockStore}
+
+		if err := authService.EnsureAdmin("admin@example.com"); !errors.Is(err, ErrUserNotFound) {
+			t.Errorf("EnsureAdmin() error = %v, want %v", err, ErrUserNotFound)
+		}
+		mockStore.AssertNotCalled(t, "UpdateUserRole", mock.Anything, mock.Anything)
+	})
+}
+
+func TestParseToken_ShortToken(t *testing.T) {
+	authService := &AuthService{store: new(MockUserStore)}
+
+	// Короткий токен не выводится в журнал целиком и не приводит к панике
+	for _, token := range []string{"", "x", "abc.def"} {
+		if _, err := authService.ParseToken(token); err == nil {
+			t.Errorf("ParseToken(%q) error = nil, want ошибку", token)
+		}
+	}
+}
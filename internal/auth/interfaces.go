@@ -22,6 +22,9 @@ type AuthServiceInterface interface {
 	// ValidateToken проверяет валидность токена и возвращает ID пользователя
 	ValidateToken(tokenString string) (int, error)
 
+	// ParseToken проверяет валидность токена и возвращает его claims (ID и роль пользователя)
+	ParseToken(tokenString string) (*Claims, error)
+
 	// WithCache добавляет клиент кэширования к сервису
 	WithCache(cacheClient *cache.RedisClient) *AuthService
 
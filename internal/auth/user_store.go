@@ -14,6 +14,8 @@ var (
 	ErrEmailAlreadyExists = errors.New("пользователь с таким email уже существует")
 	ErrTokenNotFound      = errors.New("токен не найден")
 	ErrTokenExpired       = errors.New("токен истек")
+	ErrInvalidCredentials = errors.New("неверный email или пароль")
+	ErrInvalidRole        = errors.New("недопустимая роль пользователя")
 )
 
 // UserStore представляет хранилище пользователей
@@ -35,7 +37,7 @@ func (s *UserStore) CreateUser(user models.User) (int, error) {
 		return 0, fmt.Errorf("ошибка при проверке существования пользователя: %w", err)
 	}
 	if exists {
-		return 0, errors.New("пользователь с таким email уже существует")
+		return 0, ErrEmailAlreadyExists
 	}
 
 	// Если роль не указана, устанавливаем значение по умолчанию
@@ -90,6 +92,24 @@ func (s *UserStore) GetUserByID(id int) (models.User, error) {
 	return user, nil
 }
 
+// UpdateUserRole изменяет роль пользователя
+func (s *UserStore) UpdateUserRole(userID int, role string) error {
+	result, err := s.db.Exec("UPDATE users SET role = $1, updated_at = $2 WHERE id = $3", role, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("ошибка при изменении роли пользователя: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("ошибка при получении количества обновленных строк: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
 // SaveRefreshToken сохраняет refresh токен в базе данных
 func (s *UserStore) SaveRefreshToken(token models.RefreshToken) error {
 	// Начинаем транзакцию
@@ -175,6 +195,7 @@ func (s *UserStore) DeleteRefreshToken(token string) error {
 
 	if rowsAffected == 0 {
 		log.Printf("Токен %s не найден при попытке удаления", token)
+		return ErrTokenNotFound
 	}
 
 	return nil
@@ -1,39 +1,76 @@
 package courier
 
 import (
+	"database/sql"
 	"delivery/internal/business/models"
+	"delivery/internal/outbox"
 	"fmt"
+	"time"
 )
 
 // Определяет интерфейс для хранилища курьеров
 type CourierStorer interface {
 	Add(courier models.Courier) (int, error)
+	AddTx(tx *sql.Tx, courier models.Courier) (int, error) // Без транзакции, если tx == nil
 	Get(id int) (models.Courier, error)
-	Update(courier models.Courier) error
+	GetForUpdate(tx *sql.Tx, id int) (models.Courier, error) // Без блокировки, если tx == nil
+	Update(courier models.Courier) error                     // Статус не изменяется
+	SetStatusTx(tx *sql.Tx, id int, status string) error     // Без транзакции, если tx == nil
 	Delete(id int) error
 	GetAll() ([]models.Courier, error)
 	GetAvailableCouriers() ([]models.Courier, error)
+	GetAvailableCouriersInZone(zoneID int) ([]models.Courier, error)
+	GetByUserID(userID int) (models.Courier, error)
+}
+
+// OpenDeliveryChecker сообщает, что у курьера есть незавершенная доставка. Реализуется хранилищем доставок
+type OpenDeliveryChecker interface {
+	HasOpenDeliveryTx(tx *sql.Tx, courierID int) (bool, error)
 }
 
 type CourierService struct {
-	store CourierStorer
+	store      CourierStorer
+	outbox     outbox.Writer
+	deliveries OpenDeliveryChecker
 }
 
 func NewCourierService(store CourierStorer) *CourierService {
 	return &CourierService{store: store}
 }
 
+// WithOutbox включает запись событий CourierRegistered и CourierStatusChanged в outbox
+// в одной транзакции с изменением курьера
+func (s *CourierService) WithOutbox(w outbox.Writer) *CourierService {
+	s.outbox = w
+	return s
+}
+
+// WithDeliveries запрещает возвращать в статус available занятого курьера, пока у него есть
+// незавершенная доставка: иначе он снова попадет в назначение и получит вторую доставку
+func (s *CourierService) WithDeliveries(deliveries OpenDeliveryChecker) *CourierService {
+	s.deliveries = deliveries
+	return s
+}
+
 func (s *CourierService) Create(courier *models.Courier) error {
 	if courier.Status == "" {
-		courier.Status = "available" // По умолчанию курьер доступен
+		courier.Status = models.CourierStatusAvailable // По умолчанию курьер доступен
 	}
 
-	id, err := s.store.Add(*courier)
-	if err != nil {
-		return err
-	}
-	courier.ID = id
-	return nil
+	return outbox.InTx(s.outbox, func(tx *sql.Tx) ([]models.OutboxEvent, error) {
+		id, err := s.store.AddTx(tx, *courier)
+		if err != nil {
+			return nil, err
+		}
+		courier.ID = id
+
+		return outbox.Event(models.TopicCouriers, id, models.EventCourierRegistered, models.CourierRegisteredEvent{
+			CourierID:   id,
+			UserID:      courier.UserID,
+			VehicleType: courier.VehicleType,
+			Status:      courier.Status,
+		})
+	})
 }
 
 func (s *CourierService) Get(id int) (*models.Courier, error) {
@@ -42,23 +79,36 @@ func (s *CourierService) Get(id int) (*models.Courier, error) {
 		return nil, fmt.Errorf("курьер не найден: %w", err)
 	}
 	return &models.Courier{
-		ID:        courier.ID,
-		Name:      courier.Name,
-		Phone:     courier.Phone,
-		Email:     courier.Email,
-		VehicleID: courier.VehicleID,
-		Status:    courier.Status,
+		ID:          courier.ID,
+		UserID:      courier.UserID,
+		Name:        courier.Name,
+		Phone:       courier.Phone,
+		Email:       courier.Email,
+		VehicleID:   courier.VehicleID,
+		VehicleType: courier.VehicleType,
+		Status:      courier.Status,
 	}, nil
 }
 
+// GetByUserID возвращает профиль курьера, привязанный к учетной записи пользователя
+func (s *CourierService) GetByUserID(userID int) (*models.Courier, error) {
+	courier, err := s.store.GetByUserID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("курьер не найден: %w", err)
+	}
+	return &courier, nil
+}
+
+// Update обновляет данные курьера. Статус не изменяется: он меняется через UpdateCourierStatus,
+// который проверяет статус и записывает событие CourierStatusChanged
 func (s *CourierService) Update(id int, courier *models.Courier) error {
 	cour := models.Courier{
-		ID:        id,
-		Name:      courier.Name,
-		Phone:     courier.Phone,
-		Email:     courier.Email,
-		VehicleID: courier.VehicleID,
-		Status:    courier.Status,
+		ID:          id,
+		Name:        courier.Name,
+		Phone:       courier.Phone,
+		Email:       courier.Email,
+		VehicleID:   courier.VehicleID,
+		VehicleType: courier.VehicleType,
 	}
 
 	return s.store.Update(cour)
@@ -86,17 +136,54 @@ func (s *CourierService) GetAvailableCouriers() ([]models.Courier, error) {
 	return couriers, nil
 }
 
-func (s *CourierService) UpdateCourierStatus(id int, status string) error {
-	courier, err := s.store.Get(id)
+// GetAvailableCouriersInZone возвращает доступных курьеров, обслуживающих зону доставки
+func (s *CourierService) GetAvailableCouriersInZone(zoneID int) ([]models.Courier, error) {
+	couriers, err := s.store.GetAvailableCouriersInZone(zoneID)
 	if err != nil {
-		return fmt.Errorf("курьер не найден: %w", err)
+		return nil, fmt.Errorf("ошибка при получении доступных курьеров: %w", err)
 	}
 
+	return couriers, nil
+}
+
+// UpdateCourierStatus изменяет статус курьера и записывает событие CourierStatusChanged.
+// Курьер читается и блокируется в транзакции изменения так же, как при назначении доставки,
+// поэтому прежний статус в событии не устаревает, а назначение не проходит между проверкой
+// незавершенных доставок и изменением статуса
+func (s *CourierService) UpdateCourierStatus(id int, status string) error {
 	// Проверка допустимых статусов
-	if status != "available" && status != "busy" && status != "offline" {
-		return fmt.Errorf("недопустимый статус курьера: %s", status)
+	if status != models.CourierStatusAvailable && status != models.CourierStatusBusy && status != models.CourierStatusOffline {
+		return fmt.Errorf("%w: %s", models.ErrInvalidCourierStatus, status)
 	}
 
-	courier.Status = status
-	return s.store.Update(courier)
+	return outbox.InTx(s.outbox, func(tx *sql.Tx) ([]models.OutboxEvent, error) {
+		courier, err := s.store.GetForUpdate(tx, id)
+		if err != nil {
+			return nil, fmt.Errorf("курьер не найден: %w", err)
+		}
+		if courier.Status == status {
+			return nil, nil
+		}
+		// Проверяется любой переход в available, в том числе из offline: иначе курьер с доставкой
+		// становился бы доступным через busy -> offline -> available и получал вторую доставку
+		if status == models.CourierStatusAvailable && s.deliveries != nil {
+			open, err := s.deliveries.HasOpenDeliveryTx(tx, id)
+			if err != nil {
+				return nil, err
+			}
+			if open {
+				return nil, fmt.Errorf("%w: курьер %d", models.ErrCourierHasOpenDelivery, id)
+			}
+		}
+
+		if err := s.store.SetStatusTx(tx, id, status); err != nil {
+			return nil, err
+		}
+		return outbox.Event(models.TopicCouriers, id, models.EventCourierStatusChanged, models.CourierStatusChangedEvent{
+			CourierID: id,
+			OldStatus: courier.Status,
+			NewStatus: status,
+			ChangedAt: time.Now().UTC(),
+		})
+	})
 }
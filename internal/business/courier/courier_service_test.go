@@ -1,14 +1,22 @@
 package courier
 
 import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
 	"delivery/internal/business/models"
+	"delivery/internal/outbox"
 	"errors"
+	"regexp"
 	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
 )
 
 // Мок-объект хранилища для тестирования
 type MockCourierStore struct {
 	couriers    map[int]models.Courier
+	zones       map[int][]int // Зоны, которые обслуживает курьер
 	nextID      int
 	shouldError bool
 }
@@ -30,6 +38,10 @@ func (m *MockCourierStore) Add(courier models.Courier) (int, error) {
 	return courier.ID, nil
 }
 
+func (m *MockCourierStore) AddTx(tx *sql.Tx, courier models.Courier) (int, error) {
+	return m.Add(courier)
+}
+
 func (m *MockCourierStore) Get(id int) (models.Courier, error) {
 	if m.shouldError {
 		return models.Courier{}, errors.New("ошибка при получении")
@@ -41,6 +53,13 @@ func (m *MockCourierStore) Get(id int) (models.Courier, error) {
 	return courier, nil
 }
 
+func (m *MockCourierStore) GetForUpdate(tx *sql.Tx, id int) (models.Courier, error) {
+	if _, exists := m.couriers[id]; !exists && !m.shouldError {
+		return models.Courier{}, models.ErrCourierNotFound
+	}
+	return m.Get(id)
+}
+
 func (m *MockCourierStore) Update(courier models.Courier) error {
 	if m.shouldError {
 		return errors.New("ошибка при обновлении")
@@ -49,10 +68,25 @@ func (m *MockCourierStore) Update(courier models.Courier) error {
 	if !exists {
 		return errors.New("курьер не найден")
 	}
+	// Как и CourierStore, статус не изменяется
+	courier.Status = m.couriers[courier.ID].Status
 	m.couriers[courier.ID] = courier
 	return nil
 }
 
+func (m *MockCourierStore) SetStatusTx(tx *sql.Tx, id int, status string) error {
+	if m.shouldError {
+		return errors.New("ошибка при обновлении статуса")
+	}
+	courier, exists := m.couriers[id]
+	if !exists {
+		return errors.New("курьер не найден")
+	}
+	courier.Status = status
+	m.couriers[id] = courier
+	return nil
+}
+
 func (m *MockCourierStore) Delete(id int) error {
 	if m.shouldError {
 		return errors.New("ошибка при удалении")
@@ -89,6 +123,34 @@ func (m *MockCourierStore) GetAvailableCouriers() ([]models.Courier, error) {
 	return couriers, nil
 }
 
+func (m *MockCourierStore) GetAvailableCouriersInZone(zoneID int) ([]models.Courier, error) {
+	available, err := m.GetAvailableCouriers()
+	if err != nil {
+		return nil, err
+	}
+	var couriers []models.Courier
+	for _, c := range available {
+		for _, id := range m.zones[c.ID] {
+			if id == zoneID {
+				couriers = append(couriers, c)
+			}
+		}
+	}
+	return couriers, nil
+}
+
+func (m *MockCourierStore) GetByUserID(userID int) (models.Courier, error) {
+	if m.shouldError {
+		return models.Courier{}, errors.New("ошибка при получении")
+	}
+	for _, c := range m.couriers {
+		if c.UserID == userID {
+			return c, nil
+		}
+	}
+	return models.Courier{}, errors.New("курьер не найден")
+}
+
 func TestCourierService_Create(t *testing.T) {
 	mockStore := NewMockCourierStore()
 	service := NewCourierService(mockStore)
@@ -122,3 +184,191 @@ func TestCourierService_Create(t *testing.T) {
 		t.Errorf("ожидалась ошибка, но её не было")
 	}
 }
+
+func TestCourierService_UpdateKeepsStatus(t *testing.T) {
+	mockStore := NewMockCourierStore()
+	service := NewCourierService(mockStore)
+
+	courier := &models.Courier{Name: "Тестовый Курьер", Phone: "+79001234567", Email: "test.courier@example.com"}
+	if err := service.Create(courier); err != nil {
+		t.Fatalf("Ошибка при создании курьера: %v", err)
+	}
+
+	// Статус в общем обновлении не применяется: он меняется только вместе с событием CourierStatusChanged
+	update := &models.Courier{Name: "Новое Имя", Phone: courier.Phone, Email: courier.Email, Status: models.CourierStatusOffline}
+	if err := service.Update(courier.ID, update); err != nil {
+		t.Fatalf("Ошибка при обновлении курьера: %v", err)
+	}
+	stored, _ := service.Get(courier.ID)
+	if stored.Name != "Новое Имя" {
+		t.Errorf("ожидалось имя 'Новое Имя', получено '%s'", stored.Name)
+	}
+	if stored.Status != models.CourierStatusAvailable {
+		t.Errorf("ожидался статус 'available', получено '%s'", stored.Status)
+	}
+
+	if err := service.UpdateCourierStatus(courier.ID, models.CourierStatusOffline); err != nil {
+		t.Fatalf("Ошибка при изменении статуса курьера: %v", err)
+	}
+	stored, _ = service.Get(courier.ID)
+	if stored.Status != models.CourierStatusOffline {
+		t.Errorf("ожидался статус 'offline', получено '%s'", stored.Status)
+	}
+	if stored.Name != "Новое Имя" {
+		t.Errorf("изменение статуса не должно менять данные курьера, получено имя '%s'", stored.Name)
+	}
+
+	if err := service.UpdateCourierStatus(courier.ID, "vacation"); !errors.Is(err, models.ErrInvalidCourierStatus) {
+		t.Errorf("ожидалась ошибка ErrInvalidCourierStatus, получено %v", err)
+	}
+	if err := service.UpdateCourierStatus(courier.ID+100, models.CourierStatusOffline); !errors.Is(err, models.ErrCourierNotFound) {
+		t.Errorf("ожидалась ошибка ErrCourierNotFound, получено %v", err)
+	}
+}
+
+// payloadContains проверяет, что в тело события входит фрагмент JSON
+type payloadContains string
+
+func (p payloadContains) Match(v driver.Value) bool {
+	payload, ok := v.([]byte)
+	return ok && bytes.Contains(payload, []byte(p))
+}
+
+var courierColumnNames = []string{"id", "user_id", "name", "phone", "email", "vehicle_id", "vehicle_type", "status"}
+
+func TestCourierService_UpdateCourierStatus_Outbox(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	service := NewCourierService(NewCourierStore(db)).WithOutbox(outbox.NewStore(db))
+
+	// Прежний статус берется из записи, заблокированной в транзакции изменения
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + courierColumns + " FROM courier WHERE id = $1 FOR UPDATE")).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(courierColumnNames).AddRow(3, nil, "Курьер", "+79001234567", "c@example.com", nil, nil, "offline"))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE courier SET status = $1 WHERE id = $2")).
+		WithArgs(models.CourierStatusAvailable, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO outbox").
+		WithArgs(models.TopicCouriers, "3", models.EventCourierStatusChanged, 1,
+			payloadContains(`"old_status":"offline","new_status":"available"`), sqlmock.AnyArg(), nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	if err := service.UpdateCourierStatus(3, models.CourierStatusAvailable); err != nil {
+		t.Fatalf("UpdateCourierStatus() error = %v", err)
+	}
+
+	// Статус не изменился - событие не записывается
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("FROM courier WHERE id = $1 FOR UPDATE")).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(courierColumnNames).AddRow(3, nil, "Курьер", "+79001234567", "c@example.com", nil, nil, "available"))
+	mock.ExpectCommit()
+
+	if err := service.UpdateCourierStatus(3, models.CourierStatusAvailable); err != nil {
+		t.Fatalf("UpdateCourierStatus() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+// openDeliveries - курьеры с незавершенными доставками
+type openDeliveries map[int]bool
+
+func (d openDeliveries) HasOpenDeliveryTx(tx *sql.Tx, courierID int) (bool, error) {
+	return d[courierID], nil
+}
+
+func TestCourierService_UpdateCourierStatus_OpenDelivery(t *testing.T) {
+	mockStore := NewMockCourierStore()
+	deliveries := openDeliveries{}
+	service := NewCourierService(mockStore).WithDeliveries(deliveries)
+
+	courier := &models.Courier{Name: "Тестовый Курьер", Status: models.CourierStatusBusy}
+	if err := service.Create(courier); err != nil {
+		t.Fatalf("Ошибка при создании курьера: %v", err)
+	}
+
+	// Пока доставка не завершена, курьер не может снова стать доступным для назначения
+	deliveries[courier.ID] = true
+	if err := service.UpdateCourierStatus(courier.ID, models.CourierStatusAvailable); !errors.Is(err, models.ErrCourierHasOpenDelivery) {
+		t.Errorf("ожидалась ошибка ErrCourierHasOpenDelivery, получено %v", err)
+	}
+	if stored, _ := service.Get(courier.ID); stored.Status != models.CourierStatusBusy {
+		t.Errorf("ожидался статус 'busy', получено '%s'", stored.Status)
+	}
+
+	// Уход в offline не позволяет обойти проверку: из offline в available тоже нельзя
+	if err := service.UpdateCourierStatus(courier.ID, models.CourierStatusOffline); err != nil {
+		t.Fatalf("Ошибка при изменении статуса курьера: %v", err)
+	}
+	if err := service.UpdateCourierStatus(courier.ID, models.CourierStatusAvailable); !errors.Is(err, models.ErrCourierHasOpenDelivery) {
+		t.Errorf("ожидалась ошибка ErrCourierHasOpenDelivery, получено %v", err)
+	}
+	if stored, _ := service.Get(courier.ID); stored.Status != models.CourierStatusOffline {
+		t.Errorf("ожидался статус 'offline', получено '%s'", stored.Status)
+	}
+
+	deliveries[courier.ID] = false
+	if err := service.UpdateCourierStatus(courier.ID, models.CourierStatusAvailable); err != nil {
+		t.Errorf("Ошибка при изменении статуса курьера: %v", err)
+	}
+}
+
+func TestCourierService_GetByUserID(t *testing.T) {
+	mockStore := NewMockCourierStore()
+	service := NewCourierService(mockStore)
+
+	courier := &models.Courier{
+		UserID: 42,
+		Name:   "Тестовый Курьер",
+		Phone:  "+79001234567",
+		Email:  "test.courier@example.com",
+	}
+	if err := service.Create(courier); err != nil {
+		t.Fatalf("Ошибка при создании курьера: %v", err)
+	}
+
+	found, err := service.GetByUserID(42)
+	if err != nil {
+		t.Fatalf("Ошибка при получении курьера по пользователю: %v", err)
+	}
+	if found.ID != courier.ID {
+		t.Errorf("ожидался курьер с ID=%d, получено ID=%d", courier.ID, found.ID)
+	}
+
+	if _, err := service.GetByUserID(43); err == nil {
+		t.Errorf("ожидалась ошибка для пользователя без профиля курьера")
+	}
+}
+
+func TestCourierService_GetAvailableCouriersInZone(t *testing.T) {
+	mockStore := NewMockCourierStore()
+	service := NewCourierService(mockStore)
+
+	for _, c := range []models.Courier{
+		{Name: "Первый", Status: models.CourierStatusAvailable},
+		{Name: "Второй", Status: models.CourierStatusAvailable},
+		{Name: "Занятый", Status: models.CourierStatusBusy},
+	} {
+		if err := service.Create(&c); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+	mockStore.zones = map[int][]int{1: {10}, 2: {10, 20}, 3: {10}}
+
+	couriers, err := service.GetAvailableCouriersInZone(20)
+	if err != nil || len(couriers) != 1 || couriers[0].ID != 2 {
+		t.Errorf("GetAvailableCouriersInZone(20) = %+v, %v", couriers, err)
+	}
+
+	couriers, err = service.GetAvailableCouriersInZone(10)
+	if err != nil || len(couriers) != 2 {
+		t.Errorf("GetAvailableCouriersInZone(10) = %d курьеров, %v, want 2", len(couriers), err)
+	}
+}
@@ -10,26 +10,48 @@ import (
 var _ CourierStorer = (*CourierStore)(nil)
 
 type CourierStore struct {
-	db        *sql.DB
-	tableName string
+	db         *sql.DB
+	tableName  string
+	zonesTable string // Зоны доставки, которые обслуживает курьер
 }
 
 func NewCourierStore(db *sql.DB) *CourierStore {
 	return &CourierStore{
-		db:        db,
-		tableName: "courier",
+		db:         db,
+		tableName:  "courier",
+		zonesTable: "courier_zones",
 	}
 }
 
+// conn возвращает транзакцию tx или, если tx == nil, соединение с БД
+func (s *CourierStore) conn(tx *sql.Tx) querier {
+	if tx == nil {
+		return s.db
+	}
+	return tx
+}
+
 func (s *CourierStore) Add(c models.Courier) (int, error) {
+	return s.add(s.db, c)
+}
+
+// AddTx добавляет курьера в транзакции tx; без транзакции, если tx == nil
+func (s *CourierStore) AddTx(tx *sql.Tx, c models.Courier) (int, error) {
+	return s.add(s.conn(tx), c)
+}
+
+func (s *CourierStore) add(q querier, c models.Courier) (int, error) {
 	query := fmt.Sprintf(`
-		INSERT INTO %s (name, phone, email, vehicle_id, status)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO %s (user_id, name, phone, email, vehicle_id, vehicle_type, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 		RETURNING id
 	`, s.tableName)
 
+	// Курьер без привязанной учетной записи хранится с user_id = NULL
+	userID := sql.NullInt64{Int64: int64(c.UserID), Valid: c.UserID != 0}
+
 	var id int
-	err := s.db.QueryRow(query, c.Name, c.Phone, c.Email, c.VehicleID, c.Status).Scan(&id)
+	err := q.QueryRow(query, userID, c.Name, c.Phone, c.Email, c.VehicleID, c.VehicleType, c.Status).Scan(&id)
 	if err != nil {
 		return 0, fmt.Errorf("ошибка при добавлении курьера: %w", err)
 	}
@@ -37,13 +59,41 @@ func (s *CourierStore) Add(c models.Courier) (int, error) {
 	return id, nil
 }
 
-func (s *CourierStore) Get(id int) (models.Courier, error) {
-	query := fmt.Sprintf(`SELECT id, name, phone, email, vehicle_id, status FROM %s WHERE id = $1`, s.tableName)
-	row := s.db.QueryRow(query, id)
+// Колонки, которые читаются при выборке курьера
+const courierColumns = "id, user_id, name, phone, email, vehicle_id, vehicle_type, status"
+
+// rowScanner - общий интерфейс для *sql.Row и *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// querier - общий интерфейс для *sql.DB и *sql.Tx
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
 
+func scanCourier(row rowScanner) (models.Courier, error) {
 	var courier models.Courier
-	var vehicleID sql.NullString
-	err := row.Scan(&courier.ID, &courier.Name, &courier.Phone, &courier.Email, &vehicleID, &courier.Status)
+	var userID sql.NullInt64
+	var vehicleID, vehicleType sql.NullString
+	err := row.Scan(&courier.ID, &userID, &courier.Name, &courier.Phone, &courier.Email, &vehicleID, &vehicleType, &courier.Status)
+	if err != nil {
+		return courier, err
+	}
+
+	courier.UserID = int(userID.Int64)
+	if vehicleID.Valid {
+		courier.VehicleID = vehicleID.String
+	}
+	courier.VehicleType = vehicleType.String
+
+	return courier, nil
+}
+
+func (s *CourierStore) Get(id int) (models.Courier, error) {
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE id = $1`, courierColumns, s.tableName)
+	courier, err := scanCourier(s.db.QueryRow(query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return courier, fmt.Errorf("курьер с ID %d не найден", id)
@@ -51,16 +101,29 @@ func (s *CourierStore) Get(id int) (models.Courier, error) {
 		return courier, fmt.Errorf("ошибка при получении курьера: %w", err)
 	}
 
-	if vehicleID.Valid {
-		courier.VehicleID = vehicleID.String
+	return courier, nil
+}
+
+// GetByUserID возвращает профиль курьера, привязанный к учетной записи пользователя
+func (s *CourierStore) GetByUserID(userID int) (models.Courier, error) {
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE user_id = $1`, courierColumns, s.tableName)
+	courier, err := scanCourier(s.db.QueryRow(query, userID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return courier, fmt.Errorf("курьер для пользователя %d не найден", userID)
+		}
+		return courier, fmt.Errorf("ошибка при получении курьера: %w", err)
 	}
 
 	return courier, nil
 }
 
+// Update обновляет данные курьера. Статус не изменяется: он меняется через SetStatusTx
+// вместе с событием CourierStatusChanged
 func (s *CourierStore) Update(courier models.Courier) error {
-	query := fmt.Sprintf(`UPDATE %s SET name = $1, phone = $2, email = $3, vehicle_id = $4, status = $5 WHERE id = $6`, s.tableName)
-	_, err := s.db.Exec(query, courier.Name, courier.Phone, courier.Email, courier.VehicleID, courier.Status, courier.ID)
+	query := fmt.Sprintf(`UPDATE %s SET name = $1, phone = $2, email = $3, vehicle_id = $4, vehicle_type = $5 WHERE id = $6`, s.tableName)
+	_, err := s.db.Exec(query, courier.Name, courier.Phone, courier.Email, courier.VehicleID,
+		courier.VehicleType, courier.ID)
 	if err != nil {
 		return fmt.Errorf("ошибка при обновлении курьера: %w", err)
 	}
@@ -76,8 +139,41 @@ func (s *CourierStore) Delete(id int) error {
 	return nil
 }
 
+// GetForUpdate возвращает курьера и блокирует его запись до конца транзакции tx;
+// без транзакции, если tx == nil, запись не остается заблокированной
+func (s *CourierStore) GetForUpdate(tx *sql.Tx, id int) (models.Courier, error) {
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE id = $1 FOR UPDATE`, courierColumns, s.tableName)
+	courier, err := scanCourier(s.conn(tx).QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return courier, fmt.Errorf("%w: ID %d", models.ErrCourierNotFound, id)
+		}
+		return courier, fmt.Errorf("ошибка при получении курьера: %w", err)
+	}
+
+	return courier, nil
+}
+
+// SetStatus изменяет только статус курьера
+func (s *CourierStore) SetStatus(id int, status string) error {
+	return s.setStatus(s.db, id, status)
+}
+
+// SetStatusTx изменяет статус курьера в транзакции tx; без транзакции, если tx == nil
+func (s *CourierStore) SetStatusTx(tx *sql.Tx, id int, status string) error {
+	return s.setStatus(s.conn(tx), id, status)
+}
+
+func (s *CourierStore) setStatus(q querier, id int, status string) error {
+	query := fmt.Sprintf(`UPDATE %s SET status = $1 WHERE id = $2`, s.tableName)
+	if _, err := q.Exec(query, status, id); err != nil {
+		return fmt.Errorf("ошибка при обновлении статуса курьера: %w", err)
+	}
+	return nil
+}
+
 func (s *CourierStore) GetAll() ([]models.Courier, error) {
-	query := fmt.Sprintf(`SELECT id, name, phone, email, vehicle_id, status FROM %s`, s.tableName)
+	query := fmt.Sprintf(`SELECT %s FROM %s`, courierColumns, s.tableName)
 	rows, err := s.db.Query(query)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка при получении списка курьеров: %w", err)
@@ -86,17 +182,11 @@ func (s *CourierStore) GetAll() ([]models.Courier, error) {
 
 	var couriers []models.Courier
 	for rows.Next() {
-		var courier models.Courier
-		var vehicleID sql.NullString
-		err := rows.Scan(&courier.ID, &courier.Name, &courier.Phone, &courier.Email, &vehicleID, &courier.Status)
+		courier, err := scanCourier(rows)
 		if err != nil {
 			return nil, fmt.Errorf("ошибка при сканировании данных курьера: %w", err)
 		}
 
-		if vehicleID.Valid {
-			courier.VehicleID = vehicleID.String
-		}
-
 		couriers = append(couriers, courier)
 	}
 
@@ -108,30 +198,40 @@ func (s *CourierStore) GetAll() ([]models.Courier, error) {
 }
 
 func (s *CourierStore) GetAvailableCouriers() ([]models.Courier, error) {
-	query := fmt.Sprintf(`SELECT id, name, phone, email, vehicle_id, status FROM %s WHERE status = 'available'`, s.tableName)
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE status = 'available'`, courierColumns, s.tableName)
 	rows, err := s.db.Query(query)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка при получении доступных курьеров: %w", err)
 	}
+	return scanCouriers(rows)
+}
+
+// GetAvailableCouriersInZone возвращает доступных курьеров, обслуживающих зону доставки
+func (s *CourierStore) GetAvailableCouriersInZone(zoneID int) ([]models.Courier, error) {
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE status = 'available'
+		AND id IN (SELECT courier_id FROM %s WHERE zone_id = $1)`, courierColumns, s.tableName, s.zonesTable)
+	rows, err := s.db.Query(query, zoneID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении доступных курьеров зоны %d: %w", zoneID, err)
+	}
+	return scanCouriers(rows)
+}
+
+// scanCouriers читает список курьеров и закрывает rows
+func scanCouriers(rows *sql.Rows) ([]models.Courier, error) {
 	defer rows.Close()
 
 	var couriers []models.Courier
 	for rows.Next() {
-		var courier models.Courier
-		var vehicleID sql.NullString
-		err := rows.Scan(&courier.ID, &courier.Name, &courier.Phone, &courier.Email, &vehicleID, &courier.Status)
+		courier, err := scanCourier(rows)
 		if err != nil {
 			return nil, fmt.Errorf("ошибка при сканировании данных курьера: %w", err)
 		}
 
-		if vehicleID.Valid {
-			courier.VehicleID = vehicleID.String
-		}
-
 		couriers = append(couriers, courier)
 	}
 
-	if err = rows.Err(); err != nil {
+	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("ошибка при обработке результатов: %w", err)
 	}
 
@@ -34,10 +34,12 @@ func setupTestDB(t *testing.T) (*sql.DB, string, func()) {
 	if _, err := testDB.Exec(fmt.Sprintf(`
 		CREATE TABLE %s (
 			id SERIAL PRIMARY KEY,
+			user_id INTEGER UNIQUE,
 			name TEXT NOT NULL,
 			phone TEXT NOT NULL,
 			email TEXT UNIQUE NOT NULL,
 			vehicle_id TEXT,
+			vehicle_type TEXT,
 			status TEXT NOT NULL
 		);
 	`, tableName)); err != nil {
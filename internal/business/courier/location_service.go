@@ -0,0 +1,150 @@
+package courier
+
+import (
+	"context"
+	"delivery/internal/business/models"
+	"delivery/internal/cache"
+	"delivery/internal/geo"
+	"delivery/internal/metrics"
+	"fmt"
+	"log"
+	"time"
+)
+
+// LocationStorer определяет интерфейс для хранилища истории местоположений
+type LocationStorer interface {
+	Add(location models.CourierLocation) error
+	Latest(courierID int) (models.CourierLocation, error)
+	Track(courierID int, from, to time.Time) ([]models.CourierLocation, error)
+}
+
+// LocationListener получает уведомление о каждой сохраненной отметке местоположения
+type LocationListener interface {
+	LocationRecorded(location models.CourierLocation)
+}
+
+const (
+	// maxClockSkew - насколько время устройства может опережать время сервера
+	maxClockSkew = time.Minute
+	// locationCacheTTL - время хранения последнего местоположения в кэше
+	locationCacheTTL = time.Hour
+)
+
+// LocationService принимает местоположения курьеров: история хранится в БД,
+// последнее местоположение дополнительно кэшируется в Redis
+type LocationService struct {
+	store       LocationStorer
+	cacheClient *cache.RedisClient
+	listener    LocationListener
+	now         func() time.Time
+}
+
+func NewLocationService(store LocationStorer) *LocationService {
+	return &LocationService{store: store, now: time.Now}
+}
+
+// WithCache добавляет клиент кэширования к сервису
+func (s *LocationService) WithCache(cacheClient *cache.RedisClient) *LocationService {
+	s.cacheClient = cacheClient
+	return s
+}
+
+// WithListener подписывает listener на новые отметки местоположения
+func (s *LocationService) WithListener(listener LocationListener) *LocationService {
+	s.listener = listener
+	return s
+}
+
+func locationCacheKey(courierID int) string {
+	return fmt.Sprintf("courier:location:%d", courierID)
+}
+
+// Record сохраняет местоположение курьера. Если время устройства не передано, используется время сервера
+func (s *LocationService) Record(location models.CourierLocation) error {
+	if location.Timestamp.IsZero() {
+		location.Timestamp = s.now()
+	}
+	if err := s.validate(location); err != nil {
+		return err
+	}
+	location.Timestamp = location.Timestamp.UTC()
+
+	if err := s.store.Add(location); err != nil {
+		return err
+	}
+	s.cacheLatest(location)
+
+	// Слушатель уведомляется после обновления кэша, чтобы Latest уже возвращал новую отметку
+	if s.listener != nil {
+		s.listener.LocationRecorded(location)
+	}
+
+	return nil
+}
+
+// cacheLatest кэширует отметку, если она новее закэшированной.
+// Отметки могут приходить не по порядку: в кэше остается самая свежая
+func (s *LocationService) cacheLatest(location models.CourierLocation) {
+	if s.cacheClient == nil {
+		return
+	}
+	ctx := context.Background()
+	key := locationCacheKey(location.CourierID)
+
+	var cached models.CourierLocation
+	if err := s.cacheClient.GetJSON(ctx, key, &cached); err == nil && cached.Timestamp.After(location.Timestamp) {
+		return
+	}
+	if err := s.cacheClient.SetJSON(ctx, key, location, locationCacheTTL); err != nil {
+		log.Printf("Ошибка при сохранении местоположения курьера в кэш: %v", err)
+	}
+}
+
+func (s *LocationService) validate(location models.CourierLocation) error {
+	point := geo.Point{Lat: location.Lat, Lon: location.Lon}
+	switch {
+	case !point.Valid():
+		return fmt.Errorf("%w: координаты вне допустимого диапазона", models.ErrInvalidLocation)
+	case location.Accuracy < 0:
+		return fmt.Errorf("%w: отрицательная погрешность", models.ErrInvalidLocation)
+	case location.Heading < 0 || location.Heading >= 360:
+		return fmt.Errorf("%w: направление должно быть в диапазоне [0, 360)", models.ErrInvalidLocation)
+	case location.Timestamp.After(s.now().Add(maxClockSkew)):
+		return fmt.Errorf("%w: время отметки в будущем", models.ErrInvalidLocation)
+	}
+	return nil
+}
+
+// Latest возвращает последнее известное местоположение курьера
+func (s *LocationService) Latest(courierID int) (models.CourierLocation, error) {
+	if s.cacheClient != nil {
+		var location models.CourierLocation
+		if err := s.cacheClient.GetJSON(context.Background(), locationCacheKey(courierID), &location); err == nil {
+			metrics.CacheHitTotal.Inc()
+			return location, nil
+		}
+		metrics.CacheMissTotal.Inc()
+	}
+
+	location, err := s.store.Latest(courierID)
+	if err != nil {
+		return location, err
+	}
+
+	if s.cacheClient != nil {
+		if err := s.cacheClient.SetJSON(context.Background(), locationCacheKey(courierID), location, locationCacheTTL); err != nil {
+			log.Printf("Ошибка при сохранении местоположения курьера в кэш: %v", err)
+		}
+	}
+
+	return location, nil
+}
+
+// Track возвращает маршрут курьера за период [from, to]
+func (s *LocationService) Track(courierID int, from, to time.Time) ([]models.CourierLocation, error) {
+	track, err := s.store.Track(courierID, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении маршрута курьера: %w", err)
+	}
+	return track, nil
+}
@@ -0,0 +1,118 @@
+package courier
+
+import (
+	"delivery/internal/business/models"
+	"errors"
+	"testing"
+	"time"
+)
+
+// mockLocationStore хранит местоположения в памяти
+type mockLocationStore struct {
+	locations []models.CourierLocation
+}
+
+func (m *mockLocationStore) Add(location models.CourierLocation) error {
+	m.locations = append(m.locations, location)
+	return nil
+}
+
+func (m *mockLocationStore) Latest(courierID int) (models.CourierLocation, error) {
+	var latest models.CourierLocation
+	found := false
+	for _, l := range m.locations {
+		if l.CourierID == courierID && (!found || l.Timestamp.After(latest.Timestamp)) {
+			latest, found = l, true
+		}
+	}
+	if !found {
+		return latest, models.ErrLocationNotFound
+	}
+	return latest, nil
+}
+
+func (m *mockLocationStore) Track(courierID int, from, to time.Time) ([]models.CourierLocation, error) {
+	var track []models.CourierLocation
+	for _, l := range m.locations {
+		if l.CourierID == courierID && !l.Timestamp.Before(from) && !l.Timestamp.After(to) {
+			track = append(track, l)
+		}
+	}
+	return track, nil
+}
+
+// recordedLocations запоминает отметки, о которых сообщил сервис
+type recordedLocations []models.CourierLocation
+
+func (r *recordedLocations) LocationRecorded(location models.CourierLocation) {
+	*r = append(*r, location)
+}
+
+func TestLocationService_Record(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		location models.CourierLocation
+		wantErr  bool
+	}{
+		{"Корректная отметка", models.CourierLocation{Lat: 55.75, Lon: 37.62, Accuracy: 10, Heading: 270, Timestamp: now.Add(-time.Second)}, false},
+		{"Без времени устройства", models.CourierLocation{Lat: 55.75, Lon: 37.62}, false},
+		{"Широта вне диапазона", models.CourierLocation{Lat: 91, Lon: 37.62}, true},
+		{"Долгота вне диапазона", models.CourierLocation{Lat: 55.75, Lon: -181}, true},
+		{"Отрицательная погрешность", models.CourierLocation{Lat: 55.75, Lon: 37.62, Accuracy: -1}, true},
+		{"Направление 360", models.CourierLocation{Lat: 55.75, Lon: 37.62, Heading: 360}, true},
+		{"Время в будущем", models.CourierLocation{Lat: 55.75, Lon: 37.62, Timestamp: now.Add(time.Hour)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &mockLocationStore{}
+			listener := &recordedLocations{}
+			service := NewLocationService(store).WithListener(listener)
+			service.now = func() time.Time { return now }
+
+			tt.location.CourierID = 1
+			err := service.Record(tt.location)
+			if tt.wantErr {
+				if !errors.Is(err, models.ErrInvalidLocation) || len(store.locations) != 0 || len(*listener) != 0 {
+					t.Errorf("Record() error = %v, сохранено %d, уведомлений %d", err, len(store.locations), len(*listener))
+				}
+				return
+			}
+			if err != nil || len(store.locations) != 1 || store.locations[0].Timestamp.IsZero() {
+				t.Errorf("Record() error = %v, сохранено %+v", err, store.locations)
+			}
+			if len(*listener) != 1 || (*listener)[0].CourierID != 1 {
+				t.Errorf("LocationRecorded() получил %+v", *listener)
+			}
+		})
+	}
+}
+
+func TestLocationService_LatestAndTrack(t *testing.T) {
+	store := &mockLocationStore{}
+	service := NewLocationService(store)
+
+	if _, err := service.Latest(1); !errors.Is(err, models.ErrLocationNotFound) {
+		t.Errorf("Latest() без отметок error = %v", err)
+	}
+
+	start := time.Now().Add(-time.Hour)
+	for i, lat := range []float64{55.70, 55.71, 55.72} {
+		location := models.CourierLocation{CourierID: 1, Lat: lat, Lon: 37.60, Timestamp: start.Add(time.Duration(i) * 10 * time.Minute)}
+		if err := service.Record(location); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+
+	latest, err := service.Latest(1)
+	if err != nil || latest.Lat != 55.72 {
+		t.Errorf("Latest() = %+v, %v", latest, err)
+	}
+
+	track, err := service.Track(1, start.Add(5*time.Minute), start.Add(time.Hour))
+	if err != nil || len(track) != 2 {
+		t.Errorf("Track() = %d отметок, %v, want 2", len(track), err)
+	}
+}
@@ -0,0 +1,98 @@
+package courier
+
+import (
+	"database/sql"
+	"delivery/internal/business/models"
+	"fmt"
+	"time"
+)
+
+// Убедимся, что LocationStore реализует интерфейс LocationStorer
+var _ LocationStorer = (*LocationStore)(nil)
+
+// LocationStore хранит историю местоположений курьеров
+type LocationStore struct {
+	db        *sql.DB
+	tableName string
+}
+
+func NewLocationStore(db *sql.DB) *LocationStore {
+	return &LocationStore{
+		db:        db,
+		tableName: "courier_locations",
+	}
+}
+
+// Колонки, которые читаются при выборке местоположения
+const locationColumns = "courier_id, lat, lon, accuracy, heading, recorded_at"
+
+func scanLocation(row rowScanner) (models.CourierLocation, error) {
+	var location models.CourierLocation
+	var accuracy, heading sql.NullFloat64
+	err := row.Scan(&location.CourierID, &location.Lat, &location.Lon, &accuracy, &heading, &location.Timestamp)
+	if err != nil {
+		return location, err
+	}
+
+	location.Accuracy = accuracy.Float64
+	location.Heading = heading.Float64
+	return location, nil
+}
+
+func (s *LocationStore) Add(location models.CourierLocation) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s (courier_id, lat, lon, accuracy, heading, recorded_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, s.tableName)
+
+	_, err := s.db.Exec(query, location.CourierID, location.Lat, location.Lon,
+		location.Accuracy, location.Heading, location.Timestamp)
+	if err != nil {
+		return fmt.Errorf("ошибка при сохранении местоположения курьера: %w", err)
+	}
+	return nil
+}
+
+// Latest возвращает последнее по времени устройства местоположение курьера
+func (s *LocationStore) Latest(courierID int) (models.CourierLocation, error) {
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE courier_id = $1 ORDER BY recorded_at DESC, id DESC LIMIT 1`,
+		locationColumns, s.tableName)
+	location, err := scanLocation(s.db.QueryRow(query, courierID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return location, fmt.Errorf("%w: курьер %d", models.ErrLocationNotFound, courierID)
+		}
+		return location, fmt.Errorf("ошибка при получении местоположения курьера: %w", err)
+	}
+
+	return location, nil
+}
+
+// Track возвращает местоположения курьера за период [from, to] в хронологическом порядке
+func (s *LocationStore) Track(courierID int, from, to time.Time) ([]models.CourierLocation, error) {
+	query := fmt.Sprintf(`
+		SELECT %s FROM %s
+		WHERE courier_id = $1 AND recorded_at BETWEEN $2 AND $3
+		ORDER BY recorded_at, id
+	`, locationColumns, s.tableName)
+	rows, err := s.db.Query(query, courierID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении маршрута курьера: %w", err)
+	}
+	defer rows.Close()
+
+	track := []models.CourierLocation{}
+	for rows.Next() {
+		location, err := scanLocation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при сканировании местоположения курьера: %w", err)
+		}
+		track = append(track, location)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке результатов: %w", err)
+	}
+
+	return track, nil
+}
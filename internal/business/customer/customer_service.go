@@ -1,18 +1,28 @@
 package customer
 
 import (
+	"database/sql"
 	"delivery/internal/business/models"
+	"delivery/internal/outbox"
 	"fmt"
 )
 
 type CustomerService struct {
-	store *CustomerStore
+	store  *CustomerStore
+	outbox outbox.Writer
 }
 
 func NewCustomerService(store *CustomerStore) *CustomerService {
 	return &CustomerService{store: store}
 }
 
+// WithOutbox включает запись событий CustomerRegistered и CustomerUpdated в outbox
+// в одной транзакции с изменением клиента
+func (s *CustomerService) WithOutbox(w outbox.Writer) *CustomerService {
+	s.outbox = w
+	return s
+}
+
 func (s *CustomerService) Create(customer *models.Customer) error {
 	if err := ValidateEmail(customer.Email); err != nil {
 		return err
@@ -22,18 +32,24 @@ func (s *CustomerService) Create(customer *models.Customer) error {
 	}
 
 	cust := models.Customer{
-		Name:  customer.Name,
-		Email: customer.Email,
-		Phone: customer.Phone,
+		UserID: customer.UserID,
+		Name:   customer.Name,
+		Email:  customer.Email,
+		Phone:  customer.Phone,
 	}
 
-	id, err := s.store.Add(cust)
-	if err != nil {
-		return err
-	}
-	customer.ID = id
+	return outbox.InTx(s.outbox, func(tx *sql.Tx) ([]models.OutboxEvent, error) {
+		id, err := s.store.AddTx(tx, cust)
+		if err != nil {
+			return nil, err
+		}
+		customer.ID = id
 
-	return nil
+		return outbox.Event(models.TopicCustomers, id, models.EventCustomerRegistered, models.CustomerRegisteredEvent{
+			CustomerID: id,
+			UserID:     cust.UserID,
+		})
+	})
 }
 
 func (s *CustomerService) Get(id int) (*models.Customer, error) {
@@ -43,13 +59,24 @@ func (s *CustomerService) Get(id int) (*models.Customer, error) {
 	}
 
 	return &models.Customer{
-		ID:    customer.ID,
-		Name:  customer.Name,
-		Email: customer.Email,
-		Phone: customer.Phone,
+		ID:     customer.ID,
+		UserID: customer.UserID,
+		Name:   customer.Name,
+		Email:  customer.Email,
+		Phone:  customer.Phone,
 	}, nil
 }
 
+// GetByUserID возвращает профиль клиента, привязанный к учетной записи пользователя
+func (s *CustomerService) GetByUserID(userID int) (*models.Customer, error) {
+	customer, err := s.store.GetByUserID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("customer not found: %w", err)
+	}
+
+	return &customer, nil
+}
+
 func (s *CustomerService) Update(id int, customer *models.Customer) error {
 	cust := models.Customer{
 		ID:    id,
@@ -65,7 +92,12 @@ func (s *CustomerService) Update(id int, customer *models.Customer) error {
 		return err
 	}
 
-	return s.store.Update(cust)
+	return outbox.InTx(s.outbox, func(tx *sql.Tx) ([]models.OutboxEvent, error) {
+		if err := s.store.UpdateTx(tx, cust); err != nil {
+			return nil, err
+		}
+		return outbox.Event(models.TopicCustomers, id, models.EventCustomerUpdated, models.CustomerUpdatedEvent{CustomerID: id})
+	})
 }
 
 func (s *CustomerService) Delete(id int) error {
@@ -81,10 +113,11 @@ func (s *CustomerService) List() ([]models.Customer, error) {
 	var result []models.Customer
 	for _, customer := range customers {
 		result = append(result, models.Customer{
-			ID:    customer.ID,
-			Name:  customer.Name,
-			Email: customer.Email,
-			Phone: customer.Phone,
+			ID:     customer.ID,
+			UserID: customer.UserID,
+			Name:   customer.Name,
+			Email:  customer.Email,
+			Phone:  customer.Phone,
 		})
 	}
 
@@ -36,6 +36,7 @@ func setupCustomerTestDB() *CustomerStore {
 	createTable := fmt.Sprintf(`
 	CREATE TABLE %s (
 		id SERIAL PRIMARY KEY,
+		user_id INTEGER UNIQUE,
 		name TEXT,
 		email TEXT,
 		phone TEXT
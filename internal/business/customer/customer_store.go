@@ -21,6 +21,20 @@ func NewCustomerStore(db *sql.DB) *CustomerStore {
 	}
 }
 
+// querier - общий интерфейс *sql.DB и *sql.Tx
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// conn возвращает транзакцию tx или, если tx == nil, соединение с БД
+func (s CustomerStore) conn(tx *sql.Tx) querier {
+	if tx == nil {
+		return s.db
+	}
+	return tx
+}
+
 // Обрабатывает и логирует ошибки
 func logAndReturnError(context string, err error) error {
 	if err != nil {
@@ -29,10 +43,24 @@ func logAndReturnError(context string, err error) error {
 	return err
 }
 
+// Клиент без привязанной учетной записи хранится с user_id = NULL
+func nullUserID(userID int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(userID), Valid: userID != 0}
+}
+
 func (s CustomerStore) Add(c models.Customer) (int, error) {
-	query := fmt.Sprintf("INSERT INTO %s (name, email, phone) VALUES ($1, $2, $3) RETURNING id", s.tableName)
+	return s.add(s.db, c)
+}
+
+// AddTx добавляет клиента в транзакции tx; без транзакции, если tx == nil
+func (s CustomerStore) AddTx(tx *sql.Tx, c models.Customer) (int, error) {
+	return s.add(s.conn(tx), c)
+}
+
+func (s CustomerStore) add(q querier, c models.Customer) (int, error) {
+	query := fmt.Sprintf("INSERT INTO %s (user_id, name, email, phone) VALUES ($1, $2, $3, $4) RETURNING id", s.tableName)
 	var id int
-	err := s.db.QueryRow(query, c.Name, c.Email, c.Phone).Scan(&id)
+	err := q.QueryRow(query, nullUserID(c.UserID), c.Name, c.Email, c.Phone).Scan(&id)
 	if err != nil {
 		return 0, logAndReturnError("Ошибка добавления клиента", err)
 	}
@@ -40,10 +68,8 @@ func (s CustomerStore) Add(c models.Customer) (int, error) {
 }
 
 func (s CustomerStore) Get(id int) (models.Customer, error) {
-	query := fmt.Sprintf("SELECT id, name, email, phone FROM %s WHERE id = $1", s.tableName)
-	row := s.db.QueryRow(query, id)
-	c := models.Customer{}
-	err := row.Scan(&c.ID, &c.Name, &c.Email, &c.Phone)
+	query := fmt.Sprintf("SELECT id, user_id, name, email, phone FROM %s WHERE id = $1", s.tableName)
+	c, err := scanCustomer(s.db.QueryRow(query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return c, fmt.Errorf("Клиент с ID %d не найден", id)
@@ -53,6 +79,29 @@ func (s CustomerStore) Get(id int) (models.Customer, error) {
 	return c, nil
 }
 
+// GetByUserID возвращает профиль клиента, привязанный к учетной записи пользователя
+func (s CustomerStore) GetByUserID(userID int) (models.Customer, error) {
+	query := fmt.Sprintf("SELECT id, user_id, name, email, phone FROM %s WHERE user_id = $1", s.tableName)
+	c, err := scanCustomer(s.db.QueryRow(query, userID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return c, fmt.Errorf("Клиент для пользователя %d не найден", userID)
+		}
+		return c, logAndReturnError("Ошибка получения клиента", err)
+	}
+	return c, nil
+}
+
+func scanCustomer(row *sql.Row) (models.Customer, error) {
+	var c models.Customer
+	var userID sql.NullInt64
+	if err := row.Scan(&c.ID, &userID, &c.Name, &c.Email, &c.Phone); err != nil {
+		return c, err
+	}
+	c.UserID = int(userID.Int64)
+	return c, nil
+}
+
 func (s *CustomerStore) GetByClient(clientID int) ([]models.Customer, error) {
 	query := fmt.Sprintf("SELECT id, name, email, phone FROM %s WHERE id = $1", s.tableName)
 	rows, err := s.db.Query(query, clientID)
@@ -77,8 +126,17 @@ func (s *CustomerStore) GetByClient(clientID int) ([]models.Customer, error) {
 }
 
 func (s CustomerStore) Update(c models.Customer) error {
+	return s.update(s.db, c)
+}
+
+// UpdateTx обновляет клиента в транзакции tx; без транзакции, если tx == nil
+func (s CustomerStore) UpdateTx(tx *sql.Tx, c models.Customer) error {
+	return s.update(s.conn(tx), c)
+}
+
+func (s CustomerStore) update(q querier, c models.Customer) error {
 	query := fmt.Sprintf("UPDATE %s SET name = $1, email = $2, phone = $3 WHERE id = $4", s.tableName)
-	_, err := s.db.Exec(query, c.Name, c.Email, c.Phone, c.ID)
+	_, err := q.Exec(query, c.Name, c.Email, c.Phone, c.ID)
 	return logAndReturnError("Ошибка обновления клиента", err)
 }
 
@@ -105,7 +163,7 @@ func ValidatePhone(phone string) error {
 }
 
 func (s *CustomerStore) GetAll() ([]models.Customer, error) {
-	query := fmt.Sprintf("SELECT id, name, email, phone FROM %s", s.tableName)
+	query := fmt.Sprintf("SELECT id, user_id, name, email, phone FROM %s", s.tableName)
 	rows, err := s.db.Query(query)
 	if err != nil {
 		return nil, fmt.Errorf("Ошибка при получении списка клиентов: %w", err)
@@ -115,9 +173,11 @@ func (s *CustomerStore) GetAll() ([]models.Customer, error) {
 	var customers []models.Customer
 	for rows.Next() {
 		var customer models.Customer
-		if err := rows.Scan(&customer.ID, &customer.Name, &customer.Email, &customer.Phone); err != nil {
+		var userID sql.NullInt64
+		if err := rows.Scan(&customer.ID, &userID, &customer.Name, &customer.Email, &customer.Phone); err != nil {
 			return nil, fmt.Errorf("Ошибка при сканировании клиента: %w", err)
 		}
+		customer.UserID = int(userID.Int64)
 		customers = append(customers, customer)
 	}
 
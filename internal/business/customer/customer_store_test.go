@@ -18,9 +18,10 @@ func TestCustomerStore(t *testing.T) {
 
 	// Добавление клиента
 	customer := models.Customer{
-		Name:  "John Doe",
-		Email: "john.doe@example.com",
-		Phone: "1234567890",
+		UserID: 7,
+		Name:   "John Doe",
+		Email:  "john.doe@example.com",
+		Phone:  "1234567890",
 	}
 	id, err := store.Add(customer)
 	require.NoError(t, err)
@@ -31,6 +32,14 @@ func TestCustomerStore(t *testing.T) {
 	require.Equal(t, customer.Name, storedCustomer.Name)
 	require.Equal(t, customer.Email, storedCustomer.Email)
 
+	// Получение клиента по учетной записи пользователя
+	ownCustomer, err := store.GetByUserID(7)
+	require.NoError(t, err)
+	require.Equal(t, id, ownCustomer.ID)
+
+	_, err = store.GetByUserID(8)
+	require.Error(t, err)
+
 	// Обновление клиента
 	customer.ID = id
 	customer.Name = "Jane Doe"
@@ -2,19 +2,51 @@ package delivery
 
 import (
 	"context"
+	"database/sql"
 	"delivery/internal/api"
 	"delivery/internal/business/models"
 	"delivery/internal/cache"
 	"delivery/internal/metrics"
+	"delivery/internal/outbox"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 )
 
+// ParcelStatusUpdater обновляет статус посылки вслед за статусом доставки
+// и сообщает зону доставки посылки для адресации уведомлений
+type ParcelStatusUpdater interface {
+	Get(id int) (*models.Parcel, error)
+	UpdateStatus(id int, status string) error
+}
+
+// CourierStatusStore - курьеры, которых назначение доставки переводит в busy, а завершение - обратно в available
+type CourierStatusStore interface {
+	GetForUpdate(tx *sql.Tx, id int) (models.Courier, error)
+	SetStatusTx(tx *sql.Tx, id int, status string) error
+}
+
+// ParcelLockStore - посылки, которые блокируются и обновляются в транзакции изменения доставки
+type ParcelLockStore interface {
+	GetForUpdate(tx *sql.Tx, id int) (*models.Parcel, error)
+	SetStatusTx(tx *sql.Tx, id int, status string) error
+}
+
+// ETAEstimator пересчитывает ожидаемое время открытых доставок курьера
+type ETAEstimator interface {
+	Notify(courierID int)
+}
+
 type DeliveryService struct {
 	store       *DeliveryStore
 	cacheClient *cache.RedisClient
 	wsManager   *api.WebSocketManager
+	parcels     ParcelStatusUpdater
+	couriers    CourierStatusStore
+	parcelLocks ParcelLockStore
+	eta         ETAEstimator
+	outbox      outbox.Writer
 }
 
 func NewDeliveryService(store *DeliveryStore) *DeliveryService {
@@ -33,31 +65,309 @@ func (s *DeliveryService) WithWebSocket(wsManager *api.WebSocketManager) *Delive
 	return s
 }
 
-func (s *DeliveryService) Create(delivery *models.Delivery) error {
+// WithParcels включает синхронизацию статуса посылки со статусом доставки
+func (s *DeliveryService) WithParcels(parcels ParcelStatusUpdater) *DeliveryService {
+	s.parcels = parcels
+	return s
+}
+
+// WithAssignmentStores включает проверку и блокировку курьера и посылки при назначении доставки.
+// Статусы посылки и курьера тогда меняются в одной транзакции с доставкой
+func (s *DeliveryService) WithAssignmentStores(couriers CourierStatusStore, parcels ParcelLockStore) *DeliveryService {
+	s.couriers = couriers
+	s.parcelLocks = parcels
+	return s
+}
+
+// WithETA включает пересчет ожидаемого времени доставок курьера при изменении статуса его доставок
+func (s *DeliveryService) WithETA(eta ETAEstimator) *DeliveryService {
+	s.eta = eta
+	return s
+}
+
+// WithOutbox включает запись событий DeliveryCreated, DeliveryAssigned, DeliveryStatusChanged
+// DeliveryCompleted и DeliveryDeleted в outbox в одной транзакции с изменением доставки. Вместе с ними записываются
+// ParcelStatusChanged и CourierStatusChanged, если статусы посылки и курьера меняются в этой транзакции
+func (s *DeliveryService) WithOutbox(w outbox.Writer) *DeliveryService {
+	s.outbox = w
+	return s
+}
+
+// courierChanged сбрасывает кэш доставок курьера и пересчитывает ожидаемое время оставшихся доставок
+func (s *DeliveryService) courierChanged(courierID int) {
+	if courierID == 0 {
+		return
+	}
+	if s.cacheClient != nil {
+		if err := s.cacheClient.Delete(context.Background(), fmt.Sprintf("deliveries:courier:%d", courierID)); err != nil {
+			log.Printf("Ошибка при удалении кэша доставок курьера: %v", err)
+		}
+	}
+	if s.eta != nil {
+		s.eta.Notify(courierID)
+	}
+}
+
+// orderTarget определяет подписчиков WebSocket, которым отправляются уведомления о доставке
+func (s *DeliveryService) orderTarget(d models.Delivery) api.OrderTarget {
+	target := api.OrderTarget{OrderID: d.ID, CourierID: d.CourierID}
+	if s.parcels == nil {
+		return target
+	}
+	parcel, err := s.parcels.Get(d.ParcelID)
+	if err != nil {
+		log.Printf("Ошибка при получении зоны доставки посылки %d: %v", d.ParcelID, err)
+		return target
+	}
+	target.ZoneID = parcel.ZoneID
+	return target
+}
+
+// syncParcelStatus переводит посылку в статус, соответствующий статусу доставки, отдельной операцией.
+// Используется без хранилища посылок, когда статус посылки не обновляется в транзакции доставки
+func (s *DeliveryService) syncParcelStatus(parcelID int, status Status) {
+	if s.parcels == nil || s.parcelLocks != nil {
+		return
+	}
+	if err := s.parcels.UpdateStatus(parcelID, status.ParcelStatus()); err != nil {
+		log.Printf("Ошибка при синхронизации статуса посылки %d: %v", parcelID, err)
+	}
+}
+
+// statusEvent создает событие изменения статуса доставки d: DeliveryCompleted для доставленной,
+// DeliveryStatusChanged - для остальных статусов
+func statusEvent(d models.Delivery, oldStatus string) ([]models.OutboxEvent, error) {
+	if Status(d.Status) == StatusDelivered {
+		return outbox.Event(models.TopicDeliveries, d.ID, models.EventDeliveryCompleted, models.DeliveryCompletedEvent{
+			DeliveryID:  d.ID,
+			ParcelID:    d.ParcelID,
+			CourierID:   d.CourierID,
+			DeliveredAt: d.DeliveredAt,
+		})
+	}
+	return outbox.Event(models.TopicDeliveries, d.ID, models.EventDeliveryStatusChanged, models.DeliveryStatusChangedEvent{
+		DeliveryID: d.ID,
+		ParcelID:   d.ParcelID,
+		CourierID:  d.CourierID,
+		OldStatus:  oldStatus,
+		NewStatus:  d.Status,
+		ChangedAt:  time.Now().UTC(),
+	})
+}
+
+func newEvent(deliveryID int, oldStatus, newStatus string, audit models.AuditInfo) models.DeliveryEvent {
+	return models.DeliveryEvent{
+		DeliveryID: deliveryID,
+		OldStatus:  oldStatus,
+		NewStatus:  newStatus,
+		ActorID:    audit.ActorID,
+		Note:       audit.Note,
+		CreatedAt:  time.Now().UTC(),
+	}
+}
+
+// recordEvent записывает изменение доставки в журнал в транзакции изменения tx:
+// изменение без записи в журнале не сохраняется
+func (s *DeliveryService) recordEvent(tx *sql.Tx, deliveryID int, oldStatus, newStatus string, audit models.AuditInfo) error {
+	_, err := s.store.AddEventTx(tx, newEvent(deliveryID, oldStatus, newStatus, audit))
+	return err
+}
+
+// inTx выполняет изменение доставки в транзакции. С outbox в ней же записываются события,
+// которые вернула change, - в цепочке audit.CorrelationID; без outbox события отбрасываются
+func (s *DeliveryService) inTx(audit models.AuditInfo, change func(tx *sql.Tx) ([]models.OutboxEvent, error)) error {
+	var w outbox.Writer = discardEvents{s.store}
+	if s.outbox != nil {
+		w = s.outbox
+	}
+	return outbox.InTx(w, func(tx *sql.Tx) ([]models.OutboxEvent, error) {
+		events, err := change(tx)
+		return outbox.Correlate(events, audit.CorrelationID), err
+	})
+}
+
+// discardEvents - outbox.Writer для сервиса без outbox: транзакции начинаются в хранилище доставок,
+// события не записываются
+type discardEvents struct {
+	store *DeliveryStore
+}
+
+func (w discardEvents) BeginTx() (*sql.Tx, error) {
+	return w.store.BeginTx()
+}
+
+func (w discardEvents) AddTx(tx *sql.Tx, event models.OutboxEvent) error {
+	return nil
+}
+
+// syncParcelTx переводит посылку в статус, соответствующий статусу доставки, в транзакции tx
+// и возвращает событие ParcelStatusChanged
+func (s *DeliveryService) syncParcelTx(tx *sql.Tx, parcelID int, status Status) ([]models.OutboxEvent, error) {
+	if s.parcelLocks == nil {
+		return nil, nil
+	}
+	if err := s.parcelLocks.SetStatusTx(tx, parcelID, status.ParcelStatus()); err != nil {
+		return nil, err
+	}
+	return parcelStatusEvent(parcelID, status.ParcelStatus(), time.Now().UTC())
+}
+
+// releaseCourierTx возвращает курьера в статус available в транзакции tx, когда его доставка завершена,
+// не удалась или отменена, и возвращает событие CourierStatusChanged
+func (s *DeliveryService) releaseCourierTx(tx *sql.Tx, courierID int, status Status) ([]models.OutboxEvent, error) {
+	if s.couriers == nil || courierID == 0 || !status.ReleasesCourier() {
+		return nil, nil
+	}
+	courier, err := s.couriers.GetForUpdate(tx, courierID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.couriers.SetStatusTx(tx, courierID, models.CourierStatusAvailable); err != nil {
+		return nil, fmt.Errorf("Ошибка при освобождении курьера %d: %w", courierID, err)
+	}
+	return courierStatusEvent(courierID, courier.Status, models.CourierStatusAvailable, time.Now().UTC())
+}
+
+func parcelStatusEvent(parcelID int, status string, changedAt time.Time) ([]models.OutboxEvent, error) {
+	return outbox.Event(models.TopicParcels, parcelID, models.EventParcelStatusChanged, models.ParcelStatusChangedEvent{
+		ParcelID:  parcelID,
+		Status:    status,
+		ChangedAt: changedAt,
+	})
+}
+
+// courierStatusEvent создает событие CourierStatusChanged, если статус курьера изменился
+func courierStatusEvent(courierID int, oldStatus, newStatus string, changedAt time.Time) ([]models.OutboxEvent, error) {
+	if oldStatus == newStatus {
+		return nil, nil
+	}
+	return outbox.Event(models.TopicCouriers, courierID, models.EventCourierStatusChanged, models.CourierStatusChangedEvent{
+		CourierID: courierID,
+		OldStatus: oldStatus,
+		NewStatus: newStatus,
+		ChangedAt: changedAt,
+	})
+}
+
+// lockDeliveryTx блокирует доставку id в транзакции tx и возвращает ее актуальное состояние.
+// Посылка доставки блокируется раньше самой доставки, в том же порядке, что и при назначении,
+// поэтому изменение статуса и параллельное назначение не блокируют друг друга
+func (s *DeliveryService) lockDeliveryTx(tx *sql.Tx, id int) (models.Delivery, error) {
+	if s.parcelLocks != nil {
+		// Посылка доставки не меняется, поэтому ее можно узнать до блокировки доставки
+		d, err := s.store.GetTx(tx, id)
+		if err != nil {
+			return d, fmt.Errorf("Ошибка при получении доставки: %w", err)
+		}
+		if _, err := s.parcelLocks.GetForUpdate(tx, d.ParcelID); err != nil {
+			return d, err
+		}
+	}
+	d, err := s.store.GetForUpdate(tx, id)
+	if err != nil {
+		return d, fmt.Errorf("Ошибка при получении доставки: %w", err)
+	}
+	return d, nil
+}
+
+// changeStatusTx сохраняет новый статус доставки d в транзакции tx вместе с записью журнала, статусом посылки
+// и, если доставка освобождает курьера, его статусом. Доставка и посылка должны быть заблокированы
+// lockDeliveryTx до проверки перехода. Возвращает события для outbox этой транзакции
+func (s *DeliveryService) changeStatusTx(tx *sql.Tx, d models.Delivery, previous string, audit models.AuditInfo) ([]models.OutboxEvent, error) {
+	status := Status(d.Status)
+	parcelEvents, err := s.syncParcelTx(tx, d.ParcelID, status)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.UpdateTx(tx, d); err != nil {
+		return nil, fmt.Errorf("Ошибка при обновлении доставки: %w", err)
+	}
+	if err := s.recordEvent(tx, d.ID, previous, d.Status, audit); err != nil {
+		return nil, err
+	}
+	courierEvents, err := s.releaseCourierTx(tx, d.CourierID, status)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := statusEvent(d, previous)
+	if err != nil {
+		return nil, err
+	}
+	return append(append(events, parcelEvents...), courierEvents...), nil
+}
+
+func (s *DeliveryService) Create(delivery *models.Delivery, audit models.AuditInfo) error {
+	// Новая доставка начинается со статуса created; курьер назначается через AssignDelivery
+	status := StatusCreated
+	if delivery.Status != "" {
+		parsed, err := ParseStatus(delivery.Status)
+		if err != nil {
+			return err
+		}
+		if parsed != StatusCreated {
+			return fmt.Errorf("%w: новая доставка создается в статусе %s", models.ErrInvalidStatusTransition, StatusCreated)
+		}
+	}
+
 	d := models.Delivery{
 		ParcelID:   delivery.ParcelID,
 		CourierID:  delivery.CourierID,
-		Status:     delivery.Status,
+		Status:     string(status),
 		AssignedAt: time.Now().UTC(),
 	}
 
-	id, err := s.store.Add(d)
+	var id int
+	err := s.inTx(audit, func(tx *sql.Tx) ([]models.OutboxEvent, error) {
+		// Посылка блокируется так же, как при назначении, поэтому параллельные создание
+		// и назначение не оставят у посылки две открытые доставки
+		existing, found, err := s.currentDeliveryTx(tx, d.ParcelID)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			return nil, fmt.Errorf("%w: доставка %d в статусе %s", models.ErrParcelAlreadyAssigned, existing.ID, existing.Status)
+		}
+
+		if id, err = s.store.AddTx(tx, d); err != nil {
+			return nil, fmt.Errorf("Ошибка при создании доставки: %w", err)
+		}
+		if err := s.recordEvent(tx, id, "", d.Status, audit); err != nil {
+			return nil, err
+		}
+		parcelEvents, err := s.syncParcelTx(tx, d.ParcelID, status)
+		if err != nil {
+			return nil, err
+		}
+		events, err := outbox.Event(models.TopicDeliveries, id, models.EventDeliveryCreated, models.DeliveryCreatedEvent{
+			DeliveryID: id,
+			ParcelID:   d.ParcelID,
+			CreatedAt:  d.AssignedAt,
+		})
+		return append(events, parcelEvents...), err
+	})
 	if err != nil {
-		return fmt.Errorf("Ошибка при создании доставки: %w", err)
+		return err
 	}
 
 	delivery.ID = id
+	delivery.Status = d.Status
 	delivery.AssignedAt = d.AssignedAt
 
+	s.syncParcelStatus(d.ParcelID, status)
+
 	// Увеличиваем счетчик созданных доставок
 	metrics.DeliveryCreatedTotal.Inc()
 
-	// Инвалидируем кэш списка доставок
+	// Инвалидируем кэш списка доставок и доставки по посылке: в нем могла остаться отмененная доставка
 	if s.cacheClient != nil {
 		ctx := context.Background()
 		if err := s.cacheClient.Delete(ctx, "deliveries:list"); err != nil {
 			log.Printf("Ошибка при удалении кэша списка доставок: %v", err)
 		}
+		if err := s.cacheClient.Delete(ctx, fmt.Sprintf("delivery:parcel:%d", d.ParcelID)); err != nil {
+			log.Printf("Ошибка при удалении кэша доставки: %v", err)
+		}
 	}
 
 	return nil
@@ -92,14 +402,7 @@ func (s *DeliveryService) Get(id int) (*models.Delivery, error) {
 	// Записываем метрику времени выполнения запроса к БД
 	metrics.DatabaseQueryDuration.WithLabelValues("get_delivery").Observe(time.Since(start).Seconds())
 
-	result := &models.Delivery{
-		ID:          delivery.ID,
-		ParcelID:    delivery.ParcelID,
-		CourierID:   delivery.CourierID,
-		Status:      delivery.Status,
-		AssignedAt:  delivery.AssignedAt,
-		DeliveredAt: delivery.DeliveredAt,
-	}
+	result := &delivery
 
 	// Если кэширование включено, сохраняем в кэш
 	if s.cacheClient != nil {
@@ -113,22 +416,69 @@ func (s *DeliveryService) Get(id int) (*models.Delivery, error) {
 	return result, nil
 }
 
-func (s *DeliveryService) Update(id int, delivery *models.Delivery) error {
-	d := models.Delivery{
-		ID:          id,
-		ParcelID:    delivery.ParcelID,
-		CourierID:   delivery.CourierID,
-		Status:      delivery.Status,
-		AssignedAt:  delivery.AssignedAt,
-		DeliveredAt: delivery.DeliveredAt,
+// Update изменяет статус доставки и время вручения. Курьер и посылка меняются только через AssignDelivery,
+// поэтому их значения в delivery должны быть пустыми или совпадать с сохраненными
+func (s *DeliveryService) Update(id int, delivery *models.Delivery, audit models.AuditInfo) error {
+	var next Status
+	if delivery.Status != "" {
+		parsed, err := ParseStatus(delivery.Status)
+		if err != nil {
+			return err
+		}
+		// Назначение проверяет доступность курьера и блокирует записи, поэтому выполняется только через AssignDelivery
+		if parsed == StatusAssigned {
+			return fmt.Errorf("%w: курьер назначается через AssignDelivery", models.ErrInvalidStatusTransition)
+		}
+		next = parsed
 	}
 
-	if err := s.store.Update(d); err != nil {
-		return fmt.Errorf("Ошибка при обновлении доставки: %w", err)
+	// Переход проверяется по заблокированной в транзакции доставке, а не по кэшу или прочитанной заранее записи,
+	// поэтому из двух параллельных изменений статуса второе проверяется уже по результату первого
+	var d models.Delivery
+	changed := false
+	err := s.inTx(audit, func(tx *sql.Tx) ([]models.OutboxEvent, error) {
+		current, err := s.lockDeliveryTx(tx, id)
+		if err != nil {
+			return nil, err
+		}
+		if (delivery.CourierID != 0 && delivery.CourierID != current.CourierID) ||
+			(delivery.ParcelID != 0 && delivery.ParcelID != current.ParcelID) {
+			return nil, fmt.Errorf("%w: курьер и посылка доставки меняются через AssignDelivery", models.ErrInvalidStatusTransition)
+		}
+
+		// Пустой или прежний статус означает, что доставка не меняется
+		d = current
+		if next == "" || string(next) == current.Status {
+			return nil, nil
+		}
+		if err := checkTransition(current.Status, next); err != nil {
+			return nil, err
+		}
+
+		d.Status = string(next)
+		if next == StatusDelivered {
+			d.DeliveredAt = delivery.DeliveredAt
+			if d.DeliveredAt.IsZero() {
+				d.DeliveredAt = time.Now().UTC()
+			}
+		}
+		changed = true
+		return s.changeStatusTx(tx, d, current.Status, audit)
+	})
+	if err != nil {
+		return err
+	}
+	delivery.Status = d.Status
+	if !changed {
+		return nil
 	}
+	status := d.Status
+
+	s.syncParcelStatus(d.ParcelID, next)
+	s.courierChanged(d.CourierID)
 
 	// Увеличиваем счетчик обновлений статуса доставок
-	metrics.DeliveryStatusUpdatedTotal.WithLabelValues(delivery.Status).Inc()
+	metrics.DeliveryStatusUpdatedTotal.WithLabelValues(status).Inc()
 
 	// Обновляем кэш
 	if s.cacheClient != nil {
@@ -140,10 +490,13 @@ func (s *DeliveryService) Update(id int, delivery *models.Delivery) error {
 			log.Printf("Ошибка при удалении кэша доставки: %v", err)
 		}
 
-		// Инвалидируем кэш списка доставок
+		// Инвалидируем кэш списка доставок и доставки по посылке
 		if err := s.cacheClient.Delete(ctx, "deliveries:list"); err != nil {
 			log.Printf("Ошибка при удалении кэша списка доставок: %v", err)
 		}
+		if err := s.cacheClient.Delete(ctx, fmt.Sprintf("delivery:parcel:%d", d.ParcelID)); err != nil {
+			log.Printf("Ошибка при удалении кэша доставки: %v", err)
+		}
 
 		// Сохраняем обновленные данные в кэш
 		if err := s.cacheClient.SetJSON(ctx, cacheKey, d, 30*time.Minute); err != nil {
@@ -153,7 +506,7 @@ func (s *DeliveryService) Update(id int, delivery *models.Delivery) error {
 
 	// Отправляем уведомление через WebSocket, если менеджер инициализирован
 	if s.wsManager != nil {
-		s.wsManager.BroadcastOrderStatusUpdate(fmt.Sprintf("%d", id), delivery.Status)
+		s.wsManager.BroadcastOrderStatusUpdate(s.orderTarget(d), status)
 
 		// Увеличиваем счетчик активных WebSocket соединений
 		metrics.ActiveConnections.Set(float64(s.wsManager.GetActiveConnectionsCount()))
@@ -162,21 +515,32 @@ func (s *DeliveryService) Update(id int, delivery *models.Delivery) error {
 	return nil
 }
 
-func (s *DeliveryService) CompleteDelivery(deliveryID int) error {
-	delivery, err := s.store.Get(deliveryID)
-	if err != nil {
-		return fmt.Errorf("Ошибка при получении доставки: %w", err)
-	}
+func (s *DeliveryService) CompleteDelivery(deliveryID int, audit models.AuditInfo) error {
+	var delivery models.Delivery
+	err := s.inTx(audit, func(tx *sql.Tx) ([]models.OutboxEvent, error) {
+		current, err := s.lockDeliveryTx(tx, deliveryID)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkTransition(current.Status, StatusDelivered); err != nil {
+			return nil, err
+		}
 
-	if delivery.Status != "assigned" && delivery.Status != "in progress" {
-		return fmt.Errorf("Завершение доставки недоступно для статуса: %s", delivery.Status)
+		delivery = current
+		delivery.Status = string(StatusDelivered)
+		delivery.DeliveredAt = time.Now().UTC()
+		return s.changeStatusTx(tx, delivery, current.Status, audit)
+	})
+	if err != nil {
+		return err
 	}
 
-	delivery.Status = "delivered"
-	delivery.DeliveredAt = time.Now().UTC()
+	// Кэш и уведомления обновляются только после сохранения доставки
+	s.syncParcelStatus(delivery.ParcelID, StatusDelivered)
+	s.courierChanged(delivery.CourierID)
 
 	// Увеличиваем счетчик обновлений статуса доставок
-	metrics.DeliveryStatusUpdatedTotal.WithLabelValues("delivered").Inc()
+	metrics.DeliveryStatusUpdatedTotal.WithLabelValues(delivery.Status).Inc()
 
 	// Обновляем кэш
 	if s.cacheClient != nil {
@@ -188,10 +552,13 @@ func (s *DeliveryService) CompleteDelivery(deliveryID int) error {
 			log.Printf("Ошибка при удалении кэша доставки: %v", err)
 		}
 
-		// Инвалидируем кэш списка доставок
+		// Инвалидируем кэш списка доставок и доставки по посылке
 		if err := s.cacheClient.Delete(ctx, "deliveries:list"); err != nil {
 			log.Printf("Ошибка при удалении кэша списка доставок: %v", err)
 		}
+		if err := s.cacheClient.Delete(ctx, fmt.Sprintf("delivery:parcel:%d", delivery.ParcelID)); err != nil {
+			log.Printf("Ошибка при удалении кэша доставки: %v", err)
+		}
 
 		// Сохраняем обновленные данные в кэш
 		if err := s.cacheClient.SetJSON(ctx, cacheKey, delivery, 30*time.Minute); err != nil {
@@ -201,10 +568,27 @@ func (s *DeliveryService) CompleteDelivery(deliveryID int) error {
 
 	// Отправляем уведомление через WebSocket, если менеджер инициализирован
 	if s.wsManager != nil {
-		s.wsManager.BroadcastOrderStatusUpdate(fmt.Sprintf("%d", deliveryID), "delivered")
+		s.wsManager.BroadcastOrderStatusUpdate(s.orderTarget(delivery), delivery.Status)
 	}
 
-	return s.store.Update(delivery)
+	return nil
+}
+
+// SetETA сохраняет ожидаемое время доставки. parcelID нужен, чтобы сбросить кэш доставки по посылке
+func (s *DeliveryService) SetETA(id, parcelID int, eta time.Time) error {
+	if err := s.store.SetETA(id, eta.UTC()); err != nil {
+		return err
+	}
+
+	if s.cacheClient != nil {
+		ctx := context.Background()
+		for _, key := range []string{fmt.Sprintf("delivery:%d", id), fmt.Sprintf("delivery:parcel:%d", parcelID)} {
+			if err := s.cacheClient.Delete(ctx, key); err != nil {
+				log.Printf("Ошибка при удалении кэша доставки: %v", err)
+			}
+		}
+	}
+	return nil
 }
 
 func (s *DeliveryService) GetByParcelID(parcelID int) (*models.Delivery, error) {
@@ -226,14 +610,7 @@ func (s *DeliveryService) GetByParcelID(parcelID int) (*models.Delivery, error)
 		return nil, fmt.Errorf("Ошибка при получении доставки по ID посылки: %w", err)
 	}
 
-	result := &models.Delivery{
-		ID:          delivery.ID,
-		ParcelID:    delivery.ParcelID,
-		CourierID:   delivery.CourierID,
-		Status:      delivery.Status,
-		AssignedAt:  delivery.AssignedAt,
-		DeliveredAt: delivery.DeliveredAt,
-	}
+	result := &delivery
 
 	// Если кэширование включено, сохраняем в кэш
 	if s.cacheClient != nil {
@@ -274,10 +651,60 @@ func (s *DeliveryService) GetDeliveriesByCourier(courierID int) ([]models.Delive
 	return deliveries, nil
 }
 
-func (s *DeliveryService) Delete(id int) error {
-	err := s.store.Delete(id)
+// Delete удаляет доставку и записывает событие DeliveryDeleted. Курьер незавершенной доставки освобождается,
+// а посылка возвращается в статус registered в той же транзакции, как при отмене, чтобы ее можно было назначить снова
+func (s *DeliveryService) Delete(id int, audit models.AuditInfo) error {
+	var current models.Delivery
+	resetParcel := false
+	err := s.inTx(audit, func(tx *sql.Tx) ([]models.OutboxEvent, error) {
+		var err error
+		if current, err = s.lockDeliveryTx(tx, id); err != nil {
+			return nil, err
+		}
+		if err := s.store.DeleteTx(tx, id); err != nil {
+			return nil, fmt.Errorf("Ошибка при удалении доставки: %w", err)
+		}
+		// Журнал не удаляется вместе с доставкой
+		if err := s.recordEvent(tx, id, current.Status, eventDeleted, audit); err != nil {
+			return nil, err
+		}
+		events, err := outbox.Event(models.TopicDeliveries, id, models.EventDeliveryDeleted, models.DeliveryDeletedEvent{
+			DeliveryID: id,
+			ParcelID:   current.ParcelID,
+			CourierID:  current.CourierID,
+			Status:     current.Status,
+			DeletedAt:  time.Now().UTC(),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// Статус посылки после завершенной доставки остается итоговым
+		status := Status(current.Status)
+		if status.IsFinal() {
+			return events, nil
+		}
+		resetParcel = true
+		parcelEvents, err := s.syncParcelTx(tx, current.ParcelID, StatusCancelled)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, parcelEvents...)
+		// После неудачной доставки курьер уже освобожден и мог получить новую
+		if !status.IsOpen() {
+			return events, nil
+		}
+		courierEvents, err := s.releaseCourierTx(tx, current.CourierID, StatusCancelled)
+		if err != nil {
+			return nil, err
+		}
+		return append(events, courierEvents...), nil
+	})
 	if err != nil {
-		return fmt.Errorf("Ошибка при удалении доставки: %w", err)
+		return err
+	}
+	if resetParcel {
+		s.syncParcelStatus(current.ParcelID, StatusCancelled)
 	}
 
 	// Удаляем из кэша
@@ -288,32 +715,205 @@ func (s *DeliveryService) Delete(id int) error {
 		// Удаляем данные из кэша
 		s.cacheClient.Delete(ctx, cacheKey)
 
-		// Инвалидируем кэш списка доставок
+		// Инвалидируем кэш списка доставок и доставки по посылке
 		s.cacheClient.Delete(ctx, "deliveries:list")
+		s.cacheClient.Delete(ctx, fmt.Sprintf("delivery:parcel:%d", current.ParcelID))
 	}
+	s.courierChanged(current.CourierID)
 
 	return nil
 }
 
-func (s *DeliveryService) AssignDelivery(courierID, parcelID int) (models.Delivery, error) {
+// AssignDelivery назначает курьера на доставку посылки в одной транзакции: проверяет, что посылка
+// существует и не назначена, а курьер доступен, и переводит курьера в busy, а посылку - в sent.
+// Если у посылки уже есть доставка в статусе created или failed, назначается она; после отмены создается новая
+func (s *DeliveryService) AssignDelivery(courierID, parcelID int, audit models.AuditInfo) (models.Delivery, error) {
+	tx, err := s.store.BeginTx()
+	if err != nil {
+		return models.Delivery{}, fmt.Errorf("Ошибка при начале транзакции: %w", err)
+	}
+	defer tx.Rollback() // После Commit не выполняет никаких действий
+
+	delivery, err := s.assignTx(tx, courierID, parcelID, audit)
+	if err != nil {
+		return models.Delivery{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.Delivery{}, fmt.Errorf("Ошибка при назначении доставки: %w", err)
+	}
+
+	// Без хранилища посылок статус посылки не обновлялся в транзакции
+	s.syncParcelStatus(parcelID, StatusAssigned)
+
+	// Удаляем устаревшие данные из кэша
+	if s.cacheClient != nil {
+		ctx := context.Background()
+		keys := []string{
+			fmt.Sprintf("delivery:%d", delivery.ID),
+			fmt.Sprintf("delivery:parcel:%d", parcelID),
+			fmt.Sprintf("deliveries:courier:%d", courierID),
+			"deliveries:list",
+		}
+		for _, key := range keys {
+			if err := s.cacheClient.Delete(ctx, key); err != nil {
+				log.Printf("Ошибка при удалении кэша доставки: %v", err)
+			}
+		}
+	}
+
+	// Отправляем уведомление через WebSocket, если менеджер инициализирован
+	if s.wsManager != nil {
+		s.wsManager.BroadcastOrderStatusUpdate(s.orderTarget(delivery), delivery.Status)
+	}
+
+	if s.eta != nil {
+		s.eta.Notify(courierID)
+	}
+
+	return delivery, nil
+}
+
+// assignTx выполняет назначение в транзакции tx. Записи блокируются в одном порядке - посылка,
+// затем курьер, - поэтому параллельные назначения не приводят к взаимной блокировке
+func (s *DeliveryService) assignTx(tx *sql.Tx, courierID, parcelID int, audit models.AuditInfo) (models.Delivery, error) {
+	existing, reuse, err := s.currentDeliveryTx(tx, parcelID)
+	if err != nil {
+		return models.Delivery{}, err
+	}
+	if reuse {
+		current, err := ParseStatus(existing.Status)
+		if err != nil {
+			return models.Delivery{}, err
+		}
+		if !CanTransition(current, StatusAssigned) {
+			return models.Delivery{}, fmt.Errorf("%w: доставка %d в статусе %s", models.ErrParcelAlreadyAssigned, existing.ID, current)
+		}
+	}
+
+	if s.couriers != nil {
+		courier, err := s.couriers.GetForUpdate(tx, courierID)
+		if err != nil {
+			return models.Delivery{}, err
+		}
+		if courier.Status != models.CourierStatusAvailable {
+			return models.Delivery{}, fmt.Errorf("%w: курьер %d в статусе %s", models.ErrCourierUnavailable, courierID, courier.Status)
+		}
+	}
+
 	delivery := models.Delivery{
 		CourierID:  courierID,
 		ParcelID:   parcelID,
-		Status:     "assigned",
+		Status:     string(StatusAssigned),
 		AssignedAt: time.Now().UTC(),
 	}
 
-	id, err := s.store.Add(delivery)
-	if err != nil {
-		return models.Delivery{}, fmt.Errorf("Ошибка при создании доставки: %w", err)
+	previous := ""
+	if reuse {
+		delivery.ID = existing.ID
+		previous = existing.Status
+		if err := s.store.UpdateTx(tx, delivery); err != nil {
+			return models.Delivery{}, fmt.Errorf("Ошибка при назначении доставки: %w", err)
+		}
+	} else {
+		id, err := s.store.AddTx(tx, delivery)
+		if err != nil {
+			return models.Delivery{}, fmt.Errorf("Ошибка при создании доставки: %w", err)
+		}
+		delivery.ID = id
 	}
 
-	delivery.ID = id
+	if err := s.recordEvent(tx, delivery.ID, previous, delivery.Status, audit); err != nil {
+		return models.Delivery{}, err
+	}
 
-	// Отправляем уведомление через WebSocket, если менеджер инициализирован
-	if s.wsManager != nil {
-		s.wsManager.BroadcastOrderStatusUpdate(fmt.Sprintf("%d", id), "assigned")
+	if s.couriers != nil {
+		if err := s.couriers.SetStatusTx(tx, courierID, models.CourierStatusBusy); err != nil {
+			return models.Delivery{}, err
+		}
+	}
+	if s.parcelLocks != nil {
+		if err := s.parcelLocks.SetStatusTx(tx, parcelID, StatusAssigned.ParcelStatus()); err != nil {
+			return models.Delivery{}, err
+		}
+	}
+
+	if err := s.recordAssignment(tx, delivery, audit.CorrelationID); err != nil {
+		return models.Delivery{}, err
 	}
 
 	return delivery, nil
 }
+
+// currentDeliveryTx блокирует посылку parcelID в транзакции tx и возвращает ее текущую доставку.
+// Отмененная доставка текущей не считается: после отмены для посылки создается новая.
+// found = false, если текущей доставки у посылки нет
+func (s *DeliveryService) currentDeliveryTx(tx *sql.Tx, parcelID int) (d models.Delivery, found bool, err error) {
+	if s.parcelLocks != nil {
+		if _, err := s.parcelLocks.GetForUpdate(tx, parcelID); err != nil {
+			return models.Delivery{}, false, err
+		}
+	}
+
+	existing, err := s.store.GetByParcelIDTx(tx, parcelID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Delivery{}, false, nil
+	}
+	if err != nil {
+		return models.Delivery{}, false, fmt.Errorf("Ошибка при получении доставки посылки: %w", err)
+	}
+	if Status(existing.Status) == StatusCancelled {
+		return models.Delivery{}, false, nil
+	}
+	return existing, true, nil
+}
+
+// recordAssignment записывает в outbox транзакции назначения событие DeliveryAssigned,
+// а если статусы посылки и курьера изменены в этой же транзакции - и ParcelStatusChanged, CourierStatusChanged.
+// События относятся к цепочке correlationID
+func (s *DeliveryService) recordAssignment(tx *sql.Tx, delivery models.Delivery, correlationID string) error {
+	if s.outbox == nil {
+		return nil
+	}
+
+	events, err := outbox.Event(models.TopicDeliveries, delivery.ID, models.EventDeliveryAssigned, models.DeliveryAssignedEvent{
+		DeliveryID: delivery.ID,
+		ParcelID:   delivery.ParcelID,
+		CourierID:  delivery.CourierID,
+		AssignedAt: delivery.AssignedAt,
+	})
+	if err != nil {
+		return err
+	}
+	if s.parcelLocks != nil {
+		parcelEvents, err := parcelStatusEvent(delivery.ParcelID, StatusAssigned.ParcelStatus(), delivery.AssignedAt)
+		if err != nil {
+			return err
+		}
+		events = append(events, parcelEvents...)
+	}
+	// Назначается только доступный курьер
+	if s.couriers != nil {
+		courierEvents, err := courierStatusEvent(delivery.CourierID, models.CourierStatusAvailable, models.CourierStatusBusy, delivery.AssignedAt)
+		if err != nil {
+			return err
+		}
+		events = append(events, courierEvents...)
+	}
+
+	for _, event := range outbox.Correlate(events, correlationID) {
+		if err := s.outbox.AddTx(tx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// History возвращает журнал изменений доставки
+func (s *DeliveryService) History(deliveryID int) ([]models.DeliveryEvent, error) {
+	events, err := s.store.GetEvents(deliveryID)
+	if err != nil {
+		return nil, fmt.Errorf("Ошибка при получении журнала доставки: %w", err)
+	}
+	return events, nil
+}
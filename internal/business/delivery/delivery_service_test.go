@@ -2,11 +2,16 @@ package delivery
 
 import (
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
 	"regexp"
 	"testing"
 	"time"
 
+	"delivery/internal/business/courier"
 	"delivery/internal/business/models"
+	"delivery/internal/business/parcel"
+	"delivery/internal/outbox"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
@@ -21,10 +26,10 @@ func TestServiceGet(t *testing.T) {
 	service := NewDeliveryService(store)
 
 	// Указание конкретных колонок
-	rows := sqlmock.NewRows([]string{"id", "courier_id", "parcel_id", "status", "assigned_at", "delivered_at"}).
-		AddRow(1, 1, 2, "assigned", time.Now().UTC(), sql.NullTime{Valid: false})
+	rows := sqlmock.NewRows([]string{"id", "courier_id", "parcel_id", "status", "assigned_at", "delivered_at", "eta"}).
+		AddRow(1, 1, 2, "assigned", time.Now().UTC(), sql.NullTime{Valid: false}, nil)
 
-	mock.ExpectQuery("SELECT id, courier_id, parcel_id, status, assigned_at, delivered_at FROM delivery WHERE id = \\$1").
+	mock.ExpectQuery("SELECT id, courier_id, parcel_id, status, assigned_at, delivered_at, eta FROM delivery WHERE id = \\$1").
 		WithArgs(1).
 		WillReturnRows(rows)
 
@@ -35,17 +40,96 @@ func TestServiceGet(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestServiceCreate_WithoutCourier(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	service := NewDeliveryService(NewDeliveryStore(db))
+
+	// Доставка в статусе created сохраняется без курьера: courier_id записывается как NULL
+	mock.ExpectBegin()
+	mock.ExpectQuery(deliveryByParcel).WithArgs(2).WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("INSERT INTO delivery").
+		WithArgs(sql.NullInt64{}, 2, "created", sqlmock.AnyArg(), sql.NullTime{}).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	expectEvent(mock, 5, sql.NullString{}, "created", sql.NullInt64{})
+	mock.ExpectCommit()
+
+	delivery := &models.Delivery{ParcelID: 2}
+	assert.NoError(t, service.Create(delivery, models.AuditInfo{}))
+	assert.Equal(t, 5, delivery.ID)
+	assert.Equal(t, "created", delivery.Status)
+
+	// Доставка без курьера читается с нулевым CourierID
+	mock.ExpectQuery("SELECT id, courier_id, parcel_id, status, assigned_at, delivered_at, eta FROM delivery WHERE id = \\$1").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(deliveryRowColumns).AddRow(5, nil, 2, "created", time.Now().UTC(), nil, nil))
+	stored, err := service.Get(5)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, stored.CourierID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// deliveryRowColumns - колонки, которые возвращает DeliveryStore.Get
+var deliveryRowColumns = []string{"id", "courier_id", "parcel_id", "status", "assigned_at", "delivered_at", "eta"}
+
+func expectGetDelivery(mock sqlmock.Sqlmock, id int, status string) {
+	mock.ExpectQuery("SELECT id, courier_id, parcel_id, status, assigned_at, delivered_at, eta FROM delivery WHERE id = \\$1").
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows(deliveryRowColumns).AddRow(id, 1, 2, status, time.Now().UTC(), sql.NullTime{Valid: false}, nil))
+}
+
+// expectLockDelivery ожидает чтение доставки с блокировкой в транзакции изменения статуса
+func expectLockDelivery(mock sqlmock.Sqlmock, id int, status string) {
+	mock.ExpectQuery("SELECT id, courier_id, parcel_id, status, assigned_at, delivered_at, eta FROM delivery WHERE id = \\$1 FOR UPDATE").
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows(deliveryRowColumns).AddRow(id, 1, 2, status, time.Now().UTC(), sql.NullTime{Valid: false}, nil))
+}
+
+func expectEvent(mock sqlmock.Sqlmock, deliveryID int, oldStatus, newStatus interface{}, actorID interface{}) {
+	mock.ExpectQuery("INSERT INTO delivery_events").
+		WithArgs(deliveryID, oldStatus, newStatus, actorID, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+}
+
+// fakeParcels запоминает статусы, в которые переводились посылки
+type fakeParcels map[int]string
+
+func (f fakeParcels) Get(id int) (*models.Parcel, error) {
+	return &models.Parcel{ID: id, Status: f[id]}, nil
+}
+
+func (f fakeParcels) UpdateStatus(id int, status string) error {
+	f[id] = status
+	return nil
+}
+
+// fakeETA запоминает курьеров, для которых запрошен пересчет ожидаемого времени
+type fakeETA []int
+
+func (f *fakeETA) Notify(courierID int) {
+	*f = append(*f, courierID)
+}
+
 func TestServiceUpdate(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	assert.NoError(t, err)
 	defer db.Close()
 
 	store := NewDeliveryStore(db)
-	service := NewDeliveryService(store)
+	parcels := fakeParcels{}
+	eta := &fakeETA{}
+	service := NewDeliveryService(store).WithParcels(parcels).WithETA(eta)
 
+	// Статус читается с блокировкой, доставка и запись журнала сохраняются в одной транзакции
+	mock.ExpectBegin()
+	expectLockDelivery(mock, 1, "in_transit")
 	mock.ExpectExec(regexp.QuoteMeta("UPDATE delivery SET courier_id = $1, parcel_id = $2, status = $3, assigned_at = $4, delivered_at = $5 WHERE id = $6")).
 		WithArgs(1, 2, "delivered", sqlmock.AnyArg(), sqlmock.AnyArg(), 1).
 		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectEvent(mock, 1, sql.NullString{String: "in_transit", Valid: true}, "delivered", sql.NullInt64{Int64: 3, Valid: true})
+	mock.ExpectCommit()
 
 	delivery := &models.Delivery{
 		ID:          1,
@@ -56,7 +140,645 @@ func TestServiceUpdate(t *testing.T) {
 		DeliveredAt: time.Now().UTC(),
 	}
 
-	err = service.Update(1, delivery)
+	err = service.Update(1, delivery, models.AuditInfo{ActorID: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, models.ParcelStatusDelivered, parcels[2])
+	assert.Equal(t, []int{1}, []int(*eta))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestServiceSetETA(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	service := NewDeliveryService(NewDeliveryStore(db))
+	eta := time.Date(2026, 5, 1, 15, 30, 0, 0, time.FixedZone("MSK", 3*60*60))
+
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE delivery SET eta = $1 WHERE id = $2")).
+		WithArgs(eta.UTC(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, service.SetETA(1, 2, eta))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestServiceSetETA_ReadBack(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	service := NewDeliveryService(NewDeliveryStore(db))
+	eta := time.Date(2026, 5, 1, 12, 30, 0, 0, time.UTC)
+
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE delivery SET eta = $1 WHERE id = $2")).
+		WithArgs(eta, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, service.SetETA(1, 2, eta))
+
+	// Сохраненное время возвращается при чтении доставки по ID и по посылке (для отслеживания)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM delivery WHERE id = $1")).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(deliveryRowColumns).AddRow(1, 1, 2, "in_transit", time.Now().UTC(), nil, eta))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM delivery WHERE parcel_id = $1")).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(deliveryRowColumns).AddRow(1, 1, 2, "in_transit", time.Now().UTC(), nil, eta))
+
+	delivery, err := service.Get(1)
+	assert.NoError(t, err)
+	if assert.NotNil(t, delivery.ETA) {
+		assert.Equal(t, eta, *delivery.ETA)
+	}
+	delivery, err = service.GetByParcelID(2)
+	assert.NoError(t, err)
+	if assert.NotNil(t, delivery.ETA) {
+		assert.Equal(t, eta, *delivery.ETA)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestServiceUpdate_InvalidTransition(t *testing.T) {
+	tests := []struct {
+		name    string
+		current string
+		next    string
+		wantErr error
+	}{
+		{"Пропуск этапа", "assigned", "delivered", models.ErrInvalidStatusTransition},
+		{"Из конечного статуса", "delivered", "in_transit", models.ErrInvalidStatusTransition},
+		{"Неизвестный статус", "assigned", "lost", models.ErrUnknownDeliveryStatus},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			service := NewDeliveryService(NewDeliveryStore(db))
+			if tt.wantErr != models.ErrUnknownDeliveryStatus {
+				mock.ExpectBegin()
+				expectLockDelivery(mock, 1, tt.current)
+				mock.ExpectRollback()
+			}
+
+			err = service.Update(1, &models.Delivery{CourierID: 1, ParcelID: 2, Status: tt.next}, models.AuditInfo{ActorID: 3})
+			assert.ErrorIs(t, err, tt.wantErr)
+			// UPDATE не выполняется, событие не записывается
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestServiceCompleteDelivery(t *testing.T) {
+	t.Run("Из статуса in_transit", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		parcels := fakeParcels{}
+		service := NewDeliveryService(NewDeliveryStore(db)).WithParcels(parcels)
+
+		mock.ExpectBegin()
+		expectLockDelivery(mock, 1, "in_transit")
+		mock.ExpectExec("UPDATE delivery SET").
+			WithArgs(1, 2, "delivered", sqlmock.AnyArg(), sqlmock.AnyArg(), 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectEvent(mock, 1, sqlmock.AnyArg(), "delivered", sqlmock.AnyArg())
+		mock.ExpectCommit()
+
+		assert.NoError(t, service.CompleteDelivery(1, models.AuditInfo{}))
+		assert.Equal(t, models.ParcelStatusDelivered, parcels[2])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Посылка еще не забрана", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		service := NewDeliveryService(NewDeliveryStore(db))
+		mock.ExpectBegin()
+		expectLockDelivery(mock, 1, "assigned")
+		mock.ExpectRollback()
+
+		assert.ErrorIs(t, service.CompleteDelivery(1, models.AuditInfo{}), models.ErrInvalidStatusTransition)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestServiceCompleteDelivery_Outbox(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	store := NewDeliveryStore(db)
+	service := NewDeliveryService(store).WithOutbox(outbox.NewStore(db))
+
+	// Доставка, запись журнала и событие DeliveryCompleted сохраняются в одной транзакции;
+	// событие продолжает цепочку запроса
+	mock.ExpectBegin()
+	expectLockDelivery(mock, 1, "in_transit")
+	mock.ExpectExec("UPDATE delivery SET").
+		WithArgs(1, 2, "delivered", sqlmock.AnyArg(), sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectEvent(mock, 1, sqlmock.AnyArg(), "delivered", sqlmock.AnyArg())
+	mock.ExpectExec("INSERT INTO outbox").
+		WithArgs(models.TopicDeliveries, "1", models.EventDeliveryCompleted, 1, sqlmock.AnyArg(), sqlmock.AnyArg(), "req-42").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	assert.NoError(t, service.CompleteDelivery(1, models.AuditInfo{CorrelationID: "req-42"}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// Если событие не записано, доставка не изменяется
+	mock.ExpectBegin()
+	expectLockDelivery(mock, 1, "in_transit")
+	mock.ExpectExec("UPDATE delivery SET").WillReturnResult(sqlmock.NewResult(0, 1))
+	expectEvent(mock, 1, sqlmock.AnyArg(), "delivered", sqlmock.AnyArg())
+	mock.ExpectExec("INSERT INTO outbox").WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
+
+	assert.ErrorIs(t, service.CompleteDelivery(1, models.AuditInfo{}), sql.ErrConnDone)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// Если не записан журнал, доставка не изменяется
+	mock.ExpectBegin()
+	expectLockDelivery(mock, 1, "in_transit")
+	mock.ExpectExec("UPDATE delivery SET").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO delivery_events").WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
+
+	assert.ErrorIs(t, service.CompleteDelivery(1, models.AuditInfo{}), sql.ErrConnDone)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// parcelForUpdate - чтение посылки с блокировкой в ParcelStore.GetForUpdate
+var parcelForUpdate = regexp.QuoteMeta("SELECT id, client_id, address, status, created_at, tracking_number, street, city, postal_code, country, lat, lon, zone_id FROM parcels WHERE id = $1 FOR UPDATE")
+
+// courierForUpdate - чтение курьера с блокировкой в CourierStore.GetForUpdate
+var courierForUpdate = regexp.QuoteMeta("SELECT id, user_id, name, phone, email, vehicle_id, vehicle_type, status FROM courier WHERE id = $1 FOR UPDATE")
+
+// deliveryByParcel - чтение последней доставки посылки в DeliveryStore.GetByParcelIDTx
+var deliveryByParcel = regexp.QuoteMeta("SELECT id, courier_id, parcel_id, status, assigned_at, delivered_at, eta FROM delivery WHERE parcel_id = $1")
+
+// parcelRow возвращает строку посылки 2 в статусе status
+func parcelRow(status string) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "client_id", "address", "status", "created_at", "tracking_number",
+		"street", "city", "postal_code", "country", "lat", "lon", "zone_id"}).
+		AddRow(2, 10, "ул. Ленина, 1", status, time.Now().UTC().Format(time.RFC3339), "DL123456789015",
+			nil, nil, nil, nil, nil, nil, nil)
+}
+
+// expectLockWithParcel ожидает блокировку посылки 2 и затем доставки id в транзакции изменения статуса
+func expectLockWithParcel(mock sqlmock.Sqlmock, id int, status string) {
+	expectGetDelivery(mock, id, status)
+	mock.ExpectQuery(parcelForUpdate).WithArgs(2).WillReturnRows(parcelRow("in_transit"))
+	expectLockDelivery(mock, id, status)
+}
+
+func TestServiceAssignDelivery(t *testing.T) {
+	courierRow := func(status string) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "user_id", "name", "phone", "email", "vehicle_id", "vehicle_type", "status"}).
+			AddRow(7, nil, "Иван", "+7900", "ivan@example.com", nil, nil, status)
+	}
+	deliveryRow := func(status string) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "courier_id", "parcel_id", "status", "assigned_at", "delivered_at", "eta"}).
+			AddRow(5, 3, 2, status, time.Now().UTC(), nil, nil)
+	}
+
+	newService := func(t *testing.T) (*DeliveryService, sqlmock.Sqlmock, fakeParcels) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+
+		parcels := fakeParcels{}
+		service := NewDeliveryService(NewDeliveryStore(db)).
+			WithParcels(parcels).
+			WithAssignmentStores(courier.NewCourierStore(db), parcel.NewParcelStore(db))
+		return service, mock, parcels
+	}
+
+	t.Run("Новая доставка", func(t *testing.T) {
+		service, mock, parcels := newService(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(parcelForUpdate).WithArgs(2).WillReturnRows(parcelRow("registered"))
+		mock.ExpectQuery(deliveryByParcel).WithArgs(2).WillReturnError(sql.ErrNoRows)
+		mock.ExpectQuery(courierForUpdate).WithArgs(7).WillReturnRows(courierRow("available"))
+		mock.ExpectQuery("INSERT INTO delivery").
+			WithArgs(7, 2, "assigned", sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+		// Событие без предыдущего статуса и без пользователя (назначение системой)
+		expectEvent(mock, 5, sql.NullString{}, "assigned", sql.NullInt64{})
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE courier SET status = $1 WHERE id = $2")).
+			WithArgs("busy", 7).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE parcels SET status = $1 WHERE id = $2")).
+			WithArgs("sent", 2).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		delivery, err := service.AssignDelivery(7, 2, models.AuditInfo{})
+		assert.NoError(t, err)
+		assert.Equal(t, 5, delivery.ID)
+		// Статус посылки обновлен в транзакции, а не отдельным вызовом
+		assert.Empty(t, parcels)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Повторное назначение после неудачи", func(t *testing.T) {
+		service, mock, _ := newService(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(parcelForUpdate).WithArgs(2).WillReturnRows(parcelRow("delivery_failed"))
+		mock.ExpectQuery(deliveryByParcel).WithArgs(2).WillReturnRows(deliveryRow("failed"))
+		mock.ExpectQuery(courierForUpdate).WithArgs(7).WillReturnRows(courierRow("available"))
+		mock.ExpectExec("UPDATE delivery SET").
+			WithArgs(7, 2, "assigned", sqlmock.AnyArg(), sqlmock.AnyArg(), 5).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectEvent(mock, 5, sql.NullString{String: "failed", Valid: true}, "assigned", sql.NullInt64{Int64: 9, Valid: true})
+		mock.ExpectExec("UPDATE courier SET status").WithArgs("busy", 7).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE parcels SET status").WithArgs("sent", 2).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		delivery, err := service.AssignDelivery(7, 2, models.AuditInfo{ActorID: 9, Note: "Повторная попытка"})
+		assert.NoError(t, err)
+		assert.Equal(t, 5, delivery.ID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("События назначения", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+		service := NewDeliveryService(NewDeliveryStore(db)).
+			WithAssignmentStores(courier.NewCourierStore(db), parcel.NewParcelStore(db)).
+			WithOutbox(outbox.NewStore(db))
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(parcelForUpdate).WithArgs(2).WillReturnRows(parcelRow("registered"))
+		mock.ExpectQuery(deliveryByParcel).WithArgs(2).WillReturnError(sql.ErrNoRows)
+		mock.ExpectQuery(courierForUpdate).WithArgs(7).WillReturnRows(courierRow("available"))
+		mock.ExpectQuery("INSERT INTO delivery").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+		expectEvent(mock, 5, sql.NullString{}, "assigned", sql.NullInt64{})
+		mock.ExpectExec("UPDATE courier SET status").WithArgs("busy", 7).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE parcels SET status").WithArgs("sent", 2).WillReturnResult(sqlmock.NewResult(0, 1))
+		// События пишутся в транзакцию назначения и продолжают цепочку события регистрации посылки
+		for _, args := range [][]interface{}{
+			{models.TopicDeliveries, "5", models.EventDeliveryAssigned},
+			{models.TopicParcels, "2", models.EventParcelStatusChanged},
+			{models.TopicCouriers, "7", models.EventCourierStatusChanged},
+		} {
+			mock.ExpectExec("INSERT INTO outbox").
+				WithArgs(args[0], args[1], args[2], 1, sqlmock.AnyArg(), sqlmock.AnyArg(), "31").
+				WillReturnResult(sqlmock.NewResult(1, 1))
+		}
+		mock.ExpectCommit()
+
+		_, err = service.AssignDelivery(7, 2, models.AuditInfo{CorrelationID: "31"})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	conflicts := []struct {
+		name    string
+		expect  func(mock sqlmock.Sqlmock)
+		wantErr error
+	}{
+		{"Посылка не найдена", func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery(parcelForUpdate).WithArgs(2).WillReturnError(sql.ErrNoRows)
+		}, models.ErrParcelNotFound},
+		{"Посылка уже в доставке", func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery(parcelForUpdate).WithArgs(2).WillReturnRows(parcelRow("in_transit"))
+			mock.ExpectQuery(deliveryByParcel).WithArgs(2).WillReturnRows(deliveryRow("in_transit"))
+		}, models.ErrParcelAlreadyAssigned},
+		{"Курьер занят", func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery(parcelForUpdate).WithArgs(2).WillReturnRows(parcelRow("registered"))
+			mock.ExpectQuery(deliveryByParcel).WithArgs(2).WillReturnError(sql.ErrNoRows)
+			mock.ExpectQuery(courierForUpdate).WithArgs(7).WillReturnRows(courierRow("busy"))
+		}, models.ErrCourierUnavailable},
+		{"Курьер не найден", func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery(parcelForUpdate).WithArgs(2).WillReturnRows(parcelRow("registered"))
+			mock.ExpectQuery(deliveryByParcel).WithArgs(2).WillReturnError(sql.ErrNoRows)
+			mock.ExpectQuery(courierForUpdate).WithArgs(7).WillReturnError(sql.ErrNoRows)
+		}, models.ErrCourierNotFound},
+	}
+
+	for _, tt := range conflicts {
+		t.Run(tt.name, func(t *testing.T) {
+			service, mock, _ := newService(t)
+
+			mock.ExpectBegin()
+			tt.expect(mock)
+			// Ничего не записано, транзакция откатывается
+			mock.ExpectRollback()
+
+			_, err := service.AssignDelivery(7, 2, models.AuditInfo{})
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestServiceUpdate_ReleasesCourier(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	parcels := fakeParcels{}
+	service := NewDeliveryService(NewDeliveryStore(db)).
+		WithParcels(parcels).
+		WithAssignmentStores(courier.NewCourierStore(db), parcel.NewParcelStore(db)).
+		WithOutbox(outbox.NewStore(db))
+
+	// Статусы посылки и курьера меняются в транзакции доставки вместе с их событиями
+	mock.ExpectBegin()
+	expectLockWithParcel(mock, 1, "in_transit")
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE parcels SET status = $1 WHERE id = $2")).
+		WithArgs("delivery_failed", 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE delivery SET").WillReturnResult(sqlmock.NewResult(0, 1))
+	expectEvent(mock, 1, sqlmock.AnyArg(), "failed", sqlmock.AnyArg())
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, user_id, name, phone, email, vehicle_id, vehicle_type, status FROM courier WHERE id = $1 FOR UPDATE")).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "name", "phone", "email", "vehicle_id", "vehicle_type", "status"}).
+			AddRow(1, nil, "Иван", "+7900", "ivan@example.com", nil, nil, "busy"))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE courier SET status = $1 WHERE id = $2")).
+		WithArgs("available", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO outbox").
+		WithArgs(models.TopicDeliveries, "1", models.EventDeliveryStatusChanged, 1, sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO outbox").
+		WithArgs(models.TopicParcels, "2", models.EventParcelStatusChanged, 1, sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec("INSERT INTO outbox").
+		WithArgs(models.TopicCouriers, "1", models.EventCourierStatusChanged, 1, sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectCommit()
+
+	err = service.Update(1, &models.Delivery{CourierID: 1, ParcelID: 2, Status: "failed"}, models.AuditInfo{})
+	assert.NoError(t, err)
+	// Статус посылки не обновляется отдельным вызовом
+	assert.Empty(t, parcels)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// Если курьера не удалось освободить, доставка не изменяется
+	mock.ExpectBegin()
+	expectLockWithParcel(mock, 1, "in_transit")
+	mock.ExpectExec("UPDATE parcels SET status").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE delivery SET").WillReturnResult(sqlmock.NewResult(0, 1))
+	expectEvent(mock, 1, sqlmock.AnyArg(), "failed", sqlmock.AnyArg())
+	mock.ExpectQuery("FROM courier WHERE id = \\$1 FOR UPDATE").WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
+
+	err = service.Update(1, &models.Delivery{Status: "failed"}, models.AuditInfo{})
+	assert.ErrorIs(t, err, sql.ErrConnDone)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestServiceUpdate_ConcurrentTransitions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	service := NewDeliveryService(NewDeliveryStore(db))
+
+	// Оба запроса видели доставку в статусе in_transit. Блокировка строки упорядочивает их:
+	// первый вручает посылку, второй читает доставку после фиксации первого и получает delivered
+	mock.ExpectBegin()
+	expectLockDelivery(mock, 1, "in_transit")
+	mock.ExpectExec("UPDATE delivery SET").
+		WithArgs(1, 2, "delivered", sqlmock.AnyArg(), sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectEvent(mock, 1, sqlmock.AnyArg(), "delivered", sqlmock.AnyArg())
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	expectLockDelivery(mock, 1, "delivered")
+	mock.ExpectRollback()
+
+	assert.NoError(t, service.CompleteDelivery(1, models.AuditInfo{}))
+	// Переход проверен по заблокированной строке, поэтому вторая запись не перезаписывает первую
+	err = service.Update(1, &models.Delivery{Status: "failed"}, models.AuditInfo{})
+	assert.ErrorIs(t, err, models.ErrInvalidStatusTransition)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestServiceUpdate_NoStatusChange(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	service := NewDeliveryService(NewDeliveryStore(db))
+
+	// Статус не изменился - доставка не обновляется, журнал не пополняется
+	mock.ExpectBegin()
+	expectLockDelivery(mock, 1, "in_transit")
+	mock.ExpectCommit()
+
+	err = service.Update(1, &models.Delivery{CourierID: 1, ParcelID: 2, Status: "in_transit"}, models.AuditInfo{})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestServiceUpdate_StatusOnly(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	parcels := fakeParcels{}
+	service := NewDeliveryService(NewDeliveryStore(db)).WithParcels(parcels)
+
+	// Курьер, посылка и время назначения берутся из сохраненной доставки
+	mock.ExpectBegin()
+	expectLockDelivery(mock, 1, "assigned")
+	mock.ExpectExec("UPDATE delivery SET").
+		WithArgs(1, 2, "picked_up", sqlmock.AnyArg(), sql.NullTime{}, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectEvent(mock, 1, sqlmock.AnyArg(), "picked_up", sqlmock.AnyArg())
+	mock.ExpectCommit()
+
+	err = service.Update(1, &models.Delivery{Status: "picked_up"}, models.AuditInfo{})
+	assert.NoError(t, err)
+	assert.Equal(t, models.ParcelStatusInTransit, parcels[2])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestServiceUpdate_CourierAndParcelFixed(t *testing.T) {
+	for name, input := range map[string]models.Delivery{
+		"Другой курьер":  {CourierID: 9, Status: "picked_up"},
+		"Другая посылка": {CourierID: 1, ParcelID: 5, Status: "picked_up"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			service := NewDeliveryService(NewDeliveryStore(db))
+			mock.ExpectBegin()
+			expectLockDelivery(mock, 1, "assigned")
+			mock.ExpectRollback()
+
+			err = service.Update(1, &input, models.AuditInfo{})
+			assert.ErrorIs(t, err, models.ErrInvalidStatusTransition)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestServiceUpdate_AssignOnlyViaAssignDelivery(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	service := NewDeliveryService(NewDeliveryStore(db))
+
+	// Запрос отклоняется до обращения к БД
+	err = service.Update(1, &models.Delivery{CourierID: 7, ParcelID: 2, Status: "assigned"}, models.AuditInfo{})
+	assert.ErrorIs(t, err, models.ErrInvalidStatusTransition)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestServiceDelete_KeepsHistory(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	service := NewDeliveryService(NewDeliveryStore(db))
+
+	mock.ExpectBegin()
+	expectLockDelivery(mock, 1, "cancelled")
+	mock.ExpectExec("DELETE FROM delivery WHERE id = \\$1").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectEvent(mock, 1, sql.NullString{String: "cancelled", Valid: true}, "deleted", sql.NullInt64{Int64: 4, Valid: true})
+	mock.ExpectCommit()
+
+	assert.NoError(t, service.Delete(1, models.AuditInfo{ActorID: 4}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// deletedPayload проверяет данные события DeliveryDeleted без времени удаления
+type deletedPayload models.DeliveryDeletedEvent
+
+func (p deletedPayload) Match(v driver.Value) bool {
+	data, ok := v.([]byte)
+	var got models.DeliveryDeletedEvent
+	if !ok || json.Unmarshal(data, &got) != nil || got.DeletedAt.IsZero() {
+		return false
+	}
+	got.DeletedAt = time.Time{}
+	return got == models.DeliveryDeletedEvent(p)
+}
+
+func TestServiceDelete_OpenDelivery(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	service := NewDeliveryService(NewDeliveryStore(db)).
+		WithAssignmentStores(courier.NewCourierStore(db), parcel.NewParcelStore(db)).
+		WithOutbox(outbox.NewStore(db))
+
+	// Удаление незавершенной доставки освобождает курьера и возвращает посылку в registered в той же транзакции
+	mock.ExpectBegin()
+	expectLockWithParcel(mock, 1, "picked_up")
+	mock.ExpectExec("DELETE FROM delivery WHERE id = \\$1").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectEvent(mock, 1, sql.NullString{String: "picked_up", Valid: true}, "deleted", sql.NullInt64{})
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE parcels SET status = $1 WHERE id = $2")).
+		WithArgs("registered", 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(courierForUpdate).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "name", "phone", "email", "vehicle_id", "vehicle_type", "status"}).
+			AddRow(1, nil, "Иван", "+7900", "ivan@example.com", nil, nil, "busy"))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE courier SET status = $1 WHERE id = $2")).
+		WithArgs("available", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	// Получатели событий узнают об удалении доставки и статусе, в котором она была удалена
+	mock.ExpectExec("INSERT INTO outbox").
+		WithArgs(models.TopicDeliveries, "1", models.EventDeliveryDeleted, 1,
+			deletedPayload{DeliveryID: 1, ParcelID: 2, CourierID: 1, Status: "picked_up"}, sqlmock.AnyArg(), nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO outbox").
+		WithArgs(models.TopicParcels, "2", models.EventParcelStatusChanged, 1, sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec("INSERT INTO outbox").
+		WithArgs(models.TopicCouriers, "1", models.EventCourierStatusChanged, 1, sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectCommit()
+
+	assert.NoError(t, service.Delete(1, models.AuditInfo{}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// Если курьера не удалось освободить, доставка не удаляется
+	mock.ExpectBegin()
+	expectLockWithParcel(mock, 1, "assigned")
+	mock.ExpectExec("DELETE FROM delivery").WillReturnResult(sqlmock.NewResult(0, 1))
+	expectEvent(mock, 1, sqlmock.AnyArg(), "deleted", sqlmock.AnyArg())
+	mock.ExpectExec("UPDATE parcels SET status").WithArgs("registered", 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(courierForUpdate).WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
+
+	assert.ErrorIs(t, service.Delete(1, models.AuditInfo{}), sql.ErrConnDone)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestServiceCreate_ParcelAlreadyHasDelivery(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	service := NewDeliveryService(NewDeliveryStore(db)).
+		WithAssignmentStores(courier.NewCourierStore(db), parcel.NewParcelStore(db))
+
+	// Посылка блокируется, как при назначении, и вторая доставка для нее не создается
+	mock.ExpectBegin()
+	mock.ExpectQuery(parcelForUpdate).WithArgs(2).WillReturnRows(parcelRow("sent"))
+	mock.ExpectQuery(deliveryByParcel).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(deliveryRowColumns).AddRow(5, 3, 2, "assigned", time.Now().UTC(), nil, nil))
+	mock.ExpectRollback()
+
+	err = service.Create(&models.Delivery{ParcelID: 2}, models.AuditInfo{})
+	assert.ErrorIs(t, err, models.ErrParcelAlreadyAssigned)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// После отмены доставки посылке можно создать новую
+	mock.ExpectBegin()
+	mock.ExpectQuery(parcelForUpdate).WithArgs(2).WillReturnRows(parcelRow("registered"))
+	mock.ExpectQuery(deliveryByParcel).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(deliveryRowColumns).AddRow(5, nil, 2, "cancelled", time.Now().UTC(), nil, nil))
+	mock.ExpectQuery("INSERT INTO delivery").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(6))
+	expectEvent(mock, 6, sql.NullString{}, "created", sql.NullInt64{})
+	mock.ExpectExec("UPDATE parcels SET status").WithArgs("registered", 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	delivery := &models.Delivery{ParcelID: 2}
+	assert.NoError(t, service.Create(delivery, models.AuditInfo{}))
+	assert.Equal(t, 6, delivery.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// Если посылки нет, доставка не создается
+	mock.ExpectBegin()
+	mock.ExpectQuery(parcelForUpdate).WithArgs(2).WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	err = service.Create(&models.Delivery{ParcelID: 2}, models.AuditInfo{})
+	assert.ErrorIs(t, err, models.ErrParcelNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestServiceHistory(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	service := NewDeliveryService(NewDeliveryStore(db))
+
+	created := time.Now().UTC()
+	mock.ExpectQuery("SELECT id, delivery_id, old_status, new_status, actor_id, note, created_at FROM delivery_events").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "delivery_id", "old_status", "new_status", "actor_id", "note", "created_at"}).
+			AddRow(1, 1, nil, "assigned", nil, nil, created).
+			AddRow(2, 1, "assigned", "picked_up", 3, "Забрал со склада", created.Add(time.Hour)))
+
+	events, err := service.History(1)
 	assert.NoError(t, err)
+	assert.Equal(t, []models.DeliveryEvent{
+		{ID: 1, DeliveryID: 1, NewStatus: "assigned", CreatedAt: created},
+		{ID: 2, DeliveryID: 1, OldStatus: "assigned", NewStatus: "picked_up", ActorID: 3, Note: "Забрал со склада", CreatedAt: created.Add(time.Hour)},
+	}, events)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
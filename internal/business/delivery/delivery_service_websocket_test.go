@@ -1,6 +1,7 @@
 package delivery
 
 import (
+	"database/sql"
 	"delivery/internal/api"
 	"delivery/internal/business/models"
 	"net/http"
@@ -9,6 +10,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -49,6 +51,21 @@ func (m *MockDeliveryStore) GetByParcelID(parcelID int) (models.Delivery, error)
 	return args.Get(0).(models.Delivery), args.Error(1)
 }
 
+// subscribe подписывает клиента на канал и дожидается подтверждения
+func subscribe(t *testing.T, ws *websocket.Conn, channel string, id int) {
+	t.Helper()
+	if err := ws.WriteJSON(map[string]interface{}{"action": "subscribe", "channel": channel, "id": id}); err != nil {
+		t.Fatalf("Ошибка при отправке подписки: %v", err)
+	}
+	var reply map[string]interface{}
+	if err := ws.ReadJSON(&reply); err != nil {
+		t.Fatalf("Ошибка при чтении подтверждения подписки: %v", err)
+	}
+	if reply["type"] != "SUBSCRIBED" {
+		t.Fatalf("ответ на подписку = %v, want SUBSCRIBED", reply)
+	}
+}
+
 // TestWebSocketIntegration - тест для демонстрации интеграции WebSocket
 func TestWebSocketIntegration(t *testing.T) {
 	// Создаем WebSocket сервер для тестирования
@@ -88,8 +105,11 @@ func TestWebSocketIntegration(t *testing.T) {
 	// В этом тесте мы не используем DeliveryService напрямую,
 	// а просто проверяем, что WebSocket менеджер работает корректно
 
+	// Подписываемся на обновления заказа и ждем подтверждения подписки
+	subscribe(t, ws, api.ChannelOrder, 1)
+
 	// Отправляем тестовое сообщение через WebSocket менеджер
-	wsManager.BroadcastOrderStatusUpdate("1", "delivered")
+	wsManager.BroadcastOrderStatusUpdate(api.OrderTarget{OrderID: 1, CourierID: 200}, "delivered")
 
 	// Ожидаем получения сообщения от WebSocket сервера
 	messageType, message, err := ws.ReadMessage()
@@ -104,6 +124,68 @@ func TestWebSocketIntegration(t *testing.T) {
 	assert.Contains(t, string(message), "delivered")
 }
 
+// TestWebSocketETAUpdate проверяет формат сообщения об изменении ожидаемого времени доставки
+func TestWebSocketETAUpdate(t *testing.T) {
+	wsManager := api.NewWebSocketManager()
+	go wsManager.Run()
+
+	server := httptest.NewServer(http.HandlerFunc(wsManager.WebSocketHandler))
+	defer server.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Не удалось подключиться к WebSocket серверу: %v", err)
+	}
+	defer ws.Close()
+
+	// Подписка на курьера доставляет обновления всех его заказов
+	subscribe(t, ws, api.ChannelCourier, 3)
+
+	eta := time.Date(2026, 5, 1, 12, 30, 0, 0, time.UTC)
+	wsManager.BroadcastETAUpdate(api.OrderTarget{OrderID: 7, CourierID: 3}, eta)
+
+	var update api.ETAUpdate
+	if err := ws.ReadJSON(&update); err != nil {
+		t.Fatalf("Ошибка при чтении сообщения: %v", err)
+	}
+	assert.Equal(t, "ETA_UPDATE", update.Type)
+	assert.Equal(t, "7", update.OrderID)
+	assert.Equal(t, eta.UnixMilli(), update.ETA)
+}
+
+// TestCompleteDelivery_BroadcastAfterCommit проверяет, что о доставке сообщается только после ее сохранения
+func TestCompleteDelivery_BroadcastAfterCommit(t *testing.T) {
+	wsManager := api.NewWebSocketManager()
+	go wsManager.Run()
+
+	server := httptest.NewServer(http.HandlerFunc(wsManager.WebSocketHandler))
+	defer server.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Не удалось подключиться к WebSocket серверу: %v", err)
+	}
+	defer ws.Close()
+	subscribe(t, ws, api.ChannelOrder, 1)
+
+	db, dbMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	service := NewDeliveryService(NewDeliveryStore(db)).WithWebSocket(wsManager)
+
+	// Доставка не сохранена - уведомления нет
+	dbMock.ExpectBegin()
+	expectLockDelivery(dbMock, 1, "in_transit")
+	dbMock.ExpectExec("UPDATE delivery SET").WillReturnError(sql.ErrConnDone)
+	dbMock.ExpectRollback()
+
+	assert.ErrorIs(t, service.CompleteDelivery(1, models.AuditInfo{}), sql.ErrConnDone)
+	ws.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	_, _, err = ws.ReadMessage()
+	assert.Error(t, err, "уведомление отправлено до сохранения доставки")
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
 // Пример того, как можно было бы расширить DeliveryService для поддержки WebSocket
 /*
 // Добавляем поле wsManager в структуру DeliveryService
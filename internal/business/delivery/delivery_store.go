@@ -4,23 +4,60 @@ import (
 	"database/sql"
 	"delivery/internal/business/models"
 	"fmt"
+	"time"
 )
 
 type DeliveryStore struct {
-	db        *sql.DB
-	tableName string
+	db          *sql.DB
+	tableName   string
+	eventsTable string
 }
 
 func NewDeliveryStore(db *sql.DB) *DeliveryStore {
 	return &DeliveryStore{
-		db:        db,
-		tableName: "delivery",
+		db:          db,
+		tableName:   "delivery",
+		eventsTable: "delivery_events",
 	}
 }
 
+// rowScanner - общий интерфейс для *sql.Row и *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// querier - общий интерфейс *sql.DB и *sql.Tx
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// BeginTx начинает транзакцию, в которой можно вызывать методы с суффиксом Tx
+func (s *DeliveryStore) BeginTx() (*sql.Tx, error) {
+	return s.db.Begin()
+}
+
+// conn возвращает транзакцию tx или, если tx == nil, соединение с БД
+func (s *DeliveryStore) conn(tx *sql.Tx) querier {
+	if tx == nil {
+		return s.db
+	}
+	return tx
+}
+
 // Методы для управления данными доставок в БД
 
 func (s *DeliveryStore) Add(d models.Delivery) (int, error) {
+	return s.add(s.db, d)
+}
+
+// AddTx добавляет доставку в транзакции tx; без транзакции, если tx == nil
+func (s *DeliveryStore) AddTx(tx *sql.Tx, d models.Delivery) (int, error) {
+	return s.add(s.conn(tx), d)
+}
+
+func (s *DeliveryStore) add(q querier, d models.Delivery) (int, error) {
 	query := fmt.Sprintf(`INSERT INTO %s (courier_id, parcel_id, status, assigned_at, delivered_at) 
               VALUES ($1, $2, $3, $4, $5) RETURNING id`, s.tableName)
 
@@ -30,44 +67,102 @@ func (s *DeliveryStore) Add(d models.Delivery) (int, error) {
 		deliveredAt = sql.NullTime{Time: d.DeliveredAt, Valid: true}
 	}
 
-	err := s.db.QueryRow(query, d.CourierID, d.ParcelID, d.Status, d.AssignedAt, deliveredAt).Scan(&id)
+	err := q.QueryRow(query, nullInt(d.CourierID), d.ParcelID, d.Status, d.AssignedAt, deliveredAt).Scan(&id)
 	if err != nil {
 		return 0, fmt.Errorf("ошибка при добавлении доставки: %w", err)
 	}
 	return id, nil
 }
 
+// Колонки, которые читаются при выборке доставки
+const deliveryColumns = "id, courier_id, parcel_id, status, assigned_at, delivered_at, eta"
+
+func scanDelivery(row rowScanner) (models.Delivery, error) {
+	var d models.Delivery
+	var courierID sql.NullInt64
+	var deliveredAt, eta sql.NullTime
+	err := row.Scan(&d.ID, &courierID, &d.ParcelID, &d.Status, &d.AssignedAt, &deliveredAt, &eta)
+	if err != nil {
+		return d, err
+	}
+
+	// У доставки в статусе created курьера нет
+	d.CourierID = int(courierID.Int64)
+	if deliveredAt.Valid {
+		d.DeliveredAt = deliveredAt.Time
+	}
+	if eta.Valid {
+		d.ETA = &eta.Time
+	}
+	return d, nil
+}
+
 func (s *DeliveryStore) Get(id int) (models.Delivery, error) {
-	query := fmt.Sprintf(`SELECT id, courier_id, parcel_id, status, assigned_at, delivered_at FROM %s WHERE id = $1`, s.tableName)
-	row := s.db.QueryRow(query, id)
-	d := models.Delivery{}
+	return s.get(s.db, id, "")
+}
 
-	var deliveredAt sql.NullTime
-	err := row.Scan(&d.ID, &d.CourierID, &d.ParcelID, &d.Status, &d.AssignedAt, &deliveredAt)
+// GetTx возвращает доставку в транзакции tx без блокировки
+func (s *DeliveryStore) GetTx(tx *sql.Tx, id int) (models.Delivery, error) {
+	return s.get(tx, id, "")
+}
+
+// GetForUpdate возвращает доставку и блокирует ее до конца транзакции tx,
+// чтобы параллельные запросы не изменили ее статус между проверкой перехода и записью
+func (s *DeliveryStore) GetForUpdate(tx *sql.Tx, id int) (models.Delivery, error) {
+	return s.get(tx, id, " FOR UPDATE")
+}
+
+func (s *DeliveryStore) get(q querier, id int, lock string) (models.Delivery, error) {
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE id = $1%s`, deliveryColumns, s.tableName, lock)
+	d, err := scanDelivery(q.QueryRow(query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return d, fmt.Errorf("Доставка с ID %d не найдена", id)
+			return d, fmt.Errorf("%w: ID %d", models.ErrDeliveryNotFound, id)
 		}
 		return d, fmt.Errorf("Ошибка при получении доставки: %w", err)
 	}
-	if deliveredAt.Valid {
-		d.DeliveredAt = deliveredAt.Time
-	}
 	return d, nil
 }
 
 func (s *DeliveryStore) Update(d models.Delivery) error {
+	return s.update(s.db, d)
+}
+
+// UpdateTx обновляет доставку в транзакции tx; без транзакции, если tx == nil
+func (s *DeliveryStore) UpdateTx(tx *sql.Tx, d models.Delivery) error {
+	return s.update(s.conn(tx), d)
+}
+
+func (s *DeliveryStore) update(q querier, d models.Delivery) error {
 	query := fmt.Sprintf(`UPDATE %s SET courier_id = $1, parcel_id = $2, status = $3, assigned_at = $4, delivered_at = $5 WHERE id = $6`, s.tableName)
-	_, err := s.db.Exec(query, d.CourierID, d.ParcelID, d.Status, d.AssignedAt, sql.NullTime{Time: d.DeliveredAt, Valid: !d.DeliveredAt.IsZero()}, d.ID)
+	_, err := q.Exec(query, nullInt(d.CourierID), d.ParcelID, d.Status, d.AssignedAt, sql.NullTime{Time: d.DeliveredAt, Valid: !d.DeliveredAt.IsZero()}, d.ID)
 	if err != nil {
 		return fmt.Errorf("Ошибка при обновлении доставки: %w", err)
 	}
 	return nil
 }
 
+// SetETA сохраняет ожидаемое время доставки, не изменяя остальные поля
+func (s *DeliveryStore) SetETA(id int, eta time.Time) error {
+	query := fmt.Sprintf(`UPDATE %s SET eta = $1 WHERE id = $2`, s.tableName)
+	if _, err := s.db.Exec(query, eta, id); err != nil {
+		return fmt.Errorf("Ошибка при сохранении ожидаемого времени доставки: %w", err)
+	}
+	return nil
+}
+
 func (s *DeliveryStore) Delete(id int) error {
+	return s.delete(s.db, id)
+}
+
+// DeleteTx удаляет доставку в транзакции tx
+func (s *DeliveryStore) DeleteTx(tx *sql.Tx, id int) error {
+	return s.delete(tx, id)
+}
+
+func (s *DeliveryStore) delete(q querier, id int) error {
 	query := fmt.Sprintf(`DELETE FROM %s WHERE id = $1`, s.tableName)
-	_, err := s.db.Exec(query, id)
+	_, err := q.Exec(query, id)
 	if err != nil {
 		return fmt.Errorf("Ошибка при удалении доставки: %w", err)
 	}
@@ -75,7 +170,7 @@ func (s *DeliveryStore) Delete(id int) error {
 }
 
 func (s *DeliveryStore) GetByCourierID(courierID int) ([]models.Delivery, error) {
-	query := fmt.Sprintf(`SELECT id, courier_id, parcel_id, status, assigned_at, delivered_at FROM %s WHERE courier_id = $1`, s.tableName)
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE courier_id = $1`, deliveryColumns, s.tableName)
 	rows, err := s.db.Query(query, courierID)
 	if err != nil {
 		return nil, fmt.Errorf("Ошибка при получении доставок по ID курьера: %w", err)
@@ -84,15 +179,10 @@ func (s *DeliveryStore) GetByCourierID(courierID int) ([]models.Delivery, error)
 
 	var deliveries []models.Delivery
 	for rows.Next() {
-		d := models.Delivery{}
-		var deliveredAt sql.NullTime
-		err := rows.Scan(&d.ID, &d.CourierID, &d.ParcelID, &d.Status, &d.AssignedAt, &deliveredAt)
+		d, err := scanDelivery(rows)
 		if err != nil {
 			return nil, fmt.Errorf("Ошибка при сканировании данных доставки: %w", err)
 		}
-		if deliveredAt.Valid {
-			d.DeliveredAt = deliveredAt.Time
-		}
 		deliveries = append(deliveries, d)
 	}
 
@@ -103,23 +193,112 @@ func (s *DeliveryStore) GetByCourierID(courierID int) ([]models.Delivery, error)
 	return deliveries, nil
 }
 
+// HasOpenDeliveryTx сообщает в транзакции tx, что у курьера есть незавершенная доставка.
+// Доставка в неизвестном статусе считается незавершенной
+func (s *DeliveryStore) HasOpenDeliveryTx(tx *sql.Tx, courierID int) (bool, error) {
+	query := fmt.Sprintf(`SELECT status FROM %s WHERE courier_id = $1`, s.tableName)
+	rows, err := s.conn(tx).Query(query, courierID)
+	if err != nil {
+		return false, fmt.Errorf("Ошибка при получении доставок курьера: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return false, fmt.Errorf("Ошибка при сканировании статуса доставки: %w", err)
+		}
+		if status, err := ParseStatus(value); err != nil || status.IsOpen() {
+			return true, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return false, fmt.Errorf("Ошибка при обработке результатов: %w", err)
+	}
+	return false, nil
+}
+
 func (s *DeliveryStore) GetByParcelID(parcelID int) (models.Delivery, error) {
-	query := fmt.Sprintf(`SELECT id, parcel_id, courier_id, status, assigned_at, delivered_at FROM %s WHERE parcel_id = $1`, s.tableName)
-	row := s.db.QueryRow(query, parcelID)
+	return s.getByParcelID(s.db, parcelID)
+}
 
-	var delivery models.Delivery
-	var deliveredAt sql.NullTime
-	err := row.Scan(&delivery.ID, &delivery.ParcelID, &delivery.CourierID, &delivery.Status, &delivery.AssignedAt, &deliveredAt)
+// GetByParcelIDTx возвращает последнюю доставку посылки в транзакции tx
+func (s *DeliveryStore) GetByParcelIDTx(tx *sql.Tx, parcelID int) (models.Delivery, error) {
+	return s.getByParcelID(tx, parcelID)
+}
+
+func (s *DeliveryStore) getByParcelID(q querier, parcelID int) (models.Delivery, error) {
+	// У посылки может быть несколько доставок (например, после отмены), возвращаем последнюю
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE parcel_id = $1 ORDER BY id DESC LIMIT 1`, deliveryColumns, s.tableName)
+	delivery, err := scanDelivery(q.QueryRow(query, parcelID))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return delivery, fmt.Errorf("Доставка с ParcelID %d не найдена", parcelID)
+			return delivery, fmt.Errorf("Доставка с ParcelID %d не найдена: %w", parcelID, err)
 		}
 		return delivery, fmt.Errorf("Ошибка при получении доставки по ParcelID: %w", err)
 	}
 
-	if deliveredAt.Valid {
-		delivery.DeliveredAt = deliveredAt.Time
+	return delivery, nil
+}
+
+// Методы для журнала изменений доставок
+
+func (s *DeliveryStore) AddEvent(e models.DeliveryEvent) (int, error) {
+	return s.addEvent(s.db, e)
+}
+
+// AddEventTx записывает событие в журнал в транзакции tx
+func (s *DeliveryStore) AddEventTx(tx *sql.Tx, e models.DeliveryEvent) (int, error) {
+	return s.addEvent(tx, e)
+}
+
+func (s *DeliveryStore) addEvent(q querier, e models.DeliveryEvent) (int, error) {
+	query := fmt.Sprintf(`INSERT INTO %s (delivery_id, old_status, new_status, actor_id, note, created_at) 
+              VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`, s.eventsTable)
+
+	var id int
+	err := q.QueryRow(query, e.DeliveryID, nullString(e.OldStatus), e.NewStatus,
+		nullInt(e.ActorID), nullString(e.Note), e.CreatedAt).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка при добавлении события доставки: %w", err)
+	}
+	return id, nil
+}
+
+// GetEvents возвращает журнал изменений доставки в хронологическом порядке
+func (s *DeliveryStore) GetEvents(deliveryID int) ([]models.DeliveryEvent, error) {
+	query := fmt.Sprintf(`SELECT id, delivery_id, old_status, new_status, actor_id, note, created_at FROM %s 
+              WHERE delivery_id = $1 ORDER BY created_at, id`, s.eventsTable)
+	rows, err := s.db.Query(query, deliveryID)
+	if err != nil {
+		return nil, fmt.Errorf("Ошибка при получении журнала доставки: %w", err)
 	}
+	defer rows.Close()
 
-	return delivery, nil
+	events := []models.DeliveryEvent{}
+	for rows.Next() {
+		var e models.DeliveryEvent
+		var oldStatus, note sql.NullString
+		var actorID sql.NullInt64
+		if err := rows.Scan(&e.ID, &e.DeliveryID, &oldStatus, &e.NewStatus, &actorID, &note, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("Ошибка при сканировании события доставки: %w", err)
+		}
+		e.OldStatus, e.Note, e.ActorID = oldStatus.String, note.String, int(actorID.Int64)
+		events = append(events, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Ошибка при обработке результатов: %w", err)
+	}
+
+	return events, nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// nullInt сохраняет нулевой ID как NULL
+func nullInt(id int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id != 0}
 }
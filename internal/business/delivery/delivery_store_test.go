@@ -34,11 +34,12 @@ func setupTestDB(t *testing.T) (*sql.DB, string, func()) {
 	if _, err := testDB.Exec(fmt.Sprintf(`
 		CREATE TABLE %s (
 			id SERIAL PRIMARY KEY,
-			courier_id INTEGER NOT NULL,
+			courier_id INTEGER,
 			parcel_id INTEGER NOT NULL,
 			status TEXT NOT NULL,
 			assigned_at TIMESTAMP NOT NULL,
-			delivered_at TIMESTAMP
+			delivered_at TIMESTAMP,
+			eta TIMESTAMP
 		);
 	`, tableName)); err != nil {
 		testDB.Close()
@@ -125,6 +126,60 @@ func TestDeliveryStore_Get(t *testing.T) {
 	assert.True(t, retrieved.DeliveredAt.IsZero())
 }
 
+func TestDeliveryStore_GetForUpdate(t *testing.T) {
+	db, tableName, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	store := &DeliveryStore{
+		db:        db,
+		tableName: tableName,
+	}
+
+	id, err := store.Add(models.Delivery{CourierID: 1, ParcelID: 2, Status: "in_transit", AssignedAt: time.Now().UTC()})
+	assert.NoError(t, err)
+
+	first, err := store.BeginTx()
+	assert.NoError(t, err)
+	defer first.Rollback()
+	locked, err := store.GetForUpdate(first, id)
+	assert.NoError(t, err)
+
+	// Вторая транзакция ждет, пока первая не изменит статус и не завершится
+	read := make(chan models.Delivery, 1)
+	go func() {
+		second, err := store.BeginTx()
+		if err != nil {
+			close(read)
+			return
+		}
+		defer second.Rollback()
+		d, err := store.GetForUpdate(second, id)
+		if err != nil {
+			close(read)
+			return
+		}
+		read <- d
+	}()
+
+	select {
+	case <-read:
+		t.Fatal("GetForUpdate() вернул заблокированную доставку до завершения первой транзакции")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	locked.Status = "delivered"
+	assert.NoError(t, store.UpdateTx(first, locked))
+	assert.NoError(t, first.Commit())
+
+	select {
+	case d, ok := <-read:
+		assert.True(t, ok)
+		assert.Equal(t, "delivered", d.Status)
+	case <-time.After(5 * time.Second):
+		t.Fatal("GetForUpdate() не вернул доставку после завершения первой транзакции")
+	}
+}
+
 func TestDeliveryStore_Update(t *testing.T) {
 	db, tableName, cleanup := setupTestDB(t)
 	defer cleanup()
@@ -227,6 +282,31 @@ func TestDeliveryStore_GetByCourierID(t *testing.T) {
 	assert.Len(t, deliveries, 2)
 }
 
+func TestDeliveryStore_HasOpenDeliveryTx(t *testing.T) {
+	db, tableName, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	store := &DeliveryStore{
+		db:        db,
+		tableName: tableName,
+	}
+
+	now := time.Now().UTC()
+	_, err := store.Add(models.Delivery{CourierID: 1, ParcelID: 2, Status: "delivered", AssignedAt: now, DeliveredAt: now})
+	assert.NoError(t, err)
+	_, err = store.Add(models.Delivery{CourierID: 2, ParcelID: 3, Status: "picked_up", AssignedAt: now})
+	assert.NoError(t, err)
+
+	// Завершенная доставка курьера не держит, незавершенная - держит
+	open, err := store.HasOpenDeliveryTx(nil, 1)
+	assert.NoError(t, err)
+	assert.False(t, open)
+
+	open, err = store.HasOpenDeliveryTx(nil, 2)
+	assert.NoError(t, err)
+	assert.True(t, open)
+}
+
 func TestDeliveryStore_GetByParcelID(t *testing.T) {
 	db, tableName, cleanup := setupTestDB(t)
 	defer cleanup()
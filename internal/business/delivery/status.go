@@ -0,0 +1,111 @@
+package delivery
+
+import (
+	"delivery/internal/business/models"
+	"fmt"
+)
+
+// Status - этап жизненного цикла доставки
+type Status string
+
+// Статусы доставки. Основной путь: created → assigned → picked_up → in_transit → delivered
+const (
+	StatusCreated   Status = "created"    // Доставка создана, курьер не назначен
+	StatusAssigned  Status = "assigned"   // Курьер назначен
+	StatusPickedUp  Status = "picked_up"  // Курьер забрал посылку
+	StatusInTransit Status = "in_transit" // Посылка в пути к получателю
+	StatusDelivered Status = "delivered"  // Посылка вручена получателю
+	StatusFailed    Status = "failed"     // Вручить посылку не удалось
+	StatusReturned  Status = "returned"   // Посылка возвращена отправителю
+	StatusCancelled Status = "cancelled"  // Доставка отменена
+)
+
+// eventDeleted - новый статус в журнале для события удаления доставки
+const eventDeleted = "deleted"
+
+// legacyInProgress - статус, который сохранялся до введения жизненного цикла
+const legacyInProgress = "in progress"
+
+// transitions - допустимые переходы между статусами
+var transitions = map[Status][]Status{
+	StatusCreated:   {StatusAssigned, StatusCancelled},
+	StatusAssigned:  {StatusPickedUp, StatusCancelled},
+	StatusPickedUp:  {StatusInTransit, StatusFailed},
+	StatusInTransit: {StatusDelivered, StatusFailed},
+	StatusFailed:    {StatusAssigned, StatusReturned},
+	StatusDelivered: {},
+	StatusReturned:  {},
+	StatusCancelled: {},
+}
+
+// parcelStatuses - статус посылки, соответствующий статусу доставки
+var parcelStatuses = map[Status]string{
+	StatusCreated:   models.ParcelStatusRegistered,
+	StatusAssigned:  models.ParcelStatusSent,
+	StatusPickedUp:  models.ParcelStatusInTransit,
+	StatusInTransit: models.ParcelStatusInTransit,
+	StatusDelivered: models.ParcelStatusDelivered,
+	StatusFailed:    models.ParcelStatusDeliveryFailed,
+	StatusReturned:  models.ParcelStatusReturned,
+	// Отмененную доставку можно создать заново, поэтому посылка возвращается в исходный статус
+	StatusCancelled: models.ParcelStatusRegistered,
+}
+
+// ParseStatus преобразует строку в статус доставки
+func ParseStatus(s string) (Status, error) {
+	if s == legacyInProgress {
+		return StatusInTransit, nil
+	}
+	status := Status(s)
+	if _, ok := transitions[status]; !ok {
+		return "", fmt.Errorf("%w: %q", models.ErrUnknownDeliveryStatus, s)
+	}
+	return status, nil
+}
+
+// IsFinal сообщает, что из статуса нет переходов
+func (s Status) IsFinal() bool {
+	return len(transitions[s]) == 0
+}
+
+// CanTransition сообщает, допустим ли переход из статуса from в статус to
+func CanTransition(from, to Status) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ReleasesCourier сообщает, что в этом статусе курьер больше не занят доставкой
+func (s Status) ReleasesCourier() bool {
+	return s == StatusDelivered || s == StatusFailed || s == StatusCancelled
+}
+
+// IsOpen сообщает, что доставка не завершена и курьер, если он назначен, еще занят ею
+func (s Status) IsOpen() bool {
+	return !s.IsFinal() && !s.ReleasesCourier()
+}
+
+// OnRoute сообщает, что посылка ждет курьера или уже у него, и ее адрес входит в маршрут курьера
+func (s Status) OnRoute() bool {
+	return s == StatusAssigned || s == StatusPickedUp || s == StatusInTransit
+}
+
+// ParcelStatus возвращает статус посылки, соответствующий статусу доставки
+func (s Status) ParcelStatus() string {
+	return parcelStatuses[s]
+}
+
+// checkTransition проверяет, что доставку в статусе from можно перевести в статус to
+func checkTransition(from string, to Status) error {
+	current, err := ParseStatus(from)
+	if err != nil {
+		return err
+	}
+	if !CanTransition(current, to) {
+		return fmt.Errorf("%w: %s → %s", models.ErrInvalidStatusTransition, current, to)
+	}
+	return nil
+}
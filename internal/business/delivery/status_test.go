@@ -0,0 +1,75 @@
+package delivery
+
+import (
+	"delivery/internal/business/models"
+	"errors"
+	"testing"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to Status
+		want     bool
+	}{
+		{StatusCreated, StatusAssigned, true},
+		{StatusAssigned, StatusPickedUp, true},
+		{StatusPickedUp, StatusInTransit, true},
+		{StatusInTransit, StatusDelivered, true},
+		{StatusInTransit, StatusFailed, true},
+		{StatusFailed, StatusReturned, true},
+		{StatusFailed, StatusAssigned, true},
+		{StatusAssigned, StatusCancelled, true},
+		{StatusCreated, StatusDelivered, false},
+		{StatusInTransit, StatusCancelled, false},
+		{StatusDelivered, StatusReturned, false},
+		{StatusCancelled, StatusAssigned, false},
+	}
+
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	if status, err := ParseStatus("picked_up"); err != nil || status != StatusPickedUp {
+		t.Errorf("ParseStatus(picked_up) = %q, %v", status, err)
+	}
+	// Статус, сохраненный до введения жизненного цикла
+	if status, err := ParseStatus("in progress"); err != nil || status != StatusInTransit {
+		t.Errorf("ParseStatus(in progress) = %q, %v", status, err)
+	}
+	if _, err := ParseStatus("lost"); !errors.Is(err, models.ErrUnknownDeliveryStatus) {
+		t.Errorf("ParseStatus(lost) error = %v, want ErrUnknownDeliveryStatus", err)
+	}
+}
+
+func TestStatus_IsFinal(t *testing.T) {
+	for _, status := range []Status{StatusDelivered, StatusReturned, StatusCancelled} {
+		if !status.IsFinal() {
+			t.Errorf("%s.IsFinal() = false", status)
+		}
+	}
+	if StatusInTransit.IsFinal() {
+		t.Error("in_transit.IsFinal() = true")
+	}
+}
+
+func TestStatus_OnRoute(t *testing.T) {
+	for status := range transitions {
+		want := status == StatusAssigned || status == StatusPickedUp || status == StatusInTransit
+		if got := status.OnRoute(); got != want {
+			t.Errorf("%s.OnRoute() = %v, want %v", status, got, want)
+		}
+	}
+}
+
+func TestStatus_ParcelStatus(t *testing.T) {
+	// Посылку можно перевести через UpdateStatus в любой статус, который задает доставка
+	for status := range transitions {
+		if parcelStatus := status.ParcelStatus(); !models.IsValidParcelStatus(parcelStatus) {
+			t.Errorf("%s.ParcelStatus() = %q - недопустимый статус посылки", status, parcelStatus)
+		}
+	}
+}
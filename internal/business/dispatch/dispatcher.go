@@ -0,0 +1,303 @@
+package dispatch
+
+import (
+	"context"
+	"delivery/internal/business/models"
+	"delivery/internal/events"
+	"delivery/internal/metrics"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// DefaultInterval - период автоматического распределения по умолчанию
+const DefaultInterval = 30 * time.Second
+
+// ParcelSource - посылки, ожидающие назначения курьера
+type ParcelSource interface {
+	ListUnassigned() ([]models.Parcel, error)
+}
+
+// CourierSource - курьеры, которым можно назначить доставку
+type CourierSource interface {
+	GetAvailableCouriers() ([]models.Courier, error)
+}
+
+// ZoneCourierSource - доступные курьеры, обслуживающие зону доставки
+type ZoneCourierSource interface {
+	GetAvailableCouriersInZone(zoneID int) ([]models.Courier, error)
+}
+
+// Assigner создает доставку для пары курьер - посылка
+type Assigner interface {
+	AssignDelivery(courierID, parcelID int, audit models.AuditInfo) (models.Delivery, error)
+}
+
+// Result - итог одного прохода распределения
+type Result struct {
+	Assigned []models.Delivery
+	Pending  int // Посылки, оставшиеся без курьера
+}
+
+// Dispatcher распределяет неназначенные посылки между доступными курьерами
+type Dispatcher struct {
+	parcels  ParcelSource
+	couriers CourierSource
+	zones    ZoneCourierSource
+	assigner Assigner
+	strategy Strategy
+	interval time.Duration
+	trigger  chan struct{}
+	mu       sync.Mutex // Проходы распределения не выполняются параллельно
+
+	chainsMu sync.Mutex
+	chains   map[int]string // Цепочки событий ParcelRegistered по ID посылки, еще не распределенной
+}
+
+func NewDispatcher(parcels ParcelSource, couriers CourierSource, assigner Assigner, strategy Strategy) *Dispatcher {
+	return &Dispatcher{
+		parcels:  parcels,
+		couriers: couriers,
+		assigner: assigner,
+		strategy: strategy,
+		interval: DefaultInterval,
+		trigger:  make(chan struct{}, 1),
+		chains:   make(map[int]string),
+	}
+}
+
+// WithInterval задает период автоматического распределения
+func (d *Dispatcher) WithInterval(interval time.Duration) *Dispatcher {
+	if interval > 0 {
+		d.interval = interval
+	}
+	return d
+}
+
+// WithZones ограничивает выбор курьерами, обслуживающими зону посылки.
+// Посылки вне зон доставки распределяются между всеми доступными курьерами
+func (d *Dispatcher) WithZones(zones ZoneCourierSource) *Dispatcher {
+	d.zones = zones
+	return d
+}
+
+// Start запускает распределение по таймеру и по уведомлениям Notify до отмены ctx
+func (d *Dispatcher) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-d.trigger:
+			case <-ctx.Done():
+				return
+			}
+
+			if _, err := d.RunOnce(); err != nil {
+				log.Printf("[DISPATCH] Ошибка распределения посылок: %v", err)
+			}
+		}
+	}()
+	log.Printf("[DISPATCH] Автоматическое распределение запущено: стратегия %s, период %s", d.strategy.Name(), d.interval)
+}
+
+// Notify запрашивает внеочередной проход распределения. Не блокируется:
+// несколько уведомлений до начала прохода объединяются в один
+func (d *Dispatcher) Notify() {
+	select {
+	case d.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// ParcelRegistered запускает распределение при регистрации новой посылки
+func (d *Dispatcher) ParcelRegistered(parcel models.Parcel) {
+	d.Notify()
+}
+
+// OnParcelRegistered запускает распределение по событию ParcelRegistered из Kafka: посылку
+// распределяет экземпляр, получивший событие, даже если она зарегистрирована на другом.
+// События назначения посылки продолжают цепочку полученного события
+func (d *Dispatcher) OnParcelRegistered(ctx context.Context, event models.ParcelRegisteredEvent) error {
+	if correlationID := events.CorrelationID(ctx); correlationID != "" {
+		d.chainsMu.Lock()
+		d.chains[event.ParcelID] = correlationID
+		d.chainsMu.Unlock()
+	}
+	d.Notify()
+	return nil
+}
+
+// knownChains возвращает копию цепочек событий нераспределенных посылок
+func (d *Dispatcher) knownChains() map[int]string {
+	d.chainsMu.Lock()
+	defer d.chainsMu.Unlock()
+
+	chains := make(map[int]string, len(d.chains))
+	for id, correlationID := range d.chains {
+		chains[id] = correlationID
+	}
+	return chains
+}
+
+// forgetChains удаляет цепочки событий посылок, которые больше не ожидают назначения
+func (d *Dispatcher) forgetChains(ids ...int) {
+	d.chainsMu.Lock()
+	defer d.chainsMu.Unlock()
+
+	for _, id := range ids {
+		delete(d.chains, id)
+	}
+}
+
+// RunOnce выполняет один проход: посылки рассматриваются в порядке регистрации,
+// каждой стратегия подбирает курьера из еще не занятых
+func (d *Dispatcher) RunOnce() (Result, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var result Result
+
+	// Цепочки запоминаются до выборки: посылка, зарегистрированная после нее, ждет следующего прохода
+	chains := d.knownChains()
+	parcels, err := d.parcels.ListUnassigned()
+	if err != nil {
+		return result, fmt.Errorf("ошибка при получении неназначенных посылок: %w", err)
+	}
+	d.forgetChains(assignedElsewhere(chains, parcels)...)
+	if len(parcels) == 0 {
+		return result, nil
+	}
+
+	couriers, err := d.couriers.GetAvailableCouriers()
+	if err != nil {
+		return result, fmt.Errorf("ошибка при получении доступных курьеров: %w", err)
+	}
+
+	members := make(map[int]map[int]bool) // Курьеры зон, загруженные за этот проход
+	for _, parcel := range parcels {
+		audit := models.AuditInfo{
+			Note:          "Автоматическое назначение: " + d.strategy.Name(),
+			CorrelationID: chains[parcel.ID],
+		}
+		candidates, err := d.candidates(parcel, couriers, members)
+		if err != nil {
+			log.Printf("[DISPATCH] Не удалось получить курьеров зоны %d для посылки %d: %v", parcel.ZoneID, parcel.ID, err)
+			result.Pending++
+			continue
+		}
+
+		delivery, excluded, ok := d.assign(parcel, candidates, audit)
+		couriers = without(couriers, excluded...)
+		if !ok {
+			result.Pending++
+			continue
+		}
+		d.forgetChains(parcel.ID)
+		result.Assigned = append(result.Assigned, delivery)
+		metrics.DispatchAssignedTotal.WithLabelValues(d.strategy.Name()).Inc()
+	}
+
+	if len(result.Assigned) > 0 || result.Pending > 0 {
+		log.Printf("[DISPATCH] Назначено доставок: %d, ожидают курьера: %d", len(result.Assigned), result.Pending)
+	}
+	return result, nil
+}
+
+// candidates возвращает курьеров из списка, которые могут доставить посылку с учетом ее зоны.
+// members хранит уже загруженный состав зон, чтобы не запрашивать его для каждой посылки
+func (d *Dispatcher) candidates(parcel models.Parcel, couriers []models.Courier, members map[int]map[int]bool) ([]models.Courier, error) {
+	if d.zones == nil || parcel.ZoneID == 0 {
+		return couriers, nil
+	}
+
+	inZone, ok := members[parcel.ZoneID]
+	if !ok {
+		zoneCouriers, err := d.zones.GetAvailableCouriersInZone(parcel.ZoneID)
+		if err != nil {
+			return nil, err
+		}
+		inZone = make(map[int]bool, len(zoneCouriers))
+		for _, c := range zoneCouriers {
+			inZone[c.ID] = true
+		}
+		members[parcel.ZoneID] = inZone
+	}
+
+	result := make([]models.Courier, 0, len(couriers))
+	for _, c := range couriers {
+		if inZone[c.ID] {
+			result = append(result, c)
+		}
+	}
+	return result, nil
+}
+
+// assign подбирает курьера для посылки. Возвращает ID курьеров, которых нужно исключить
+// из дальнейшего распределения: получившего доставку и ставших недоступными
+func (d *Dispatcher) assign(parcel models.Parcel, couriers []models.Courier, audit models.AuditInfo) (models.Delivery, []int, bool) {
+	var excluded []int
+	for len(couriers) > 0 {
+		courier, err := d.strategy.Pick(parcel, couriers)
+		if err != nil {
+			log.Printf("[DISPATCH] Стратегия %s не выбрала курьера для посылки %d: %v", d.strategy.Name(), parcel.ID, err)
+			return models.Delivery{}, excluded, false
+		}
+
+		delivery, err := d.assigner.AssignDelivery(courier.ID, parcel.ID, audit)
+		switch {
+		case err == nil:
+			return delivery, append(excluded, courier.ID), true
+		case errors.Is(err, models.ErrCourierUnavailable), errors.Is(err, models.ErrCourierNotFound):
+			// Курьера заняли вручную после выборки - пробуем следующего
+			excluded = append(excluded, courier.ID)
+			couriers = without(couriers, courier.ID)
+		default:
+			// Посылку назначили вручную или удалили; курьер остается свободен
+			log.Printf("[DISPATCH] Не удалось назначить посылку %d курьеру %d: %v", parcel.ID, courier.ID, err)
+			return models.Delivery{}, excluded, false
+		}
+	}
+	return models.Delivery{}, excluded, false
+}
+
+// assignedElsewhere возвращает ID посылок из chains, которых нет среди неназначенных:
+// их назначили вручную или удалили
+func assignedElsewhere(chains map[int]string, unassigned []models.Parcel) []int {
+	listed := make(map[int]bool, len(unassigned))
+	for _, p := range unassigned {
+		listed[p.ID] = true
+	}
+
+	var ids []int
+	for id := range chains {
+		if !listed[id] {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// without возвращает список курьеров без курьеров с указанными ID
+func without(couriers []models.Courier, ids ...int) []models.Courier {
+	if len(ids) == 0 {
+		return couriers
+	}
+
+	skip := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		skip[id] = true
+	}
+
+	result := make([]models.Courier, 0, len(couriers))
+	for _, c := range couriers {
+		if !skip[c.ID] {
+			result = append(result, c)
+		}
+	}
+	return result
+}
@@ -0,0 +1,149 @@
+package dispatch
+
+import (
+	"context"
+	"delivery/internal/business/models"
+	"delivery/internal/events"
+	"testing"
+	"time"
+)
+
+func TestDispatcher_RunOnce(t *testing.T) {
+	store := newMemoryStore([]int{10, 11, 12}, []int{1, 2})
+	dispatcher := NewDispatcher(store, store, store, NewRoundRobin())
+
+	result, err := dispatcher.RunOnce()
+	if err != nil {
+		t.Fatalf("RunOnce() error = %v", err)
+	}
+
+	// Две посылки получили курьеров, третья ждет освободившегося
+	if len(result.Assigned) != 2 || result.Pending != 1 {
+		t.Fatalf("RunOnce() = %+v", result)
+	}
+	if result.Assigned[0].ParcelID != 10 || result.Assigned[0].CourierID != 1 ||
+		result.Assigned[1].ParcelID != 11 || result.Assigned[1].CourierID != 2 {
+		t.Errorf("RunOnce() назначил %+v", result.Assigned)
+	}
+
+	// Курьер освободился - оставшаяся посылка назначается при следующем проходе
+	store.setCourierStatus(1, models.CourierStatusAvailable)
+	result, err = dispatcher.RunOnce()
+	if err != nil || len(result.Assigned) != 1 || result.Assigned[0].ParcelID != 12 {
+		t.Errorf("RunOnce() = %+v, %v", result, err)
+	}
+
+	// Назначать больше нечего
+	if result, _ := dispatcher.RunOnce(); len(result.Assigned) != 0 || result.Pending != 0 {
+		t.Errorf("RunOnce() = %+v", result)
+	}
+}
+
+func TestDispatcher_RunOnce_Zones(t *testing.T) {
+	store := newMemoryStore([]int{10, 11, 12, 13}, []int{1, 2, 3})
+	store.zones = map[int][]int{100: {2}, 200: {3}, 300: {}}
+	store.setParcelZone(10, 200)
+	store.setParcelZone(11, 300) // Зону никто не обслуживает
+	store.setParcelZone(12, 200) // Единственный курьер зоны уже занят посылкой 10
+	// Посылка 13 вне зон - ей подходит любой курьер
+
+	dispatcher := NewDispatcher(store, store, store, NewRoundRobin()).WithZones(store)
+	result, err := dispatcher.RunOnce()
+	if err != nil {
+		t.Fatalf("RunOnce() error = %v", err)
+	}
+
+	assigned := make(map[int]int)
+	for _, d := range result.Assigned {
+		assigned[d.ParcelID] = d.CourierID
+	}
+	if len(assigned) != 2 || assigned[10] != 3 || assigned[13] != 1 || result.Pending != 2 {
+		t.Errorf("RunOnce() назначил %v, ожидают %d", assigned, result.Pending)
+	}
+}
+
+// busyOnAssign имитирует курьера, которого заняли вручную между выборкой и назначением
+type busyOnAssign struct {
+	*memoryStore
+	courierID int
+}
+
+func (s busyOnAssign) GetAvailableCouriers() ([]models.Courier, error) {
+	couriers, err := s.memoryStore.GetAvailableCouriers()
+	s.setCourierStatus(s.courierID, models.CourierStatusBusy)
+	return couriers, err
+}
+
+func TestDispatcher_RunOnce_CourierTaken(t *testing.T) {
+	store := newMemoryStore([]int{10}, []int{1, 2})
+	source := busyOnAssign{memoryStore: store, courierID: 1}
+	dispatcher := NewDispatcher(store, source, store, NewRoundRobin())
+
+	result, err := dispatcher.RunOnce()
+	if err != nil {
+		t.Fatalf("RunOnce() error = %v", err)
+	}
+	if len(result.Assigned) != 1 || result.Assigned[0].CourierID != 2 {
+		t.Errorf("RunOnce() = %+v, want назначение курьеру 2", result)
+	}
+}
+
+// auditRecorder запоминает сведения для журнала, с которыми назначены посылки
+type auditRecorder struct {
+	*memoryStore
+	audits map[int]models.AuditInfo
+}
+
+func (s auditRecorder) AssignDelivery(courierID, parcelID int, audit models.AuditInfo) (models.Delivery, error) {
+	s.audits[parcelID] = audit
+	return s.memoryStore.AssignDelivery(courierID, parcelID, audit)
+}
+
+func TestDispatcher_OnParcelRegistered_Correlation(t *testing.T) {
+	store := newMemoryStore([]int{10, 11}, []int{1, 2})
+	assigner := auditRecorder{memoryStore: store, audits: map[int]models.AuditInfo{}}
+	dispatcher := NewDispatcher(store, store, assigner, NewRoundRobin())
+
+	// Событие посылки 10 начинает цепочку, посылка 11 зарегистрирована без события
+	ctx := events.WithEnvelope(context.Background(), events.Envelope{ID: "31"})
+	if err := dispatcher.OnParcelRegistered(ctx, models.ParcelRegisteredEvent{ParcelID: 10}); err != nil {
+		t.Fatalf("OnParcelRegistered() error = %v", err)
+	}
+
+	if _, err := dispatcher.RunOnce(); err != nil {
+		t.Fatalf("RunOnce() error = %v", err)
+	}
+	if got := assigner.audits[10].CorrelationID; got != "31" {
+		t.Errorf("CorrelationID посылки 10 = %q, want 31", got)
+	}
+	if got := assigner.audits[11].CorrelationID; got != "" {
+		t.Errorf("CorrelationID посылки 11 = %q, want пусто", got)
+	}
+	if chains := dispatcher.knownChains(); len(chains) != 0 {
+		t.Errorf("после назначения остались цепочки %v", chains)
+	}
+}
+
+func TestDispatcher_NotifyOnRegistration(t *testing.T) {
+	store := newMemoryStore(nil, []int{1})
+	// Большой период, чтобы проход запускался только уведомлением
+	dispatcher := NewDispatcher(store, store, store, NewRoundRobin()).WithInterval(time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dispatcher.Start(ctx)
+
+	store.mu.Lock()
+	store.parcels[10] = models.Parcel{ID: 10, Status: models.ParcelStatusRegistered}
+	store.mu.Unlock()
+	dispatcher.ParcelRegistered(models.Parcel{ID: 10})
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if deliveries, _ := store.GetDeliveriesByCourier(1); len(deliveries) == 1 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("посылка не распределена после уведомления о регистрации")
+}
@@ -0,0 +1,42 @@
+package dispatch
+
+import (
+	"delivery/internal/business/models"
+	"delivery/internal/geo"
+	"time"
+)
+
+// DefaultPositionMaxAge - отметки местоположения старше этого срока не учитываются при выборе ближайшего курьера
+const DefaultPositionMaxAge = 15 * time.Minute
+
+// PositionSource - последние известные местоположения курьеров
+type PositionSource interface {
+	Latest(courierID int) (models.CourierLocation, error)
+}
+
+// FleetLocator берет координаты посылки из ее геокодированного адреса,
+// а координаты курьера - из последней отметки его устройства
+type FleetLocator struct {
+	positions PositionSource
+	maxAge    time.Duration
+	now       func() time.Time
+}
+
+func NewFleetLocator(positions PositionSource) *FleetLocator {
+	return &FleetLocator{positions: positions, maxAge: DefaultPositionMaxAge, now: time.Now}
+}
+
+func (l *FleetLocator) ParcelLocation(parcel models.Parcel) (geo.Point, bool) {
+	if parcel.Destination == nil {
+		return geo.Point{}, false
+	}
+	return geo.Point{Lat: parcel.Destination.Lat, Lon: parcel.Destination.Lon}, true
+}
+
+func (l *FleetLocator) CourierLocation(courierID int) (geo.Point, bool) {
+	location, err := l.positions.Latest(courierID)
+	if err != nil || l.now().Sub(location.Timestamp) > l.maxAge {
+		return geo.Point{}, false
+	}
+	return geo.Point{Lat: location.Lat, Lon: location.Lon}, true
+}
@@ -0,0 +1,166 @@
+package dispatch
+
+import (
+	"delivery/internal/business/models"
+	"delivery/internal/geo"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// memoryStore - хранилище посылок, курьеров и доставок в памяти, повторяющее правила AssignDelivery
+type memoryStore struct {
+	mu         sync.Mutex
+	parcels    map[int]models.Parcel
+	couriers   map[int]models.Courier
+	zones      map[int][]int // Курьеры, обслуживающие зону
+	deliveries []models.Delivery
+}
+
+func newMemoryStore(parcelIDs []int, courierIDs []int) *memoryStore {
+	s := &memoryStore{parcels: map[int]models.Parcel{}, couriers: map[int]models.Courier{}}
+	for _, id := range parcelIDs {
+		s.parcels[id] = models.Parcel{ID: id, Status: models.ParcelStatusRegistered}
+	}
+	for _, id := range courierIDs {
+		s.couriers[id] = models.Courier{ID: id, Status: models.CourierStatusAvailable}
+	}
+	return s
+}
+
+func (s *memoryStore) ListUnassigned() ([]models.Parcel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []models.Parcel
+	for _, p := range s.parcels {
+		if p.Status == models.ParcelStatusRegistered {
+			result = append(result, p)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result, nil
+}
+
+func (s *memoryStore) GetAvailableCouriers() ([]models.Courier, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []models.Courier
+	for _, c := range s.couriers {
+		if c.Status == models.CourierStatusAvailable {
+			result = append(result, c)
+		}
+	}
+	// Порядок из БД не гарантирован, поэтому стратегии не должны от него зависеть
+	sort.Slice(result, func(i, j int) bool { return result[i].ID > result[j].ID })
+	return result, nil
+}
+
+func (s *memoryStore) GetAvailableCouriersInZone(zoneID int) ([]models.Courier, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []models.Courier
+	for _, id := range s.zones[zoneID] {
+		if c := s.couriers[id]; c.Status == models.CourierStatusAvailable {
+			result = append(result, c)
+		}
+	}
+	return result, nil
+}
+
+// setParcelZone относит посылку к зоне доставки
+func (s *memoryStore) setParcelZone(id, zoneID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.parcels[id]
+	p.ZoneID = zoneID
+	s.parcels[id] = p
+}
+
+func (s *memoryStore) AssignDelivery(courierID, parcelID int, audit models.AuditInfo) (models.Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	parcel, ok := s.parcels[parcelID]
+	if !ok {
+		return models.Delivery{}, models.ErrParcelNotFound
+	}
+	if parcel.Status != models.ParcelStatusRegistered {
+		return models.Delivery{}, fmt.Errorf("%w: посылка %d", models.ErrParcelAlreadyAssigned, parcelID)
+	}
+	courier, ok := s.couriers[courierID]
+	if !ok {
+		return models.Delivery{}, models.ErrCourierNotFound
+	}
+	if courier.Status != models.CourierStatusAvailable {
+		return models.Delivery{}, fmt.Errorf("%w: курьер %d", models.ErrCourierUnavailable, courierID)
+	}
+
+	parcel.Status = models.ParcelStatusSent
+	courier.Status = models.CourierStatusBusy
+	s.parcels[parcelID], s.couriers[courierID] = parcel, courier
+
+	delivery := models.Delivery{
+		ID:         len(s.deliveries) + 1,
+		CourierID:  courierID,
+		ParcelID:   parcelID,
+		Status:     "assigned",
+		AssignedAt: time.Now(),
+	}
+	s.deliveries = append(s.deliveries, delivery)
+	return delivery, nil
+}
+
+func (s *memoryStore) GetDeliveriesByCourier(courierID int) ([]models.Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []models.Delivery
+	for _, d := range s.deliveries {
+		if d.CourierID == courierID {
+			result = append(result, d)
+		}
+	}
+	return result, nil
+}
+
+// setCourierStatus имитирует изменение статуса курьера вне распределения
+func (s *memoryStore) setCourierStatus(id int, status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.couriers[id]
+	c.Status = status
+	s.couriers[id] = c
+}
+
+// memoryLocator - координаты посылок и курьеров в памяти
+type memoryLocator struct {
+	parcels  map[int]geo.Point
+	couriers map[int]geo.Point
+}
+
+func (l memoryLocator) ParcelLocation(parcel models.Parcel) (geo.Point, bool) {
+	p, ok := l.parcels[parcel.ID]
+	return p, ok
+}
+
+func (l memoryLocator) CourierLocation(courierID int) (geo.Point, bool) {
+	p, ok := l.couriers[courierID]
+	return p, ok
+}
+
+// memoryPositions - последние местоположения курьеров в памяти
+type memoryPositions map[int]models.CourierLocation
+
+func (m memoryPositions) Latest(courierID int) (models.CourierLocation, error) {
+	location, ok := m[courierID]
+	if !ok {
+		return location, models.ErrLocationNotFound
+	}
+	return location, nil
+}
@@ -0,0 +1,170 @@
+package dispatch
+
+import (
+	"delivery/internal/business/delivery"
+	"delivery/internal/business/models"
+	"delivery/internal/geo"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+// Названия стратегий, используемые в конфигурации
+const (
+	StrategyRoundRobin  = "round_robin"
+	StrategyLeastLoaded = "least_loaded"
+	StrategyNearest     = "nearest"
+)
+
+// ErrNoCouriers возвращается стратегией, если выбирать не из кого
+var ErrNoCouriers = errors.New("нет доступных курьеров")
+
+// Strategy выбирает курьера для посылки из списка доступных
+type Strategy interface {
+	Name() string
+	Pick(parcel models.Parcel, couriers []models.Courier) (models.Courier, error)
+}
+
+// LoadSource - доставки курьера, по которым считается его загрузка
+type LoadSource interface {
+	GetDeliveriesByCourier(courierID int) ([]models.Delivery, error)
+}
+
+// Locator определяет координаты посылки и курьера. false означает, что координаты неизвестны
+type Locator interface {
+	ParcelLocation(parcel models.Parcel) (geo.Point, bool)
+	CourierLocation(courierID int) (geo.Point, bool)
+}
+
+// NewStrategy создает стратегию по названию из конфигурации
+func NewStrategy(name string, loads LoadSource, locator Locator) (Strategy, error) {
+	switch name {
+	case "", StrategyRoundRobin:
+		return NewRoundRobin(), nil
+	case StrategyLeastLoaded:
+		return NewLeastLoaded(loads), nil
+	case StrategyNearest:
+		return NewNearest(locator), nil
+	}
+	return nil, fmt.Errorf("неизвестная стратегия распределения: %s", name)
+}
+
+// sortedByID возвращает копию списка курьеров, упорядоченную по ID, чтобы выбор не зависел от порядка выборки из БД
+func sortedByID(couriers []models.Courier) []models.Courier {
+	sorted := append([]models.Courier(nil), couriers...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	return sorted
+}
+
+// RoundRobin назначает курьеров по очереди: следующим выбирается курьер с ID больше последнего назначенного
+type RoundRobin struct {
+	mu     sync.Mutex
+	lastID int
+}
+
+func NewRoundRobin() *RoundRobin {
+	return &RoundRobin{}
+}
+
+func (s *RoundRobin) Name() string { return StrategyRoundRobin }
+
+func (s *RoundRobin) Pick(parcel models.Parcel, couriers []models.Courier) (models.Courier, error) {
+	if len(couriers) == 0 {
+		return models.Courier{}, ErrNoCouriers
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sorted := sortedByID(couriers)
+	picked := sorted[0] // Если все ID не больше последнего, начинаем очередь сначала
+	for _, c := range sorted {
+		if c.ID > s.lastID {
+			picked = c
+			break
+		}
+	}
+	s.lastID = picked.ID
+	return picked, nil
+}
+
+// LeastLoaded выбирает курьера с наименьшим числом открытых доставок
+type LeastLoaded struct {
+	loads LoadSource
+}
+
+func NewLeastLoaded(loads LoadSource) *LeastLoaded {
+	return &LeastLoaded{loads: loads}
+}
+
+func (s *LeastLoaded) Name() string { return StrategyLeastLoaded }
+
+func (s *LeastLoaded) Pick(parcel models.Parcel, couriers []models.Courier) (models.Courier, error) {
+	if len(couriers) == 0 {
+		return models.Courier{}, ErrNoCouriers
+	}
+
+	var picked models.Courier
+	minLoad := math.MaxInt
+	// При равной загрузке выбирается курьер с меньшим ID
+	for _, c := range sortedByID(couriers) {
+		deliveries, err := s.loads.GetDeliveriesByCourier(c.ID)
+		if err != nil {
+			return models.Courier{}, fmt.Errorf("ошибка при подсчете загрузки курьера %d: %w", c.ID, err)
+		}
+
+		// Завершенные, неудавшиеся и отмененные доставки курьера не занимают
+		load := 0
+		for _, d := range deliveries {
+			if status, err := delivery.ParseStatus(d.Status); err == nil && status.IsOpen() {
+				load++
+			}
+		}
+		if load < minLoad {
+			picked, minLoad = c, load
+		}
+	}
+	return picked, nil
+}
+
+// Nearest выбирает курьера, ближайшего к посылке. Курьеры с неизвестными координатами
+// рассматриваются после остальных; если неизвестны координаты посылки, выбирается курьер с наименьшим ID
+type Nearest struct {
+	locator Locator
+}
+
+func NewNearest(locator Locator) *Nearest {
+	return &Nearest{locator: locator}
+}
+
+func (s *Nearest) Name() string { return StrategyNearest }
+
+func (s *Nearest) Pick(parcel models.Parcel, couriers []models.Courier) (models.Courier, error) {
+	if len(couriers) == 0 {
+		return models.Courier{}, ErrNoCouriers
+	}
+
+	sorted := sortedByID(couriers)
+	if s.locator == nil {
+		return sorted[0], nil
+	}
+	target, ok := s.locator.ParcelLocation(parcel)
+	if !ok {
+		return sorted[0], nil
+	}
+
+	picked := sorted[0]
+	minDistance := math.Inf(1)
+	for _, c := range sorted {
+		position, ok := s.locator.CourierLocation(c.ID)
+		if !ok {
+			continue
+		}
+		if d := geo.Distance(target, position); d < minDistance {
+			picked, minDistance = c, d
+		}
+	}
+	return picked, nil
+}
@@ -0,0 +1,142 @@
+package dispatch
+
+import (
+	"delivery/internal/business/models"
+	"delivery/internal/geo"
+	"errors"
+	"testing"
+	"time"
+)
+
+func couriers(ids ...int) []models.Courier {
+	result := make([]models.Courier, len(ids))
+	for i, id := range ids {
+		result[i] = models.Courier{ID: id, Status: models.CourierStatusAvailable}
+	}
+	return result
+}
+
+func TestRoundRobin_Pick(t *testing.T) {
+	strategy := NewRoundRobin()
+
+	var picked []int
+	for i := 0; i < 4; i++ {
+		c, err := strategy.Pick(models.Parcel{ID: i}, couriers(3, 1, 2))
+		if err != nil {
+			t.Fatalf("Pick() error = %v", err)
+		}
+		picked = append(picked, c.ID)
+	}
+
+	want := []int{1, 2, 3, 1}
+	for i := range want {
+		if picked[i] != want[i] {
+			t.Fatalf("Pick() по очереди = %v, want %v", picked, want)
+		}
+	}
+
+	// Курьер 2 занят - очередь продолжается со следующего
+	if c, _ := strategy.Pick(models.Parcel{}, couriers(1, 3)); c.ID != 3 {
+		t.Errorf("Pick() = %d, want 3", c.ID)
+	}
+
+	if _, err := strategy.Pick(models.Parcel{}, nil); !errors.Is(err, ErrNoCouriers) {
+		t.Errorf("Pick() без курьеров error = %v", err)
+	}
+}
+
+func TestLeastLoaded_Pick(t *testing.T) {
+	store := newMemoryStore(nil, nil)
+	store.deliveries = []models.Delivery{
+		{CourierID: 1, Status: "assigned"},
+		{CourierID: 1, Status: "in_transit"},
+		{CourierID: 2, Status: "picked_up"},
+		// Завершенные, неудавшиеся и отмененные доставки не учитываются
+		{CourierID: 3, Status: "delivered"},
+		{CourierID: 3, Status: "failed"},
+		{CourierID: 3, Status: "cancelled"},
+	}
+	strategy := NewLeastLoaded(store)
+
+	if c, _ := strategy.Pick(models.Parcel{}, couriers(1, 2, 3)); c.ID != 3 {
+		t.Errorf("Pick() = %d, want 3", c.ID)
+	}
+	if c, _ := strategy.Pick(models.Parcel{}, couriers(1, 2)); c.ID != 2 {
+		t.Errorf("Pick() = %d, want 2", c.ID)
+	}
+	// При равной загрузке выбирается меньший ID
+	if c, _ := strategy.Pick(models.Parcel{}, couriers(5, 4)); c.ID != 4 {
+		t.Errorf("Pick() = %d, want 4", c.ID)
+	}
+}
+
+func TestNearest_Pick(t *testing.T) {
+	locator := memoryLocator{
+		parcels: map[int]geo.Point{1: {Lat: 55.75, Lon: 37.62}},
+		couriers: map[int]geo.Point{
+			1: {Lat: 59.93, Lon: 30.33}, // Санкт-Петербург
+			2: {Lat: 55.76, Lon: 37.60}, // Рядом с посылкой
+			3: {Lat: 56.33, Lon: 44.00}, // Нижний Новгород
+		},
+	}
+	strategy := NewNearest(locator)
+
+	tests := []struct {
+		name     string
+		parcel   models.Parcel
+		couriers []models.Courier
+		want     int
+	}{
+		{"Ближайший курьер", models.Parcel{ID: 1}, couriers(1, 2, 3), 2},
+		{"Курьер без координат уступает остальным", models.Parcel{ID: 1}, couriers(4, 1, 3), 3},
+		{"Координаты посылки неизвестны", models.Parcel{ID: 2}, couriers(3, 2), 2},
+		{"Координаты курьеров неизвестны", models.Parcel{ID: 1}, couriers(6, 5), 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := strategy.Pick(tt.parcel, tt.couriers)
+			if err != nil || c.ID != tt.want {
+				t.Errorf("Pick() = %d, %v, want %d", c.ID, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewStrategy(t *testing.T) {
+	for _, name := range []string{"", StrategyRoundRobin, StrategyLeastLoaded, StrategyNearest} {
+		if _, err := NewStrategy(name, newMemoryStore(nil, nil), nil); err != nil {
+			t.Errorf("NewStrategy(%q) error = %v", name, err)
+		}
+	}
+	if _, err := NewStrategy("random", nil, nil); err == nil {
+		t.Error("NewStrategy(random) не вернул ошибку")
+	}
+}
+
+func TestFleetLocator(t *testing.T) {
+	now := time.Now()
+	positions := memoryPositions{
+		1: {CourierID: 1, Lat: 55.76, Lon: 37.60, Timestamp: now.Add(-time.Minute)},
+		2: {CourierID: 2, Lat: 55.70, Lon: 37.50, Timestamp: now.Add(-time.Hour)},
+	}
+	locator := NewFleetLocator(positions)
+
+	if p, ok := locator.CourierLocation(1); !ok || p.Lat != 55.76 {
+		t.Errorf("CourierLocation(1) = %v, %v", p, ok)
+	}
+	// Устаревшая отметка и курьер без отметок
+	for _, id := range []int{2, 3} {
+		if _, ok := locator.CourierLocation(id); ok {
+			t.Errorf("CourierLocation(%d) вернул координаты", id)
+		}
+	}
+
+	if _, ok := locator.ParcelLocation(models.Parcel{ID: 1}); ok {
+		t.Error("ParcelLocation() без геокодированного адреса вернул координаты")
+	}
+	parcel := models.Parcel{ID: 2, Destination: &models.Address{City: "Москва", Lat: 55.75, Lon: 37.62}}
+	if p, ok := locator.ParcelLocation(parcel); !ok || p != (geo.Point{Lat: 55.75, Lon: 37.62}) {
+		t.Errorf("ParcelLocation() = %v, %v", p, ok)
+	}
+}
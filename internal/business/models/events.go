@@ -0,0 +1,150 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Топики Kafka, в которые публикуются доменные события. Ключ сообщения - ID записи,
+// поэтому события одной посылки, доставки, курьера или клиента читаются в порядке записи
+const (
+	TopicParcels    = "parcels"
+	TopicDeliveries = "deliveries"
+	TopicCouriers   = "couriers"
+	TopicCustomers  = "customers"
+)
+
+// Типы доменных событий
+const (
+	EventParcelRegistered      = "ParcelRegistered"
+	EventParcelStatusChanged   = "ParcelStatusChanged"
+	EventDeliveryCreated       = "DeliveryCreated"
+	EventDeliveryAssigned      = "DeliveryAssigned"
+	EventDeliveryStatusChanged = "DeliveryStatusChanged"
+	EventDeliveryCompleted     = "DeliveryCompleted"
+	EventDeliveryDeleted       = "DeliveryDeleted"
+	EventCourierRegistered     = "CourierRegistered"
+	EventCourierStatusChanged  = "CourierStatusChanged"
+	EventCustomerRegistered    = "CustomerRegistered"
+	EventCustomerUpdated       = "CustomerUpdated"
+)
+
+// OutboxEvent - доменное событие, записанное в outbox в одной транзакции с изменением
+// и ожидающее публикации в Kafka
+type OutboxEvent struct {
+	ID        int64           `json:"id"`
+	Topic     string          `json:"topic"`
+	Key       string          `json:"key"`
+	Type      string          `json:"type"`
+	Version   int             `json:"version"` // Версия формата данных события
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+	Attempts  int             `json:"attempts"` // Неудачные попытки публикации
+	// Цепочка событий, к которой относится событие; пусто, если событие начинает новую цепочку
+	CorrelationID string `json:"correlation_id,omitempty"`
+}
+
+// NewOutboxEvent создает событие типа eventType записи с ID key для публикации в топик topic
+func NewOutboxEvent(topic string, key int, eventType string, payload interface{}) (OutboxEvent, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return OutboxEvent{}, fmt.Errorf("ошибка при сериализации события %s: %w", eventType, err)
+	}
+	return OutboxEvent{
+		Topic:     topic,
+		Key:       strconv.Itoa(key),
+		Type:      eventType,
+		Payload:   data,
+		CreatedAt: time.Now().UTC(),
+	}, nil
+}
+
+// ParcelRegisteredEvent - посылка зарегистрирована
+type ParcelRegisteredEvent struct {
+	ParcelID       int       `json:"parcel_id"`
+	ClientID       int       `json:"client_id"`
+	TrackingNumber string    `json:"tracking_number"`
+	ZoneID         int       `json:"zone_id,omitempty"`
+	RegisteredAt   time.Time `json:"registered_at"`
+}
+
+// ParcelStatusChangedEvent - изменился статус посылки
+type ParcelStatusChangedEvent struct {
+	ParcelID  int       `json:"parcel_id"`
+	Status    string    `json:"status"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// DeliveryCreatedEvent - создана доставка посылки, курьер еще не назначен
+type DeliveryCreatedEvent struct {
+	DeliveryID int       `json:"delivery_id"`
+	ParcelID   int       `json:"parcel_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// DeliveryAssignedEvent - на доставку назначен курьер
+type DeliveryAssignedEvent struct {
+	DeliveryID int       `json:"delivery_id"`
+	ParcelID   int       `json:"parcel_id"`
+	CourierID  int       `json:"courier_id"`
+	AssignedAt time.Time `json:"assigned_at"`
+}
+
+// DeliveryStatusChangedEvent - изменился статус доставки, кроме назначения и завершения,
+// о которых сообщают DeliveryAssignedEvent и DeliveryCompletedEvent
+type DeliveryStatusChangedEvent struct {
+	DeliveryID int       `json:"delivery_id"`
+	ParcelID   int       `json:"parcel_id"`
+	CourierID  int       `json:"courier_id,omitempty"`
+	OldStatus  string    `json:"old_status"`
+	NewStatus  string    `json:"new_status"`
+	ChangedAt  time.Time `json:"changed_at"`
+}
+
+// DeliveryCompletedEvent - посылка доставлена
+type DeliveryCompletedEvent struct {
+	DeliveryID  int       `json:"delivery_id"`
+	ParcelID    int       `json:"parcel_id"`
+	CourierID   int       `json:"courier_id"`
+	DeliveredAt time.Time `json:"delivered_at"`
+}
+
+// DeliveryDeletedEvent - доставка удалена. Status - статус доставки на момент удаления;
+// если доставка не была завершена, посылка и курьер освобождены в той же транзакции
+type DeliveryDeletedEvent struct {
+	DeliveryID int       `json:"delivery_id"`
+	ParcelID   int       `json:"parcel_id"`
+	CourierID  int       `json:"courier_id,omitempty"`
+	Status     string    `json:"status"`
+	DeletedAt  time.Time `json:"deleted_at"`
+}
+
+// CourierRegisteredEvent - добавлен курьер
+type CourierRegisteredEvent struct {
+	CourierID   int    `json:"courier_id"`
+	UserID      int    `json:"user_id,omitempty"`
+	VehicleType string `json:"vehicle_type,omitempty"`
+	Status      string `json:"status"`
+}
+
+// CourierStatusChangedEvent - курьер изменил статус, в том числе при назначении доставки
+// и ее завершении, неудаче или отмене
+type CourierStatusChangedEvent struct {
+	CourierID int       `json:"courier_id"`
+	OldStatus string    `json:"old_status"`
+	NewStatus string    `json:"new_status"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// CustomerRegisteredEvent - добавлен клиент
+type CustomerRegisteredEvent struct {
+	CustomerID int `json:"customer_id"`
+	UserID     int `json:"user_id,omitempty"`
+}
+
+// CustomerUpdatedEvent - изменились контактные данные клиента
+type CustomerUpdatedEvent struct {
+	CustomerID int `json:"customer_id"`
+}
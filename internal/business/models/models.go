@@ -1,41 +1,205 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	ParcelStatusRegistered     = "registered"
+	ParcelStatusSent           = "sent"
+	ParcelStatusInTransit      = "in_transit"
+	ParcelStatusDelivered      = "delivered"
+	ParcelStatusDeliveryFailed = "delivery_failed"
+	ParcelStatusReturned       = "returned"
+)
+
+// ErrInvalidParcelStatus - статус посылки не входит в список известных статусов
+var ErrInvalidParcelStatus = errors.New("недопустимый статус посылки")
 
+// IsValidParcelStatus проверяет, что статус входит в список статусов посылки,
+// в которые ее переводит жизненный цикл доставки
+func IsValidParcelStatus(status string) bool {
+	switch status {
+	case ParcelStatusRegistered, ParcelStatusSent, ParcelStatusInTransit,
+		ParcelStatusDelivered, ParcelStatusDeliveryFailed, ParcelStatusReturned:
+		return true
+	}
+	return false
+}
+
+// Статусы курьера
 const (
-	ParcelStatusRegistered = "registered"
-	ParcelStatusSent       = "sent"
+	CourierStatusAvailable = "available"
+	CourierStatusBusy      = "busy"
+	CourierStatusOffline   = "offline"
+)
+
+// ErrInvalidCourierStatus - статус курьера не входит в список известных статусов
+var ErrInvalidCourierStatus = errors.New("недопустимый статус курьера")
+
+// Типы транспорта курьера. От типа зависит средняя скорость при расчете ожидаемого времени доставки
+const (
+	VehicleFoot    = "foot"
+	VehicleBicycle = "bicycle"
+	VehicleScooter = "scooter"
+	VehicleCar     = "car"
+)
+
+// Ошибки назначения доставки
+var (
+	ErrParcelNotFound        = errors.New("посылка не найдена")
+	ErrCourierNotFound       = errors.New("курьер не найден")
+	ErrParcelAlreadyAssigned = errors.New("посылка уже назначена курьеру")
+	ErrCourierUnavailable    = errors.New("курьер недоступен")
+	// ErrCourierHasOpenDelivery - курьер с незавершенной доставкой не может снова стать доступным
+	ErrCourierHasOpenDelivery = errors.New("у курьера есть незавершенная доставка")
+)
+
+// ErrInvalidTrackingNumber - номер отслеживания имеет неверный формат или контрольную цифру
+var ErrInvalidTrackingNumber = errors.New("некорректный номер отслеживания")
+
+// Ошибки местоположения курьера
+var (
+	ErrInvalidLocation  = errors.New("некорректные данные местоположения")
+	ErrLocationNotFound = errors.New("местоположение курьера неизвестно")
+)
+
+// Ошибки зон доставки
+var (
+	ErrZoneNotFound  = errors.New("зона доставки не найдена")
+	ErrZoneNameTaken = errors.New("зона доставки с таким названием уже существует")
+	ErrInvalidZone   = errors.New("некорректная зона доставки")
+)
+
+// Ошибки жизненного цикла доставки
+var (
+	ErrDeliveryNotFound        = errors.New("доставка не найдена")
+	ErrUnknownDeliveryStatus   = errors.New("неизвестный статус доставки")
+	ErrInvalidStatusTransition = errors.New("недопустимый переход статуса доставки")
 )
 
 type Customer struct {
-	ID    int    `json:"id"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
-	Phone string `json:"phone"`
+	ID     int    `json:"id"`
+	UserID int    `json:"user_id,omitempty"` // Учетная запись, которой принадлежит профиль клиента
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+	Phone  string `json:"phone"`
+}
+
+// ErrAddressNotFound - геокодер не смог определить адрес
+var ErrAddressNotFound = errors.New("адрес не найден")
+
+// Address - структурированный адрес с координатами, определенными геокодером
+type Address struct {
+	Street     string  `json:"street,omitempty"` // Улица и дом
+	City       string  `json:"city,omitempty"`
+	PostalCode string  `json:"postal_code,omitempty"`
+	Country    string  `json:"country,omitempty"`
+	Lat        float64 `json:"lat,omitempty"`
+	Lon        float64 `json:"lon,omitempty"`
+}
+
+// String возвращает адрес одной строкой: улица, город, индекс, страна
+func (a Address) String() string {
+	var parts []string
+	for _, part := range []string{a.Street, a.City, a.PostalCode, a.Country} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
 }
 
 type Parcel struct {
-	ID        int       `json:"id"`
-	ClientID  int       `json:"client_id"`
-	Address   string    `json:"address"`
-	Status    string    `json:"status"`
-	CreatedAt time.Time `json:"created_at"`
+	ID             int       `json:"id"`
+	TrackingNumber string    `json:"tracking_number,omitempty"` // Публичный номер отслеживания для этикетки
+	ClientID       int       `json:"client_id"`
+	Address        string    `json:"address"`
+	Destination    *Address  `json:"destination,omitempty"` // Адрес доставки, определенный геокодером
+	ZoneID         int       `json:"zone_id,omitempty"`     // Зона доставки, в которую попадает адрес
+	Status         string    `json:"status"`
+	CreatedAt      time.Time `json:"created_at"`
 }
 
 type Courier struct {
-	ID        int    `json:"id"`
-	Name      string `json:"name"`
-	Phone     string `json:"phone"`
-	Email     string `json:"email"`
-	VehicleID string `json:"vehicle_id,omitempty"`
-	Status    string `json:"status"`
+	ID          int    `json:"id"`
+	UserID      int    `json:"user_id,omitempty"` // Учетная запись, которой принадлежит профиль курьера
+	Name        string `json:"name"`
+	Phone       string `json:"phone"`
+	Email       string `json:"email"`
+	VehicleID   string `json:"vehicle_id,omitempty"`
+	VehicleType string `json:"vehicle_type,omitempty"` // foot, bicycle, scooter или car
+	Status      string `json:"status"`
+}
+
+// CourierLocation - отметка местоположения курьера, переданная его устройством
+type CourierLocation struct {
+	CourierID int       `json:"courier_id"`
+	Lat       float64   `json:"lat"`
+	Lon       float64   `json:"lon"`
+	Accuracy  float64   `json:"accuracy,omitempty"` // Погрешность в метрах
+	Heading   float64   `json:"heading,omitempty"`  // Направление движения в градусах от севера по часовой стрелке
+	Timestamp time.Time `json:"timestamp"`          // Время определения координат на устройстве
+}
+
+// RouteStop - адрес доставки в маршруте курьера
+type RouteStop struct {
+	DeliveryID int       `json:"delivery_id"`
+	ParcelID   int       `json:"parcel_id"`
+	Address    string    `json:"address"`
+	Lat        float64   `json:"lat"`
+	Lon        float64   `json:"lon"`
+	ZoneID     int       `json:"zone_id,omitempty"` // Зона доставки адреса
+	DistanceKm float64   `json:"distance_km"`       // От предыдущей остановки, для первой - от курьера
+	ETA        time.Time `json:"eta"`               // Ожидаемое время прибытия
+}
+
+// Route - порядок объезда адресов открытых доставок курьера
+type Route struct {
+	CourierID       int             `json:"courier_id"`
+	Start           CourierLocation `json:"start"` // Местоположение курьера, от которого построен маршрут
+	Stops           []RouteStop     `json:"stops"`
+	Unrouted        []int           `json:"unrouted,omitempty"` // Доставки, адрес которых не геокодирован
+	DistanceKm      float64         `json:"distance_km"`
+	DurationMinutes int             `json:"duration_minutes"` // Оценка времени в пути с учетом вручения на каждой остановке
+}
+
+// Zone - район доставки, заданный полигоном GeoJSON
+type Zone struct {
+	ID       int             `json:"id"`
+	Name     string          `json:"name"`
+	Geometry json.RawMessage `json:"geometry"` // Polygon или MultiPolygon; координаты в порядке [долгота, широта]
 }
 
 type Delivery struct {
-	ID          int       `json:"id"`
-	CourierID   int       `json:"courier_id"`
-	ParcelID    int       `json:"parcel_id"`
-	Status      string    `json:"status"`
-	AssignedAt  time.Time `json:"assigned_at"`
-	DeliveredAt time.Time `json:"delivered_at"`
+	ID          int        `json:"id"`
+	CourierID   int        `json:"courier_id"`
+	ParcelID    int        `json:"parcel_id"`
+	Status      string     `json:"status"`
+	AssignedAt  time.Time  `json:"assigned_at"`
+	DeliveredAt time.Time  `json:"delivered_at"`
+	ETA         *time.Time `json:"eta,omitempty"` // Ожидаемое время доставки; пересчитывается по местоположению курьера
+}
+
+// DeliveryEvent - запись журнала изменений доставки
+type DeliveryEvent struct {
+	ID         int       `json:"id"`
+	DeliveryID int       `json:"delivery_id"`
+	OldStatus  string    `json:"old_status,omitempty"` // Пусто для события создания доставки
+	NewStatus  string    `json:"new_status"`
+	ActorID    int       `json:"actor_id,omitempty"` // Пользователь, внесший изменение; 0 - система
+	Note       string    `json:"note,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// AuditInfo - кто и с каким комментарием изменяет запись
+type AuditInfo struct {
+	ActorID int
+	Note    string
+	// Сквозной номер цепочки событий: из заголовка запроса X-Correlation-ID или из обрабатываемого события.
+	// Записывается в события outbox, которые порождает изменение
+	CorrelationID string
 }
@@ -2,6 +2,23 @@ package models
 
 import "time"
 
+// Роли пользователей системы
+const (
+	UserRoleClient     = "client"
+	UserRoleCourier    = "courier"
+	UserRoleDispatcher = "dispatcher"
+	UserRoleAdmin      = "admin"
+)
+
+// IsValidUserRole проверяет, что роль входит в список известных ролей
+func IsValidUserRole(role string) bool {
+	switch role {
+	case UserRoleClient, UserRoleCourier, UserRoleDispatcher, UserRoleAdmin:
+		return true
+	}
+	return false
+}
+
 // User представляет пользователя системы
 type User struct {
 	ID        int       `json:"id"`
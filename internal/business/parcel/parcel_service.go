@@ -1,38 +1,147 @@
 package parcel
 
 import (
+	"database/sql"
 	"delivery/internal/business/models"
+	"delivery/internal/geo"
 	"delivery/internal/metrics"
+	"delivery/internal/outbox"
+	"errors"
 	"fmt"
 	"time"
 )
 
+// maxTrackingAttempts - сколько раз генерировать новый номер отслеживания, если он уже занят
+const maxTrackingAttempts = 5
+
+// Geocoder определяет структурированный адрес и координаты по адресной строке.
+// Если адрес не найден, возвращает ошибку, обернутую вокруг models.ErrAddressNotFound
+type Geocoder interface {
+	Geocode(query string) (models.Address, error)
+}
+
+// ZoneLocator определяет зону доставки, в которую попадает точка
+type ZoneLocator interface {
+	ZoneAt(point geo.Point) (int, bool)
+}
+
+// RegistrationListener получает уведомление о каждой зарегистрированной посылке
+type RegistrationListener interface {
+	ParcelRegistered(parcel models.Parcel)
+}
+
 type ParcelService struct {
-	store *ParcelStore
+	store    *ParcelStore
+	geocoder Geocoder
+	zones    ZoneLocator
+	listener RegistrationListener
+	outbox   outbox.Writer
 }
 
 func NewParcelService(store *ParcelStore) *ParcelService {
 	return &ParcelService{store: store}
 }
 
+// WithGeocoder включает геокодирование адреса при регистрации посылки и изменении адреса
+func (s *ParcelService) WithGeocoder(geocoder Geocoder) *ParcelService {
+	s.geocoder = geocoder
+	return s
+}
+
+// WithZones включает привязку посылки к зоне доставки по координатам геокодированного адреса
+func (s *ParcelService) WithZones(zones ZoneLocator) *ParcelService {
+	s.zones = zones
+	return s
+}
+
+// WithRegistrationListener подписывает listener на регистрацию посылок
+func (s *ParcelService) WithRegistrationListener(listener RegistrationListener) *ParcelService {
+	s.listener = listener
+	return s
+}
+
+// WithOutbox включает запись событий ParcelRegistered и ParcelStatusChanged в outbox
+// в одной транзакции с изменением посылки
+func (s *ParcelService) WithOutbox(w outbox.Writer) *ParcelService {
+	s.outbox = w
+	return s
+}
+
+// locate заполняет адресную строку, результат ее геокодирования и зону доставки посылки.
+// Если строка не задана, она составляется из переданного структурированного адреса
+func (s *ParcelService) locate(p *models.Parcel, address string, destination *models.Address) error {
+	if address == "" && destination != nil {
+		address = destination.String()
+	}
+	p.Address, p.Destination, p.ZoneID = address, nil, 0
+	if s.geocoder == nil || address == "" {
+		return nil
+	}
+
+	resolved, err := s.geocoder.Geocode(address)
+	if err != nil {
+		return fmt.Errorf("Ошибка геокодирования адреса: %w", err)
+	}
+	p.Destination = &resolved
+
+	if s.zones != nil {
+		if zoneID, ok := s.zones.ZoneAt(geo.Point{Lat: resolved.Lat, Lon: resolved.Lon}); ok {
+			p.ZoneID = zoneID
+		}
+	}
+	return nil
+}
+
 func (s *ParcelService) Register(parcel *models.Parcel) error {
 	p := models.Parcel{
 		ClientID:  parcel.ClientID,
-		Address:   parcel.Address,
-		Status:    "registered",
+		Status:    models.ParcelStatusRegistered,
 		CreatedAt: time.Now().UTC(),
 	}
+	if err := s.locate(&p, parcel.Address, parcel.Destination); err != nil {
+		return err
+	}
 
-	id, err := s.store.Add(p)
+	var id int
+	err := outbox.InTx(s.outbox, func(tx *sql.Tx) ([]models.OutboxEvent, error) {
+		err := ErrTrackingNumberTaken
+		for attempt := 0; attempt < maxTrackingAttempts && errors.Is(err, ErrTrackingNumberTaken); attempt++ {
+			if p.TrackingNumber, err = GenerateTrackingNumber(); err != nil {
+				return nil, fmt.Errorf("Ошибка при генерации номера отслеживания: %w", err)
+			}
+			id, err = s.store.AddTx(tx, p)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Ошибка при регистрации посылки: %w", err)
+		}
+
+		return outbox.Event(models.TopicParcels, id, models.EventParcelRegistered, models.ParcelRegisteredEvent{
+			ParcelID:       id,
+			ClientID:       p.ClientID,
+			TrackingNumber: p.TrackingNumber,
+			ZoneID:         p.ZoneID,
+			RegisteredAt:   p.CreatedAt,
+		})
+	})
 	if err != nil {
-		return fmt.Errorf("Ошибка при регистрации посылки: %w", err)
+		return err
 	}
 
 	parcel.ID = id
+	parcel.Address = p.Address
+	parcel.Destination = p.Destination
+	parcel.ZoneID = p.ZoneID
+	parcel.TrackingNumber = p.TrackingNumber
+	parcel.Status = p.Status
+	parcel.CreatedAt = p.CreatedAt
 
 	// Увеличиваем счетчик созданных посылок
 	metrics.ParcelCreatedTotal.Inc()
 
+	if s.listener != nil {
+		s.listener.ParcelRegistered(*parcel)
+	}
+
 	return nil
 }
 
@@ -42,13 +151,29 @@ func (s *ParcelService) Get(id int) (*models.Parcel, error) {
 		return nil, fmt.Errorf("parcel not found: %w", err)
 	}
 	return &models.Parcel{
-		ID:       parcel.ID,
-		ClientID: parcel.ClientID,
-		Address:  parcel.Address,
-		Status:   parcel.Status,
+		ID:             parcel.ID,
+		TrackingNumber: parcel.TrackingNumber,
+		ClientID:       parcel.ClientID,
+		Address:        parcel.Address,
+		Destination:    parcel.Destination,
+		ZoneID:         parcel.ZoneID,
+		Status:         parcel.Status,
+		CreatedAt:      parcel.CreatedAt,
 	}, nil
 }
 
+// GetByTrackingNumber возвращает посылку по номеру отслеживания, не обращаясь к БД для номеров с неверной контрольной цифрой
+func (s *ParcelService) GetByTrackingNumber(trackingNumber string) (*models.Parcel, error) {
+	if !ValidTrackingNumber(trackingNumber) {
+		return nil, models.ErrInvalidTrackingNumber
+	}
+	parcel, err := s.store.GetByTrackingNumber(trackingNumber)
+	if err != nil {
+		return nil, fmt.Errorf("parcel not found: %w", err)
+	}
+	return parcel, nil
+}
+
 func (s *ParcelService) List(clientID int) ([]models.Parcel, error) {
 	parcels, err := s.store.GetByClient(clientID)
 	if err != nil {
@@ -58,23 +183,39 @@ func (s *ParcelService) List(clientID int) ([]models.Parcel, error) {
 	var result []models.Parcel
 	for _, parcel := range parcels {
 		result = append(result, models.Parcel{
-			ID:       parcel.ID,
-			ClientID: parcel.ClientID,
-			Address:  parcel.Address,
-			Status:   parcel.Status,
+			ID:             parcel.ID,
+			TrackingNumber: parcel.TrackingNumber,
+			ClientID:       parcel.ClientID,
+			Address:        parcel.Address,
+			Destination:    parcel.Destination,
+			ZoneID:         parcel.ZoneID,
+			Status:         parcel.Status,
+			CreatedAt:      parcel.CreatedAt,
 		})
 	}
 	return result, nil
 }
 
+// ListUnassigned возвращает посылки, ожидающие назначения курьера
+func (s *ParcelService) ListUnassigned() ([]models.Parcel, error) {
+	parcels, err := s.store.GetUnassigned()
+	if err != nil {
+		return nil, fmt.Errorf("Ошибка при получении неназначенных посылок: %w", err)
+	}
+	return parcels, nil
+}
+
+// Update изменяет данные посылки. Статус из parcel не сохраняется: статус посылки
+// следует за статусом ее доставки и меняется через UpdateStatus
 func (s *ParcelService) Update(id int, parcel *models.Parcel) error {
 	p := models.Parcel{
 		ID:        id,
 		ClientID:  parcel.ClientID,
-		Address:   parcel.Address,
-		Status:    parcel.Status,
 		CreatedAt: time.Now().UTC(),
 	}
+	if err := s.locate(&p, parcel.Address, parcel.Destination); err != nil {
+		return err
+	}
 
 	if err := s.store.Update(p); err != nil {
 		return fmt.Errorf("Ошибка при обновлении посылки: %w", err)
@@ -83,8 +224,22 @@ func (s *ParcelService) Update(id int, parcel *models.Parcel) error {
 	return nil
 }
 
+// UpdateStatus переводит посылку в один из статусов, которые задает жизненный цикл доставки
 func (s *ParcelService) UpdateStatus(id int, status string) error {
-	err := s.store.SetStatus(id, status)
+	if !models.IsValidParcelStatus(status) {
+		return fmt.Errorf("%w: %q", models.ErrInvalidParcelStatus, status)
+	}
+
+	err := outbox.InTx(s.outbox, func(tx *sql.Tx) ([]models.OutboxEvent, error) {
+		if err := s.store.SetStatusTx(tx, id, status); err != nil {
+			return nil, err
+		}
+		return outbox.Event(models.TopicParcels, id, models.EventParcelStatusChanged, models.ParcelStatusChangedEvent{
+			ParcelID:  id,
+			Status:    status,
+			ChangedAt: time.Now().UTC(),
+		})
+	})
 	if err == nil {
 		// Увеличиваем счетчик обновлений статуса посылок
 		metrics.ParcelStatusUpdatedTotal.WithLabelValues(status).Inc()
@@ -92,8 +247,13 @@ func (s *ParcelService) UpdateStatus(id int, status string) error {
 	return err
 }
 
-func (s *ParcelService) UpdateAddress(id int, address string) error {
-	return s.store.SetAddress(id, address)
+// UpdateAddress изменяет адрес посылки. Адрес задается строкой или, если она пуста, структурированным адресом
+func (s *ParcelService) UpdateAddress(id int, address string, destination *models.Address) error {
+	p := models.Parcel{ID: id}
+	if err := s.locate(&p, address, destination); err != nil {
+		return err
+	}
+	return s.store.SetAddress(p)
 }
 
 func (s *ParcelService) Delete(id int) error {
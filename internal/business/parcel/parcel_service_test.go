@@ -1,11 +1,15 @@
 package parcel
 
 import (
+	"fmt"
+	"regexp"
 	"testing"
 	"time"
 
 	"delivery/internal/business/models"
+	"delivery/internal/geo"
 
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/require"
 )
 
@@ -47,3 +51,84 @@ func TestParcelService(t *testing.T) {
 	_, err = service.Get(parcel.ID)
 	require.Error(t, err)
 }
+
+// fakeGeocoder знает только один адрес
+type fakeGeocoder struct{}
+
+func (fakeGeocoder) Geocode(query string) (models.Address, error) {
+	if query != "Москва, ул. Ленина, 1" && query != "ул. Ленина, 1, Москва" {
+		return models.Address{}, fmt.Errorf("%w: %q", models.ErrAddressNotFound, query)
+	}
+	return models.Address{Street: "ул. Ленина, 1", City: "Москва", PostalCode: "101000", Country: "Россия", Lat: 55.7558, Lon: 37.6173}, nil
+}
+
+// fakeZones относит к зоне 3 точки севернее 55.75 широты
+type fakeZones struct{}
+
+func (fakeZones) ZoneAt(point geo.Point) (int, bool) {
+	return 3, point.Lat > 55.75
+}
+
+func TestParcelService_Geocoding(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	service := NewParcelService(NewParcelStore(db)).WithGeocoder(fakeGeocoder{}).WithZones(fakeZones{})
+
+	t.Run("Регистрация со структурированным адресом", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO parcels")).
+			WithArgs(10, "ул. Ленина, 1, Москва", models.ParcelStatusRegistered, sqlmock.AnyArg(), sqlmock.AnyArg(),
+				"ул. Ленина, 1", "Москва", "101000", "Россия", 55.7558, 37.6173, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+		// Адресная строка составляется из переданных частей адреса
+		parcel := models.Parcel{ClientID: 10, Destination: &models.Address{City: "Москва", Street: "ул. Ленина, 1"}}
+		require.NoError(t, service.Register(&parcel))
+		require.Equal(t, "ул. Ленина, 1, Москва", parcel.Address)
+		require.Equal(t, "101000", parcel.Destination.PostalCode)
+		require.Equal(t, 3, parcel.ZoneID)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Адрес не найден", func(t *testing.T) {
+		parcel := models.Parcel{ClientID: 10, Address: "Казань, ул. Баумана, 1"}
+		require.ErrorIs(t, service.Register(&parcel), models.ErrAddressNotFound)
+		require.ErrorIs(t, service.UpdateAddress(1, "Казань, ул. Баумана, 1", nil), models.ErrAddressNotFound)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Изменение адреса", func(t *testing.T) {
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE parcels SET address = $1")).
+			WithArgs("Москва, ул. Ленина, 1", "ул. Ленина, 1", "Москва", "101000", "Россия", 55.7558, 37.6173, 3, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		require.NoError(t, service.UpdateAddress(1, "Москва, ул. Ленина, 1", nil))
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestParcelService_Status(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	service := NewParcelService(NewParcelStore(db))
+
+	t.Run("Изменение данных не меняет статус", func(t *testing.T) {
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE parcels SET client_id = $1, address = $2, created_at = $3,")).
+			WithArgs(10, "Москва, ул. Ленина, 1", sqlmock.AnyArg(), nil, nil, nil, nil, nil, nil, nil, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		parcel := models.Parcel{ClientID: 10, Address: "Москва, ул. Ленина, 1", Status: models.ParcelStatusRegistered}
+		require.NoError(t, service.Update(1, &parcel))
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Неизвестный статус", func(t *testing.T) {
+		for _, status := range []string{"", "lost"} {
+			require.ErrorIs(t, service.UpdateStatus(1, status), models.ErrInvalidParcelStatus)
+		}
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
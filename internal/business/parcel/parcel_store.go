@@ -7,6 +7,20 @@ import (
 	"time"
 )
 
+// parcelColumns - колонки, которые читает scanParcel
+const parcelColumns = "id, client_id, address, status, created_at, tracking_number, street, city, postal_code, country, lat, lon, zone_id"
+
+// rowScanner - общий интерфейс *sql.Row и *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// querier - общий интерфейс *sql.DB и *sql.Tx
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
 type ParcelStore struct {
 	db        *sql.DB
 	tableName string
@@ -19,41 +33,82 @@ func NewParcelStore(db *sql.DB) *ParcelStore {
 	}
 }
 
+// conn возвращает транзакцию tx или, если tx == nil, соединение с БД
+func (s *ParcelStore) conn(tx *sql.Tx) querier {
+	if tx == nil {
+		return s.db
+	}
+	return tx
+}
+
+// Add добавляет посылку. Если номер отслеживания уже занят, возвращает ErrTrackingNumberTaken
 func (s *ParcelStore) Add(p models.Parcel) (int, error) {
+	return s.add(s.db, p)
+}
+
+// AddTx добавляет посылку в транзакции tx; без транзакции, если tx == nil
+func (s *ParcelStore) AddTx(tx *sql.Tx, p models.Parcel) (int, error) {
+	return s.add(s.conn(tx), p)
+}
+
+func (s *ParcelStore) add(q querier, p models.Parcel) (int, error) {
 	createdAt := p.CreatedAt.Format(time.RFC3339) // Преобразование в строку для хранения в базе данных
-	query := fmt.Sprintf(`INSERT INTO %s (client_id, address, status, created_at) VALUES ($1, $2, $3, $4) RETURNING id`, s.tableName)
+	query := fmt.Sprintf(`INSERT INTO %s (client_id, address, status, created_at, tracking_number, street, city, postal_code, country, lat, lon, zone_id)
+              VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+              ON CONFLICT (tracking_number) DO NOTHING RETURNING id`, s.tableName)
+	args := append([]interface{}{p.ClientID, p.Address, p.Status, createdAt, nullString(p.TrackingNumber)}, destinationArgs(p.Destination)...)
+	args = append(args, nullInt(p.ZoneID))
 	var id int
-	err := s.db.QueryRow(query, p.ClientID, p.Address, p.Status, createdAt).Scan(&id)
+	err := q.QueryRow(query, args...).Scan(&id)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrTrackingNumberTaken
+		}
 		return 0, fmt.Errorf("Ошибка при добавлении посылки: %w", err)
 	}
 	return id, nil
 }
 
 func (s *ParcelStore) Get(id int) (*models.Parcel, error) {
-	var parcel models.Parcel
-	var createdAtStr string
-
-	query := fmt.Sprintf(`SELECT id, client_id, address, status, created_at FROM %s WHERE id = $1`, s.tableName)
-	err := s.db.QueryRow(query, id).Scan(&parcel.ID, &parcel.ClientID, &parcel.Address, &parcel.Status, &createdAtStr)
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE id = $1`, parcelColumns, s.tableName)
+	parcel, err := scanParcel(s.db.QueryRow(query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("Посылка с ID %d не найдена", id)
 		}
 		return nil, fmt.Errorf("Ошибка при получении посылки: %w", err)
 	}
+	return parcel, nil
+}
 
-	// Преобразование строки в time.Time
-	parcel.CreatedAt, err = time.Parse(time.RFC3339, createdAtStr)
+// GetForUpdate возвращает посылку и блокирует ее запись до конца транзакции tx
+func (s *ParcelStore) GetForUpdate(tx *sql.Tx, id int) (*models.Parcel, error) {
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE id = $1 FOR UPDATE`, parcelColumns, s.tableName)
+	parcel, err := scanParcel(tx.QueryRow(query, id))
 	if err != nil {
-		return nil, fmt.Errorf("Ошибка преобразования created_at: %w", err)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: ID %d", models.ErrParcelNotFound, id)
+		}
+		return nil, fmt.Errorf("Ошибка при получении посылки: %w", err)
 	}
+	return parcel, nil
+}
 
-	return &parcel, nil
+// GetByTrackingNumber возвращает посылку по номеру отслеживания
+func (s *ParcelStore) GetByTrackingNumber(trackingNumber string) (*models.Parcel, error) {
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE tracking_number = $1`, parcelColumns, s.tableName)
+	parcel, err := scanParcel(s.db.QueryRow(query, trackingNumber))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("Посылка с номером %s не найдена", trackingNumber)
+		}
+		return nil, fmt.Errorf("Ошибка при получении посылки: %w", err)
+	}
+	return parcel, nil
 }
 
 func (s *ParcelStore) GetByClient(clientID int) ([]models.Parcel, error) {
-	query := fmt.Sprintf(`SELECT id, client_id, address, status, created_at FROM %s WHERE client_id = $1`, s.tableName)
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE client_id = $1`, parcelColumns, s.tableName)
 	rows, err := s.db.Query(query, clientID)
 	if err != nil {
 		return nil, fmt.Errorf("Ошибка при получении посылок клиента: %w", err)
@@ -62,30 +117,49 @@ func (s *ParcelStore) GetByClient(clientID int) ([]models.Parcel, error) {
 
 	var parcels []models.Parcel
 	for rows.Next() {
-		var parcel models.Parcel
-		var createdAtStr string
-
-		err := rows.Scan(&parcel.ID, &parcel.ClientID, &parcel.Address, &parcel.Status, &createdAtStr)
+		parcel, err := scanParcel(rows)
 		if err != nil {
 			return nil, fmt.Errorf("Ошибка при сканировании посылки: %w", err)
 		}
+		parcels = append(parcels, *parcel)
+	}
+
+	return parcels, nil
+}
 
-		// Преобразование created_at в time.Time
-		parcel.CreatedAt, err = time.Parse(time.RFC3339, createdAtStr)
+// GetUnassigned возвращает посылки, ожидающие назначения курьера, в порядке регистрации
+func (s *ParcelStore) GetUnassigned() ([]models.Parcel, error) {
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE status = $1 ORDER BY id`, parcelColumns, s.tableName)
+	rows, err := s.db.Query(query, models.ParcelStatusRegistered)
+	if err != nil {
+		return nil, fmt.Errorf("Ошибка при получении неназначенных посылок: %w", err)
+	}
+	defer rows.Close()
+
+	var parcels []models.Parcel
+	for rows.Next() {
+		parcel, err := scanParcel(rows)
 		if err != nil {
-			return nil, fmt.Errorf("Ошибка преобразования created_at: %w", err)
+			return nil, fmt.Errorf("Ошибка при сканировании посылки: %w", err)
 		}
+		parcels = append(parcels, *parcel)
+	}
 
-		parcels = append(parcels, parcel)
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Ошибка при обработке результатов: %w", err)
 	}
 
 	return parcels, nil
 }
 
+// Update изменяет данные посылки. Статус не изменяется: он меняется только вместе
+// со статусом доставки или через SetStatusTx
 func (s *ParcelStore) Update(p models.Parcel) error {
 	createdAt := p.CreatedAt.Format(time.RFC3339) // Преобразование в строку
-	query := fmt.Sprintf(`UPDATE %s SET client_id = $1, address = $2, status = $3, created_at = $4 WHERE id = $5`, s.tableName)
-	_, err := s.db.Exec(query, p.ClientID, p.Address, p.Status, createdAt, p.ID)
+	query := fmt.Sprintf(`UPDATE %s SET client_id = $1, address = $2, created_at = $3,
+		street = $4, city = $5, postal_code = $6, country = $7, lat = $8, lon = $9, zone_id = $10 WHERE id = $11`, s.tableName)
+	args := append([]interface{}{p.ClientID, p.Address, createdAt}, destinationArgs(p.Destination)...)
+	_, err := s.db.Exec(query, append(args, nullInt(p.ZoneID), p.ID)...)
 	if err != nil {
 		return fmt.Errorf("Ошибка при обновлении посылки: %w", err)
 	}
@@ -102,19 +176,83 @@ func (s *ParcelStore) Delete(id int) error {
 }
 
 func (s *ParcelStore) SetStatus(id int, status string) error {
+	return s.setStatus(s.db, id, status)
+}
+
+// SetStatusTx изменяет статус посылки в транзакции tx; без транзакции, если tx == nil
+func (s *ParcelStore) SetStatusTx(tx *sql.Tx, id int, status string) error {
+	return s.setStatus(s.conn(tx), id, status)
+}
+
+func (s *ParcelStore) setStatus(q querier, id int, status string) error {
 	query := fmt.Sprintf(`UPDATE %s SET status = $1 WHERE id = $2`, s.tableName)
-	_, err := s.db.Exec(query, status, id)
+	_, err := q.Exec(query, status, id)
 	if err != nil {
 		return fmt.Errorf("Ошибка при обновлении статуса посылки: %w", err)
 	}
 	return nil
 }
 
-func (s *ParcelStore) SetAddress(id int, address string) error {
-	query := fmt.Sprintf(`UPDATE %s SET address = $1 WHERE id = $2`, s.tableName)
-	_, err := s.db.Exec(query, address, id)
+// SetAddress изменяет адрес посылки p.ID вместе с результатом геокодирования и зоной доставки
+func (s *ParcelStore) SetAddress(p models.Parcel) error {
+	query := fmt.Sprintf(`UPDATE %s SET address = $1, street = $2, city = $3, postal_code = $4, country = $5, lat = $6, lon = $7,
+		zone_id = $8 WHERE id = $9`, s.tableName)
+	args := append([]interface{}{p.Address}, destinationArgs(p.Destination)...)
+	_, err := s.db.Exec(query, append(args, nullInt(p.ZoneID), p.ID)...)
 	if err != nil {
 		return fmt.Errorf("Ошибка при обновлении адреса посылки: %w", err)
 	}
 	return nil
 }
+
+func scanParcel(row rowScanner) (*models.Parcel, error) {
+	var parcel models.Parcel
+	var createdAtStr string
+	var trackingNumber, street, city, postalCode, country sql.NullString
+	var lat, lon sql.NullFloat64
+	var zoneID sql.NullInt64
+
+	if err := row.Scan(&parcel.ID, &parcel.ClientID, &parcel.Address, &parcel.Status, &createdAtStr, &trackingNumber,
+		&street, &city, &postalCode, &country, &lat, &lon, &zoneID); err != nil {
+		return nil, err
+	}
+	parcel.TrackingNumber = trackingNumber.String
+	parcel.ZoneID = int(zoneID.Int64)
+
+	// Посылки, зарегистрированные без геокодера, не имеют структурированного адреса
+	if lat.Valid && lon.Valid {
+		parcel.Destination = &models.Address{
+			Street:     street.String,
+			City:       city.String,
+			PostalCode: postalCode.String,
+			Country:    country.String,
+			Lat:        lat.Float64,
+			Lon:        lon.Float64,
+		}
+	}
+
+	// Преобразование строки в time.Time
+	createdAt, err := time.Parse(time.RFC3339, createdAtStr)
+	if err != nil {
+		return nil, fmt.Errorf("Ошибка преобразования created_at: %w", err)
+	}
+	parcel.CreatedAt = createdAt
+
+	return &parcel, nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func nullInt(v int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(v), Valid: v != 0}
+}
+
+// destinationArgs возвращает значения колонок street, city, postal_code, country, lat, lon
+func destinationArgs(d *models.Address) []interface{} {
+	if d == nil {
+		return []interface{}{nil, nil, nil, nil, nil, nil}
+	}
+	return []interface{}{nullString(d.Street), nullString(d.City), nullString(d.PostalCode), nullString(d.Country), d.Lat, d.Lon}
+}
@@ -35,7 +35,15 @@ func setupParcelTestDB() *ParcelStore {
         client_id INTEGER,
         status TEXT,
         address TEXT,
-        created_at TIMESTAMP
+        created_at TIMESTAMP,
+        tracking_number TEXT UNIQUE,
+        street TEXT,
+        city TEXT,
+        postal_code TEXT,
+        country TEXT,
+        lat DOUBLE PRECISION,
+        lon DOUBLE PRECISION,
+        zone_id INTEGER
     );`, tableName)
 
 	_, err = db.Exec(createTable)
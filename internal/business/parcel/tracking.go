@@ -0,0 +1,67 @@
+package parcel
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// Номер отслеживания: префикс, случайные цифры и контрольная цифра по алгоритму Луна, например DL482913570264
+const (
+	trackingPrefix = "DL"
+	trackingDigits = 11 // Случайных цифр без учета контрольной
+)
+
+// ErrTrackingNumberTaken возвращается хранилищем, если сгенерированный номер уже занят
+var ErrTrackingNumberTaken = errors.New("номер отслеживания уже используется")
+
+// GenerateTrackingNumber создает случайный номер отслеживания с контрольной цифрой.
+// Используется crypto/rand, чтобы номера нельзя было подобрать перебором соседних значений
+func GenerateTrackingNumber() (string, error) {
+	digits := make([]byte, trackingDigits)
+	for i := range digits {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		digits[i] = byte('0' + n.Int64())
+	}
+	return trackingPrefix + string(digits) + string(luhnCheckDigit(string(digits))), nil
+}
+
+// ValidTrackingNumber проверяет формат номера отслеживания и его контрольную цифру
+func ValidTrackingNumber(number string) bool {
+	if !strings.HasPrefix(number, trackingPrefix) {
+		return false
+	}
+	digits := strings.TrimPrefix(number, trackingPrefix)
+	if len(digits) != trackingDigits+1 {
+		return false
+	}
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return luhnCheckDigit(digits[:trackingDigits]) == digits[trackingDigits]
+}
+
+// luhnCheckDigit вычисляет контрольную цифру по алгоритму Луна. Он обнаруживает
+// любую ошибку в одной цифре и большинство перестановок соседних цифр
+func luhnCheckDigit(digits string) byte {
+	sum := 0
+	double := true // Удваиваются цифры через одну, начиная с крайней правой
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return byte('0' + (10-sum%10)%10)
+}
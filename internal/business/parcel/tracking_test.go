@@ -0,0 +1,42 @@
+package parcel
+
+import "testing"
+
+func TestGenerateTrackingNumber(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		number, err := GenerateTrackingNumber()
+		if err != nil {
+			t.Fatalf("GenerateTrackingNumber() error = %v", err)
+		}
+		if !ValidTrackingNumber(number) {
+			t.Errorf("GenerateTrackingNumber() = %q не проходит проверку", number)
+		}
+		if seen[number] {
+			t.Errorf("GenerateTrackingNumber() повторил номер %q", number)
+		}
+		seen[number] = true
+	}
+}
+
+func TestValidTrackingNumber(t *testing.T) {
+	tests := []struct {
+		number string
+		want   bool
+	}{
+		{"DL000000000000", true},
+		{"DL123456789015", true},
+		{"DL123456789016", false}, // Неверная контрольная цифра
+		{"DL123456789105", false}, // Переставлены соседние цифры
+		{"XX123456789015", false},
+		{"DL12345678901", false},
+		{"DL12345678901A", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := ValidTrackingNumber(tt.number); got != tt.want {
+			t.Errorf("ValidTrackingNumber(%q) = %v, want %v", tt.number, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Убедимся, что HTTPProvider реализует интерфейс Provider
+var _ Provider = (*HTTPProvider)(nil)
+
+// HTTPProvider - платежный провайдер с JSON API платежного шлюза:
+//
+//	POST /payments                - блокировка средств
+//	GET  /payments/{id}           - состояние платежа
+//	POST /payments/{id}/capture   - списание
+//	POST /payments/{id}/void      - снятие блокировки
+//	POST /payments/{id}/refund    - возврат
+//
+// Этот API реализует симулятор платежного шлюза paysim
+type HTTPProvider struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewHTTPProvider(baseURL string) *HTTPProvider {
+	return &HTTPProvider{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: DefaultProviderTimeout},
+	}
+}
+
+// WithClient задает HTTP-клиент для запросов к шлюзу
+func (p *HTTPProvider) WithClient(client *http.Client) *HTTPProvider {
+	p.client = client
+	return p
+}
+
+// gatewayPayment - платеж в ответе шлюза
+type gatewayPayment struct {
+	ID             string         `json:"id"`
+	Status         ProviderStatus `json:"status"`
+	RedirectURL    string         `json:"redirect_url,omitempty"`
+	Error          string         `json:"error,omitempty"`
+	RefundedAmount float64        `json:"refunded_amount,omitempty"`
+}
+
+// gatewayError - ответ шлюза с ошибкой
+type gatewayError struct {
+	Error string `json:"error"`
+}
+
+func (p *HTTPProvider) Authorize(ctx context.Context, request ProviderRequest) (ProviderPayment, error) {
+	return p.do(ctx, http.MethodPost, "/payments", map[string]interface{}{
+		"reference":   request.Reference,
+		"amount":      request.Amount,
+		"currency":    request.Currency,
+		"method":      request.Method,
+		"description": request.Description,
+	})
+}
+
+func (p *HTTPProvider) Capture(ctx context.Context, providerID string) (ProviderPayment, error) {
+	return p.do(ctx, http.MethodPost, "/payments/"+url.PathEscape(providerID)+"/capture", nil)
+}
+
+func (p *HTTPProvider) Void(ctx context.Context, providerID string) (ProviderPayment, error) {
+	return p.do(ctx, http.MethodPost, "/payments/"+url.PathEscape(providerID)+"/void", nil)
+}
+
+func (p *HTTPProvider) Refund(ctx context.Context, providerID string, amount float64) (ProviderPayment, error) {
+	return p.do(ctx, http.MethodPost, "/payments/"+url.PathEscape(providerID)+"/refund",
+		map[string]interface{}{"amount": amount})
+}
+
+func (p *HTTPProvider) Status(ctx context.Context, providerID string) (ProviderPayment, error) {
+	return p.do(ctx, http.MethodGet, "/payments/"+url.PathEscape(providerID), nil)
+}
+
+// do выполняет запрос к шлюзу. Отказ шлюза выполнить операцию в текущем статусе платежа
+// (409 Conflict) и некорректный запрос возвращаются как ErrInvalidPayment
+func (p *HTTPProvider) do(ctx context.Context, method, path string, body interface{}) (ProviderPayment, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return ProviderPayment{}, fmt.Errorf("ошибка кодирования запроса к платежному шлюзу: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, p.baseURL+path, reader)
+	if err != nil {
+		return ProviderPayment{}, fmt.Errorf("ошибка создания запроса к платежному шлюзу: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return ProviderPayment{}, fmt.Errorf("платежный шлюз недоступен: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var gwErr gatewayError
+		if err := json.NewDecoder(resp.Body).Decode(&gwErr); err != nil || gwErr.Error == "" {
+			gwErr.Error = resp.Status
+		}
+		switch resp.StatusCode {
+		case http.StatusBadRequest, http.StatusConflict:
+			return ProviderPayment{}, fmt.Errorf("%w: %s", ErrInvalidPayment, gwErr.Error)
+		}
+		return ProviderPayment{}, fmt.Errorf("платежный шлюз вернул %d: %s", resp.StatusCode, gwErr.Error)
+	}
+
+	var payment gatewayPayment
+	if err := json.NewDecoder(resp.Body).Decode(&payment); err != nil {
+		return ProviderPayment{}, fmt.Errorf("ошибка декодирования ответа платежного шлюза: %w", err)
+	}
+	return ProviderPayment{
+		ID:             payment.ID,
+		Status:         payment.Status,
+		RedirectURL:    payment.RedirectURL,
+		Error:          payment.Error,
+		RefundedAmount: payment.RefundedAmount,
+	}, nil
+}
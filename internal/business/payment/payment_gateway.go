@@ -1,14 +1,20 @@
 package payment
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"math"
+	"sort"
+	"sync"
 	"time"
 )
 
 // Ошибки платежной системы
 var (
 	ErrPaymentNotFound = errors.New("платеж не найден")
+	ErrInvalidPayment  = errors.New("некорректный платеж")
 )
 
 // PaymentStatus представляет статус платежа
@@ -60,6 +66,12 @@ type PaymentResponse struct {
 	RedirectURL  string        `json:"redirect_url,omitempty"`
 	ReceiptURL   string        `json:"receipt_url,omitempty"`
 	ErrorMessage string        `json:"error_message,omitempty"`
+	// Сумма возвратов нарастающим итогом; платеж с возвратом переходит в статус refunded
+	RefundedAmount float64 `json:"refunded_amount,omitempty"`
+
+	ProviderPaymentID string `json:"provider_payment_id,omitempty"` // ID платежа у платежного провайдера
+	// Заказ доставлен, пока платеж ожидал подтверждения 3-D Secure: средства списываются после подтверждения
+	CaptureRequested bool `json:"capture_requested,omitempty"`
 }
 
 // PaymentService - интерфейс для работы с платежами
@@ -75,10 +87,13 @@ type PaymentService interface {
 	// Получает информацию о платеже по ID
 	GetPayment(paymentID string) (*PaymentResponse, error)
 
+	// Возвращает сохраненный платеж без сверки с провайдером и других изменений
+	StoredPayment(paymentID string) (*PaymentResponse, error)
+
 	// Отменяет платеж
 	CancelPayment(paymentID string) (*PaymentResponse, error)
 
-	// Возвращает платеж
+	// Возвращает платеж полностью или частично; amount <= 0 возвращает остаток
 	RefundPayment(paymentID string, amount float64) (*PaymentResponse, error)
 
 	// Обрабатывает платеж (устаревший метод, сохранен для обратной совместимости)
@@ -92,7 +107,9 @@ type PaymentService interface {
 // Возвращает строку с результатом и ошибку, если она возникла
 
 type MockPaymentService struct {
-	// Хранилище платежей для имитации базы данных
+	mu sync.Mutex
+	// Хранилище платежей для имитации базы данных. Наружу отдаются копии,
+	// чтобы платежи не менялись в обход блокировки
 	payments map[string]*PaymentResponse
 }
 
@@ -106,17 +123,15 @@ func NewMockPaymentService() *MockPaymentService {
 // CreatePayment создает новый платеж
 func (m *MockPaymentService) CreatePayment(request PaymentRequest) (*PaymentResponse, error) {
 	// Валидация запроса
-	if request.Amount <= 0 {
-		return nil, fmt.Errorf("недопустимая сумма платежа")
+	if err := validateRequest(request); err != nil {
+		return nil, err
 	}
 
-	if request.Currency == "" {
-		return nil, fmt.Errorf("валюта не указана")
+	paymentID, err := newPaymentID()
+	if err != nil {
+		return nil, err
 	}
 
-	// Генерация ID платежа (в реальном сервисе был бы более сложный алгоритм)
-	paymentID := fmt.Sprintf("pay_%d", time.Now().UnixNano())
-
 	// Создание ответа
 	now := time.Now()
 	response := &PaymentResponse{
@@ -127,89 +142,248 @@ func (m *MockPaymentService) CreatePayment(request PaymentRequest) (*PaymentResp
 		Status:      StatusPending,
 		Method:      request.Method,
 		CreatedAt:   now,
-		RedirectURL: fmt.Sprintf("https://example.com/payments/%s", paymentID),
+		RedirectURL: redirectURL(paymentID),
 	}
 
 	// Сохранение платежа в "базе данных"
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.payments[paymentID] = response
 
-	return response, nil
+	return copyPayment(response), nil
 }
 
 // GetPayment получает информацию о платеже по ID
 func (m *MockPaymentService) GetPayment(paymentID string) (*PaymentResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	payment, exists := m.payments[paymentID]
 	if !exists {
 		return nil, ErrPaymentNotFound
 	}
 
-	return payment, nil
+	return copyPayment(payment), nil
+}
+
+// StoredPayment возвращает платеж; заглушка не сверяет платежи, поэтому совпадает с GetPayment
+func (m *MockPaymentService) StoredPayment(paymentID string) (*PaymentResponse, error) {
+	return m.GetPayment(paymentID)
 }
 
 // CancelPayment отменяет платеж
 func (m *MockPaymentService) CancelPayment(paymentID string) (*PaymentResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	payment, exists := m.payments[paymentID]
 	if !exists {
 		return nil, ErrPaymentNotFound
 	}
 
-	if payment.Status != StatusPending {
-		return nil, fmt.Errorf("нельзя отменить платеж в статусе %s", payment.Status)
+	if err := checkCancel(payment); err != nil {
+		return nil, err
 	}
 
 	payment.Status = StatusFailed
-	payment.ErrorMessage = "Платеж отменен пользователем"
+	payment.ErrorMessage = cancelMessage
 
-	return payment, nil
+	return copyPayment(payment), nil
 }
 
-// RefundPayment возвращает платеж
+// RefundPayment возвращает платеж полностью или частично
 func (m *MockPaymentService) RefundPayment(paymentID string, amount float64) (*PaymentResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	payment, exists := m.payments[paymentID]
 	if !exists {
 		return nil, ErrPaymentNotFound
 	}
 
-	if payment.Status != StatusCompleted {
-		return nil, fmt.Errorf("нельзя вернуть платеж в статусе %s", payment.Status)
-	}
-
-	if amount > payment.Amount {
-		return nil, fmt.Errorf("сумма возврата не может превышать сумму платежа")
+	amount, err := refundAmount(payment, amount)
+	if err != nil {
+		return nil, err
 	}
 
+	payment.RefundedAmount = math.Round((payment.RefundedAmount+amount)*100) / 100
 	payment.Status = StatusRefunded
 	now := time.Now()
 	payment.CompletedAt = &now
 
-	return payment, nil
+	return copyPayment(payment), nil
+}
+
+// SettleOrder завершает ожидающие платежи заказа и возвращает их. Платежи в других статусах
+// не меняются, поэтому повторный вызов ничего не списывает
+func (m *MockPaymentService) SettleOrder(orderID string) ([]*PaymentResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var settled []*PaymentResponse
+	for _, payment := range m.payments {
+		if payment.OrderID != orderID || payment.Status != StatusPending {
+			continue
+		}
+
+		complete(payment)
+		settled = append(settled, copyPayment(payment))
+	}
+
+	return settled, nil
+}
+
+// UpdatePayment сохраняет платеж, если его статус не изменился с from
+func (m *MockPaymentService) UpdatePayment(payment *PaymentResponse, from PaymentStatus) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, exists := m.payments[payment.PaymentID]
+	if !exists {
+		return ErrPaymentNotFound
+	}
+	if stored.Status != from {
+		return fmt.Errorf("%w: статус платежа %s изменился на %s", ErrInvalidPayment, payment.PaymentID, stored.Status)
+	}
+	m.payments[payment.PaymentID] = copyPayment(payment)
+	return nil
+}
+
+// PendingPayments возвращает ожидающие платежи заказа в порядке создания
+func (m *MockPaymentService) PendingPayments(orderID string) ([]*PaymentResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var pending []*PaymentResponse
+	for _, payment := range m.payments {
+		if payment.OrderID == orderID && payment.Status == StatusPending {
+			pending = append(pending, copyPayment(payment))
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].CreatedAt.Before(pending[j].CreatedAt) })
+	return pending, nil
+}
+
+// CaptureRequestedPayments возвращает ожидающие платежи, отмеченные к списанию, в порядке создания
+func (m *MockPaymentService) CaptureRequestedPayments() ([]*PaymentResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var pending []*PaymentResponse
+	for _, payment := range m.payments {
+		if payment.Status == StatusPending && payment.CaptureRequested {
+			pending = append(pending, copyPayment(payment))
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].CreatedAt.Before(pending[j].CreatedAt) })
+	return pending, nil
 }
 
 // Charge обрабатывает платеж (устаревший метод, сохранен для обратной совместимости)
 func (m *MockPaymentService) Charge(amount float64, currency string) (string, error) {
+	// Создаем платеж через новый интерфейс
+	response, err := m.CreatePayment(chargeRequest(amount, currency))
+	if err != nil {
+		return "", err
+	}
+
+	// Имитируем успешное завершение платежа
+	m.mu.Lock()
+	complete(m.payments[response.PaymentID])
+	m.mu.Unlock()
+
+	return chargeResult(amount, currency, response.PaymentID), nil
+}
+
+// cancelMessage - причина, которая сохраняется в отмененном платеже
+const cancelMessage = "Платеж отменен пользователем"
+
+// validateRequest проверяет запрос на оплату
+func validateRequest(request PaymentRequest) error {
+	if request.Amount <= 0 {
+		return fmt.Errorf("%w: недопустимая сумма платежа", ErrInvalidPayment)
+	}
+	if request.Currency == "" {
+		return fmt.Errorf("%w: валюта не указана", ErrInvalidPayment)
+	}
+	return nil
+}
+
+// checkCancel проверяет, что платеж можно отменить: отменяются только ожидающие платежи
+func checkCancel(payment *PaymentResponse) error {
+	if payment.Status != StatusPending {
+		return fmt.Errorf("%w: нельзя отменить платеж в статусе %s", ErrInvalidPayment, payment.Status)
+	}
+	return nil
+}
+
+// refundAmount проверяет, что платеж можно вернуть в размере amount, и возвращает сумму возврата.
+// Завершенный платеж возвращается частями, пока не будет возвращена вся сумма; amount <= 0
+// возвращает остаток
+func refundAmount(payment *PaymentResponse, amount float64) (float64, error) {
+	if payment.Status != StatusCompleted && payment.Status != StatusRefunded {
+		return 0, fmt.Errorf("%w: нельзя вернуть платеж в статусе %s", ErrInvalidPayment, payment.Status)
+	}
+
+	remaining := payment.Amount - payment.RefundedAmount
 	if amount <= 0 {
-		return "", fmt.Errorf("недопустимая сумма платежа")
+		amount = remaining
+	}
+	if amount > remaining+0.005 || remaining < 0.005 {
+		return 0, fmt.Errorf("%w: сумма возврата %.2f больше остатка платежа %.2f", ErrInvalidPayment, amount, remaining)
 	}
+	return amount, nil
+}
 
-	// Создаем платеж через новый интерфейс
-	request := PaymentRequest{
+// newPaymentID возвращает случайный ID платежа, который нельзя подобрать перебором
+func newPaymentID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("ошибка генерации ID платежа: %w", err)
+	}
+	return "pay_" + hex.EncodeToString(b), nil
+}
+
+// redirectURL возвращает адрес страницы оплаты
+func redirectURL(paymentID string) string {
+	return fmt.Sprintf("https://example.com/payments/%s", paymentID)
+}
+
+// receiptURL возвращает адрес чека завершенного платежа
+func receiptURL(paymentID string) string {
+	return fmt.Sprintf("https://example.com/receipts/%s", paymentID)
+}
+
+// complete отмечает платеж завершенным
+func complete(payment *PaymentResponse) {
+	now := time.Now()
+	payment.Status = StatusCompleted
+	payment.CompletedAt = &now
+	payment.ReceiptURL = receiptURL(payment.PaymentID)
+}
+
+// chargeRequest возвращает запрос на оплату устаревшего метода Charge
+func chargeRequest(amount float64, currency string) PaymentRequest {
+	return PaymentRequest{
 		OrderID:     fmt.Sprintf("order_%d", time.Now().Unix()),
 		Amount:      amount,
 		Currency:    currency,
 		Method:      MethodCard,
 		Description: "Оплата через устаревший метод Charge",
 	}
+}
 
-	response, err := m.CreatePayment(request)
-	if err != nil {
-		return "", err
-	}
-
-	// Имитируем успешное завершение платежа
-	now := time.Now()
-	response.Status = StatusCompleted
-	response.CompletedAt = &now
-	response.ReceiptURL = fmt.Sprintf("https://example.com/receipts/%s", response.PaymentID)
+// chargeResult возвращает результат устаревшего метода Charge
+func chargeResult(amount float64, currency, paymentID string) string {
+	return fmt.Sprintf("Платеж на сумму %.2f %s успешно обработан. ID платежа: %s", amount, currency, paymentID)
+}
 
-	return fmt.Sprintf("Платеж на сумму %.2f %s успешно обработан. ID платежа: %s", amount, currency, response.PaymentID), nil
+// copyPayment возвращает копию платежа
+func copyPayment(payment *PaymentResponse) *PaymentResponse {
+	copied := *payment
+	if payment.CompletedAt != nil {
+		completedAt := *payment.CompletedAt
+		copied.CompletedAt = &completedAt
+	}
+	return &copied
 }
@@ -1,8 +1,8 @@
 package payment
 
 import (
+	"sync"
 	"testing"
-	"time"
 )
 
 func TestNewMockPaymentService(t *testing.T) {
@@ -170,10 +170,10 @@ func TestRefundPayment(t *testing.T) {
 	response, _ := service.CreatePayment(request)
 	paymentID := response.PaymentID
 
-	// Имитируем успешное завершение платежа
-	now := time.Now()
-	response.Status = StatusCompleted
-	response.CompletedAt = &now
+	// Платеж завершается после доставки заказа
+	if _, err := service.SettleOrder(request.OrderID); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
 
 	// Тест успешного возврата платежа
 	refundedPayment, err := service.RefundPayment(paymentID, 50.00)
@@ -184,6 +184,9 @@ func TestRefundPayment(t *testing.T) {
 	if refundedPayment.Status != StatusRefunded {
 		t.Errorf("Expected Status %s, got %s", StatusRefunded, refundedPayment.Status)
 	}
+	if refundedPayment.RefundedAmount != 50.00 {
+		t.Errorf("Expected RefundedAmount 50.00, got %.2f", refundedPayment.RefundedAmount)
+	}
 
 	// Тест возврата несуществующего платежа
 	_, err = service.RefundPayment("non_existent_id", 50.00)
@@ -191,10 +194,19 @@ func TestRefundPayment(t *testing.T) {
 		t.Fatal("Expected error for non-existent payment, got nil")
 	}
 
-	// Тест возврата с суммой больше платежа
-	_, err = service.RefundPayment(paymentID, 200.00)
+	// Тест возврата с суммой больше остатка платежа
+	_, err = service.RefundPayment(paymentID, 60.00)
 	if err == nil {
-		t.Fatal("Expected error for refund amount greater than payment amount, got nil")
+		t.Fatal("Expected error for refund amount greater than remaining amount, got nil")
+	}
+
+	// Тест возврата остатка платежа
+	refundedPayment, err = service.RefundPayment(paymentID, 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if refundedPayment.RefundedAmount != 100.00 {
+		t.Errorf("Expected RefundedAmount 100.00, got %.2f", refundedPayment.RefundedAmount)
 	}
 }
 
@@ -217,3 +229,31 @@ func TestCharge(t *testing.T) {
 		t.Fatal("Expected error for negative amount, got nil")
 	}
 }
+
+func TestMockPaymentService_Concurrent(t *testing.T) {
+	service := NewMockPaymentService()
+
+	// Платежи создаются, читаются и завершаются одновременно; гонки выявляет go test -race
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response, err := service.CreatePayment(PaymentRequest{OrderID: "parcel_1", Amount: 10, Currency: "RUB"})
+			if err != nil {
+				t.Errorf("Expected no error, got %v", err)
+				return
+			}
+			service.SettleOrder("parcel_1")
+			if _, err := service.GetPayment(response.PaymentID); err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	settled, _ := service.SettleOrder("parcel_1")
+	if len(settled) != 0 {
+		t.Errorf("Expected all payments to be settled, %d pending", len(settled))
+	}
+}
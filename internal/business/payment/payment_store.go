@@ -0,0 +1,265 @@
+package payment
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Убедимся, что PgPaymentStore реализует интерфейсы платежного сервиса
+var (
+	_ PaymentService = (*PgPaymentStore)(nil)
+	_ OrderSettler   = (*PgPaymentStore)(nil)
+)
+
+// paymentColumns - колонки платежа в порядке сканирования scanPayment
+const paymentColumns = `id, order_id, amount, currency, status, method, created_at,
+	completed_at, redirect_url, receipt_url, error_message, provider_payment_id, capture_requested,
+	refunded_amount`
+
+// rowScanner - общий интерфейс для *sql.Row и *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// PgPaymentStore хранит платежи в PostgreSQL. Платеж заказа ParcelOrderID(id) связан
+// с посылкой id, а через нее - с ее доставкой. Переходы между статусами выполняются одним
+// запросом с условием на текущий статус, поэтому одновременные запросы не отменяют
+// завершенный платеж и не списывают платеж дважды
+type PgPaymentStore struct {
+	db          *sql.DB
+	tableName   string
+	parcelTable string
+}
+
+func NewPgPaymentStore(db *sql.DB) *PgPaymentStore {
+	return &PgPaymentStore{
+		db:          db,
+		tableName:   "payments",
+		parcelTable: "parcels",
+	}
+}
+
+func scanPayment(row rowScanner) (*PaymentResponse, error) {
+	var p PaymentResponse
+	var completedAt sql.NullTime
+	var redirect, receipt, errorMessage, providerID sql.NullString
+	err := row.Scan(&p.PaymentID, &p.OrderID, &p.Amount, &p.Currency, &p.Status, &p.Method, &p.CreatedAt,
+		&completedAt, &redirect, &receipt, &errorMessage, &providerID, &p.CaptureRequested,
+		&p.RefundedAmount)
+	if err != nil {
+		return nil, err
+	}
+	if completedAt.Valid {
+		p.CompletedAt = &completedAt.Time
+	}
+	p.RedirectURL = redirect.String
+	p.ReceiptURL = receipt.String
+	p.ErrorMessage = errorMessage.String
+	p.ProviderPaymentID = providerID.String
+	return &p, nil
+}
+
+// CreatePayment сохраняет новый ожидающий платеж. Платеж заказа посылки можно создать
+// только для существующей посылки
+func (s *PgPaymentStore) CreatePayment(request PaymentRequest) (*PaymentResponse, error) {
+	if err := validateRequest(request); err != nil {
+		return nil, err
+	}
+
+	var parcelID sql.NullInt64
+	if id, ok := ParseParcelOrderID(request.OrderID); ok {
+		var exists bool
+		query := fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s WHERE id = $1)`, s.parcelTable)
+		if err := s.db.QueryRow(query, id).Scan(&exists); err != nil {
+			return nil, fmt.Errorf("ошибка при проверке посылки заказа %s: %w", request.OrderID, err)
+		}
+		if !exists {
+			return nil, fmt.Errorf("%w: посылка заказа %s не найдена", ErrInvalidPayment, request.OrderID)
+		}
+		parcelID = sql.NullInt64{Int64: int64(id), Valid: true}
+	}
+
+	paymentID, err := newPaymentID()
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`INSERT INTO %s (id, order_id, parcel_id, amount, currency, status, method,
+		description, customer_name, customer_email, customer_phone, created_at, redirect_url)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING %s`, s.tableName, paymentColumns)
+	payment, err := scanPayment(s.db.QueryRow(query,
+		paymentID, request.OrderID, parcelID, request.Amount, request.Currency, StatusPending, request.Method,
+		request.Description, request.CustomerInfo.Name, request.CustomerInfo.Email, request.CustomerInfo.Phone,
+		time.Now(), redirectURL(paymentID)))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при создании платежа: %w", err)
+	}
+	return payment, nil
+}
+
+func (s *PgPaymentStore) GetPayment(paymentID string) (*PaymentResponse, error) {
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE id = $1`, paymentColumns, s.tableName)
+	payment, err := scanPayment(s.db.QueryRow(query, paymentID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: ID %s", ErrPaymentNotFound, paymentID)
+		}
+		return nil, fmt.Errorf("ошибка при получении платежа: %w", err)
+	}
+	return payment, nil
+}
+
+// StoredPayment возвращает сохраненный платеж; без провайдера совпадает с GetPayment
+func (s *PgPaymentStore) StoredPayment(paymentID string) (*PaymentResponse, error) {
+	return s.GetPayment(paymentID)
+}
+
+// CancelPayment отменяет ожидающий платеж
+func (s *PgPaymentStore) CancelPayment(paymentID string) (*PaymentResponse, error) {
+	query := fmt.Sprintf(`UPDATE %s SET status = $1, error_message = $2
+		WHERE id = $3 AND status = $4 RETURNING %s`, s.tableName, paymentColumns)
+	payment, err := scanPayment(s.db.QueryRow(query, StatusFailed, cancelMessage, paymentID, StatusPending))
+	if err == sql.ErrNoRows {
+		// Платеж не найден или уже не ожидает оплаты
+		current, err := s.GetPayment(paymentID)
+		if err != nil {
+			return nil, err
+		}
+		return nil, checkCancel(current)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при отмене платежа: %w", err)
+	}
+	return payment, nil
+}
+
+// RefundPayment возвращает завершенный платеж полностью или частично. Сумма возвратов
+// увеличивается одним запросом с проверкой остатка, поэтому одновременные возвраты
+// не превышают сумму платежа
+func (s *PgPaymentStore) RefundPayment(paymentID string, amount float64) (*PaymentResponse, error) {
+	if amount < 0 {
+		amount = 0
+	}
+	query := fmt.Sprintf(`UPDATE %s SET status = $1, completed_at = $2,
+		refunded_amount = CASE WHEN $5::NUMERIC > 0 THEN refunded_amount + $5::NUMERIC ELSE amount END
+		WHERE id = $3 AND status IN ($4, $1) AND amount - refunded_amount >= 0.005
+		AND amount - refunded_amount + 0.005 >= $5::NUMERIC RETURNING %s`, s.tableName, paymentColumns)
+	payment, err := scanPayment(s.db.QueryRow(query, StatusRefunded, time.Now(), paymentID, StatusCompleted, amount))
+	if err == sql.ErrNoRows {
+		// Платеж не найден, не завершен или сумма возврата больше остатка платежа
+		current, err := s.GetPayment(paymentID)
+		if err != nil {
+			return nil, err
+		}
+		_, err = refundAmount(current, amount)
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при возврате платежа: %w", err)
+	}
+	return payment, nil
+}
+
+// SettleOrder завершает ожидающие платежи заказа и возвращает их. Платежи в других статусах
+// не меняются, поэтому повторный вызов ничего не списывает
+func (s *PgPaymentStore) SettleOrder(orderID string) ([]*PaymentResponse, error) {
+	return s.settle(`order_id = $3`, orderID)
+}
+
+// UpdatePayment сохраняет статус платежа и его состояние у провайдера, если статус
+// не изменился с from
+func (s *PgPaymentStore) UpdatePayment(payment *PaymentResponse, from PaymentStatus) error {
+	var completedAt sql.NullTime
+	if payment.CompletedAt != nil {
+		completedAt = sql.NullTime{Time: *payment.CompletedAt, Valid: true}
+	}
+	query := fmt.Sprintf(`UPDATE %s SET status = $1, completed_at = $2, redirect_url = $3, receipt_url = $4,
+		error_message = $5, provider_payment_id = $6, capture_requested = $7, refunded_amount = $8
+		WHERE id = $9 AND status = $10`, s.tableName)
+	result, err := s.db.Exec(query, payment.Status, completedAt, nullString(payment.RedirectURL),
+		nullString(payment.ReceiptURL), nullString(payment.ErrorMessage), nullString(payment.ProviderPaymentID),
+		payment.CaptureRequested, payment.RefundedAmount, payment.PaymentID, from)
+	if err != nil {
+		return fmt.Errorf("ошибка при обновлении платежа: %w", err)
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		// Платеж не найден или его статус уже изменен другим запросом
+		current, err := s.GetPayment(payment.PaymentID)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: статус платежа %s изменился на %s", ErrInvalidPayment, payment.PaymentID, current.Status)
+	}
+	return nil
+}
+
+// PendingPayments возвращает ожидающие платежи заказа в порядке создания
+func (s *PgPaymentStore) PendingPayments(orderID string) ([]*PaymentResponse, error) {
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE order_id = $1 AND status = $2 ORDER BY created_at`,
+		paymentColumns, s.tableName)
+	rows, err := s.db.Query(query, orderID, StatusPending)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении платежей заказа %s: %w", orderID, err)
+	}
+	defer rows.Close()
+	return scanPayments(rows)
+}
+
+// CaptureRequestedPayments возвращает ожидающие платежи, отмеченные к списанию, в порядке создания
+func (s *PgPaymentStore) CaptureRequestedPayments() ([]*PaymentResponse, error) {
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE status = $1 AND capture_requested ORDER BY created_at`,
+		paymentColumns, s.tableName)
+	rows, err := s.db.Query(query, StatusPending)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении платежей к списанию: %w", err)
+	}
+	defer rows.Close()
+	return scanPayments(rows)
+}
+
+// Charge создает и сразу завершает платеж (устаревший метод, сохранен для обратной совместимости)
+func (s *PgPaymentStore) Charge(amount float64, currency string) (string, error) {
+	response, err := s.CreatePayment(chargeRequest(amount, currency))
+	if err != nil {
+		return "", err
+	}
+	if _, err := s.settle(`id = $3`, response.PaymentID); err != nil {
+		return "", err
+	}
+	return chargeResult(amount, currency, response.PaymentID), nil
+}
+
+// settle завершает ожидающие платежи, выбранные условием where с параметром $3
+func (s *PgPaymentStore) settle(where string, arg interface{}) ([]*PaymentResponse, error) {
+	query := fmt.Sprintf(`UPDATE %s SET status = $1, completed_at = $2, receipt_url = $4 || id
+		WHERE %s AND status = $5 RETURNING %s`, s.tableName, where, paymentColumns)
+	rows, err := s.db.Query(query, StatusCompleted, time.Now(), arg, receiptURL(""), StatusPending)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при завершении платежей: %w", err)
+	}
+	defer rows.Close()
+	return scanPayments(rows)
+}
+
+func scanPayments(rows *sql.Rows) ([]*PaymentResponse, error) {
+	var payments []*PaymentResponse
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при сканировании платежа: %w", err)
+		}
+		payments = append(payments, payment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке результатов: %w", err)
+	}
+	return payments, nil
+}
+
+// nullString сохраняет пустую строку как NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,183 @@
+package payment
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var paymentRowColumns = []string{"id", "order_id", "amount", "currency", "status", "method", "created_at",
+	"completed_at", "redirect_url", "receipt_url", "error_message", "provider_payment_id", "capture_requested",
+	"refunded_amount"}
+
+func newPaymentStore(t *testing.T) (*PgPaymentStore, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return NewPgPaymentStore(db), mock
+}
+
+// paymentRow возвращает строку платежа в статусе status
+func paymentRow(id string, status PaymentStatus) *sqlmock.Rows {
+	return refundedPaymentRow(id, status, 0)
+}
+
+// refundedPaymentRow возвращает строку платежа, по которому возвращено refunded
+func refundedPaymentRow(id string, status PaymentStatus, refunded float64) *sqlmock.Rows {
+	var completedAt interface{}
+	if status == StatusCompleted || status == StatusRefunded {
+		completedAt = time.Now()
+	}
+	return sqlmock.NewRows(paymentRowColumns).AddRow(id, ParcelOrderID(7), 100.0, "RUB", string(status), "card",
+		time.Now(), completedAt, "https://example.com/payments/"+id, nil, nil, nil, false, refunded)
+}
+
+func TestPgPaymentStore_CreatePayment(t *testing.T) {
+	parcelExists := regexp.QuoteMeta("SELECT EXISTS (SELECT 1 FROM parcels WHERE id = $1)")
+	insert := regexp.QuoteMeta("INSERT INTO payments")
+	request := PaymentRequest{OrderID: ParcelOrderID(7), Amount: 100, Currency: "RUB", Method: MethodCard}
+
+	t.Run("Оплата доставки посылки", func(t *testing.T) {
+		store, mock := newPaymentStore(t)
+		mock.ExpectQuery(parcelExists).WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectQuery(insert).
+			WithArgs(sqlmock.AnyArg(), "parcel_7", int64(7), 100.0, "RUB", StatusPending, MethodCard,
+				"", "", "", "", sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnRows(paymentRow("pay_1", StatusPending))
+
+		payment, err := store.CreatePayment(request)
+		assert.NoError(t, err)
+		assert.Equal(t, StatusPending, payment.Status)
+		assert.Nil(t, payment.CompletedAt)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Посылка не найдена", func(t *testing.T) {
+		store, mock := newPaymentStore(t)
+		mock.ExpectQuery(parcelExists).WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+		_, err := store.CreatePayment(request)
+		assert.ErrorIs(t, err, ErrInvalidPayment)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Некорректная сумма", func(t *testing.T) {
+		store, mock := newPaymentStore(t)
+		_, err := store.CreatePayment(PaymentRequest{OrderID: "order_1", Currency: "RUB"})
+		assert.ErrorIs(t, err, ErrInvalidPayment)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPgPaymentStore_CancelPayment(t *testing.T) {
+	cancel := regexp.QuoteMeta("UPDATE payments SET status = $1, error_message = $2")
+	get := regexp.QuoteMeta("FROM payments WHERE id = $1")
+
+	t.Run("Успешно", func(t *testing.T) {
+		store, mock := newPaymentStore(t)
+		mock.ExpectQuery(cancel).WithArgs(StatusFailed, cancelMessage, "pay_1", StatusPending).
+			WillReturnRows(paymentRow("pay_1", StatusFailed))
+
+		payment, err := store.CancelPayment("pay_1")
+		assert.NoError(t, err)
+		assert.Equal(t, StatusFailed, payment.Status)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Платеж уже завершен", func(t *testing.T) {
+		store, mock := newPaymentStore(t)
+		mock.ExpectQuery(cancel).WillReturnRows(sqlmock.NewRows(paymentRowColumns))
+		mock.ExpectQuery(get).WithArgs("pay_1").WillReturnRows(paymentRow("pay_1", StatusCompleted))
+
+		_, err := store.CancelPayment("pay_1")
+		assert.ErrorIs(t, err, ErrInvalidPayment)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Платеж не найден", func(t *testing.T) {
+		store, mock := newPaymentStore(t)
+		mock.ExpectQuery(cancel).WillReturnRows(sqlmock.NewRows(paymentRowColumns))
+		mock.ExpectQuery(get).WithArgs("pay_1").WillReturnRows(sqlmock.NewRows(paymentRowColumns))
+
+		_, err := store.CancelPayment("pay_1")
+		assert.ErrorIs(t, err, ErrPaymentNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPgPaymentStore_RefundPayment(t *testing.T) {
+	refund := regexp.QuoteMeta("UPDATE payments SET status = $1, completed_at = $2")
+	get := regexp.QuoteMeta("FROM payments WHERE id = $1")
+
+	store, mock := newPaymentStore(t)
+	mock.ExpectQuery(refund).WithArgs(StatusRefunded, sqlmock.AnyArg(), "pay_1", StatusCompleted, 50.0).
+		WillReturnRows(refundedPaymentRow("pay_1", StatusRefunded, 50))
+	payment, err := store.RefundPayment("pay_1", 50)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusRefunded, payment.Status)
+	assert.Equal(t, 50.0, payment.RefundedAmount)
+
+	// Сумма возврата больше остатка платежа
+	mock.ExpectQuery(refund).WillReturnRows(sqlmock.NewRows(paymentRowColumns))
+	mock.ExpectQuery(get).WithArgs("pay_1").WillReturnRows(refundedPaymentRow("pay_1", StatusRefunded, 50))
+	_, err = store.RefundPayment("pay_1", 60)
+	assert.ErrorIs(t, err, ErrInvalidPayment)
+
+	// Без суммы возвращается остаток
+	mock.ExpectQuery(refund).WithArgs(StatusRefunded, sqlmock.AnyArg(), "pay_1", StatusCompleted, 0.0).
+		WillReturnRows(refundedPaymentRow("pay_1", StatusRefunded, 100))
+	payment, err = store.RefundPayment("pay_1", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 100.0, payment.RefundedAmount)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPgPaymentStore_SettleOrder(t *testing.T) {
+	settle := regexp.QuoteMeta("UPDATE payments SET status = $1, completed_at = $2, receipt_url = $4 || id WHERE order_id = $3 AND status = $5")
+
+	store, mock := newPaymentStore(t)
+	mock.ExpectQuery(settle).
+		WithArgs(StatusCompleted, sqlmock.AnyArg(), "parcel_7", "https://example.com/receipts/", StatusPending).
+		WillReturnRows(paymentRow("pay_1", StatusCompleted))
+	settled, err := store.SettleOrder(ParcelOrderID(7))
+	assert.NoError(t, err)
+	if assert.Len(t, settled, 1) {
+		assert.Equal(t, StatusCompleted, settled[0].Status)
+		assert.NotNil(t, settled[0].CompletedAt)
+	}
+
+	// Повторное завершение ничего не списывает
+	mock.ExpectQuery(settle).WillReturnRows(sqlmock.NewRows(paymentRowColumns))
+	settled, err = store.SettleOrder(ParcelOrderID(7))
+	assert.NoError(t, err)
+	assert.Empty(t, settled)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPgPaymentStore_CaptureRequestedPayments(t *testing.T) {
+	store, mock := newPaymentStore(t)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM payments WHERE status = $1 AND capture_requested ORDER BY created_at")).
+		WithArgs(StatusPending).
+		WillReturnRows(paymentRow("pay_1", StatusPending))
+
+	pending, err := store.CaptureRequestedPayments()
+	assert.NoError(t, err)
+	if assert.Len(t, pending, 1) {
+		assert.Equal(t, "pay_1", pending[0].PaymentID)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestParseParcelOrderID(t *testing.T) {
+	for orderID, want := range map[string]int{"parcel_7": 7, "parcel_0": 0, "parcel_x": 0, "order_7": 0, "7": 0} {
+		id, ok := ParseParcelOrderID(orderID)
+		if id != want || ok != (want > 0) {
+			t.Errorf("ParseParcelOrderID(%q) = %d, %v", orderID, id, ok)
+		}
+	}
+}
@@ -0,0 +1,436 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// DefaultProviderTimeout ограничивает ожидание ответа платежного провайдера
+const DefaultProviderTimeout = 10 * time.Second
+
+// DefaultReconcileInterval - период сверки с провайдером платежей, отмеченных к списанию
+const DefaultReconcileInterval = time.Minute
+
+// ProviderStatus - статус платежа у платежного провайдера
+type ProviderStatus string
+
+const (
+	ProviderRequiresAction ProviderStatus = "requires_action" // Ожидает подтверждения 3-D Secure
+	ProviderAuthorized     ProviderStatus = "authorized"      // Средства заблокированы, но не списаны
+	ProviderCaptured       ProviderStatus = "captured"
+	ProviderVoided         ProviderStatus = "voided"
+	ProviderRefunded       ProviderStatus = "refunded"
+	ProviderFailed         ProviderStatus = "failed"
+)
+
+// PaymentStatus возвращает статус платежа сервиса, соответствующий статусу у провайдера
+func (s ProviderStatus) PaymentStatus() PaymentStatus {
+	switch s {
+	case ProviderCaptured:
+		return StatusCompleted
+	case ProviderRefunded:
+		return StatusRefunded
+	case ProviderVoided, ProviderFailed:
+		return StatusFailed
+	}
+	return StatusPending
+}
+
+// ProviderRequest - запрос на блокировку средств у провайдера
+type ProviderRequest struct {
+	Reference   string // ID платежа сервиса
+	Amount      float64
+	Currency    string
+	Method      PaymentMethod
+	Description string
+}
+
+// ProviderPayment - состояние платежа у провайдера
+type ProviderPayment struct {
+	ID             string
+	Status         ProviderStatus
+	RedirectURL    string  // Страница подтверждения 3-D Secure, пока платеж ее ожидает
+	Error          string  // Причина отказа
+	RefundedAmount float64 // Сумма возвратов нарастающим итогом
+}
+
+// Provider - платежный провайдер. Платеж сначала блокирует средства (Authorize), затем они
+// списываются (Capture) или блокировка снимается (Void); списанные средства можно вернуть (Refund).
+// Status опрашивает текущее состояние платежа, например после подтверждения 3-D Secure.
+// Ошибку ErrInvalidPayment провайдер возвращает, если операция недопустима в статусе платежа
+type Provider interface {
+	Authorize(ctx context.Context, request ProviderRequest) (ProviderPayment, error)
+	Capture(ctx context.Context, providerID string) (ProviderPayment, error)
+	Void(ctx context.Context, providerID string) (ProviderPayment, error)
+	Refund(ctx context.Context, providerID string, amount float64) (ProviderPayment, error)
+	Status(ctx context.Context, providerID string) (ProviderPayment, error)
+}
+
+// PaymentStore хранит платежи, которые проводятся через провайдера
+type PaymentStore interface {
+	// CreatePayment сохраняет новый ожидающий платеж
+	CreatePayment(request PaymentRequest) (*PaymentResponse, error)
+	GetPayment(paymentID string) (*PaymentResponse, error)
+	// UpdatePayment сохраняет платеж, если его статус не изменился с from
+	UpdatePayment(payment *PaymentResponse, from PaymentStatus) error
+	PendingPayments(orderID string) ([]*PaymentResponse, error)
+	// CaptureRequestedPayments возвращает ожидающие платежи, отмеченные к списанию
+	CaptureRequestedPayments() ([]*PaymentResponse, error)
+}
+
+// Processor - платежный сервис, который завершает платежи заказа после доставки
+type Processor interface {
+	PaymentService
+	OrderSettler
+}
+
+// Убедимся, что хранилища и ProviderPaymentService реализуют нужные интерфейсы
+var (
+	_ PaymentStore = (*PgPaymentStore)(nil)
+	_ PaymentStore = (*MockPaymentService)(nil)
+	_ Processor    = (*ProviderPaymentService)(nil)
+)
+
+// ProviderPaymentService проводит платежи через платежного провайдера и хранит их состояние
+// в PaymentStore. Созданный платеж блокирует средства у провайдера, после доставки заказа
+// они списываются, при отмене - освобождаются. Ожидающий платеж при чтении сверяется
+// с провайдером: так становится известен результат подтверждения 3-D Secure. Если заказ
+// доставлен до подтверждения, средства списываются, как только чтение платежа или периодическая
+// сверка (StartReconciler) его обнаружит
+type ProviderPaymentService struct {
+	store    PaymentStore
+	provider Provider
+	timeout  time.Duration
+}
+
+func NewProviderPaymentService(store PaymentStore, provider Provider) *ProviderPaymentService {
+	return &ProviderPaymentService{
+		store:    store,
+		provider: provider,
+		timeout:  DefaultProviderTimeout,
+	}
+}
+
+// WithTimeout задает, сколько ждать ответа провайдера
+func (s *ProviderPaymentService) WithTimeout(timeout time.Duration) *ProviderPaymentService {
+	if timeout > 0 {
+		s.timeout = timeout
+	}
+	return s
+}
+
+// CreatePayment сохраняет платеж и блокирует средства у провайдера. Если провайдер недоступен,
+// платеж отмечается неудавшимся, чтобы он не ожидал оплаты, которой не будет
+func (s *ProviderPaymentService) CreatePayment(request PaymentRequest) (*PaymentResponse, error) {
+	payment, err := s.store.CreatePayment(request)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	result, err := s.provider.Authorize(ctx, ProviderRequest{
+		Reference:   payment.PaymentID,
+		Amount:      payment.Amount,
+		Currency:    payment.Currency,
+		Method:      payment.Method,
+		Description: request.Description,
+	})
+	if err != nil {
+		payment.Status = StatusFailed
+		payment.RedirectURL = ""
+		payment.ErrorMessage = err.Error()
+		if err := s.store.UpdatePayment(payment, StatusPending); err != nil {
+			log.Printf("[PAYMENT] Ошибка при сохранении неудавшегося платежа %s: %v", payment.PaymentID, err)
+		}
+		return nil, fmt.Errorf("ошибка при создании платежа у провайдера: %w", err)
+	}
+
+	applyProviderPayment(payment, result)
+	if err := s.store.UpdatePayment(payment, StatusPending); err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+// GetPayment возвращает платеж. Ожидающий платеж сверяется с провайдером; если провайдер
+// недоступен, возвращается сохраненное состояние
+func (s *ProviderPaymentService) GetPayment(paymentID string) (*PaymentResponse, error) {
+	payment, err := s.store.GetPayment(paymentID)
+	if err != nil || payment.Status != StatusPending || payment.ProviderPaymentID == "" {
+		return payment, err
+	}
+
+	synced, err := s.sync(payment)
+	if err != nil {
+		log.Printf("[PAYMENT] Ошибка при сверке платежа %s с провайдером: %v", paymentID, err)
+	}
+	return synced, nil
+}
+
+// StoredPayment возвращает сохраненное состояние платежа, не обращаясь к провайдеру. Используется,
+// когда платеж нужно прочитать до проверки доступа к нему: чтение не сверяет и не списывает платеж
+func (s *ProviderPaymentService) StoredPayment(paymentID string) (*PaymentResponse, error) {
+	return s.store.GetPayment(paymentID)
+}
+
+// sync сверяет ожидающий платеж с провайдером и сохраняет его новое состояние. Если заказ уже
+// доставлен, подтвержденный платеж сразу списывается. При ошибке возвращается сохраненное состояние платежа
+func (s *ProviderPaymentService) sync(payment *PaymentResponse) (*PaymentResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	result, err := s.provider.Status(ctx, payment.ProviderPaymentID)
+	if err != nil {
+		return payment, fmt.Errorf("ошибка при получении статуса платежа у провайдера: %w", err)
+	}
+
+	updated := copyPayment(payment)
+	applyProviderPayment(updated, result)
+
+	if updated.CaptureRequested && result.Status == ProviderAuthorized {
+		if err := s.capture(updated); err != nil {
+			if current, getErr := s.store.GetPayment(payment.PaymentID); getErr == nil {
+				payment = current
+			}
+			return payment, fmt.Errorf("ошибка при списании подтвержденного платежа: %w", err)
+		}
+		return updated, nil
+	}
+
+	if updated.Status == payment.Status && updated.RedirectURL == payment.RedirectURL &&
+		updated.ErrorMessage == payment.ErrorMessage {
+		return payment, nil
+	}
+	if err := s.store.UpdatePayment(updated, StatusPending); err != nil {
+		// Платеж изменен другим запросом - возвращаем его актуальное состояние
+		current, err := s.store.GetPayment(payment.PaymentID)
+		if err != nil {
+			return payment, err
+		}
+		return current, nil
+	}
+	return updated, nil
+}
+
+// ReconcileCaptures сверяет с провайдером ожидающие платежи, отмеченные к списанию, и списывает
+// подтвержденные. Возвращает списанные платежи; ошибки отдельных платежей возвращаются
+// после обработки остальных
+func (s *ProviderPaymentService) ReconcileCaptures() ([]*PaymentResponse, error) {
+	pending, err := s.store.CaptureRequestedPayments()
+	if err != nil {
+		return nil, err
+	}
+
+	var captured []*PaymentResponse
+	var errs []error
+	for _, payment := range pending {
+		if payment.ProviderPaymentID == "" {
+			continue
+		}
+		synced, err := s.sync(payment)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("платеж %s: %w", payment.PaymentID, err))
+			continue
+		}
+		if synced.Status == StatusCompleted {
+			captured = append(captured, synced)
+		}
+	}
+	return captured, errors.Join(errs...)
+}
+
+// StartReconciler запускает ReconcileCaptures каждые interval до отмены ctx, чтобы платеж,
+// подтвержденный после доставки заказа, был списан, даже если его никто не запрашивает
+func (s *ProviderPaymentService) StartReconciler(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultReconcileInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+
+			captured, err := s.ReconcileCaptures()
+			if err != nil {
+				log.Printf("[PAYMENT] Ошибка сверки платежей, отмеченных к списанию: %v", err)
+			}
+			for _, p := range captured {
+				log.Printf("[PAYMENT] Платеж %s заказа %s списан после подтверждения 3-D Secure", p.PaymentID, p.OrderID)
+			}
+		}
+	}()
+	log.Printf("[PAYMENT] Сверка платежей, отмеченных к списанию, запущена: период %s", interval)
+}
+
+// CancelPayment снимает блокировку средств у провайдера и отменяет ожидающий платеж
+func (s *ProviderPaymentService) CancelPayment(paymentID string) (*PaymentResponse, error) {
+	payment, err := s.store.GetPayment(paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCancel(payment); err != nil {
+		return nil, err
+	}
+
+	if payment.ProviderPaymentID != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+		defer cancel()
+		result, err := s.provider.Void(ctx, payment.ProviderPaymentID)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при отмене платежа у провайдера: %w", err)
+		}
+		applyProviderPayment(payment, result)
+	}
+	payment.Status = StatusFailed
+	if payment.ErrorMessage == "" {
+		payment.ErrorMessage = cancelMessage
+	}
+
+	if err := s.store.UpdatePayment(payment, StatusPending); err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+// RefundPayment возвращает списанные средства через провайдера полностью или частично
+func (s *ProviderPaymentService) RefundPayment(paymentID string, amount float64) (*PaymentResponse, error) {
+	payment, err := s.store.GetPayment(paymentID)
+	if err != nil {
+		return nil, err
+	}
+	amount, err = refundAmount(payment, amount)
+	if err != nil {
+		return nil, err
+	}
+	if payment.ProviderPaymentID == "" {
+		return nil, fmt.Errorf("%w: платеж %s проведен без провайдера", ErrInvalidPayment, paymentID)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	result, err := s.provider.Refund(ctx, payment.ProviderPaymentID, amount)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при возврате платежа у провайдера: %w", err)
+	}
+	from := payment.Status
+	applyProviderPayment(payment, result)
+
+	if err := s.store.UpdatePayment(payment, from); err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+// SettleOrder списывает средства ожидающих платежей заказа и возвращает завершенные платежи.
+// Платеж, еще не подтвержденный 3-D Secure, отмечается к списанию и списывается после подтверждения.
+// Платеж, который не удалось списать, остается ожидающим, и ошибка возвращается после обработки
+// остальных платежей заказа
+func (s *ProviderPaymentService) SettleOrder(orderID string) ([]*PaymentResponse, error) {
+	pending, err := s.store.PendingPayments(orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	var settled []*PaymentResponse
+	var errs []error
+	for _, payment := range pending {
+		captured, err := s.settle(payment)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("платеж %s: %w", payment.PaymentID, err))
+			continue
+		}
+		if captured {
+			settled = append(settled, payment)
+		}
+	}
+	return settled, errors.Join(errs...)
+}
+
+// settle списывает ожидающий платеж доставленного заказа. Платеж, который при последней сверке
+// ожидал подтверждения 3-D Secure, сначала сверяется с провайдером: если подтверждения все еще нет,
+// платеж отмечается к списанию, а если оплата отклонена - сохраняется неудавшимся
+func (s *ProviderPaymentService) settle(payment *PaymentResponse) (bool, error) {
+	if payment.RedirectURL != "" && payment.ProviderPaymentID != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+		result, err := s.provider.Status(ctx, payment.ProviderPaymentID)
+		cancel()
+		if err != nil {
+			return false, fmt.Errorf("ошибка при получении статуса платежа у провайдера: %w", err)
+		}
+
+		switch result.Status {
+		case ProviderRequiresAction:
+			payment.CaptureRequested = true
+			return false, s.store.UpdatePayment(payment, StatusPending)
+		case ProviderAuthorized:
+		default:
+			applyProviderPayment(payment, result)
+			return false, s.store.UpdatePayment(payment, StatusPending)
+		}
+	}
+
+	if err := s.capture(payment); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Charge создает платеж и сразу списывает средства (устаревший метод, сохранен для обратной совместимости)
+func (s *ProviderPaymentService) Charge(amount float64, currency string) (string, error) {
+	payment, err := s.CreatePayment(chargeRequest(amount, currency))
+	if err != nil {
+		return "", err
+	}
+	if err := s.capture(payment); err != nil {
+		return "", err
+	}
+	return chargeResult(amount, currency, payment.PaymentID), nil
+}
+
+// capture списывает средства ожидающего платежа
+func (s *ProviderPaymentService) capture(payment *PaymentResponse) error {
+	if payment.ProviderPaymentID == "" {
+		return fmt.Errorf("%w: платеж не создан у провайдера", ErrInvalidPayment)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	result, err := s.provider.Capture(ctx, payment.ProviderPaymentID)
+	if err != nil {
+		return fmt.Errorf("ошибка при списании платежа у провайдера: %w", err)
+	}
+	applyProviderPayment(payment, result)
+	if payment.Status != StatusCompleted {
+		return fmt.Errorf("провайдер вернул статус %s после списания", result.Status)
+	}
+	return s.store.UpdatePayment(payment, StatusPending)
+}
+
+// applyProviderPayment переносит в платеж его состояние у провайдера
+func applyProviderPayment(payment *PaymentResponse, result ProviderPayment) {
+	status := result.Status.PaymentStatus()
+	switch {
+	case status == StatusCompleted && payment.Status != StatusCompleted:
+		complete(payment)
+	case status == StatusRefunded && payment.Status != StatusRefunded:
+		now := time.Now()
+		payment.CompletedAt = &now
+	}
+
+	payment.Status = status
+	payment.ProviderPaymentID = result.ID
+	payment.RedirectURL = result.RedirectURL
+	payment.ErrorMessage = result.Error
+	if result.RefundedAmount > 0 {
+		payment.RefundedAmount = result.RefundedAmount
+	}
+}
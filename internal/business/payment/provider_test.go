@@ -0,0 +1,263 @@
+package payment
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"delivery/internal/paysim"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSimulatedService возвращает платежный сервис, который проводит платежи через симулятор шлюза
+func newSimulatedService(t *testing.T, scenario paysim.Scenario) (*ProviderPaymentService, *paysim.Simulator, *httptest.Server) {
+	sim := paysim.NewSimulator()
+	require.NoError(t, sim.SetScenario(scenario, 0))
+	server := httptest.NewServer(sim.Handler())
+	t.Cleanup(server.Close)
+	return NewProviderPaymentService(NewMockPaymentService(), NewHTTPProvider(server.URL)), sim, server
+}
+
+func newProviderRequest() PaymentRequest {
+	return PaymentRequest{OrderID: ParcelOrderID(7), Amount: 100, Currency: "RUB", Method: MethodCard}
+}
+
+func TestProviderPaymentService_PendingCompletedRefunded(t *testing.T) {
+	service, _, _ := newSimulatedService(t, paysim.ScenarioSuccess)
+
+	payment, err := service.CreatePayment(newProviderRequest())
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, payment.Status)
+	assert.NotEmpty(t, payment.ProviderPaymentID)
+
+	// До доставки платеж нельзя вернуть
+	_, err = service.RefundPayment(payment.PaymentID, 100)
+	assert.ErrorIs(t, err, ErrInvalidPayment)
+
+	settled, err := service.SettleOrder(ParcelOrderID(7))
+	require.NoError(t, err)
+	if assert.Len(t, settled, 1) {
+		assert.Equal(t, StatusCompleted, settled[0].Status)
+		assert.NotNil(t, settled[0].CompletedAt)
+		assert.NotEmpty(t, settled[0].ReceiptURL)
+	}
+
+	// Повторное завершение заказа ничего не списывает
+	settled, err = service.SettleOrder(ParcelOrderID(7))
+	assert.NoError(t, err)
+	assert.Empty(t, settled)
+
+	// Частичный возврат, затем возврат остатка
+	refunded, err := service.RefundPayment(payment.PaymentID, 40)
+	require.NoError(t, err)
+	assert.Equal(t, StatusRefunded, refunded.Status)
+	assert.Equal(t, 40.0, refunded.RefundedAmount)
+
+	_, err = service.RefundPayment(payment.PaymentID, 70)
+	assert.ErrorIs(t, err, ErrInvalidPayment)
+
+	refunded, err = service.RefundPayment(payment.PaymentID, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 100.0, refunded.RefundedAmount)
+
+	stored, err := service.GetPayment(payment.PaymentID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusRefunded, stored.Status)
+	assert.Equal(t, 100.0, stored.RefundedAmount)
+
+	// Возвращенный полностью платеж больше не возвращается
+	_, err = service.RefundPayment(payment.PaymentID, 0)
+	assert.ErrorIs(t, err, ErrInvalidPayment)
+}
+
+func TestProviderPaymentService_ThreeDS(t *testing.T) {
+	service, _, server := newSimulatedService(t, paysim.ScenarioThreeDS)
+
+	payment, err := service.CreatePayment(newProviderRequest())
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, payment.Status)
+	require.NotEmpty(t, payment.RedirectURL)
+
+	// Покупатель подтверждает платеж на странице 3-D Secure
+	resp, err := server.Client().PostForm(payment.RedirectURL, url.Values{"result": {"success"}})
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	stored, err := service.GetPayment(payment.PaymentID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, stored.Status)
+	assert.Empty(t, stored.RedirectURL)
+
+	settled, err := service.SettleOrder(ParcelOrderID(7))
+	require.NoError(t, err)
+	assert.Len(t, settled, 1)
+}
+
+func TestProviderPaymentService_ThreeDSAfterDelivery(t *testing.T) {
+	service, _, server := newSimulatedService(t, paysim.ScenarioThreeDS)
+
+	payment, err := service.CreatePayment(newProviderRequest())
+	require.NoError(t, err)
+
+	// Заказ доставлен до подтверждения: платеж не списывается, но отмечается к списанию
+	settled, err := service.SettleOrder(ParcelOrderID(7))
+	require.NoError(t, err)
+	assert.Empty(t, settled)
+	stored, err := service.GetPayment(payment.PaymentID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, stored.Status)
+	assert.True(t, stored.CaptureRequested)
+
+	// После подтверждения 3-D Secure средства списываются при сверке платежа
+	resp, err := server.Client().PostForm(payment.RedirectURL, url.Values{"result": {"success"}})
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	// Чтение сохраненного платежа не обращается к провайдеру и ничего не списывает
+	stored, err = service.StoredPayment(payment.PaymentID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, stored.Status)
+
+	stored, err = service.GetPayment(payment.PaymentID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusCompleted, stored.Status)
+	assert.NotEmpty(t, stored.ReceiptURL)
+
+	settled, err = service.SettleOrder(ParcelOrderID(7))
+	require.NoError(t, err)
+	assert.Empty(t, settled)
+}
+
+func TestProviderPaymentService_ReconcileCaptures(t *testing.T) {
+	store := NewMockPaymentService()
+	sim := paysim.NewSimulator()
+	require.NoError(t, sim.SetScenario(paysim.ScenarioThreeDS, 0))
+	server := httptest.NewServer(sim.Handler())
+	defer server.Close()
+	service := NewProviderPaymentService(store, NewHTTPProvider(server.URL))
+
+	payment, err := service.CreatePayment(newProviderRequest())
+	require.NoError(t, err)
+	_, err = service.SettleOrder(ParcelOrderID(7))
+	require.NoError(t, err)
+
+	// Пока подтверждения нет, сверка ничего не списывает
+	captured, err := service.ReconcileCaptures()
+	require.NoError(t, err)
+	assert.Empty(t, captured)
+
+	resp, err := server.Client().PostForm(payment.RedirectURL, url.Values{"result": {"success"}})
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	// Платеж списывается периодической сверкой, хотя его никто не запрашивает
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	service.StartReconciler(ctx, 10*time.Millisecond)
+	assert.Eventually(t, func() bool {
+		stored, err := store.GetPayment(payment.PaymentID)
+		return err == nil && stored.Status == StatusCompleted
+	}, time.Second, 10*time.Millisecond)
+
+	stored, err := store.GetPayment(payment.PaymentID)
+	require.NoError(t, err)
+	assert.NotEmpty(t, stored.ReceiptURL)
+	pending, err := store.CaptureRequestedPayments()
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestProviderPaymentService_ThreeDSRejected(t *testing.T) {
+	service, _, server := newSimulatedService(t, paysim.ScenarioThreeDS)
+
+	payment, err := service.CreatePayment(newProviderRequest())
+	require.NoError(t, err)
+
+	resp, err := server.Client().PostForm(payment.RedirectURL, url.Values{"result": {"failure"}})
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	stored, err := service.GetPayment(payment.PaymentID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusFailed, stored.Status)
+	assert.NotEmpty(t, stored.ErrorMessage)
+}
+
+func TestProviderPaymentService_Decline(t *testing.T) {
+	service, _, _ := newSimulatedService(t, paysim.ScenarioDecline)
+
+	payment, err := service.CreatePayment(newProviderRequest())
+	require.NoError(t, err)
+	assert.Equal(t, StatusFailed, payment.Status)
+	assert.Equal(t, paysim.DeclineMessage, payment.ErrorMessage)
+
+	settled, err := service.SettleOrder(ParcelOrderID(7))
+	assert.NoError(t, err)
+	assert.Empty(t, settled)
+}
+
+func TestProviderPaymentService_GatewayError(t *testing.T) {
+	store := NewMockPaymentService()
+	sim := paysim.NewSimulator()
+	require.NoError(t, sim.SetScenario(paysim.ScenarioError, 0))
+	server := httptest.NewServer(sim.Handler())
+	defer server.Close()
+	service := NewProviderPaymentService(store, NewHTTPProvider(server.URL))
+
+	_, err := service.CreatePayment(newProviderRequest())
+	assert.Error(t, err)
+
+	// Платеж, который не удалось создать у провайдера, не ожидает оплаты
+	pending, err := store.PendingPayments(ParcelOrderID(7))
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestProviderPaymentService_Timeout(t *testing.T) {
+	service, sim, _ := newSimulatedService(t, paysim.ScenarioSuccess)
+	service.WithTimeout(20 * time.Millisecond)
+	require.NoError(t, sim.SetScenario(paysim.ScenarioSuccess, 200*time.Millisecond))
+
+	_, err := service.CreatePayment(newProviderRequest())
+	assert.Error(t, err)
+}
+
+func TestProviderPaymentService_Cancel(t *testing.T) {
+	service, _, _ := newSimulatedService(t, paysim.ScenarioSuccess)
+
+	payment, err := service.CreatePayment(newProviderRequest())
+	require.NoError(t, err)
+
+	cancelled, err := service.CancelPayment(payment.PaymentID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusFailed, cancelled.Status)
+	assert.Equal(t, cancelMessage, cancelled.ErrorMessage)
+
+	// Снятую блокировку нельзя списать
+	_, err = service.CancelPayment(payment.PaymentID)
+	assert.ErrorIs(t, err, ErrInvalidPayment)
+	settled, err := service.SettleOrder(ParcelOrderID(7))
+	assert.NoError(t, err)
+	assert.Empty(t, settled)
+}
+
+func TestHTTPProvider_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	_, err := NewHTTPProvider(server.URL).Status(t.Context(), "sim_1")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrInvalidPayment)
+
+	sim := httptest.NewServer(paysim.NewSimulator().Handler())
+	defer sim.Close()
+	_, err = NewHTTPProvider(sim.URL).Capture(t.Context(), "sim_404")
+	assert.Error(t, err)
+}
@@ -0,0 +1,57 @@
+package payment
+
+import (
+	"context"
+	"delivery/internal/business/models"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// OrderSettler завершает ожидающие платежи заказа
+type OrderSettler interface {
+	SettleOrder(orderID string) ([]*PaymentResponse, error)
+}
+
+// ParcelOrderID возвращает номер заказа, под которым оплачивается доставка посылки
+func ParcelOrderID(parcelID int) string {
+	return fmt.Sprintf("parcel_%d", parcelID)
+}
+
+// ParseParcelOrderID возвращает посылку заказа, если это заказ доставки посылки
+func ParseParcelOrderID(orderID string) (int, bool) {
+	id, err := strconv.Atoi(strings.TrimPrefix(orderID, "parcel_"))
+	if err != nil || id <= 0 || !strings.HasPrefix(orderID, "parcel_") {
+		return 0, false
+	}
+	return id, true
+}
+
+// Settlement списывает оплату доставки, когда посылка доставлена
+type Settlement struct {
+	payments OrderSettler
+}
+
+func NewSettlement(payments OrderSettler) *Settlement {
+	return &Settlement{payments: payments}
+}
+
+// OnDeliveryCompleted завершает ожидающие платежи заказа доставленной посылки.
+// Событие может прийти повторно - завершенные платежи не списываются второй раз
+func (s *Settlement) OnDeliveryCompleted(ctx context.Context, event models.DeliveryCompletedEvent) error {
+	orderID := ParcelOrderID(event.ParcelID)
+	settled, err := s.payments.SettleOrder(orderID)
+	if err != nil {
+		return fmt.Errorf("ошибка при завершении оплаты заказа %s: %w", orderID, err)
+	}
+
+	if len(settled) == 0 {
+		log.Printf("[PAYMENT] Доставка %d завершена, ожидающих платежей заказа %s нет", event.DeliveryID, orderID)
+		return nil
+	}
+	for _, p := range settled {
+		log.Printf("[PAYMENT] Платеж %s заказа %s завершен после доставки %d", p.PaymentID, orderID, event.DeliveryID)
+	}
+	return nil
+}
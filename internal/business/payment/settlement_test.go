@@ -0,0 +1,47 @@
+package payment
+
+import (
+	"context"
+	"delivery/internal/business/models"
+	"testing"
+)
+
+func TestSettlement_OnDeliveryCompleted(t *testing.T) {
+	service := NewMockPaymentService()
+	pending, _ := service.CreatePayment(PaymentRequest{OrderID: ParcelOrderID(7), Amount: 100, Currency: "RUB", Method: MethodCard})
+	canceled, _ := service.CreatePayment(PaymentRequest{OrderID: ParcelOrderID(7), Amount: 50, Currency: "RUB", Method: MethodCard})
+	service.CancelPayment(canceled.PaymentID)
+	other, _ := service.CreatePayment(PaymentRequest{OrderID: ParcelOrderID(8), Amount: 100, Currency: "RUB", Method: MethodCard})
+
+	settlement := NewSettlement(service)
+	event := models.DeliveryCompletedEvent{DeliveryID: 3, ParcelID: 7, CourierID: 1}
+	if err := settlement.OnDeliveryCompleted(context.Background(), event); err != nil {
+		t.Fatalf("OnDeliveryCompleted() error = %v", err)
+	}
+
+	get := func(id string) *PaymentResponse {
+		p, err := service.GetPayment(id)
+		if err != nil {
+			t.Fatalf("GetPayment() error = %v", err)
+		}
+		return p
+	}
+	settled := get(pending.PaymentID)
+	if settled.Status != StatusCompleted || settled.CompletedAt == nil {
+		t.Errorf("платеж заказа = %s, want %s", settled.Status, StatusCompleted)
+	}
+	if got := get(canceled.PaymentID).Status; got != StatusFailed {
+		t.Errorf("отмененный платеж = %s, want %s", got, StatusFailed)
+	}
+	if got := get(other.PaymentID).Status; got != StatusPending {
+		t.Errorf("платеж другого заказа = %s, want %s", got, StatusPending)
+	}
+
+	// Повторное событие не меняет завершенный платеж
+	if err := settlement.OnDeliveryCompleted(context.Background(), event); err != nil {
+		t.Fatalf("повторный OnDeliveryCompleted() error = %v", err)
+	}
+	if !get(pending.PaymentID).CompletedAt.Equal(*settled.CompletedAt) {
+		t.Error("повторное событие изменило завершенный платеж")
+	}
+}
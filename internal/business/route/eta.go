@@ -0,0 +1,169 @@
+package route
+
+import (
+	"context"
+	"delivery/internal/api"
+	"delivery/internal/business/models"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultETAThreshold - изменение ожидаемого времени, о котором сообщается клиентам
+	DefaultETAThreshold = 2 * time.Minute
+	// etaStorePrecision - изменение ожидаемого времени, при котором оно перезаписывается в БД
+	etaStorePrecision = 30 * time.Second
+)
+
+// ETAStore сохраняет ожидаемое время доставки
+type ETAStore interface {
+	SetETA(id, parcelID int, eta time.Time) error
+}
+
+// ETANotifier сообщает клиентам об изменении ожидаемого времени доставки
+type ETANotifier interface {
+	BroadcastETAUpdate(target api.OrderTarget, eta time.Time)
+}
+
+// etaState - последнее сохраненное и последнее отправленное клиентам ожидаемое время доставки
+type etaState struct {
+	stored    time.Time
+	published time.Time
+}
+
+// Estimator пересчитывает ожидаемое время открытых доставок курьера по его маршруту:
+// при каждой отметке местоположения и изменении статуса доставок курьера
+type Estimator struct {
+	planner   *Planner
+	store     ETAStore
+	notifier  ETANotifier
+	threshold time.Duration
+	trigger   chan struct{}
+
+	mu      sync.Mutex
+	pending map[int]bool             // Курьеры, ожидающие пересчета
+	states  map[int]map[int]etaState // Курьер → доставка → состояние
+}
+
+func NewEstimator(planner *Planner, store ETAStore) *Estimator {
+	return &Estimator{
+		planner:   planner,
+		store:     store,
+		threshold: DefaultETAThreshold,
+		trigger:   make(chan struct{}, 1),
+		pending:   make(map[int]bool),
+		states:    make(map[int]map[int]etaState),
+	}
+}
+
+// WithNotifier включает уведомления клиентов о значительном изменении ожидаемого времени
+func (e *Estimator) WithNotifier(notifier ETANotifier) *Estimator {
+	e.notifier = notifier
+	return e
+}
+
+// WithThreshold задает изменение ожидаемого времени, о котором сообщается клиентам
+func (e *Estimator) WithThreshold(threshold time.Duration) *Estimator {
+	if threshold > 0 {
+		e.threshold = threshold
+	}
+	return e
+}
+
+// Start запускает пересчет по уведомлениям Notify до отмены ctx
+func (e *Estimator) Start(ctx context.Context) {
+	go func() {
+		for {
+			select {
+			case <-e.trigger:
+			case <-ctx.Done():
+				return
+			}
+
+			e.mu.Lock()
+			couriers := e.pending
+			e.pending = make(map[int]bool)
+			e.mu.Unlock()
+
+			for courierID := range couriers {
+				if err := e.Recompute(courierID); err != nil {
+					log.Printf("[ETA] Ошибка пересчета ожидаемого времени доставок курьера %d: %v", courierID, err)
+				}
+			}
+		}
+	}()
+}
+
+// Notify запрашивает пересчет для курьера. Не блокируется: несколько уведомлений
+// для одного курьера до начала пересчета объединяются в одно
+func (e *Estimator) Notify(courierID int) {
+	e.mu.Lock()
+	e.pending[courierID] = true
+	e.mu.Unlock()
+
+	select {
+	case e.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// LocationRecorded пересчитывает ожидаемое время после новой отметки местоположения курьера
+func (e *Estimator) LocationRecorded(location models.CourierLocation) {
+	e.Notify(location.CourierID)
+}
+
+// Recompute строит маршрут курьера, сохраняет ожидаемое время его доставок и сообщает клиентам
+// об изменениях не меньше порога. Без местоположения курьера ожидаемое время не рассчитывается
+func (e *Estimator) Recompute(courierID int) error {
+	route, err := e.planner.Plan(courierID)
+	if err != nil {
+		if errors.Is(err, models.ErrLocationNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	e.mu.Lock()
+	previous := e.states[courierID]
+	e.mu.Unlock()
+
+	states := make(map[int]etaState, len(route.Stops))
+	for _, stop := range route.Stops {
+		state, known := previous[stop.DeliveryID]
+
+		if !known || differs(state.stored, stop.ETA, etaStorePrecision) {
+			if err := e.store.SetETA(stop.DeliveryID, stop.ParcelID, stop.ETA); err != nil {
+				log.Printf("[ETA] Ошибка сохранения ожидаемого времени доставки %d: %v", stop.DeliveryID, err)
+			} else {
+				state.stored = stop.ETA
+			}
+		}
+
+		if e.notifier != nil && (!known || differs(state.published, stop.ETA, e.threshold)) {
+			target := api.OrderTarget{OrderID: stop.DeliveryID, CourierID: courierID, ZoneID: stop.ZoneID}
+			e.notifier.BroadcastETAUpdate(target, stop.ETA)
+			state.published = stop.ETA
+		}
+
+		states[stop.DeliveryID] = state
+	}
+
+	// Завершенные доставки выпадают из маршрута и больше не отслеживаются
+	e.mu.Lock()
+	if len(states) == 0 {
+		delete(e.states, courierID)
+	} else {
+		e.states[courierID] = states
+	}
+	e.mu.Unlock()
+
+	return nil
+}
+
+// differs сообщает, что время изменилось не меньше чем на threshold
+func differs(a, b time.Time, threshold time.Duration) bool {
+	// Sub ограничивает разность пределами Duration, поэтому сравнение верно и для нулевого времени
+	return a.Sub(b) >= threshold || b.Sub(a) >= threshold
+}
@@ -0,0 +1,142 @@
+package route
+
+import (
+	"context"
+	"delivery/internal/api"
+	"delivery/internal/business/models"
+	"errors"
+	"testing"
+	"time"
+)
+
+// recordingETAs запоминает сохраненное и отправленное клиентам ожидаемое время
+type recordingETAs struct {
+	stored    map[int]time.Time
+	published map[int]time.Time
+	targets   map[int]api.OrderTarget
+	writes    chan int
+	err       error
+}
+
+func newRecordingETAs() *recordingETAs {
+	return &recordingETAs{stored: map[int]time.Time{}, published: map[int]time.Time{}, targets: map[int]api.OrderTarget{}, writes: make(chan int, 10)}
+}
+
+func (r *recordingETAs) SetETA(id, parcelID int, eta time.Time) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.stored[id] = eta
+	r.writes <- id
+	return nil
+}
+
+func (r *recordingETAs) BroadcastETAUpdate(target api.OrderTarget, eta time.Time) {
+	r.published[target.OrderID] = eta
+	r.targets[target.OrderID] = target
+}
+
+func TestEstimator_Recompute(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	deliveries := fakeDeliveries{1: {{ID: 5, ParcelID: 11, Status: "in_transit"}}}
+	parcel := parcelAt(11, 0.09) // ≈10 км от начала координат
+	parcel.ZoneID = 3
+	parcels := fakeParcels{11: parcel}
+	positions := fakePositions{1: {CourierID: 1}}
+
+	planner := NewPlanner(deliveries, parcels, positions).WithSpeeds(nil, Speeds{Default: 20})
+	planner.now = func() time.Time { return now }
+	etas := newRecordingETAs()
+	estimator := NewEstimator(planner, etas).WithNotifier(etas).WithThreshold(5 * time.Minute)
+
+	if err := estimator.Recompute(1); err != nil {
+		t.Fatalf("Recompute() error = %v", err)
+	}
+	first := etas.stored[5]
+	if first.IsZero() || !etas.published[5].Equal(first) {
+		t.Fatalf("первый расчет: сохранено %v, отправлено %v", etas.stored, etas.published)
+	}
+	if want := (api.OrderTarget{OrderID: 5, CourierID: 1, ZoneID: 3}); etas.targets[5] != want {
+		t.Errorf("адресаты = %+v, want %+v", etas.targets[5], want)
+	}
+
+	// Курьер приблизился на ≈2 км: время меняется на 6 минут - сохраняется и отправляется клиентам
+	positions[1] = models.CourierLocation{CourierID: 1, Lon: 0.018}
+	if err := estimator.Recompute(1); err != nil {
+		t.Fatalf("Recompute() error = %v", err)
+	}
+	second := etas.stored[5]
+	if !second.Before(first) || !etas.published[5].Equal(second) {
+		t.Errorf("сохранено %v, отправлено %v, want %v", second, etas.published[5], second)
+	}
+
+	// Курьер сдвинулся на ≈1 км: время меняется на 3 минуты - сохраняется, но ниже порога уведомления
+	positions[1] = models.CourierLocation{CourierID: 1, Lon: 0.027}
+	if err := estimator.Recompute(1); err != nil {
+		t.Fatalf("Recompute() error = %v", err)
+	}
+	if third := etas.stored[5]; !third.Before(second) || !etas.published[5].Equal(second) {
+		t.Errorf("сохранено %v, отправлено %v, want отправленное %v", third, etas.published[5], second)
+	}
+
+	// Доставка завершена и больше не отслеживается
+	deliveries[1][0].Status = "delivered"
+	if err := estimator.Recompute(1); err != nil {
+		t.Fatalf("Recompute() error = %v", err)
+	}
+	if _, ok := estimator.states[1]; ok {
+		t.Error("состояние курьера без открытых доставок не удалено")
+	}
+}
+
+func TestEstimator_Recompute_NoLocation(t *testing.T) {
+	deliveries := fakeDeliveries{1: {{ID: 5, ParcelID: 11, Status: "assigned"}}}
+	planner := NewPlanner(deliveries, fakeParcels{11: parcelAt(11, 0.09)}, fakePositions{})
+	etas := newRecordingETAs()
+
+	if err := NewEstimator(planner, etas).WithNotifier(etas).Recompute(1); err != nil {
+		t.Errorf("Recompute() error = %v", err)
+	}
+	if len(etas.stored) != 0 || len(etas.published) != 0 {
+		t.Errorf("без местоположения сохранено %v, отправлено %v", etas.stored, etas.published)
+	}
+}
+
+func TestEstimator_StoreFailure(t *testing.T) {
+	deliveries := fakeDeliveries{1: {{ID: 5, ParcelID: 11, Status: "assigned"}}}
+	planner := NewPlanner(deliveries, fakeParcels{11: parcelAt(11, 0.09)}, fakePositions{1: {CourierID: 1}})
+	etas := newRecordingETAs()
+	etas.err = errors.New("connection refused")
+	estimator := NewEstimator(planner, etas)
+
+	if err := estimator.Recompute(1); err != nil {
+		t.Fatalf("Recompute() error = %v", err)
+	}
+
+	// После восстановления хранилища время сохраняется при следующем пересчете
+	etas.err = nil
+	if err := estimator.Recompute(1); err != nil || etas.stored[5].IsZero() {
+		t.Errorf("Recompute() error = %v, сохранено %v", err, etas.stored)
+	}
+}
+
+func TestEstimator_Start(t *testing.T) {
+	deliveries := fakeDeliveries{1: {{ID: 5, ParcelID: 11, Status: "assigned"}}}
+	planner := NewPlanner(deliveries, fakeParcels{11: parcelAt(11, 0.09)}, fakePositions{1: {CourierID: 1}})
+	etas := newRecordingETAs()
+	estimator := NewEstimator(planner, etas)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	estimator.Start(ctx)
+	estimator.LocationRecorded(models.CourierLocation{CourierID: 1})
+
+	select {
+	case id := <-etas.writes:
+		if id != 5 {
+			t.Errorf("сохранено время доставки %d, want 5", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ожидаемое время не пересчитано после отметки местоположения")
+	}
+}
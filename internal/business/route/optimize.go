@@ -0,0 +1,78 @@
+package route
+
+import "delivery/internal/geo"
+
+// maxImprovementPasses ограничивает число проходов 2-opt, чтобы время расчета не зависело от неудачных данных
+const maxImprovementPasses = 100
+
+// Optimize возвращает порядок объезда точек из start: индексы stops в порядке посещения.
+// Начальный порядок строится методом ближайшего соседа и улучшается 2-opt.
+// Маршрут открытый: возвращаться в start не нужно
+func Optimize(start geo.Point, stops []geo.Point) []int {
+	order := nearestNeighbour(start, stops)
+	twoOpt(start, stops, order)
+	return order
+}
+
+// nearestNeighbour каждый раз переходит к ближайшей из непосещенных точек
+func nearestNeighbour(start geo.Point, stops []geo.Point) []int {
+	order := make([]int, 0, len(stops))
+	visited := make([]bool, len(stops))
+	current := start
+
+	for len(order) < len(stops) {
+		next, best := -1, 0.0
+		for i, p := range stops {
+			if visited[i] {
+				continue
+			}
+			if d := geo.Distance(current, p); next < 0 || d < best {
+				next, best = i, d
+			}
+		}
+		visited[next] = true
+		order = append(order, next)
+		current = stops[next]
+	}
+	return order
+}
+
+// twoOpt разворачивает участки маршрута, пока это сокращает его длину
+func twoOpt(start geo.Point, stops []geo.Point, order []int) {
+	// point возвращает i-ю точку маршрута, где 0 - начало маршрута
+	point := func(i int) geo.Point {
+		if i == 0 {
+			return start
+		}
+		return stops[order[i-1]]
+	}
+
+	n := len(order)
+	for pass := 0; pass < maxImprovementPasses; pass++ {
+		improved := false
+		for i := 1; i < n; i++ {
+			for j := i + 1; j <= n; j++ {
+				// Разворот участка [i, j] заменяет ребра (i-1, i) и (j, j+1) на (i-1, j) и (i, j+1)
+				a, b, c := point(i-1), point(i), point(j)
+				delta := geo.Distance(a, c) - geo.Distance(a, b)
+				if j < n {
+					d := point(j + 1)
+					delta += geo.Distance(b, d) - geo.Distance(c, d)
+				}
+				if delta < -1e-9 {
+					reverse(order[i-1 : j])
+					improved = true
+				}
+			}
+		}
+		if !improved {
+			return
+		}
+	}
+}
+
+func reverse(s []int) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
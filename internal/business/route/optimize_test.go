@@ -0,0 +1,76 @@
+package route
+
+import (
+	"delivery/internal/geo"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// length возвращает длину маршрута в километрах
+func length(start geo.Point, stops []geo.Point, order []int) float64 {
+	total := 0.0
+	prev := start
+	for _, i := range order {
+		total += geo.Distance(prev, stops[i])
+		prev = stops[i]
+	}
+	return total
+}
+
+// onEquator возвращает точки на экваторе: расстояние между ними пропорционально разности долгот
+func onEquator(lons ...float64) []geo.Point {
+	points := make([]geo.Point, len(lons))
+	for i, lon := range lons {
+		points[i] = geo.Point{Lon: lon / 100}
+	}
+	return points
+}
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		name  string
+		stops []geo.Point
+		want  []int
+	}{
+		{"Без остановок", nil, []int{}},
+		{"Одна остановка", onEquator(5), []int{0}},
+		{"Остановки на одной линии", onEquator(3, 1, 4, 2), []int{1, 3, 0, 2}},
+		// Ближайший сосед идет 0 → 1 → -1.5 → 4 (9 единиц), 2-opt разворачивает начало: 0 → -1.5 → 1 → 4 (7 единиц)
+		{"Улучшение 2-opt", onEquator(1, -1.5, 4), []int{1, 0, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Optimize(geo.Point{}, tt.stops); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Optimize() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOptimize_NotWorseThanNearestNeighbour(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	start := geo.Point{Lat: 55.75, Lon: 37.62}
+
+	for n := 2; n <= 30; n++ {
+		stops := make([]geo.Point, n)
+		for i := range stops {
+			stops[i] = geo.Point{Lat: 55.6 + rnd.Float64()*0.3, Lon: 37.4 + rnd.Float64()*0.4}
+		}
+
+		order := Optimize(start, stops)
+		sorted := append([]int(nil), order...)
+		sort.Ints(sorted)
+		for i, v := range sorted {
+			if v != i {
+				t.Fatalf("Optimize() = %v: не перестановка остановок", order)
+			}
+		}
+
+		if got, nn := length(start, stops, order), length(start, stops, nearestNeighbour(start, stops)); got > nn+1e-9 {
+			t.Errorf("n=%d: длина маршрута %.3f больше, чем у ближайшего соседа %.3f", n, got, nn)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package route
+
+import (
+	"delivery/internal/business/delivery"
+	"delivery/internal/business/models"
+	"delivery/internal/geo"
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+const (
+	// DefaultSpeedKmh - средняя скорость курьера в городе с учетом светофоров и пробок
+	DefaultSpeedKmh = 20.0
+	// DefaultStopDuration - время на вручение посылки на одной остановке
+	DefaultStopDuration = 5 * time.Minute
+)
+
+// Speeds - средняя скорость курьера в км/ч в зависимости от типа транспорта
+type Speeds struct {
+	Default   float64            // Для курьера без типа транспорта или с неизвестным типом
+	ByVehicle map[string]float64 // Тип транспорта → скорость
+}
+
+// For возвращает скорость для типа транспорта
+func (s Speeds) For(vehicleType string) float64 {
+	if speed, ok := s.ByVehicle[vehicleType]; ok && speed > 0 {
+		return speed
+	}
+	if s.Default > 0 {
+		return s.Default
+	}
+	return DefaultSpeedKmh
+}
+
+// DeliverySource - доставки, назначенные курьеру
+type DeliverySource interface {
+	GetDeliveriesByCourier(courierID int) ([]models.Delivery, error)
+}
+
+// ParcelSource - посылки с геокодированными адресами доставки
+type ParcelSource interface {
+	Get(id int) (*models.Parcel, error)
+}
+
+// PositionSource - последние известные местоположения курьеров
+type PositionSource interface {
+	Latest(courierID int) (models.CourierLocation, error)
+}
+
+// CourierSource - профили курьеров, из которых берется тип транспорта
+type CourierSource interface {
+	Get(id int) (*models.Courier, error)
+}
+
+// Planner строит маршрут курьера по адресам его открытых доставок
+type Planner struct {
+	deliveries   DeliverySource
+	parcels      ParcelSource
+	positions    PositionSource
+	couriers     CourierSource
+	speeds       Speeds
+	stopDuration time.Duration
+	now          func() time.Time
+}
+
+func NewPlanner(deliveries DeliverySource, parcels ParcelSource, positions PositionSource) *Planner {
+	return &Planner{
+		deliveries:   deliveries,
+		parcels:      parcels,
+		positions:    positions,
+		speeds:       Speeds{Default: DefaultSpeedKmh},
+		stopDuration: DefaultStopDuration,
+		now:          time.Now,
+	}
+}
+
+// WithSpeeds задает среднюю скорость по типу транспорта курьера. Без couriers используется скорость по умолчанию
+func (p *Planner) WithSpeeds(couriers CourierSource, speeds Speeds) *Planner {
+	p.couriers = couriers
+	p.speeds = speeds
+	return p
+}
+
+// WithStopDuration задает время на вручение посылки на одной остановке
+func (p *Planner) WithStopDuration(d time.Duration) *Planner {
+	if d > 0 {
+		p.stopDuration = d
+	}
+	return p
+}
+
+// speed возвращает среднюю скорость курьера по его типу транспорта
+func (p *Planner) speed(courierID int) float64 {
+	if p.couriers == nil {
+		return p.speeds.For("")
+	}
+	courier, err := p.couriers.Get(courierID)
+	if err != nil {
+		log.Printf("Ошибка при получении курьера %d для расчета маршрута: %v", courierID, err)
+		return p.speeds.For("")
+	}
+	return p.speeds.For(courier.VehicleType)
+}
+
+// Plan возвращает оптимизированный порядок объезда адресов доставок курьера от его текущего местоположения
+// и ожидаемое время прибытия на каждую остановку. Доставки, адрес которых не геокодирован, перечисляются в Unrouted
+func (p *Planner) Plan(courierID int) (models.Route, error) {
+	route := models.Route{CourierID: courierID, Stops: []models.RouteStop{}}
+
+	start, err := p.positions.Latest(courierID)
+	if err != nil {
+		return route, err
+	}
+	route.Start = start
+
+	deliveries, err := p.deliveries.GetDeliveriesByCourier(courierID)
+	if err != nil {
+		return route, err
+	}
+
+	var stops []models.RouteStop
+	var points []geo.Point
+	for _, d := range deliveries {
+		status, err := delivery.ParseStatus(d.Status)
+		if err != nil || !status.OnRoute() {
+			continue
+		}
+
+		parcel, err := p.parcels.Get(d.ParcelID)
+		if err != nil {
+			return route, fmt.Errorf("ошибка при получении посылки %d: %w", d.ParcelID, err)
+		}
+		if parcel.Destination == nil {
+			route.Unrouted = append(route.Unrouted, d.ID)
+			continue
+		}
+
+		stops = append(stops, models.RouteStop{
+			DeliveryID: d.ID,
+			ParcelID:   d.ParcelID,
+			Address:    parcel.Address,
+			Lat:        parcel.Destination.Lat,
+			Lon:        parcel.Destination.Lon,
+			ZoneID:     parcel.ZoneID,
+		})
+		points = append(points, geo.Point{Lat: parcel.Destination.Lat, Lon: parcel.Destination.Lon})
+	}
+
+	speed := p.speed(courierID)
+	now := p.now()
+	prev := geo.Point{Lat: start.Lat, Lon: start.Lon}
+	total := 0.0
+	for n, i := range Optimize(prev, points) {
+		stop := stops[i]
+		leg := geo.Distance(prev, points[i])
+		total += leg
+		prev = points[i]
+
+		// До остановки курьер проезжает все предыдущие участки и вручает посылки на предыдущих остановках
+		stop.DistanceKm = roundKm(leg)
+		stop.ETA = now.Add(p.duration(total, speed, n)).UTC().Truncate(time.Second)
+		route.Stops = append(route.Stops, stop)
+	}
+
+	route.DistanceKm = roundKm(total)
+	route.DurationMinutes = int(math.Ceil(p.duration(total, speed, len(route.Stops)).Minutes()))
+	return route, nil
+}
+
+// duration оценивает время на путь длиной distanceKm и вручение посылок на stops остановках
+func (p *Planner) duration(distanceKm, speedKmh float64, stops int) time.Duration {
+	driving := time.Duration(distanceKm / speedKmh * float64(time.Hour))
+	return driving + time.Duration(stops)*p.stopDuration
+}
+
+// roundKm округляет расстояние до метров
+func roundKm(km float64) float64 {
+	return math.Round(km*1000) / 1000
+}
@@ -0,0 +1,143 @@
+package route
+
+import (
+	"delivery/internal/business/models"
+	"errors"
+	"testing"
+	"time"
+)
+
+type fakeDeliveries map[int][]models.Delivery
+
+func (f fakeDeliveries) GetDeliveriesByCourier(courierID int) ([]models.Delivery, error) {
+	return f[courierID], nil
+}
+
+type fakeParcels map[int]models.Parcel
+
+func (f fakeParcels) Get(id int) (*models.Parcel, error) {
+	p, ok := f[id]
+	if !ok {
+		return nil, models.ErrParcelNotFound
+	}
+	return &p, nil
+}
+
+type fakePositions map[int]models.CourierLocation
+
+func (f fakePositions) Latest(courierID int) (models.CourierLocation, error) {
+	location, ok := f[courierID]
+	if !ok {
+		return location, models.ErrLocationNotFound
+	}
+	return location, nil
+}
+
+// parcelAt возвращает посылку с геокодированным адресом на экваторе
+func parcelAt(id int, lon float64) models.Parcel {
+	return models.Parcel{ID: id, Address: "Адрес", Destination: &models.Address{Lon: lon}}
+}
+
+func TestPlanner_Plan(t *testing.T) {
+	deliveries := fakeDeliveries{7: {
+		{ID: 1, CourierID: 7, ParcelID: 11, Status: "assigned"},
+		{ID: 2, CourierID: 7, ParcelID: 12, Status: "in_transit"},
+		{ID: 3, CourierID: 7, ParcelID: 13, Status: "delivered"},
+		{ID: 4, CourierID: 7, ParcelID: 14, Status: "picked_up"},
+		{ID: 5, CourierID: 7, ParcelID: 15, Status: "in progress"}, // Статус до введения жизненного цикла
+		{ID: 6, CourierID: 7, ParcelID: 16, Status: "cancelled"},
+	}}
+	parcels := fakeParcels{
+		11: parcelAt(11, 0.03),
+		12: parcelAt(12, 0.01),
+		13: parcelAt(13, 0.02),
+		14: {ID: 14, Address: "Не найден"},
+		15: parcelAt(15, 0.02),
+	}
+	positions := fakePositions{7: {CourierID: 7, Timestamp: time.Now()}}
+
+	// 0.01° долготы на экваторе ≈ 1.112 км; при 20 км/ч 3.336 км - около 10 минут, плюс 3 остановки по 5 минут
+	route, err := NewPlanner(deliveries, parcels, positions).Plan(7)
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+
+	var order []int
+	for _, stop := range route.Stops {
+		order = append(order, stop.DeliveryID)
+	}
+	if len(order) != 3 || order[0] != 2 || order[1] != 5 || order[2] != 1 {
+		t.Errorf("порядок остановок = %v, want [2 5 1]", order)
+	}
+	if len(route.Unrouted) != 1 || route.Unrouted[0] != 4 {
+		t.Errorf("Unrouted = %v, want [4]", route.Unrouted)
+	}
+	if route.DistanceKm < 3.3 || route.DistanceKm > 3.4 {
+		t.Errorf("DistanceKm = %v, want ≈3.336", route.DistanceKm)
+	}
+	if route.DurationMinutes != 26 {
+		t.Errorf("DurationMinutes = %d, want 26", route.DurationMinutes)
+	}
+
+	// Без остановок время в пути нулевое
+	route, err = NewPlanner(deliveries, parcels, fakePositions{8: {CourierID: 8}}).Plan(8)
+	if err != nil || len(route.Stops) != 0 || route.DurationMinutes != 0 {
+		t.Errorf("Plan() без доставок = %+v, %v", route, err)
+	}
+}
+
+type fakeCouriers map[int]models.Courier
+
+func (f fakeCouriers) Get(id int) (*models.Courier, error) {
+	c, ok := f[id]
+	if !ok {
+		return nil, models.ErrCourierNotFound
+	}
+	return &c, nil
+}
+
+func TestPlanner_Plan_VehicleSpeed(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	// Две остановки через 0.09° долготы на экваторе (≈10 км каждая)
+	deliveries := fakeDeliveries{
+		1: {{ID: 1, ParcelID: 11, Status: "assigned"}, {ID: 2, ParcelID: 12, Status: "assigned"}},
+		2: {{ID: 3, ParcelID: 11, Status: "assigned"}},
+	}
+	parcels := fakeParcels{11: parcelAt(11, 0.09), 12: parcelAt(12, 0.18)}
+	positions := fakePositions{1: {CourierID: 1}, 2: {CourierID: 2}}
+	couriers := fakeCouriers{
+		1: {ID: 1, VehicleType: models.VehicleCar},
+		2: {ID: 2, VehicleType: "hoverboard"},
+	}
+	speeds := Speeds{Default: 10, ByVehicle: map[string]float64{models.VehicleCar: 40}}
+
+	planner := NewPlanner(deliveries, parcels, positions).WithSpeeds(couriers, speeds).WithStopDuration(10 * time.Minute)
+	planner.now = func() time.Time { return now }
+
+	// На машине: 10 км за 15 минут, затем 10 минут на вручение и еще 15 минут
+	route, err := planner.Plan(1)
+	if err != nil || len(route.Stops) != 2 {
+		t.Fatalf("Plan() = %+v, %v", route, err)
+	}
+	for i, want := range []time.Duration{15 * time.Minute, 40 * time.Minute} {
+		if got := route.Stops[i].ETA.Sub(now); got < want-time.Minute || got > want+time.Minute {
+			t.Errorf("остановка %d: ETA через %v, want ≈%v", i, got, want)
+		}
+	}
+
+	// Неизвестный тип транспорта - скорость по умолчанию: 10 км за час
+	route, err = planner.Plan(2)
+	if err != nil || len(route.Stops) != 1 {
+		t.Fatalf("Plan() = %+v, %v", route, err)
+	}
+	if got := route.Stops[0].ETA.Sub(now); got < 59*time.Minute || got > 61*time.Minute {
+		t.Errorf("ETA через %v, want ≈1ч", got)
+	}
+}
+
+func TestPlanner_Plan_UnknownLocation(t *testing.T) {
+	_, err := NewPlanner(fakeDeliveries{}, fakeParcels{}, fakePositions{}).Plan(7)
+	if !errors.Is(err, models.ErrLocationNotFound) {
+		t.Errorf("Plan() error = %v, want ErrLocationNotFound", err)
+	}
+}
@@ -0,0 +1,180 @@
+package zone
+
+import (
+	"delivery/internal/business/models"
+	"delivery/internal/geo"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ZoneStorer определяет интерфейс для хранилища зон доставки
+type ZoneStorer interface {
+	Add(zone models.Zone) (int, error)
+	Get(id int) (models.Zone, error)
+	GetAll() ([]models.Zone, error)
+	Update(zone models.Zone) error
+	Delete(id int) error
+	SetCourierZones(courierID int, zoneIDs []int) error
+	CourierZones(courierID int) ([]int, error)
+}
+
+// areasTTL - как долго используются загруженные границы зон. Зоны, измененные
+// другим экземпляром сервиса, учитываются после истечения этого срока
+const areasTTL = time.Minute
+
+// zoneArea - разобранные границы зоны
+type zoneArea struct {
+	id   int
+	area geo.Area
+}
+
+// ZoneService управляет зонами доставки и определяет зону по координатам
+type ZoneService struct {
+	store ZoneStorer
+
+	mu       sync.Mutex
+	areas    []zoneArea // Упорядочены по ID зоны
+	loadedAt time.Time
+	now      func() time.Time
+}
+
+func NewZoneService(store ZoneStorer) *ZoneService {
+	return &ZoneService{store: store, now: time.Now}
+}
+
+// validate проверяет название и границы зоны
+func validate(zone *models.Zone) error {
+	zone.Name = strings.TrimSpace(zone.Name)
+	if zone.Name == "" {
+		return fmt.Errorf("%w: не задано название", models.ErrInvalidZone)
+	}
+	if _, err := geo.ParseArea(zone.Geometry); err != nil {
+		return fmt.Errorf("%w: %v", models.ErrInvalidZone, err)
+	}
+	return nil
+}
+
+func (s *ZoneService) Create(zone *models.Zone) error {
+	if err := validate(zone); err != nil {
+		return err
+	}
+
+	id, err := s.store.Add(*zone)
+	if err != nil {
+		return err
+	}
+	zone.ID = id
+	s.invalidate()
+	return nil
+}
+
+func (s *ZoneService) Get(id int) (*models.Zone, error) {
+	zone, err := s.store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	return &zone, nil
+}
+
+func (s *ZoneService) List() ([]models.Zone, error) {
+	return s.store.GetAll()
+}
+
+func (s *ZoneService) Update(id int, zone *models.Zone) error {
+	if err := validate(zone); err != nil {
+		return err
+	}
+
+	zone.ID = id
+	if err := s.store.Update(*zone); err != nil {
+		return err
+	}
+	s.invalidate()
+	return nil
+}
+
+func (s *ZoneService) Delete(id int) error {
+	if err := s.store.Delete(id); err != nil {
+		return err
+	}
+	s.invalidate()
+	return nil
+}
+
+// SetCourierZones задает зоны, которые обслуживает курьер. Пустой список отвязывает курьера от всех зон
+func (s *ZoneService) SetCourierZones(courierID int, zoneIDs []int) error {
+	unique := make([]int, 0, len(zoneIDs))
+	seen := make(map[int]bool)
+	for _, id := range zoneIDs {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	sort.Ints(unique)
+
+	return s.store.SetCourierZones(courierID, unique)
+}
+
+// CourierZones возвращает ID зон, которые обслуживает курьер
+func (s *ZoneService) CourierZones(courierID int) ([]int, error) {
+	return s.store.CourierZones(courierID)
+}
+
+// ZoneAt возвращает зону, в которую попадает точка. Если точка попадает в несколько
+// пересекающихся зон, выбирается зона с наименьшим ID
+func (s *ZoneService) ZoneAt(point geo.Point) (int, bool) {
+	areas, err := s.loadAreas()
+	if err != nil {
+		log.Printf("Ошибка при загрузке зон доставки: %v", err)
+		return 0, false
+	}
+
+	for _, z := range areas {
+		if z.area.Contains(point) {
+			return z.id, true
+		}
+	}
+	return 0, false
+}
+
+// loadAreas возвращает границы зон, при необходимости перечитывая их из хранилища
+func (s *ZoneService) loadAreas() ([]zoneArea, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.areas != nil && s.now().Sub(s.loadedAt) < areasTTL {
+		return s.areas, nil
+	}
+
+	zones, err := s.store.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	areas := make([]zoneArea, 0, len(zones))
+	for _, z := range zones {
+		area, err := geo.ParseArea(z.Geometry)
+		if err != nil {
+			// Границы проверяются при сохранении, поэтому такая зона пропускается, а не ломает остальные
+			log.Printf("Зона доставки %d имеет некорректные границы: %v", z.ID, err)
+			continue
+		}
+		areas = append(areas, zoneArea{id: z.ID, area: area})
+	}
+	sort.Slice(areas, func(i, j int) bool { return areas[i].id < areas[j].id })
+
+	s.areas, s.loadedAt = areas, s.now()
+	return areas, nil
+}
+
+// invalidate сбрасывает загруженные границы после изменения зон
+func (s *ZoneService) invalidate() {
+	s.mu.Lock()
+	s.areas = nil
+	s.mu.Unlock()
+}
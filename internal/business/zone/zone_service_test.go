@@ -0,0 +1,170 @@
+package zone
+
+import (
+	"delivery/internal/business/models"
+	"delivery/internal/geo"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+// mockZoneStore хранит зоны в памяти
+type mockZoneStore struct {
+	zones    map[int]models.Zone
+	couriers map[int][]int
+	nextID   int
+	loads    int // Сколько раз запрашивался список зон
+}
+
+func newMockZoneStore() *mockZoneStore {
+	return &mockZoneStore{zones: map[int]models.Zone{}, couriers: map[int][]int{}, nextID: 1}
+}
+
+func (m *mockZoneStore) Add(zone models.Zone) (int, error) {
+	for _, z := range m.zones {
+		if z.Name == zone.Name {
+			return 0, models.ErrZoneNameTaken
+		}
+	}
+	zone.ID = m.nextID
+	m.zones[zone.ID] = zone
+	m.nextID++
+	return zone.ID, nil
+}
+
+func (m *mockZoneStore) Get(id int) (models.Zone, error) {
+	zone, ok := m.zones[id]
+	if !ok {
+		return zone, models.ErrZoneNotFound
+	}
+	return zone, nil
+}
+
+func (m *mockZoneStore) GetAll() ([]models.Zone, error) {
+	m.loads++
+	var zones []models.Zone
+	for _, z := range m.zones {
+		zones = append(zones, z)
+	}
+	return zones, nil
+}
+
+func (m *mockZoneStore) Update(zone models.Zone) error {
+	if _, ok := m.zones[zone.ID]; !ok {
+		return models.ErrZoneNotFound
+	}
+	m.zones[zone.ID] = zone
+	return nil
+}
+
+func (m *mockZoneStore) Delete(id int) error {
+	if _, ok := m.zones[id]; !ok {
+		return models.ErrZoneNotFound
+	}
+	delete(m.zones, id)
+	return nil
+}
+
+func (m *mockZoneStore) SetCourierZones(courierID int, zoneIDs []int) error {
+	m.couriers[courierID] = zoneIDs
+	return nil
+}
+
+func (m *mockZoneStore) CourierZones(courierID int) ([]int, error) {
+	return m.couriers[courierID], nil
+}
+
+// square возвращает полигон GeoJSON - квадрат со стороной size градусов от точки (lon, lat)
+func square(lon, lat, size float64) json.RawMessage {
+	data, _ := json.Marshal(map[string]interface{}{
+		"type": "Polygon",
+		"coordinates": [][][]float64{{
+			{lon, lat}, {lon + size, lat}, {lon + size, lat + size}, {lon, lat + size}, {lon, lat},
+		}},
+	})
+	return data
+}
+
+func TestZoneService_Create(t *testing.T) {
+	service := NewZoneService(newMockZoneStore())
+
+	zone := models.Zone{Name: "  Центр ", Geometry: square(37.5, 55.7, 0.1)}
+	if err := service.Create(&zone); err != nil || zone.ID == 0 || zone.Name != "Центр" {
+		t.Fatalf("Create() = %+v, %v", zone, err)
+	}
+
+	invalid := []models.Zone{
+		{Name: "", Geometry: square(37.5, 55.7, 0.1)},
+		{Name: "Точка", Geometry: json.RawMessage(`{"type":"Point","coordinates":[37.5,55.7]}`)},
+		{Name: "Без границ"},
+	}
+	for _, z := range invalid {
+		if err := service.Create(&z); !errors.Is(err, models.ErrInvalidZone) {
+			t.Errorf("Create(%q) error = %v, want ErrInvalidZone", z.Name, err)
+		}
+	}
+}
+
+func TestZoneService_ZoneAt(t *testing.T) {
+	store := newMockZoneStore()
+	service := NewZoneService(store)
+
+	for _, z := range []models.Zone{
+		{Name: "Москва", Geometry: square(37.3, 55.5, 0.6)},
+		{Name: "Центр", Geometry: square(37.5, 55.7, 0.1)}, // Внутри зоны "Москва"
+		{Name: "Петербург", Geometry: square(30.1, 59.8, 0.3)},
+	} {
+		if err := service.Create(&z); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		point  geo.Point
+		wantID int
+		wantOK bool
+	}{
+		{"Пересекающиеся зоны - меньший ID", geo.Point{Lat: 55.75, Lon: 37.55}, 1, true},
+		{"Петербург", geo.Point{Lat: 59.93, Lon: 30.33}, 3, true},
+		{"Вне зон", geo.Point{Lat: 56.33, Lon: 44.00}, 0, false},
+	}
+	for _, tt := range tests {
+		id, ok := service.ZoneAt(tt.point)
+		if id != tt.wantID || ok != tt.wantOK {
+			t.Errorf("%s: ZoneAt() = %d, %v, want %d, %v", tt.name, id, ok, tt.wantID, tt.wantOK)
+		}
+	}
+	if store.loads != 1 {
+		t.Errorf("границы зон загружены %d раз, want 1", store.loads)
+	}
+
+	// После удаления зоны границы перечитываются
+	if err := service.Delete(1); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if id, _ := service.ZoneAt(geo.Point{Lat: 55.75, Lon: 37.55}); id != 2 {
+		t.Errorf("ZoneAt() после удаления = %d, want 2", id)
+	}
+
+	// Зоны, измененные другим экземпляром сервиса, учитываются по истечении срока
+	store.zones[4] = models.Zone{ID: 4, Name: "Нижний Новгород", Geometry: square(43.8, 56.2, 0.3)}
+	service.now = func() time.Time { return time.Now().Add(2 * areasTTL) }
+	if id, ok := service.ZoneAt(geo.Point{Lat: 56.33, Lon: 44.00}); !ok || id != 4 {
+		t.Errorf("ZoneAt() = %d, %v, want 4", id, ok)
+	}
+}
+
+func TestZoneService_SetCourierZones(t *testing.T) {
+	store := newMockZoneStore()
+	service := NewZoneService(store)
+
+	if err := service.SetCourierZones(7, []int{3, 1, 3}); err != nil {
+		t.Fatalf("SetCourierZones() error = %v", err)
+	}
+	zoneIDs, _ := service.CourierZones(7)
+	if len(zoneIDs) != 2 || zoneIDs[0] != 1 || zoneIDs[1] != 3 {
+		t.Errorf("CourierZones() = %v, want [1 3]", zoneIDs)
+	}
+}
@@ -0,0 +1,190 @@
+package zone
+
+import (
+	"database/sql"
+	"delivery/internal/business/models"
+	"fmt"
+)
+
+// Убедимся, что ZoneStore реализует интерфейс ZoneStorer
+var _ ZoneStorer = (*ZoneStore)(nil)
+
+// rowScanner - общий интерфейс для *sql.Row и *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+type ZoneStore struct {
+	db            *sql.DB
+	tableName     string
+	couriersTable string // Привязка курьеров к зонам
+	courierTable  string
+}
+
+func NewZoneStore(db *sql.DB) *ZoneStore {
+	return &ZoneStore{
+		db:            db,
+		tableName:     "zones",
+		couriersTable: "courier_zones",
+		courierTable:  "courier",
+	}
+}
+
+func scanZone(row rowScanner) (models.Zone, error) {
+	var zone models.Zone
+	var geometry []byte
+	if err := row.Scan(&zone.ID, &zone.Name, &geometry); err != nil {
+		return zone, err
+	}
+	zone.Geometry = geometry
+	return zone, nil
+}
+
+// Add добавляет зону. Если название уже занято, возвращает models.ErrZoneNameTaken
+func (s *ZoneStore) Add(zone models.Zone) (int, error) {
+	query := fmt.Sprintf(`INSERT INTO %s (name, geometry) VALUES ($1, $2)
+		ON CONFLICT (name) DO NOTHING RETURNING id`, s.tableName)
+
+	var id int
+	err := s.db.QueryRow(query, zone.Name, string(zone.Geometry)).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("%w: %s", models.ErrZoneNameTaken, zone.Name)
+		}
+		return 0, fmt.Errorf("ошибка при добавлении зоны доставки: %w", err)
+	}
+	return id, nil
+}
+
+func (s *ZoneStore) Get(id int) (models.Zone, error) {
+	query := fmt.Sprintf(`SELECT id, name, geometry FROM %s WHERE id = $1`, s.tableName)
+	zone, err := scanZone(s.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return zone, fmt.Errorf("%w: ID %d", models.ErrZoneNotFound, id)
+		}
+		return zone, fmt.Errorf("ошибка при получении зоны доставки: %w", err)
+	}
+	return zone, nil
+}
+
+func (s *ZoneStore) GetAll() ([]models.Zone, error) {
+	query := fmt.Sprintf(`SELECT id, name, geometry FROM %s ORDER BY id`, s.tableName)
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении списка зон доставки: %w", err)
+	}
+	defer rows.Close()
+
+	zones := []models.Zone{}
+	for rows.Next() {
+		zone, err := scanZone(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при сканировании зоны доставки: %w", err)
+		}
+		zones = append(zones, zone)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке результатов: %w", err)
+	}
+
+	return zones, nil
+}
+
+// Update изменяет название и границы зоны. Название не должно совпадать с названием другой зоны
+func (s *ZoneStore) Update(zone models.Zone) error {
+	query := fmt.Sprintf(`UPDATE %[1]s SET name = $1, geometry = $2
+		WHERE id = $3 AND NOT EXISTS (SELECT 1 FROM %[1]s WHERE name = $1 AND id <> $3)`, s.tableName)
+	result, err := s.db.Exec(query, zone.Name, string(zone.Geometry), zone.ID)
+	if err != nil {
+		return fmt.Errorf("ошибка при обновлении зоны доставки: %w", err)
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		// Зона не найдена или название занято другой зоной
+		if _, err := s.Get(zone.ID); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %s", models.ErrZoneNameTaken, zone.Name)
+	}
+	return nil
+}
+
+func (s *ZoneStore) Delete(id int) error {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE id = $1`, s.tableName)
+	result, err := s.db.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("ошибка при удалении зоны доставки: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return fmt.Errorf("%w: ID %d", models.ErrZoneNotFound, id)
+	}
+	return nil
+}
+
+// SetCourierZones заменяет список зон, которые обслуживает курьер. Запись курьера блокируется,
+// чтобы одновременные изменения не смешивались
+func (s *ZoneStore) SetCourierZones(courierID int, zoneIDs []int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("ошибка при открытии транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id int
+	query := fmt.Sprintf(`SELECT id FROM %s WHERE id = $1 FOR UPDATE`, s.courierTable)
+	if err := tx.QueryRow(query, courierID).Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: ID %d", models.ErrCourierNotFound, courierID)
+		}
+		return fmt.Errorf("ошибка при получении курьера: %w", err)
+	}
+
+	query = fmt.Sprintf(`DELETE FROM %s WHERE courier_id = $1`, s.couriersTable)
+	if _, err := tx.Exec(query, courierID); err != nil {
+		return fmt.Errorf("ошибка при изменении зон курьера: %w", err)
+	}
+
+	// Строка добавляется, только если зона существует
+	query = fmt.Sprintf(`INSERT INTO %s (courier_id, zone_id) SELECT $1, id FROM %s WHERE id = $2`, s.couriersTable, s.tableName)
+	for _, zoneID := range zoneIDs {
+		result, err := tx.Exec(query, courierID, zoneID)
+		if err != nil {
+			return fmt.Errorf("ошибка при изменении зон курьера: %w", err)
+		}
+		if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+			return fmt.Errorf("%w: ID %d", models.ErrZoneNotFound, zoneID)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ошибка при фиксации транзакции: %w", err)
+	}
+	return nil
+}
+
+// CourierZones возвращает зоны, которые обслуживает курьер
+func (s *ZoneStore) CourierZones(courierID int) ([]int, error) {
+	query := fmt.Sprintf(`SELECT zone_id FROM %s WHERE courier_id = $1 ORDER BY zone_id`, s.couriersTable)
+	rows, err := s.db.Query(query, courierID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении зон курьера: %w", err)
+	}
+	defer rows.Close()
+
+	zoneIDs := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("ошибка при сканировании зоны курьера: %w", err)
+		}
+		zoneIDs = append(zoneIDs, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке результатов: %w", err)
+	}
+
+	return zoneIDs, nil
+}
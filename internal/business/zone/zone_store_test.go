@@ -0,0 +1,81 @@
+package zone
+
+import (
+	"delivery/internal/business/models"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZoneStore_SetCourierZones(t *testing.T) {
+	lockCourier := regexp.QuoteMeta("SELECT id FROM courier WHERE id = $1 FOR UPDATE")
+	clearZones := regexp.QuoteMeta("DELETE FROM courier_zones WHERE courier_id = $1")
+	addZone := regexp.QuoteMeta("INSERT INTO courier_zones (courier_id, zone_id) SELECT $1, id FROM zones WHERE id = $2")
+
+	newStore := func(t *testing.T) (*ZoneStore, sqlmock.Sqlmock) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+		return NewZoneStore(db), mock
+	}
+
+	t.Run("Успешно", func(t *testing.T) {
+		store, mock := newStore(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockCourier).WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+		mock.ExpectExec(clearZones).WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(addZone).WithArgs(7, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(addZone).WithArgs(7, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		assert.NoError(t, store.SetCourierZones(7, []int{1, 2}))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Курьер не найден", func(t *testing.T) {
+		store, mock := newStore(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockCourier).WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectRollback()
+
+		assert.ErrorIs(t, store.SetCourierZones(7, []int{1}), models.ErrCourierNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Зона не найдена - изменения отменяются", func(t *testing.T) {
+		store, mock := newStore(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockCourier).WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+		mock.ExpectExec(clearZones).WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(addZone).WithArgs(7, 9).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		assert.ErrorIs(t, store.SetCourierZones(7, []int{9}), models.ErrZoneNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestZoneStore_Update(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	store := NewZoneStore(db)
+
+	update := regexp.QuoteMeta("UPDATE zones SET name = $1, geometry = $2")
+	getZone := regexp.QuoteMeta("SELECT id, name, geometry FROM zones WHERE id = $1")
+	zone := models.Zone{ID: 1, Name: "Центр", Geometry: []byte(`{}`)}
+
+	// Название занято другой зоной
+	mock.ExpectExec(update).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(getZone).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "name", "geometry"}).AddRow(1, "Старое", []byte(`{}`)))
+	assert.ErrorIs(t, store.Update(zone), models.ErrZoneNameTaken)
+
+	// Зона не найдена
+	mock.ExpectExec(update).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(getZone).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "name", "geometry"}))
+	assert.ErrorIs(t, store.Update(zone), models.ErrZoneNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -16,6 +16,18 @@ type RedisClientInterface interface {
 	// Delete удаляет ключ
 	Delete(ctx context.Context, key string) error
 
+	// Keys возвращает ключи, соответствующие шаблону
+	Keys(ctx context.Context, pattern string) ([]string, error)
+
+	// TTL возвращает оставшееся время жизни ключа
+	TTL(ctx context.Context, key string) (time.Duration, error)
+
+	// LPushTrim добавляет значение в начало списка и атомарно обрезает список до maxLen значений
+	LPushTrim(ctx context.Context, key string, value interface{}, maxLen int64) error
+
+	// LRange возвращает элементы списка с start по stop включительно
+	LRange(ctx context.Context, key string, start, stop int64) ([]string, error)
+
 	// Close закрывает соединение с Redis
 	Close() error
 
@@ -36,4 +48,13 @@ type RedisClientInterface interface {
 
 	// ScheduleRedisCleanup запускает периодическую очистку Redis
 	ScheduleRedisCleanup(interval time.Duration) chan bool
+
+	// Publish отправляет сообщение всем подписчикам канала Redis Pub/Sub
+	Publish(ctx context.Context, channel string, message []byte) error
+
+	// Subscribe подписывается на канал Redis Pub/Sub и возвращает поток сообщений
+	Subscribe(ctx context.Context, channel string) (<-chan []byte, error)
+
+	// Incr увеличивает счетчик на 1 и возвращает новое значение
+	Incr(ctx context.Context, key string) (int64, error)
 }
@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+)
+
+// Publish отправляет сообщение всем подписчикам канала Redis Pub/Sub
+func (r *RedisClient) Publish(ctx context.Context, channel string, message []byte) error {
+	if r == nil || r.client == nil {
+		return fmt.Errorf("Redis client is nil")
+	}
+
+	return r.client.Publish(ctx, channel, message).Err()
+}
+
+// Subscribe подписывается на канал Redis Pub/Sub и возвращает поток сообщений.
+// После потери соединения подписка восстанавливается автоматически; поток закрывается при отмене ctx
+func (r *RedisClient) Subscribe(ctx context.Context, channel string) (<-chan []byte, error) {
+	if r == nil || r.client == nil {
+		return nil, fmt.Errorf("Redis client is nil")
+	}
+
+	pubsub := r.client.Subscribe(ctx, channel)
+	// Дожидаемся подтверждения подписки, чтобы сообщить об ошибке сразу
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, fmt.Errorf("ошибка подписки на канал %s: %w", channel, err)
+	}
+
+	messages := make(chan []byte)
+	go func() {
+		defer close(messages)
+		defer pubsub.Close()
+
+		incoming := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-incoming:
+				if !ok {
+					return
+				}
+				select {
+				case messages <- []byte(msg.Payload):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return messages, nil
+}
+
+// Incr увеличивает счетчик на 1 и возвращает новое значение
+func (r *RedisClient) Incr(ctx context.Context, key string) (int64, error) {
+	if r == nil || r.client == nil {
+		return 0, fmt.Errorf("Redis client is nil")
+	}
+
+	return r.client.Incr(ctx, key).Result()
+}
@@ -0,0 +1,22 @@
+package cache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPubSubWithoutRedis(t *testing.T) {
+	var client *RedisClient
+	ctx := context.Background()
+
+	assert.Error(t, client.Publish(ctx, "ws:orders", []byte("{}")))
+
+	messages, err := client.Subscribe(ctx, "ws:orders")
+	assert.Error(t, err)
+	assert.Nil(t, messages)
+
+	_, err = client.Incr(ctx, "ws:orders:seq")
+	assert.Error(t, err)
+}
@@ -109,6 +109,48 @@ func (r *RedisClient) Delete(ctx context.Context, key string) error {
 	return r.client.Del(ctx, key).Err()
 }
 
+// Keys возвращает ключи, соответствующие шаблону
+func (r *RedisClient) Keys(ctx context.Context, pattern string) ([]string, error) {
+	if r == nil || r.client == nil {
+		return nil, fmt.Errorf("Redis client is nil")
+	}
+
+	return r.client.Keys(ctx, pattern).Result()
+}
+
+// TTL возвращает оставшееся время жизни ключа
+func (r *RedisClient) TTL(ctx context.Context, key string) (time.Duration, error) {
+	if r == nil || r.client == nil {
+		return 0, fmt.Errorf("Redis client is nil")
+	}
+
+	return r.client.TTL(ctx, key).Result()
+}
+
+// LPushTrim добавляет значение в начало списка и оставляет в нем не больше maxLen последних
+// значений. Обе команды выполняются в одной транзакции MULTI/EXEC
+func (r *RedisClient) LPushTrim(ctx context.Context, key string, value interface{}, maxLen int64) error {
+	if r == nil || r.client == nil {
+		return fmt.Errorf("Redis client is nil")
+	}
+
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, key, value)
+		pipe.LTrim(ctx, key, 0, maxLen-1)
+		return nil
+	})
+	return err
+}
+
+// LRange возвращает элементы списка с start по stop включительно
+func (r *RedisClient) LRange(ctx context.Context, key string, start, stop int64) ([]string, error) {
+	if r == nil || r.client == nil {
+		return nil, fmt.Errorf("Redis client is nil")
+	}
+
+	return r.client.LRange(ctx, key, start, stop).Result()
+}
+
 // Close закрывает соединение с Redis
 func (r *RedisClient) Close() error {
 	if r == nil || r.client == nil {
@@ -2,24 +2,76 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strings"
 
+	"delivery/internal/api"
 	"delivery/internal/business/payment"
 )
 
 // PaymentController обрабатывает запросы, связанные с платежами
 type PaymentController struct {
 	paymentService payment.PaymentService
+	ownership      *api.Ownership
 }
 
 // NewPaymentController создает новый экземпляр PaymentController
-func NewPaymentController() *PaymentController {
+func NewPaymentController(paymentService payment.PaymentService) *PaymentController {
 	return &PaymentController{
-		paymentService: payment.NewMockPaymentService(),
+		paymentService: paymentService,
 	}
 }
 
+// WithOwnership ограничивает клиентов платежами за доставку их посылок
+func (pc *PaymentController) WithOwnership(ownership *api.Ownership) *PaymentController {
+	pc.ownership = ownership
+	return pc
+}
+
+// authorize проверяет, что текущий пользователь может работать с платежами заказа orderID.
+// Клиенту доступны только заказы доставки его посылок
+func (pc *PaymentController) authorize(w http.ResponseWriter, r *http.Request, orderID string) bool {
+	if !pc.ownership.Scoped(r) {
+		return true
+	}
+
+	err := api.ErrAccessDenied
+	if parcelID, ok := payment.ParseParcelOrderID(orderID); ok {
+		err = pc.ownership.CheckParcelID(r, parcelID)
+	}
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, api.ErrAccessDenied):
+		http.Error(w, "Доступ к платежу запрещен", http.StatusForbidden)
+	default:
+		http.Error(w, "Ошибка проверки доступа: "+err.Error(), http.StatusInternalServerError)
+	}
+	return false
+}
+
+// authorizePayment проверяет доступ к платежу paymentID до любых действий с ним. Платеж читается
+// без сверки с провайдером, поэтому запрос к чужому платежу не меняет его состояние. Чужой
+// и несуществующий платеж клиент получает одинаково - с 403, чтобы по ответу нельзя было узнать,
+// существует ли платеж
+func (pc *PaymentController) authorizePayment(w http.ResponseWriter, r *http.Request, paymentID string) bool {
+	if !pc.ownership.Scoped(r) {
+		return true
+	}
+
+	stored, err := pc.paymentService.StoredPayment(paymentID)
+	switch {
+	case errors.Is(err, payment.ErrPaymentNotFound):
+		http.Error(w, "Доступ к платежу запрещен", http.StatusForbidden)
+		return false
+	case err != nil:
+		http.Error(w, "Ошибка получения информации о платеже: "+err.Error(), errorStatus(err))
+		return false
+	}
+	return pc.authorize(w, r, stored.OrderID)
+}
+
 // CreatePayment обрабатывает запрос на создание нового платежа
 func (pc *PaymentController) CreatePayment(w http.ResponseWriter, r *http.Request) {
 	// Проверяем метод запроса
@@ -35,10 +87,14 @@ func (pc *PaymentController) CreatePayment(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	if !pc.authorize(w, r, req.OrderID) {
+		return
+	}
+
 	// Создаем платеж
 	response, err := pc.paymentService.CreatePayment(req)
 	if err != nil {
-		http.Error(w, "Ошибка создания платежа: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, "Ошибка создания платежа: "+err.Error(), errorStatus(err))
 		return
 	}
 
@@ -67,10 +123,15 @@ func (pc *PaymentController) GetPayment(w http.ResponseWriter, r *http.Request)
 	}
 	paymentID := path[len(path)-1]
 
+	// Доступ проверяется до сверки платежа с провайдером, которая может его изменить
+	if !pc.authorizePayment(w, r, paymentID) {
+		return
+	}
+
 	// Получаем информацию о платеже
 	response, err := pc.paymentService.GetPayment(paymentID)
 	if err != nil {
-		http.Error(w, "Ошибка получения информации о платеже: "+err.Error(), http.StatusNotFound)
+		http.Error(w, "Ошибка получения информации о платеже: "+err.Error(), errorStatus(err))
 		return
 	}
 
@@ -98,10 +159,15 @@ func (pc *PaymentController) CancelPayment(w http.ResponseWriter, r *http.Reques
 	}
 	paymentID := path[len(path)-2]
 
+	// Клиент отменяет только платежи за свои посылки
+	if !pc.authorizePayment(w, r, paymentID) {
+		return
+	}
+
 	// Отменяем платеж
 	response, err := pc.paymentService.CancelPayment(paymentID)
 	if err != nil {
-		http.Error(w, "Ошибка отмены платежа: "+err.Error(), http.StatusBadRequest)
+		http.Error(w, "Ошибка отмены платежа: "+err.Error(), errorStatus(err))
 		return
 	}
 
@@ -141,7 +207,7 @@ func (pc *PaymentController) RefundPayment(w http.ResponseWriter, r *http.Reques
 	// Возвращаем платеж
 	response, err := pc.paymentService.RefundPayment(paymentID, req.Amount)
 	if err != nil {
-		http.Error(w, "Ошибка возврата платежа: "+err.Error(), http.StatusBadRequest)
+		http.Error(w, "Ошибка возврата платежа: "+err.Error(), errorStatus(err))
 		return
 	}
 
@@ -153,27 +219,13 @@ func (pc *PaymentController) RefundPayment(w http.ResponseWriter, r *http.Reques
 	}
 }
 
-// HandlePayment обрабатывает все запросы, связанные с платежами
-func (pc *PaymentController) HandlePayment(w http.ResponseWriter, r *http.Request) {
-	path := r.URL.Path
-
-	// Маршрутизация запросов
+// errorStatus возвращает HTTP-статус ошибки платежного сервиса
+func errorStatus(err error) int {
 	switch {
-	case strings.HasSuffix(path, "/payments") && r.Method == http.MethodPost:
-		pc.CreatePayment(w, r)
-	case strings.HasSuffix(path, "/cancel") && r.Method == http.MethodPost:
-		pc.CancelPayment(w, r)
-	case strings.HasSuffix(path, "/refund") && r.Method == http.MethodPost:
-		pc.RefundPayment(w, r)
-	case strings.Contains(path, "/payments/") && r.Method == http.MethodGet:
-		pc.GetPayment(w, r)
-	default:
-		http.Error(w, "Неверный URL или метод", http.StatusNotFound)
+	case errors.Is(err, payment.ErrPaymentNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, payment.ErrInvalidPayment):
+		return http.StatusBadRequest
 	}
-}
-
-// RegisterRoutes регистрирует маршруты для платежного контроллера
-func (pc *PaymentController) RegisterRoutes() {
-	http.HandleFunc("/api/v1/payments", pc.CreatePayment)
-	http.HandleFunc("/api/v1/payments/", pc.HandlePayment)
+	return http.StatusInternalServerError
 }
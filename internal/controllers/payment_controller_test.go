@@ -2,8 +2,13 @@ package controllers
 
 import (
 	"bytes"
+	"context"
+	"delivery/internal/api"
+	"delivery/internal/business/models"
 	"delivery/internal/business/payment"
+	"delivery/internal/middleware"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -13,6 +18,7 @@ import (
 type MockPaymentService struct {
 	CreatePaymentFunc func(request payment.PaymentRequest) (*payment.PaymentResponse, error)
 	GetPaymentFunc    func(paymentID string) (*payment.PaymentResponse, error)
+	StoredPaymentFunc func(paymentID string) (*payment.PaymentResponse, error)
 	CancelPaymentFunc func(paymentID string) (*payment.PaymentResponse, error)
 	RefundPaymentFunc func(paymentID string, amount float64) (*payment.PaymentResponse, error)
 	ChargeFunc        func(amount float64, currency string) (string, error)
@@ -26,6 +32,10 @@ func (m *MockPaymentService) GetPayment(paymentID string) (*payment.PaymentRespo
 	return m.GetPaymentFunc(paymentID)
 }
 
+func (m *MockPaymentService) StoredPayment(paymentID string) (*payment.PaymentResponse, error) {
+	return m.StoredPaymentFunc(paymentID)
+}
+
 func (m *MockPaymentService) CancelPayment(paymentID string) (*payment.PaymentResponse, error) {
 	return m.CancelPaymentFunc(paymentID)
 }
@@ -275,3 +285,106 @@ func TestRefundPaymentHandler(t *testing.T) {
 		t.Errorf("handler returned unexpected status: got %v want %v", response.Status, payment.StatusRefunded)
 	}
 }
+
+// Встраивание интерфейсов позволяет переопределить только нужные в тесте методы
+
+// ownedCustomers - профиль клиента пользователя userID имеет ID userID*10
+type ownedCustomers struct {
+	api.CustomerService
+}
+
+func (ownedCustomers) GetByUserID(userID int) (*models.Customer, error) {
+	return &models.Customer{ID: userID * 10}, nil
+}
+
+// ownedParcels - посылка 7 принадлежит клиенту 10
+type ownedParcels struct {
+	api.ParcelService
+}
+
+func (ownedParcels) Get(id int) (*models.Parcel, error) {
+	if id != 7 {
+		return nil, errors.New("parcel not found")
+	}
+	return &models.Parcel{ID: 7, ClientID: 10}, nil
+}
+
+func TestPaymentController_Ownership(t *testing.T) {
+	ownership := api.NewOwnership(ownedCustomers{}, nil, ownedParcels{}, nil)
+
+	// Платеж pay_7 - оплата доставки посылки 7 клиента 10 (пользователь 1)
+	var cancelled, synced []string
+	stored := func(paymentID string) (*payment.PaymentResponse, error) {
+		switch paymentID {
+		case "pay_7":
+			return &payment.PaymentResponse{PaymentID: paymentID, OrderID: payment.ParcelOrderID(7), Status: payment.StatusPending}, nil
+		case "pay_other":
+			return &payment.PaymentResponse{PaymentID: paymentID, OrderID: "order_123", Status: payment.StatusPending}, nil
+		}
+		return nil, payment.ErrPaymentNotFound
+	}
+	service := &MockPaymentService{
+		CreatePaymentFunc: func(request payment.PaymentRequest) (*payment.PaymentResponse, error) {
+			return &payment.PaymentResponse{PaymentID: "pay_new", OrderID: request.OrderID, Status: payment.StatusPending}, nil
+		},
+		// GetPayment сверяет платеж с провайдером и может его изменить
+		GetPaymentFunc: func(paymentID string) (*payment.PaymentResponse, error) {
+			synced = append(synced, paymentID)
+			return stored(paymentID)
+		},
+		StoredPaymentFunc: stored,
+		CancelPaymentFunc: func(paymentID string) (*payment.PaymentResponse, error) {
+			cancelled = append(cancelled, paymentID)
+			return &payment.PaymentResponse{PaymentID: paymentID, Status: payment.StatusFailed}, nil
+		},
+	}
+	controller := NewPaymentController(service).WithOwnership(ownership)
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		body    string
+		userID  int
+		role    string
+		handler http.HandlerFunc
+		want    int
+	}{
+		{"Оплата своей посылки", "POST", "/payments", `{"order_id": "parcel_7", "amount": 100}`, 1, models.UserRoleClient, controller.CreatePayment, http.StatusCreated},
+		{"Оплата чужой посылки", "POST", "/payments", `{"order_id": "parcel_7", "amount": 100}`, 2, models.UserRoleClient, controller.CreatePayment, http.StatusForbidden},
+		{"Оплата неизвестной посылки", "POST", "/payments", `{"order_id": "parcel_8", "amount": 100}`, 1, models.UserRoleClient, controller.CreatePayment, http.StatusForbidden},
+		{"Заказ не связан с посылкой", "POST", "/payments", `{"order_id": "order_123", "amount": 100}`, 1, models.UserRoleClient, controller.CreatePayment, http.StatusForbidden},
+		{"Диспетчер оплачивает любой заказ", "POST", "/payments", `{"order_id": "order_123", "amount": 100}`, 5, models.UserRoleDispatcher, controller.CreatePayment, http.StatusCreated},
+		{"Свой платеж", "GET", "/payments/pay_7", "", 1, models.UserRoleClient, controller.GetPayment, http.StatusOK},
+		{"Чужой платеж", "GET", "/payments/pay_7", "", 2, models.UserRoleClient, controller.GetPayment, http.StatusForbidden},
+		{"Платеж не связан с посылкой", "GET", "/payments/pay_other", "", 1, models.UserRoleClient, controller.GetPayment, http.StatusForbidden},
+		// Клиент не отличает несуществующий платеж от чужого
+		{"Платеж не найден", "GET", "/payments/pay_404", "", 1, models.UserRoleClient, controller.GetPayment, http.StatusForbidden},
+		{"Диспетчер запрашивает несуществующий платеж", "GET", "/payments/pay_404", "", 5, models.UserRoleDispatcher, controller.GetPayment, http.StatusNotFound},
+		{"Отмена несуществующего платежа", "POST", "/payments/pay_404/cancel", "", 1, models.UserRoleClient, controller.CancelPayment, http.StatusForbidden},
+		{"Отмена чужого платежа", "POST", "/payments/pay_7/cancel", "", 2, models.UserRoleClient, controller.CancelPayment, http.StatusForbidden},
+		{"Отмена своего платежа", "POST", "/payments/pay_7/cancel", "", 1, models.UserRoleClient, controller.CancelPayment, http.StatusOK},
+		{"Диспетчер отменяет любой платеж", "POST", "/payments/pay_other/cancel", "", 5, models.UserRoleDispatcher, controller.CancelPayment, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+			ctx := context.WithValue(req.Context(), middleware.UserIDKey, tt.userID)
+			req = req.WithContext(context.WithValue(ctx, middleware.UserRoleKey, tt.role))
+			rr := httptest.NewRecorder()
+			tt.handler(rr, req)
+			if rr.Code != tt.want {
+				t.Errorf("%s %s: got %v want %v", tt.method, tt.path, rr.Code, tt.want)
+			}
+		})
+	}
+
+	// Чужой платеж не отменяется
+	if len(cancelled) != 2 || cancelled[0] != "pay_7" || cancelled[1] != "pay_other" {
+		t.Errorf("отменены платежи %v", cancelled)
+	}
+	// С провайдером сверяются только платежи, доступ к которым проверен
+	if len(synced) != 2 || synced[0] != "pay_7" || synced[1] != "pay_404" {
+		t.Errorf("сверены платежи %v", synced)
+	}
+}
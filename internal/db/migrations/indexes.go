@@ -61,6 +61,20 @@ func InitIndexes(db *sql.DB) (*IndexResult, error) {
 	}
 	result.IndicesCreated += deliveryResult
 
+	// Индексы для таблицы outbox
+	outboxResult, err := createOutboxIndexes(db)
+	if err != nil {
+		return result, err
+	}
+	result.IndicesCreated += outboxResult
+
+	// Индексы для таблицы payments
+	paymentResult, err := createPaymentIndexes(db)
+	if err != nil {
+		return result, err
+	}
+	result.IndicesCreated += paymentResult
+
 	result.ExecutionTime = time.Since(startTime)
 	log.Printf("Индексы успешно созданы: %d индексов, время выполнения: %v", result.IndicesCreated, result.ExecutionTime)
 	return result, nil
@@ -86,36 +100,88 @@ func createRefreshTokensIndexes(db *sql.DB) (int, error) {
 
 // createCustomerIndexes создает индексы для таблицы customer
 func createCustomerIndexes(db *sql.DB) (int, error) {
-	query := `CREATE INDEX IF NOT EXISTS idx_customer_email ON customer(email);`
-	if _, err := db.Exec(query); err != nil {
-		return 0, err
+	queries := []string{
+		`CREATE INDEX IF NOT EXISTS idx_customer_email ON customer(email);`,
+		`CREATE INDEX IF NOT EXISTS idx_customer_user_id ON customer(user_id);`,
 	}
-	return 1, nil
+	for _, query := range queries {
+		if _, err := db.Exec(query); err != nil {
+			return 0, err
+		}
+	}
+	return len(queries), nil
 }
 
-// createCourierIndexes создает индексы для таблицы courier
+// createCourierIndexes создает индексы для таблиц courier, courier_locations и courier_zones
 func createCourierIndexes(db *sql.DB) (int, error) {
-	query := `CREATE INDEX IF NOT EXISTS idx_courier_email ON courier(email);`
-	if _, err := db.Exec(query); err != nil {
-		return 0, err
+	queries := []string{
+		`CREATE INDEX IF NOT EXISTS idx_courier_email ON courier(email);`,
+		`CREATE INDEX IF NOT EXISTS idx_courier_user_id ON courier(user_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_courier_locations_courier_id ON courier_locations(courier_id, recorded_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_courier_zones_zone_id ON courier_zones(zone_id);`,
 	}
-	return 1, nil
+	for _, query := range queries {
+		if _, err := db.Exec(query); err != nil {
+			return 0, err
+		}
+	}
+	return len(queries), nil
 }
 
-// createParcelIndexes создает индексы для таблицы parcel
+// createParcelIndexes создает индексы для таблицы parcels
 func createParcelIndexes(db *sql.DB) (int, error) {
-	query := `CREATE INDEX IF NOT EXISTS idx_parcel_client ON parcel(client);`
-	if _, err := db.Exec(query); err != nil {
-		return 0, err
+	queries := []string{
+		`CREATE INDEX IF NOT EXISTS idx_parcels_client_id ON parcels(client_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_parcels_zone_id ON parcels(zone_id);`,
 	}
-	return 1, nil
+	for _, query := range queries {
+		if _, err := db.Exec(query); err != nil {
+			return 0, err
+		}
+	}
+	return len(queries), nil
 }
 
-// createDeliveryIndexes создает индексы для таблицы delivery
+// createDeliveryIndexes создает индексы для таблиц delivery и delivery_events
 func createDeliveryIndexes(db *sql.DB) (int, error) {
-	query := `CREATE INDEX IF NOT EXISTS idx_delivery_courier_id ON delivery(courier_id);`
-	if _, err := db.Exec(query); err != nil {
-		return 0, err
+	queries := []string{
+		`CREATE INDEX IF NOT EXISTS idx_delivery_courier_id ON delivery(courier_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_delivery_events_delivery_id ON delivery_events(delivery_id, created_at);`,
 	}
-	return 1, nil
+	for _, query := range queries {
+		if _, err := db.Exec(query); err != nil {
+			return 0, err
+		}
+	}
+	return len(queries), nil
+}
+
+// createOutboxIndexes создает индексы для таблицы outbox: неопубликованные события
+// выбираются в порядке записи, а опубликованные удаляются по времени публикации
+func createOutboxIndexes(db *sql.DB) (int, error) {
+	queries := []string{
+		`CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox(id) WHERE sent_at IS NULL;`,
+		`CREATE INDEX IF NOT EXISTS idx_outbox_sent_at ON outbox(sent_at) WHERE sent_at IS NOT NULL;`,
+	}
+	for _, query := range queries {
+		if _, err := db.Exec(query); err != nil {
+			return 0, err
+		}
+	}
+	return len(queries), nil
+}
+
+// createPaymentIndexes создает индексы для таблицы payments: платежи выбираются по заказу
+// при завершении доставки и по посылке
+func createPaymentIndexes(db *sql.DB) (int, error) {
+	queries := []string{
+		`CREATE INDEX IF NOT EXISTS idx_payments_order_id ON payments(order_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_payments_parcel_id ON payments(parcel_id);`,
+	}
+	for _, query := range queries {
+		if _, err := db.Exec(query); err != nil {
+			return 0, err
+		}
+	}
+	return len(queries), nil
 }
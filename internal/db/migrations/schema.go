@@ -2,51 +2,98 @@ package db
 
 import (
 	"database/sql"
+	"fmt"
 	"log"
 )
 
 // Создание таблиц в базе данных, если их нет
 func InitSchema(db *sql.DB, dbType string) error {
 	schema := `
+	CREATE TABLE IF NOT EXISTS users (
+		id SERIAL PRIMARY KEY,
+		email TEXT UNIQUE NOT NULL,
+		password TEXT NOT NULL,
+		role TEXT NOT NULL DEFAULT 'client',
+		created_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL
+	);
 	CREATE TABLE IF NOT EXISTS customer (
 		id SERIAL PRIMARY KEY,
+		user_id INTEGER UNIQUE,
 		name TEXT NOT NULL,
 		email TEXT UNIQUE NOT NULL,
-		phone TEXT NOT NULL
+		phone TEXT NOT NULL,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL
 	);
 	CREATE TABLE IF NOT EXISTS courier (
 		id SERIAL PRIMARY KEY,
+		user_id INTEGER UNIQUE,
 		name TEXT NOT NULL,
 		phone TEXT NOT NULL,
 		email TEXT UNIQUE NOT NULL,
 		vehicle_id TEXT,
-		status TEXT NOT NULL
+		vehicle_type TEXT,
+		status TEXT NOT NULL,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL
 	);
-	CREATE TABLE IF NOT EXISTS parcel (
+	CREATE TABLE IF NOT EXISTS zones (
 		id SERIAL PRIMARY KEY,
-		client INTEGER NOT NULL,
+		name TEXT UNIQUE NOT NULL,
+		geometry JSONB NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS courier_zones (
+		courier_id INTEGER NOT NULL,
+		zone_id INTEGER NOT NULL,
+		PRIMARY KEY (courier_id, zone_id),
+		FOREIGN KEY (courier_id) REFERENCES courier(id) ON DELETE CASCADE,
+		FOREIGN KEY (zone_id) REFERENCES zones(id) ON DELETE CASCADE
+	);
+	CREATE TABLE IF NOT EXISTS parcels (
+		id SERIAL PRIMARY KEY,
+		client_id INTEGER NOT NULL,
 		status TEXT NOT NULL,
 		address TEXT NOT NULL,
-		created_at TIMESTAMP NOT NULL,
-		FOREIGN KEY (client) REFERENCES customer(id) ON DELETE CASCADE
+		created_at TEXT NOT NULL,
+		tracking_number TEXT UNIQUE,
+		street TEXT,
+		city TEXT,
+		postal_code TEXT,
+		country TEXT,
+		lat DOUBLE PRECISION,
+		lon DOUBLE PRECISION,
+		zone_id INTEGER,
+		FOREIGN KEY (client_id) REFERENCES customer(id) ON DELETE CASCADE,
+		FOREIGN KEY (zone_id) REFERENCES zones(id) ON DELETE SET NULL
 	);
 	CREATE TABLE IF NOT EXISTS delivery (
 		id SERIAL PRIMARY KEY,
-		courier_id INTEGER NOT NULL,
+		courier_id INTEGER, -- не заполняется, пока доставка в статусе created
 		parcel_id INTEGER NOT NULL,
 		status TEXT NOT NULL,
 		assigned_at TIMESTAMP NOT NULL,
 		delivered_at TIMESTAMP DEFAULT NULL,
+		eta TIMESTAMP DEFAULT NULL,
 		FOREIGN KEY (courier_id) REFERENCES courier(id) ON DELETE CASCADE,
-		FOREIGN KEY (parcel_id) REFERENCES parcel(id) ON DELETE CASCADE
+		FOREIGN KEY (parcel_id) REFERENCES parcels(id) ON DELETE CASCADE
 	);
-	CREATE TABLE IF NOT EXISTS users (
+	CREATE TABLE IF NOT EXISTS delivery_events (
 		id SERIAL PRIMARY KEY,
-		email TEXT UNIQUE NOT NULL,
-		password TEXT NOT NULL,
-		role TEXT NOT NULL DEFAULT 'client',
-		created_at TIMESTAMP NOT NULL,
-		updated_at TIMESTAMP NOT NULL
+		delivery_id INTEGER NOT NULL, -- без внешнего ключа: журнал сохраняется после удаления доставки
+		old_status TEXT,
+		new_status TEXT NOT NULL,
+		actor_id INTEGER,
+		note TEXT,
+		created_at TIMESTAMP NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS courier_locations (
+		id SERIAL PRIMARY KEY,
+		courier_id INTEGER NOT NULL,
+		lat DOUBLE PRECISION NOT NULL,
+		lon DOUBLE PRECISION NOT NULL,
+		accuracy DOUBLE PRECISION,
+		heading DOUBLE PRECISION,
+		recorded_at TIMESTAMP NOT NULL,
+		FOREIGN KEY (courier_id) REFERENCES courier(id) ON DELETE CASCADE
 	);
 	CREATE TABLE IF NOT EXISTS refresh_tokens (
 		id SERIAL PRIMARY KEY,
@@ -56,6 +103,41 @@ func InitSchema(db *sql.DB, dbType string) error {
 		created_at TIMESTAMP NOT NULL,
 		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
 	);
+	CREATE TABLE IF NOT EXISTS outbox (
+		id BIGSERIAL PRIMARY KEY,
+		topic TEXT NOT NULL,
+		event_key TEXT NOT NULL,
+		event_type TEXT NOT NULL,
+		event_version INTEGER NOT NULL DEFAULT 1,
+		payload JSONB NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		sent_at TIMESTAMP DEFAULT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT,
+		correlation_id TEXT
+	);
+	CREATE TABLE IF NOT EXISTS payments (
+		id TEXT PRIMARY KEY,
+		order_id TEXT NOT NULL,
+		parcel_id INTEGER, -- посылка заказа parcel_<id>; у других заказов не заполняется
+		amount NUMERIC(12, 2) NOT NULL,
+		currency TEXT NOT NULL,
+		status TEXT NOT NULL,
+		method TEXT NOT NULL,
+		description TEXT,
+		customer_name TEXT,
+		customer_email TEXT,
+		customer_phone TEXT,
+		created_at TIMESTAMP NOT NULL,
+		completed_at TIMESTAMP DEFAULT NULL,
+		redirect_url TEXT,
+		receipt_url TEXT,
+		error_message TEXT,
+		provider_payment_id TEXT,
+		capture_requested BOOLEAN NOT NULL DEFAULT FALSE,
+		refunded_amount NUMERIC(12, 2) NOT NULL DEFAULT 0,
+		FOREIGN KEY (parcel_id) REFERENCES parcels(id) ON DELETE SET NULL
+	);
 	`
 
 	if _, err := db.Exec(schema); err != nil {
@@ -63,30 +145,84 @@ func InitSchema(db *sql.DB, dbType string) error {
 		return err
 	}
 
-	// Проверяем, существует ли колонка role в таблице users
+	// Добавляем колонки, появившиеся после создания таблиц
+	columns := []struct {
+		table, column, definition string
+	}{
+		{"users", "role", "TEXT NOT NULL DEFAULT 'client'"},
+		{"customer", "user_id", "INTEGER UNIQUE REFERENCES users(id) ON DELETE SET NULL"},
+		{"courier", "user_id", "INTEGER UNIQUE REFERENCES users(id) ON DELETE SET NULL"},
+		{"courier", "vehicle_type", "TEXT"},
+		{"parcels", "tracking_number", "TEXT UNIQUE"},
+		{"parcels", "street", "TEXT"},
+		{"parcels", "city", "TEXT"},
+		{"parcels", "postal_code", "TEXT"},
+		{"parcels", "country", "TEXT"},
+		{"parcels", "lat", "DOUBLE PRECISION"},
+		{"parcels", "lon", "DOUBLE PRECISION"},
+		{"parcels", "zone_id", "INTEGER REFERENCES zones(id) ON DELETE SET NULL"},
+		{"delivery", "eta", "TIMESTAMP DEFAULT NULL"},
+		{"outbox", "event_version", "INTEGER NOT NULL DEFAULT 1"},
+		{"outbox", "correlation_id", "TEXT"},
+		{"payments", "provider_payment_id", "TEXT"},
+		{"payments", "capture_requested", "BOOLEAN NOT NULL DEFAULT FALSE"},
+		{"payments", "refunded_amount", "NUMERIC(12, 2) NOT NULL DEFAULT 0"},
+	}
+	for _, c := range columns {
+		if err := addColumnIfNotExists(db, c.table, c.column, c.definition); err != nil {
+			return err
+		}
+	}
+
+	// Снимаем NOT NULL с колонок, которые стали необязательными после создания таблиц
+	nullable := []struct {
+		table, column string
+	}{
+		{"delivery", "courier_id"}, // Доставка в статусе created создается без курьера
+	}
+	for _, c := range nullable {
+		if err := dropNotNull(db, c.table, c.column); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addColumnIfNotExists добавляет колонку в таблицу, если ее еще нет
+func addColumnIfNotExists(db *sql.DB, table, column, definition string) error {
 	var exists bool
 	err := db.QueryRow(`
 		SELECT EXISTS (
 			SELECT 1 
 			FROM information_schema.columns 
-			WHERE table_name = 'users' AND column_name = 'role'
+			WHERE table_name = $1 AND column_name = $2
 		)
-	`).Scan(&exists)
+	`, table, column).Scan(&exists)
 
 	if err != nil {
-		log.Printf("Ошибка при проверке существования колонки role: %v", err)
+		log.Printf("Ошибка при проверке существования колонки %s: %v", column, err)
 		return err
 	}
 
-	// Если колонка role не существует, добавляем ее
-	if !exists {
-		_, err := db.Exec(`ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'client'`)
-		if err != nil {
-			log.Printf("Ошибка при добавлении колонки role: %v", err)
-			return err
-		}
-		log.Println("Колонка role успешно добавлена в таблицу users")
+	if exists {
+		return nil
 	}
 
+	if _, err := db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, definition)); err != nil {
+		log.Printf("Ошибка при добавлении колонки %s: %v", column, err)
+		return err
+	}
+	log.Printf("Колонка %s успешно добавлена в таблицу %s", column, table)
+
+	return nil
+}
+
+// dropNotNull разрешает NULL в колонке. Повторный вызов для необязательной колонки ничего не меняет
+func dropNotNull(db *sql.DB, table, column string) error {
+	if _, err := db.Exec(fmt.Sprintf(`ALTER TABLE %s ALTER COLUMN %s DROP NOT NULL`, table, column)); err != nil {
+		log.Printf("Ошибка при снятии NOT NULL с колонки %s: %v", column, err)
+		return err
+	}
 	return nil
 }
@@ -16,7 +16,7 @@ func OptimizeDatabase(db *sql.DB) error {
 	}
 
 	// Выполняем VACUUM ANALYZE для всех таблиц
-	tables := []string{"users", "refresh_tokens", "customer", "courier", "parcel", "delivery"}
+	tables := []string{"users", "refresh_tokens", "customer", "courier", "parcels", "delivery"}
 	for _, table := range tables {
 		_, err := db.Exec("VACUUM ANALYZE " + table)
 		if err != nil {
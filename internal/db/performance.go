@@ -247,9 +247,9 @@ func CheckDatabasePerformanceDetailed(db *sql.DB) error {
 			"SELECT * FROM refresh_tokens WHERE token = 'test-token'",
 			"SELECT * FROM customer WHERE email = 'customer@example.com'",
 			"SELECT * FROM courier WHERE status = 'active'",
-			"SELECT * FROM parcel WHERE status = 'pending'",
+			"SELECT * FROM parcels WHERE status = 'pending'",
 			"SELECT * FROM delivery WHERE courier_id = 1",
-			"SELECT d.* FROM delivery d JOIN parcel p ON d.parcel_id = p.id WHERE p.status = 'delivered'",
+			"SELECT d.* FROM delivery d JOIN parcels p ON d.parcel_id = p.id WHERE p.status = 'delivered'",
 		}
 	}
 
@@ -0,0 +1,30 @@
+package events
+
+import "delivery/internal/business/models"
+
+// Domain - реестр доменных событий сервиса. При несовместимом изменении данных события
+// регистрируется его новая версия и преобразование из предыдущей, чтобы получатели
+// могли обработать события, опубликованные до изменения
+var Domain = NewRegistry().
+	Register(Schema{Type: models.EventParcelRegistered, Version: 1, Topic: models.TopicParcels,
+		Description: "Посылка зарегистрирована", Payload: models.ParcelRegisteredEvent{}}).
+	Register(Schema{Type: models.EventParcelStatusChanged, Version: 1, Topic: models.TopicParcels,
+		Description: "Изменился статус посылки", Payload: models.ParcelStatusChangedEvent{}}).
+	Register(Schema{Type: models.EventDeliveryCreated, Version: 1, Topic: models.TopicDeliveries,
+		Description: "Создана доставка посылки, курьер еще не назначен", Payload: models.DeliveryCreatedEvent{}}).
+	Register(Schema{Type: models.EventDeliveryAssigned, Version: 1, Topic: models.TopicDeliveries,
+		Description: "На доставку назначен курьер", Payload: models.DeliveryAssignedEvent{}}).
+	Register(Schema{Type: models.EventDeliveryStatusChanged, Version: 1, Topic: models.TopicDeliveries,
+		Description: "Изменился статус доставки, кроме назначения и завершения", Payload: models.DeliveryStatusChangedEvent{}}).
+	Register(Schema{Type: models.EventDeliveryCompleted, Version: 1, Topic: models.TopicDeliveries,
+		Description: "Посылка доставлена", Payload: models.DeliveryCompletedEvent{}}).
+	Register(Schema{Type: models.EventDeliveryDeleted, Version: 1, Topic: models.TopicDeliveries,
+		Description: "Доставка удалена", Payload: models.DeliveryDeletedEvent{}}).
+	Register(Schema{Type: models.EventCourierRegistered, Version: 1, Topic: models.TopicCouriers,
+		Description: "Добавлен курьер", Payload: models.CourierRegisteredEvent{}}).
+	Register(Schema{Type: models.EventCourierStatusChanged, Version: 1, Topic: models.TopicCouriers,
+		Description: "Курьер изменил статус", Payload: models.CourierStatusChangedEvent{}}).
+	Register(Schema{Type: models.EventCustomerRegistered, Version: 1, Topic: models.TopicCustomers,
+		Description: "Добавлен клиент", Payload: models.CustomerRegisteredEvent{}}).
+	Register(Schema{Type: models.EventCustomerUpdated, Version: 1, Topic: models.TopicCustomers,
+		Description: "Изменились контактные данные клиента", Payload: models.CustomerUpdatedEvent{}})
@@ -0,0 +1,93 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotEnvelope - сообщение не является конвертом события, например опубликовано до появления конверта
+var ErrNotEnvelope = errors.New("сообщение не является конвертом события")
+
+// Envelope - конверт доменного события: значение сообщения Kafka. Данные события передаются
+// в поле payload, их формат определяется типом и версией события в реестре
+type Envelope struct {
+	ID            string          `json:"id"`                       // Номер события, по которому получатели пропускают повторы
+	Type          string          `json:"type"`                     // Тип события, например DeliveryCompleted
+	Version       int             `json:"version"`                  // Версия формата данных события
+	OccurredAt    time.Time       `json:"occurred_at"`              // Время изменения, о котором сообщает событие
+	AggregateID   string          `json:"aggregate_id"`             // ID посылки, доставки, курьера или клиента; ключ сообщения
+	CorrelationID string          `json:"correlation_id,omitempty"` // Номер события, с которого началась цепочка; у первого события совпадает с id
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// Encode кодирует конверт в JSON
+func (e Envelope) Encode() ([]byte, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при кодировании события %s: %w", e.Type, err)
+	}
+	return data, nil
+}
+
+// Decode разбирает конверт события. Возвращает ErrNotEnvelope, если в данных нет типа,
+// версии или данных события
+func Decode(data []byte) (Envelope, error) {
+	// Сначала проверяются только поля конверта: в данных события без конверта поля
+	// с теми же именами могут быть другого типа
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return Envelope{}, fmt.Errorf("ошибка при декодировании конверта события: %w", err)
+	}
+	for _, name := range []string{"type", "version", "payload"} {
+		if _, ok := fields[name]; !ok {
+			return Envelope{}, ErrNotEnvelope
+		}
+	}
+
+	var e Envelope
+	if err := json.Unmarshal(data, &e); err != nil {
+		return Envelope{}, fmt.Errorf("ошибка при декодировании конверта события: %w", err)
+	}
+	if e.Type == "" || e.Version <= 0 {
+		return Envelope{}, fmt.Errorf("ошибка при декодировании конверта события: не указаны тип или версия")
+	}
+	return e, nil
+}
+
+// DecodePayload декодирует данные события в v
+func (e Envelope) DecodePayload(v interface{}) error {
+	if err := json.Unmarshal(e.Payload, v); err != nil {
+		return fmt.Errorf("ошибка при декодировании события %s версии %d: %w", e.Type, e.Version, err)
+	}
+	return nil
+}
+
+type envelopeKey struct{}
+
+// WithEnvelope добавляет в контекст конверт обрабатываемого события
+func WithEnvelope(ctx context.Context, e Envelope) context.Context {
+	return context.WithValue(ctx, envelopeKey{}, e)
+}
+
+// FromContext возвращает конверт обрабатываемого события, например чтобы передать
+// CorrelationID дальше по цепочке
+func FromContext(ctx context.Context) (Envelope, bool) {
+	e, ok := ctx.Value(envelopeKey{}).(Envelope)
+	return e, ok
+}
+
+// CorrelationID возвращает цепочку обрабатываемого события: его CorrelationID, а у события
+// без него - его номер. Если событие не обрабатывается, возвращает пустую строку
+func CorrelationID(ctx context.Context) string {
+	e, ok := FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	if e.CorrelationID != "" {
+		return e.CorrelationID
+	}
+	return e.ID
+}
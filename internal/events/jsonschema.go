@@ -0,0 +1,107 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// jsonSchemaDraft - версия спецификации JSON Schema
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// JSONSchema возвращает JSON Schema сообщения с событием eventType версии version:
+// конверта, в поле payload которого данные события этой версии
+func (r *Registry) JSONSchema(eventType string, version int) (map[string]interface{}, error) {
+	s, ok := r.Schema(eventType, version)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s версии %d", ErrUnknownType, eventType, version)
+	}
+
+	schema := typeSchema(reflect.TypeOf(Envelope{}))
+	properties := schema["properties"].(map[string]interface{})
+	properties["type"] = map[string]interface{}{"const": s.Type}
+	properties["version"] = map[string]interface{}{"const": s.Version}
+	properties["payload"] = typeSchema(reflect.TypeOf(s.Payload))
+
+	schema["$schema"] = jsonSchemaDraft
+	schema["$id"] = fmt.Sprintf("urn:delivery:events:%s:v%d", s.Type, s.Version)
+	schema["title"] = s.Type
+	schema["x-kafka-topic"] = s.Topic
+	if s.Description != "" {
+		schema["description"] = s.Description
+	}
+	return schema, nil
+}
+
+// typeSchema строит JSON Schema значения Go-типа t так, как его кодирует encoding/json.
+// Поля без omitempty считаются обязательными
+func typeSchema(t reflect.Type) map[string]interface{} {
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case rawMessageType:
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem())
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		return structSchema(t)
+	}
+	// Интерфейсы и прочие типы могут содержать любое значение
+	return map[string]interface{}{}
+}
+
+// structSchema строит JSON Schema структуры по тегам json ее полей
+func structSchema(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = typeSchema(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			required = append(required, name)
+		}
+	}
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
@@ -0,0 +1,79 @@
+package events
+
+import (
+	"delivery/internal/business/models"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_JSONSchema(t *testing.T) {
+	schema, err := Domain.JSONSchema(models.EventDeliveryStatusChanged, 1)
+	require.NoError(t, err)
+
+	data, err := json.Marshal(schema)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$id": "urn:delivery:events:DeliveryStatusChanged:v1",
+		"title": "DeliveryStatusChanged",
+		"description": "Изменился статус доставки, кроме назначения и завершения",
+		"x-kafka-topic": "deliveries",
+		"type": "object",
+		"properties": {
+			"id": {"type": "string"},
+			"type": {"const": "DeliveryStatusChanged"},
+			"version": {"const": 1},
+			"occurred_at": {"type": "string", "format": "date-time"},
+			"aggregate_id": {"type": "string"},
+			"correlation_id": {"type": "string"},
+			"payload": {
+				"type": "object",
+				"properties": {
+					"delivery_id": {"type": "integer"},
+					"parcel_id": {"type": "integer"},
+					"courier_id": {"type": "integer"},
+					"old_status": {"type": "string"},
+					"new_status": {"type": "string"},
+					"changed_at": {"type": "string", "format": "date-time"}
+				},
+				"required": ["delivery_id", "parcel_id", "old_status", "new_status", "changed_at"]
+			}
+		},
+		"required": ["id", "type", "version", "occurred_at", "aggregate_id", "payload"]
+	}`, string(data))
+
+	_, err = Domain.JSONSchema(models.EventDeliveryStatusChanged, 2)
+	assert.ErrorIs(t, err, ErrUnknownType)
+}
+
+func TestTypeSchema(t *testing.T) {
+	type nested struct {
+		Tags     []string        `json:"tags"`
+		Labels   map[string]int  `json:"labels,omitempty"`
+		Score    *float64        `json:"score,omitempty"`
+		Raw      json.RawMessage `json:"raw"`
+		Flag     bool            `json:"flag"`
+		Data     []byte          `json:"data"`
+		Skipped  string          `json:"-"`
+		internal string
+	}
+
+	data, err := json.Marshal(typeSchema(reflect.TypeOf(nested{})))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type": "object",
+		"properties": {
+			"tags": {"type": "array", "items": {"type": "string"}},
+			"labels": {"type": "object", "additionalProperties": {"type": "integer"}},
+			"score": {"type": "number"},
+			"raw": {},
+			"flag": {"type": "boolean"},
+			"data": {"type": "string", "contentEncoding": "base64"}
+		},
+		"required": ["tags", "raw", "flag", "data"]
+	}`, string(data))
+}
@@ -0,0 +1,132 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Ошибки реестра событий
+var (
+	ErrUnknownType        = errors.New("тип события не зарегистрирован")
+	ErrUnsupportedVersion = errors.New("версия события не поддерживается")
+)
+
+// Schema - версия доменного события: топик, в который оно публикуется, и Go-тип данных.
+// Формат данных описывается самим типом: по нему строится JSON Schema
+type Schema struct {
+	Type        string
+	Version     int
+	Topic       string
+	Description string
+	Payload     interface{} // Значение типа данных, например models.DeliveryCompletedEvent{}
+}
+
+// Upcaster преобразует данные события версии N в данные версии N+1
+type Upcaster func(payload json.RawMessage) (json.RawMessage, error)
+
+// Registry - реестр доменных событий: версии каждого типа и преобразования между ними.
+// Реестр заполняется при запуске и после этого только читается
+type Registry struct {
+	schemas   map[string]map[int]Schema
+	upcasters map[string]map[int]Upcaster // Тип -> версия, из которой преобразует Upcaster
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		schemas:   make(map[string]map[int]Schema),
+		upcasters: make(map[string]map[int]Upcaster),
+	}
+}
+
+// Register добавляет версию события. Паникует при повторной регистрации версии или если
+// данные события - не структура: реестр описывается в коде, и ошибка в нем - ошибка программы
+func (r *Registry) Register(s Schema) *Registry {
+	if s.Type == "" || s.Version <= 0 {
+		panic(fmt.Sprintf("events: некорректная версия %d события %q", s.Version, s.Type))
+	}
+	if t := reflect.TypeOf(s.Payload); t == nil || t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("events: данные события %s версии %d должны быть структурой", s.Type, s.Version))
+	}
+	if _, exists := r.schemas[s.Type][s.Version]; exists {
+		panic(fmt.Sprintf("events: событие %s версии %d уже зарегистрировано", s.Type, s.Version))
+	}
+
+	if r.schemas[s.Type] == nil {
+		r.schemas[s.Type] = make(map[int]Schema)
+	}
+	r.schemas[s.Type][s.Version] = s
+	return r
+}
+
+// RegisterUpcaster добавляет преобразование данных события eventType из версии from в from+1
+func (r *Registry) RegisterUpcaster(eventType string, from int, up Upcaster) *Registry {
+	if r.upcasters[eventType] == nil {
+		r.upcasters[eventType] = make(map[int]Upcaster)
+	}
+	r.upcasters[eventType][from] = up
+	return r
+}
+
+// Current возвращает текущую версию события - ту, в которой оно публикуется
+func (r *Registry) Current(eventType string) (Schema, bool) {
+	var current Schema
+	for version, s := range r.schemas[eventType] {
+		if version > current.Version {
+			current = s
+		}
+	}
+	return current, current.Version > 0
+}
+
+// Schema возвращает версию version события eventType
+func (r *Registry) Schema(eventType string, version int) (Schema, bool) {
+	s, ok := r.schemas[eventType][version]
+	return s, ok
+}
+
+// Schemas возвращает все версии всех событий, упорядоченные по типу и версии
+func (r *Registry) Schemas() []Schema {
+	var schemas []Schema
+	for _, versions := range r.schemas {
+		for _, s := range versions {
+			schemas = append(schemas, s)
+		}
+	}
+	sort.Slice(schemas, func(i, j int) bool {
+		if schemas[i].Type != schemas[j].Type {
+			return schemas[i].Type < schemas[j].Type
+		}
+		return schemas[i].Version < schemas[j].Version
+	})
+	return schemas
+}
+
+// Upcast приводит событие к текущей версии, последовательно применяя преобразования.
+// Событие более новой версии, чем известна реестру, не поддерживается: его опубликовал
+// обновленный экземпляр, и обработать его сможет только обновленный получатель
+func (r *Registry) Upcast(e Envelope) (Envelope, error) {
+	current, ok := r.Current(e.Type)
+	if !ok {
+		return e, fmt.Errorf("%w: %s", ErrUnknownType, e.Type)
+	}
+	if e.Version > current.Version {
+		return e, fmt.Errorf("%w: %s версии %d, последняя известная версия %d", ErrUnsupportedVersion, e.Type, e.Version, current.Version)
+	}
+
+	for e.Version < current.Version {
+		up, ok := r.upcasters[e.Type][e.Version]
+		if !ok {
+			return e, fmt.Errorf("%w: нет преобразования %s из версии %d", ErrUnsupportedVersion, e.Type, e.Version)
+		}
+		payload, err := up(e.Payload)
+		if err != nil {
+			return e, fmt.Errorf("ошибка при преобразовании %s из версии %d: %w", e.Type, e.Version, err)
+		}
+		e.Payload = payload
+		e.Version++
+	}
+	return e, nil
+}
@@ -0,0 +1,150 @@
+package events
+
+import (
+	"delivery/internal/business/models"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type statusV1 struct {
+	Status string `json:"status"`
+}
+
+type statusV2 struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+type statusV3 struct {
+	NewStatus string `json:"new_status"`
+	Reason    string `json:"reason"`
+}
+
+// newStatusRegistry возвращает реестр с тремя версиями события StatusChanged
+func newStatusRegistry() *Registry {
+	return NewRegistry().
+		Register(Schema{Type: "StatusChanged", Version: 1, Topic: "statuses", Payload: statusV1{}}).
+		Register(Schema{Type: "StatusChanged", Version: 2, Topic: "statuses", Payload: statusV2{}}).
+		Register(Schema{Type: "StatusChanged", Version: 3, Topic: "statuses", Payload: statusV3{}}).
+		RegisterUpcaster("StatusChanged", 1, func(payload json.RawMessage) (json.RawMessage, error) {
+			var v1 statusV1
+			if err := json.Unmarshal(payload, &v1); err != nil {
+				return nil, err
+			}
+			return json.Marshal(statusV2{Status: v1.Status, Reason: "unknown"})
+		}).
+		RegisterUpcaster("StatusChanged", 2, func(payload json.RawMessage) (json.RawMessage, error) {
+			var v2 statusV2
+			if err := json.Unmarshal(payload, &v2); err != nil {
+				return nil, err
+			}
+			return json.Marshal(statusV3{NewStatus: v2.Status, Reason: v2.Reason})
+		})
+}
+
+func TestRegistry_Upcast(t *testing.T) {
+	registry := newStatusRegistry()
+
+	current, ok := registry.Current("StatusChanged")
+	require.True(t, ok)
+	assert.Equal(t, 3, current.Version)
+
+	t.Run("Старая версия приводится к текущей", func(t *testing.T) {
+		e, err := registry.Upcast(Envelope{Type: "StatusChanged", Version: 1, Payload: json.RawMessage(`{"status":"delivered"}`)})
+		require.NoError(t, err)
+		assert.Equal(t, 3, e.Version)
+		assert.JSONEq(t, `{"new_status":"delivered","reason":"unknown"}`, string(e.Payload))
+	})
+
+	t.Run("Текущая версия не меняется", func(t *testing.T) {
+		payload := json.RawMessage(`{"new_status":"delivered","reason":"ok"}`)
+		e, err := registry.Upcast(Envelope{Type: "StatusChanged", Version: 3, Payload: payload})
+		require.NoError(t, err)
+		assert.Equal(t, payload, e.Payload)
+	})
+
+	t.Run("Версия новее известной", func(t *testing.T) {
+		_, err := registry.Upcast(Envelope{Type: "StatusChanged", Version: 4, Payload: json.RawMessage(`{}`)})
+		assert.ErrorIs(t, err, ErrUnsupportedVersion)
+	})
+
+	t.Run("Нет преобразования", func(t *testing.T) {
+		registry := NewRegistry().
+			Register(Schema{Type: "StatusChanged", Version: 1, Payload: statusV1{}}).
+			Register(Schema{Type: "StatusChanged", Version: 2, Payload: statusV2{}})
+		_, err := registry.Upcast(Envelope{Type: "StatusChanged", Version: 1, Payload: json.RawMessage(`{}`)})
+		assert.ErrorIs(t, err, ErrUnsupportedVersion)
+	})
+
+	t.Run("Неизвестный тип", func(t *testing.T) {
+		_, err := registry.Upcast(Envelope{Type: "ParcelLost", Version: 1})
+		assert.ErrorIs(t, err, ErrUnknownType)
+	})
+
+	t.Run("Ошибка преобразования", func(t *testing.T) {
+		_, err := registry.Upcast(Envelope{Type: "StatusChanged", Version: 1, Payload: json.RawMessage(`[]`)})
+		assert.Error(t, err)
+	})
+}
+
+func TestRegistry_RegisterInvalid(t *testing.T) {
+	assert.Panics(t, func() {
+		NewRegistry().Register(Schema{Type: "StatusChanged", Version: 0, Payload: statusV1{}})
+	})
+	assert.Panics(t, func() {
+		NewRegistry().Register(Schema{Type: "StatusChanged", Version: 1, Payload: "status"})
+	})
+	assert.Panics(t, func() {
+		newStatusRegistry().Register(Schema{Type: "StatusChanged", Version: 2, Payload: statusV2{}})
+	})
+}
+
+func TestDomain(t *testing.T) {
+	types := map[string]string{
+		models.EventParcelRegistered:      models.TopicParcels,
+		models.EventParcelStatusChanged:   models.TopicParcels,
+		models.EventDeliveryCreated:       models.TopicDeliveries,
+		models.EventDeliveryAssigned:      models.TopicDeliveries,
+		models.EventDeliveryStatusChanged: models.TopicDeliveries,
+		models.EventDeliveryCompleted:     models.TopicDeliveries,
+		models.EventDeliveryDeleted:       models.TopicDeliveries,
+		models.EventCourierRegistered:     models.TopicCouriers,
+		models.EventCourierStatusChanged:  models.TopicCouriers,
+		models.EventCustomerRegistered:    models.TopicCustomers,
+		models.EventCustomerUpdated:       models.TopicCustomers,
+	}
+	for eventType, topic := range types {
+		s, ok := Domain.Current(eventType)
+		if assert.True(t, ok, eventType) {
+			assert.Equal(t, topic, s.Topic, eventType)
+		}
+	}
+	assert.Len(t, Domain.Schemas(), len(types))
+}
+
+func TestDecode(t *testing.T) {
+	data, err := Envelope{ID: "5", Type: "StatusChanged", Version: 2, AggregateID: "3", Payload: json.RawMessage(`{"status":"sent"}`)}.Encode()
+	require.NoError(t, err)
+
+	e, err := Decode(data)
+	require.NoError(t, err)
+	assert.Equal(t, "5", e.ID)
+	assert.Equal(t, "3", e.AggregateID)
+	var payload statusV2
+	require.NoError(t, e.DecodePayload(&payload))
+	assert.Equal(t, "sent", payload.Status)
+
+	// Данные события без конверта, как в сообщениях, опубликованных до его появления
+	_, err = Decode([]byte(`{"parcel_id":7,"status":"sent"}`))
+	assert.True(t, errors.Is(err, ErrNotEnvelope))
+	_, err = Decode([]byte(`{"id":7,"status":"sent"}`))
+	assert.True(t, errors.Is(err, ErrNotEnvelope))
+
+	_, err = Decode([]byte(`не json`))
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrNotEnvelope))
+}
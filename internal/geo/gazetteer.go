@@ -0,0 +1,142 @@
+package geo
+
+import (
+	"delivery/internal/business/models"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// addressTypes - обозначения типов улиц и населенных пунктов, приведенные к сокращениям.
+// Они могут отсутствовать в запросе и не обязательны для совпадения
+var addressTypes = map[string]string{
+	"улица": "ул", "ул": "ул",
+	"проспект": "пр-т", "просп": "пр-т", "пр-т": "пр-т",
+	"переулок": "пер", "пер": "пер",
+	"площадь": "пл", "пл": "пл",
+	"набережная": "наб", "наб": "наб",
+	"шоссе": "ш", "ш": "ш",
+	"бульвар": "б-р", "б-р": "б-р",
+	"дом": "д", "д": "д",
+	"город": "г", "г": "г",
+}
+
+// gazetteerEntry - адрес справочника с заранее разобранными словами
+type gazetteerEntry struct {
+	address    models.Address
+	required   []string // Слова улицы и города, которые должны быть в запросе
+	optional   []string // Типы улиц и домов: повышают точность совпадения, но не обязательны
+	postalCode string
+}
+
+// Gazetteer - офлайн-геокодер по локальному справочнику адресов.
+// Результат зависит только от справочника и запроса, поэтому подходит для тестов и разработки
+type Gazetteer struct {
+	entries []gazetteerEntry
+}
+
+// NewGazetteer создает геокодер по списку адресов. Адрес без улицы задает центр города
+func NewGazetteer(addresses []models.Address) *Gazetteer {
+	g := &Gazetteer{}
+	for _, a := range addresses {
+		entry := gazetteerEntry{address: a, postalCode: strings.TrimSpace(a.PostalCode)}
+		for _, token := range append(tokenize(a.Street), tokenize(a.City)...) {
+			if _, ok := addressTypes[token]; ok {
+				entry.optional = append(entry.optional, token)
+			} else {
+				entry.required = append(entry.required, token)
+			}
+		}
+		g.entries = append(g.entries, entry)
+	}
+	return g
+}
+
+// LoadGazetteer читает справочник из JSON-файла со списком адресов
+func LoadGazetteer(path string) (*Gazetteer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения справочника адресов: %w", err)
+	}
+
+	var addresses []models.Address
+	if err := json.Unmarshal(data, &addresses); err != nil {
+		return nil, fmt.Errorf("ошибка разбора справочника адресов %s: %w", path, err)
+	}
+	for i, a := range addresses {
+		if !(Point{Lat: a.Lat, Lon: a.Lon}).Valid() || a.City == "" {
+			return nil, fmt.Errorf("некорректная запись %d справочника адресов %s", i, path)
+		}
+	}
+
+	return NewGazetteer(addresses), nil
+}
+
+// Geocode находит в справочнике адрес, все слова улицы и города которого есть в запросе.
+// Из нескольких подходящих выбирается адрес с наибольшим числом совпавших слов, при равенстве - первый в справочнике
+func (g *Gazetteer) Geocode(query string) (models.Address, error) {
+	words := make(map[string]bool)
+	for _, token := range tokenize(query) {
+		words[token] = true
+	}
+
+	best, bestScore := -1, 0
+	for i, entry := range g.entries {
+		score := entry.match(words)
+		if score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+
+	if best < 0 {
+		return models.Address{}, fmt.Errorf("%w: %q", models.ErrAddressNotFound, query)
+	}
+	return g.entries[best].address, nil
+}
+
+// match возвращает число совпавших слов или 0, если адрес не подходит к запросу
+func (e gazetteerEntry) match(words map[string]bool) int {
+	score := 0
+	for _, token := range e.required {
+		if !words[token] {
+			return 0
+		}
+		score++
+	}
+	if score == 0 {
+		return 0
+	}
+
+	for _, token := range e.optional {
+		if words[token] {
+			score++
+		}
+	}
+	if e.postalCode != "" && words[e.postalCode] {
+		score++
+	}
+	return score
+}
+
+// tokenize разбивает строку адреса на слова в нижнем регистре, приводя типы улиц к сокращениям
+func tokenize(s string) []string {
+	s = strings.ReplaceAll(strings.ToLower(s), "ё", "е")
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-'
+	})
+
+	tokens := make([]string, 0, len(fields))
+	for _, f := range fields {
+		f = strings.Trim(f, "-")
+		if f == "" {
+			continue
+		}
+		if short, ok := addressTypes[f]; ok {
+			f = short
+		}
+		tokens = append(tokens, f)
+	}
+	return tokens
+}
@@ -0,0 +1,66 @@
+package geo
+
+import (
+	"delivery/internal/business/models"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGazetteer_Geocode(t *testing.T) {
+	gazetteer := NewGazetteer([]models.Address{
+		{Street: "ул. Тверская, 7", City: "Москва", PostalCode: "125009", Lat: 55.7602, Lon: 37.6085},
+		{Street: "ул. Тверская, 17", City: "Москва", PostalCode: "125009", Lat: 55.7650, Lon: 37.6040},
+		{Street: "Ленинский проспект, 30", City: "Москва", PostalCode: "119334", Lat: 55.7071, Lon: 37.5853},
+		{City: "Москва", Lat: 55.7558, Lon: 37.6173},
+		{Street: "ул. Тверская, 7", City: "Тверь", Lat: 56.8587, Lon: 35.9176},
+	})
+
+	tests := []struct {
+		name    string
+		query   string
+		wantLat float64
+	}{
+		{"Полный адрес", "Москва, ул. Тверская, 7", 55.7602},
+		{"Номер дома не путается", "г. Москва, Тверская улица, д. 17", 55.7650},
+		{"Без типа улицы и в другом регистре", "МОСКВА тверская 7", 55.7602},
+		{"Полное название типа улицы", "Москва, Ленинский пр-т, 30", 55.7071},
+		{"Одноименная улица в другом городе", "Тверь, Тверская ул., 7", 56.8587},
+		{"Неизвестная улица - центр города", "Москва, ул. Неизвестная, 1", 55.7558},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address, err := gazetteer.Geocode(tt.query)
+			if err != nil || address.Lat != tt.wantLat {
+				t.Errorf("Geocode(%q) = %+v, %v, want lat %v", tt.query, address, err, tt.wantLat)
+			}
+		})
+	}
+
+	for _, query := range []string{"", "Казань, ул. Баумана, 1"} {
+		if _, err := gazetteer.Geocode(query); !errors.Is(err, models.ErrAddressNotFound) {
+			t.Errorf("Geocode(%q) error = %v, want ErrAddressNotFound", query, err)
+		}
+	}
+}
+
+func TestLoadGazetteer(t *testing.T) {
+	// Справочник, который поставляется для разработки
+	gazetteer, err := LoadGazetteer(filepath.Join("..", "..", "config", "gazetteer.json"))
+	if err != nil {
+		t.Fatalf("LoadGazetteer() error = %v", err)
+	}
+	if address, err := gazetteer.Geocode("Санкт-Петербург, Невский проспект, 28"); err != nil || address.PostalCode != "191186" {
+		t.Errorf("Geocode() = %+v, %v", address, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "gazetteer.json")
+	if err := os.WriteFile(path, []byte(`[{"street": "ул. Ленина, 1", "city": "Москва", "lat": 95, "lon": 37.6}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadGazetteer(path); err == nil {
+		t.Error("LoadGazetteer() принял запись с некорректными координатами")
+	}
+}
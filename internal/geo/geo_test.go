@@ -0,0 +1,42 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	moscow := Point{Lat: 55.7558, Lon: 37.6173}
+	petersburg := Point{Lat: 59.9343, Lon: 30.3351}
+
+	tests := []struct {
+		name string
+		a, b Point
+		want float64 // км
+	}{
+		{"Одна точка", moscow, moscow, 0},
+		{"Москва - Санкт-Петербург", moscow, petersburg, 634},
+		{"Четверть экватора", Point{0, 0}, Point{0, 90}, 10007.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Distance(tt.a, tt.b)
+			if math.Abs(got-tt.want) > 1 {
+				t.Errorf("Distance() = %.1f, want %.1f", got, tt.want)
+			}
+			if back := Distance(tt.b, tt.a); math.Abs(back-got) > 1e-9 {
+				t.Errorf("Distance() несимметрично: %v и %v", got, back)
+			}
+		})
+	}
+}
+
+func TestPoint_Valid(t *testing.T) {
+	if !(Point{Lat: 55.7, Lon: 37.6}).Valid() {
+		t.Error("Valid() = false для корректной точки")
+	}
+	if (Point{Lat: 91, Lon: 0}).Valid() || (Point{Lat: 0, Lon: -181}).Valid() {
+		t.Error("Valid() = true для точки вне допустимых пределов")
+	}
+}
@@ -0,0 +1,140 @@
+package geo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidGeometry - геометрия не является корректным полигоном GeoJSON
+var ErrInvalidGeometry = errors.New("некорректная геометрия GeoJSON")
+
+// Ring - замкнутый контур полигона: первая точка совпадает с последней
+type Ring []Point
+
+// Polygon - внешний контур и вырезанные из него области (дыры)
+type Polygon []Ring
+
+// Area - область из одного или нескольких полигонов
+type Area []Polygon
+
+// geometry - геометрия GeoJSON. Координаты хранятся в порядке [долгота, широта]
+type geometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+	Geometry    *geometry       `json:"geometry"` // Для объектов типа Feature
+}
+
+// ParseArea разбирает геометрию GeoJSON типа Polygon или MultiPolygon (в том числе внутри Feature)
+func ParseArea(data []byte) (Area, error) {
+	var g geometry
+	if err := json.Unmarshal(data, &g); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidGeometry, err)
+	}
+	if g.Type == "Feature" {
+		if g.Geometry == nil {
+			return nil, fmt.Errorf("%w: Feature без геометрии", ErrInvalidGeometry)
+		}
+		g = *g.Geometry
+	}
+
+	var area Area
+	switch g.Type {
+	case "Polygon":
+		var coordinates [][][]float64
+		if err := json.Unmarshal(g.Coordinates, &coordinates); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidGeometry, err)
+		}
+		polygon, err := parsePolygon(coordinates)
+		if err != nil {
+			return nil, err
+		}
+		area = Area{polygon}
+	case "MultiPolygon":
+		var coordinates [][][][]float64
+		if err := json.Unmarshal(g.Coordinates, &coordinates); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidGeometry, err)
+		}
+		if len(coordinates) == 0 {
+			return nil, fmt.Errorf("%w: MultiPolygon без полигонов", ErrInvalidGeometry)
+		}
+		for _, c := range coordinates {
+			polygon, err := parsePolygon(c)
+			if err != nil {
+				return nil, err
+			}
+			area = append(area, polygon)
+		}
+	default:
+		return nil, fmt.Errorf("%w: поддерживаются только Polygon и MultiPolygon, получен %q", ErrInvalidGeometry, g.Type)
+	}
+
+	return area, nil
+}
+
+func parsePolygon(coordinates [][][]float64) (Polygon, error) {
+	if len(coordinates) == 0 {
+		return nil, fmt.Errorf("%w: полигон без контуров", ErrInvalidGeometry)
+	}
+
+	polygon := make(Polygon, 0, len(coordinates))
+	for _, c := range coordinates {
+		if len(c) < 4 {
+			return nil, fmt.Errorf("%w: контур должен содержать не менее 4 точек", ErrInvalidGeometry)
+		}
+
+		ring := make(Ring, 0, len(c))
+		for _, position := range c {
+			if len(position) < 2 {
+				return nil, fmt.Errorf("%w: точка должна содержать долготу и широту", ErrInvalidGeometry)
+			}
+			p := Point{Lat: position[1], Lon: position[0]}
+			if !p.Valid() {
+				return nil, fmt.Errorf("%w: координаты вне допустимого диапазона", ErrInvalidGeometry)
+			}
+			ring = append(ring, p)
+		}
+		if ring[0] != ring[len(ring)-1] {
+			return nil, fmt.Errorf("%w: контур не замкнут", ErrInvalidGeometry)
+		}
+		polygon = append(polygon, ring)
+	}
+	return polygon, nil
+}
+
+// Contains сообщает, что точка лежит внутри области
+func (a Area) Contains(p Point) bool {
+	for _, polygon := range a {
+		if polygon.Contains(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Contains сообщает, что точка лежит внутри внешнего контура и вне дыр
+func (pg Polygon) Contains(p Point) bool {
+	if len(pg) == 0 || !pg[0].contains(p) {
+		return false
+	}
+	for _, hole := range pg[1:] {
+		if hole.contains(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// contains проверяет попадание точки в контур методом трассировки луча.
+// Координаты рассматриваются как плоские, чего достаточно для районов города
+func (r Ring) contains(p Point) bool {
+	inside := false
+	for i, j := 0, len(r)-1; i < len(r); j, i = i, i+1 {
+		a, b := r[i], r[j]
+		if (a.Lat > p.Lat) != (b.Lat > p.Lat) &&
+			p.Lon < (b.Lon-a.Lon)*(p.Lat-a.Lat)/(b.Lat-a.Lat)+a.Lon {
+			inside = !inside
+		}
+	}
+	return inside
+}
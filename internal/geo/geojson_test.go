@@ -0,0 +1,78 @@
+package geo
+
+import (
+	"errors"
+	"testing"
+)
+
+// Квадрат 1x1 градус с квадратной дырой в центре
+const squareWithHole = `{"type":"Polygon","coordinates":[
+	[[37,55],[38,55],[38,56],[37,56],[37,55]],
+	[[37.4,55.4],[37.6,55.4],[37.6,55.6],[37.4,55.6],[37.4,55.4]]
+]}`
+
+func TestParseArea(t *testing.T) {
+	valid := []struct {
+		name     string
+		geometry string
+		polygons int
+	}{
+		{"Polygon", squareWithHole, 1},
+		{"MultiPolygon", `{"type":"MultiPolygon","coordinates":[
+			[[[37,55],[38,55],[38,56],[37,55]]],
+			[[[30,59],[31,59],[31,60],[30,59]]]
+		]}`, 2},
+		{"Feature", `{"type":"Feature","properties":{"name":"Центр"},"geometry":` + squareWithHole + `}`, 1},
+	}
+	for _, tt := range valid {
+		t.Run(tt.name, func(t *testing.T) {
+			area, err := ParseArea([]byte(tt.geometry))
+			if err != nil || len(area) != tt.polygons {
+				t.Errorf("ParseArea() = %d полигонов, %v, want %d", len(area), err, tt.polygons)
+			}
+		})
+	}
+
+	invalid := map[string]string{
+		"Не JSON":               `{`,
+		"Точка":                 `{"type":"Point","coordinates":[37,55]}`,
+		"Незамкнутый контур":    `{"type":"Polygon","coordinates":[[[37,55],[38,55],[38,56],[37,56]]]}`,
+		"Мало точек":            `{"type":"Polygon","coordinates":[[[37,55],[38,55],[37,55]]]}`,
+		"Широта вне диапазона":  `{"type":"Polygon","coordinates":[[[37,95],[38,55],[38,56],[37,95]]]}`,
+		"Пустой полигон":        `{"type":"Polygon","coordinates":[]}`,
+		"Feature без геометрии": `{"type":"Feature","properties":{}}`,
+		"Пустой MultiPolygon":   `{"type":"MultiPolygon","coordinates":[]}`,
+		"Неверные координаты":   `{"type":"Polygon","coordinates":"abc"}`,
+		"Точка без широты":      `{"type":"Polygon","coordinates":[[[37],[38,55],[38,56],[37]]]}`,
+	}
+	for name, geometry := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseArea([]byte(geometry)); !errors.Is(err, ErrInvalidGeometry) {
+				t.Errorf("ParseArea() error = %v, want ErrInvalidGeometry", err)
+			}
+		})
+	}
+}
+
+func TestArea_Contains(t *testing.T) {
+	area, err := ParseArea([]byte(squareWithHole))
+	if err != nil {
+		t.Fatalf("ParseArea() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		point Point
+		want  bool
+	}{
+		{"Внутри", Point{Lat: 55.2, Lon: 37.2}, true},
+		{"В дыре", Point{Lat: 55.5, Lon: 37.5}, false},
+		{"Снаружи", Point{Lat: 55.5, Lon: 38.5}, false},
+		{"Широта и долгота не перепутаны", Point{Lat: 37.5, Lon: 55.5}, false},
+	}
+	for _, tt := range tests {
+		if got := area.Contains(tt.point); got != tt.want {
+			t.Errorf("%s: Contains(%v) = %v, want %v", tt.name, tt.point, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Брокеры сообщений, из которых выбирается брокер в конфигурации
+const (
+	BrokerKafka  = "kafka"
+	BrokerMemory = "memory"
+)
+
+// Ошибки брокера сообщений
+var (
+	ErrUnknownBroker = errors.New("неизвестный брокер сообщений")
+	ErrBrokerClosed  = errors.New("брокер сообщений закрыт")
+)
+
+// Publisher публикует сообщения
+type Publisher interface {
+	Publish(ctx context.Context, messages ...Message) error
+}
+
+// Subscription - участник группы потребителей: читает сообщения назначенных ему партиций
+// и фиксирует смещения обработанных. После фиксации сообщение не читается группой повторно
+type Subscription interface {
+	Fetch(ctx context.Context) (Message, error)
+	Commit(ctx context.Context, msg Message) error
+	Close() error
+}
+
+// Broker - брокер сообщений: Kafka (Client) или брокер в памяти процесса (MemoryBroker)
+type Broker interface {
+	Publisher
+	// Subscribe подключает к группе groupID нового участника, читающего топики topics
+	Subscribe(groupID string, topics []string) (Subscription, error)
+	Close() error
+}
+
+// NewBroker создает брокер сообщений по названию: kafka (по умолчанию) или memory
+func NewBroker(name string) (Broker, error) {
+	switch name {
+	case "", BrokerKafka:
+		client, err := NewClient()
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	case BrokerMemory:
+		return NewMemoryBroker(), nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownBroker, name)
+}
@@ -2,14 +2,17 @@ package kafka
 
 import (
 	"context"
-	"log"
 	"os"
+	"time"
+
+	"github.com/segmentio/kafka-go"
 )
 
 // Client представляет собой клиент Kafka, объединяющий Producer и Consumer
 type Client struct {
 	Producer  *Producer
 	Consumers map[string]*Consumer
+	broker    string
 }
 
 // NewClient создает нового клиента Kafka
@@ -27,6 +30,7 @@ func NewClient() (*Client, error) {
 	return &Client{
 		Producer:  producer,
 		Consumers: make(map[string]*Consumer),
+		broker:    broker,
 	}, nil
 }
 
@@ -37,12 +41,7 @@ func (c *Client) RegisterConsumer(topic, groupID string) (*Consumer, error) {
 		return consumer, nil
 	}
 
-	broker := os.Getenv("KAFKA_BROKER")
-	if broker == "" {
-		broker = "localhost:9092" // Значение по умолчанию
-	}
-
-	consumer, err := NewConsumer(broker, topic, groupID)
+	consumer, err := NewConsumer(c.broker, topic, groupID)
 	if err != nil {
 		return nil, err
 	}
@@ -51,32 +50,61 @@ func (c *Client) RegisterConsumer(topic, groupID string) (*Consumer, error) {
 	return consumer, nil
 }
 
-// StartTestConsumer запускает тестового потребителя для указанного топика
-func (c *Client) StartTestConsumer(topic, groupID string) error {
-	consumer, err := c.RegisterConsumer(topic, groupID)
+// Publish публикует сообщения через Producer клиента
+func (c *Client) Publish(ctx context.Context, messages ...Message) error {
+	return c.Producer.Publish(ctx, messages...)
+}
+
+// Subscribe подключает к группе groupID участника, читающего топики topics с первого
+// незафиксированного сообщения. Kafka распределяет партиции между участниками группы
+func (c *Client) Subscribe(groupID string, topics []string) (Subscription, error) {
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     []string{c.broker},
+		GroupID:     groupID,
+		GroupTopics: topics,
+		MaxBytes:    10e6, // 10MB
+		MaxWait:     time.Second,
+		StartOffset: kafka.FirstOffset,
+	})
+	return &readerSubscription{reader: reader}, nil
+}
+
+// readerSubscription - участник группы потребителей Kafka
+type readerSubscription struct {
+	reader *kafka.Reader
+}
+
+func (s *readerSubscription) Fetch(ctx context.Context) (Message, error) {
+	m, err := s.reader.FetchMessage(ctx)
 	if err != nil {
-		return err
+		return Message{}, err
 	}
+	return fromKafka(m), nil
+}
 
-	// Запускаем чтение сообщений в отдельной горутине
-	go func() {
-		ctx := context.Background()
-		for {
-			msg, err := consumer.Consume(ctx)
-			if err != nil {
-				log.Printf("Error consuming message: %v", err)
-				return
-			}
-			log.Printf("Processing message: %s", string(msg))
-		}
-	}()
+func (s *readerSubscription) Commit(ctx context.Context, msg Message) error {
+	// Для фиксации смещения Kafka нужны только топик, партиция и смещение сообщения
+	return s.reader.CommitMessages(ctx, kafka.Message{Topic: msg.Topic, Partition: msg.Partition, Offset: msg.Offset})
+}
 
-	return nil
+func (s *readerSubscription) Close() error {
+	return s.reader.Close()
 }
 
-// SendTestMessage отправляет тестовое сообщение в указанный топик
-func (c *Client) SendTestMessage(topic string, message string) error {
-	return c.Producer.Produce(topic, []byte(message))
+// fromKafka преобразует прочитанное сообщение
+func fromKafka(m kafka.Message) Message {
+	msg := Message{
+		Topic:     m.Topic,
+		Key:       m.Key,
+		Value:     m.Value,
+		Headers:   make(map[string]string, len(m.Headers)),
+		Partition: m.Partition,
+		Offset:    m.Offset,
+	}
+	for _, h := range m.Headers {
+		msg.Headers[h.Key] = string(h.Value)
+	}
+	return msg
 }
 
 // Close закрывает клиент Kafka и все связанные ресурсы
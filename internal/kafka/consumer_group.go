@@ -0,0 +1,378 @@
+package kafka
+
+import (
+	"context"
+	"delivery/internal/events"
+	"delivery/internal/metrics"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Заголовки сообщений доменных событий
+const (
+	HeaderEventID   = "event_id"
+	HeaderEventType = "event_type"
+)
+
+// Заголовки сообщения, отправленного в DLQ: почему и откуда оно туда попало
+const (
+	HeaderDLQError     = "dlq_error"
+	HeaderDLQAttempts  = "dlq_attempts"
+	HeaderDLQTopic     = "dlq_original_topic"
+	HeaderDLQPartition = "dlq_original_partition"
+	HeaderDLQOffset    = "dlq_original_offset"
+	HeaderDLQFailedAt  = "dlq_failed_at"
+)
+
+const (
+	// DLQSuffix - суффикс топика, в который отправляются сообщения, которые не удалось обработать
+	DLQSuffix = ".dlq"
+
+	// DefaultGroupID - группа потребителей, если она не задана
+	DefaultGroupID = "delivery-service"
+	// DefaultWorkers - сколько сообщений группа обрабатывает одновременно по умолчанию
+	DefaultWorkers = 4
+	// DefaultMaxAttempts - сколько раз сообщение обрабатывается, прежде чем попасть в DLQ
+	DefaultMaxAttempts = 5
+	// DefaultInitialBackoff - пауза перед первой повторной обработкой
+	DefaultInitialBackoff = 200 * time.Millisecond
+	// DefaultMaxBackoff - наибольшая пауза между повторными обработками
+	DefaultMaxBackoff = 10 * time.Second
+
+	// kafkaTimeout ограничивает ожидание Kafka при фиксации смещения и отправке в DLQ
+	kafkaTimeout = 10 * time.Second
+	// fetchRetryDelay - пауза после ошибки чтения сообщений
+	fetchRetryDelay = time.Second
+)
+
+// Handler обрабатывает сообщение. Если обработчик вернул ошибку, сообщение обрабатывается
+// повторно, а ошибку, обернутую Permanent, повтор не исправит - сообщение сразу уходит в DLQ.
+// Сообщение может прийти повторно, поэтому обработка должна быть идемпотентной
+type Handler func(ctx context.Context, msg Message) error
+
+// permanentError - ошибка обработки, после которой сообщение не обрабатывается повторно
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent помечает ошибку, которую не исправит повторная обработка, например некорректное сообщение
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+// IsPermanent сообщает, что ошибка помечена Permanent
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
+// RetryPolicy - повторная обработка сообщения: до MaxAttempts попыток, пауза между ними
+// начинается с InitialBackoff и удваивается, но не превышает MaxBackoff
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// backoff возвращает паузу после неудачной попытки attempt
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.InitialBackoff
+	for i := 1; i < attempt && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxBackoff)
+}
+
+// ConsumerGroup читает топики в группе потребителей и передает сообщения обработчикам
+// по заголовку event_type. Сообщения одной партиции обрабатываются по очереди одним
+// обработчиком, смещение фиксируется только после обработки, поэтому после перезапуска
+// чтение продолжается с первого необработанного сообщения. Сообщение, которое не удалось
+// обработать за RetryPolicy.MaxAttempts попыток, отправляется в топик <topic>.dlq
+type ConsumerGroup struct {
+	groupID   string
+	subscribe func(topics []string) (Subscription, error)
+	dlq       Publisher
+	handlers  map[string]map[string]Handler // Топик -> тип события -> обработчик
+	workers   int
+	retry     RetryPolicy
+	registry  *events.Registry
+}
+
+// NewConsumerGroup создает группу потребителей groupID, которая читает сообщения из брокера
+// и отправляет в него необработанные сообщения. Экземпляры сервиса с одной группой делят
+// партиции между собой, поэтому каждое сообщение обрабатывает один экземпляр
+func NewConsumerGroup(broker Broker, groupID string) *ConsumerGroup {
+	g := newConsumerGroup(groupID, broker)
+	g.subscribe = func(topics []string) (Subscription, error) {
+		return broker.Subscribe(g.groupID, topics)
+	}
+	return g
+}
+
+func newConsumerGroup(groupID string, dlq Publisher) *ConsumerGroup {
+	if groupID == "" {
+		groupID = DefaultGroupID
+	}
+	return &ConsumerGroup{
+		groupID:  groupID,
+		dlq:      dlq,
+		handlers: make(map[string]map[string]Handler),
+		workers:  DefaultWorkers,
+		retry: RetryPolicy{
+			MaxAttempts:    DefaultMaxAttempts,
+			InitialBackoff: DefaultInitialBackoff,
+			MaxBackoff:     DefaultMaxBackoff,
+		},
+		registry: events.Domain,
+	}
+}
+
+// WithWorkers задает, сколько сообщений обрабатывается одновременно
+func (g *ConsumerGroup) WithWorkers(workers int) *ConsumerGroup {
+	if workers > 0 {
+		g.workers = workers
+	}
+	return g
+}
+
+// WithRetry задает повторную обработку сообщений. Незаданные поля остаются прежними
+func (g *ConsumerGroup) WithRetry(policy RetryPolicy) *ConsumerGroup {
+	if policy.MaxAttempts > 0 {
+		g.retry.MaxAttempts = policy.MaxAttempts
+	}
+	if policy.InitialBackoff > 0 {
+		g.retry.InitialBackoff = policy.InitialBackoff
+	}
+	if policy.MaxBackoff > 0 {
+		g.retry.MaxBackoff = policy.MaxBackoff
+	}
+	return g
+}
+
+// WithRegistry задает реестр, по которому события приводятся к текущей версии
+func (g *ConsumerGroup) WithRegistry(registry *events.Registry) *ConsumerGroup {
+	g.registry = registry
+	return g
+}
+
+// HandleFunc регистрирует обработчик событий eventType топика topic. Обработчик с пустым
+// eventType получает сообщения топика, для типа которых нет своего обработчика.
+// Сообщения без обработчика пропускаются
+func (g *ConsumerGroup) HandleFunc(topic, eventType string, handler Handler) *ConsumerGroup {
+	if g.handlers[topic] == nil {
+		g.handlers[topic] = make(map[string]Handler)
+	}
+	g.handlers[topic][eventType] = handler
+	return g
+}
+
+// Run читает и обрабатывает сообщения до отмены ctx. После отмены обработка текущих сообщений
+// завершается, а прочитанные, но не обработанные сообщения остаются незафиксированными
+// и будут прочитаны снова
+func (g *ConsumerGroup) Run(ctx context.Context) error {
+	topics := g.topics()
+	if len(topics) == 0 {
+		return errors.New("не зарегистрировано ни одного обработчика")
+	}
+
+	sub, err := g.subscribe(topics)
+	if err != nil {
+		return fmt.Errorf("ошибка подключения группы %s: %w", g.groupID, err)
+	}
+	defer sub.Close()
+
+	// Партиция закреплена за одним обработчиком: сообщения партиции обрабатываются по порядку,
+	// и фиксация смещения не пропускает необработанные сообщения
+	queues := make([]chan Message, g.workers)
+	var wg sync.WaitGroup
+	for i := range queues {
+		queues[i] = make(chan Message)
+		wg.Add(1)
+		go func(queue <-chan Message) {
+			defer wg.Done()
+			for msg := range queue {
+				g.process(ctx, sub, msg)
+			}
+		}(queues[i])
+	}
+	defer func() {
+		for _, queue := range queues {
+			close(queue)
+		}
+		wg.Wait()
+	}()
+
+	log.Printf("[KAFKA] Группа %s читает топики %v, обработчиков: %d", g.groupID, topics, g.workers)
+	for {
+		msg, err := sub.Fetch(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				log.Printf("[KAFKA] Группа %s остановлена", g.groupID)
+				return nil
+			}
+			log.Printf("[KAFKA] Ошибка чтения сообщений группы %s: %v", g.groupID, err)
+			if !sleep(ctx, fetchRetryDelay) {
+				return nil
+			}
+			continue
+		}
+
+		select {
+		case queues[worker(msg, len(queues))] <- msg:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// topics возвращает топики, для которых зарегистрированы обработчики
+func (g *ConsumerGroup) topics() []string {
+	topics := make([]string, 0, len(g.handlers))
+	for topic := range g.handlers {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// handler возвращает обработчик сообщения или nil, если сообщение не нужно обрабатывать
+func (g *ConsumerGroup) handler(msg Message) Handler {
+	handlers := g.handlers[msg.Topic]
+	if handler, ok := handlers[msg.Headers[HeaderEventType]]; ok {
+		return handler
+	}
+	return handlers[""]
+}
+
+// process обрабатывает сообщение и фиксирует его смещение. При остановке группы смещение
+// не фиксируется, чтобы сообщение было обработано после перезапуска
+func (g *ConsumerGroup) process(ctx context.Context, sub Subscription, msg Message) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	if handler := g.handler(msg); handler != nil {
+		attempts, err := g.handle(ctx, handler, msg)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			if !g.deadLetter(ctx, msg, attempts, err) {
+				return
+			}
+		} else {
+			metrics.KafkaMessagesProcessedTotal.WithLabelValues(msg.Topic).Inc()
+		}
+	}
+
+	// Смещение фиксируется и во время остановки: обработка уже завершена
+	commitCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), kafkaTimeout)
+	defer cancel()
+	if err := sub.Commit(commitCtx, msg); err != nil {
+		log.Printf("[KAFKA] Ошибка фиксации смещения сообщения %s: %v", describe(msg), err)
+	}
+}
+
+// handle вызывает обработчик, пока он не завершится успешно или не закончатся попытки.
+// Возвращает число попыток и последнюю ошибку
+func (g *ConsumerGroup) handle(ctx context.Context, handler Handler, msg Message) (int, error) {
+	for attempt := 1; ; attempt++ {
+		err := call(ctx, handler, msg)
+		if err == nil {
+			return attempt, nil
+		}
+		if IsPermanent(err) || attempt >= g.retry.MaxAttempts {
+			return attempt, err
+		}
+
+		delay := g.retry.backoff(attempt)
+		log.Printf("[KAFKA] Ошибка обработки сообщения %s (попытка %d из %d), повтор через %s: %v",
+			describe(msg), attempt, g.retry.MaxAttempts, delay, err)
+		if !sleep(ctx, delay) {
+			return attempt, ctx.Err()
+		}
+	}
+}
+
+// call вызывает обработчик. Паника обработчика возвращается как ошибка, которую не исправит повтор
+func call(ctx context.Context, handler Handler, msg Message) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = Permanent(fmt.Errorf("паника при обработке сообщения: %v", r))
+		}
+	}()
+	return handler(ctx, msg)
+}
+
+// deadLetter отправляет сообщение в DLQ его топика с заголовками об ошибке. Пока отправка
+// не удалась, сообщение не фиксируется и партиция не читается дальше. Возвращает false,
+// если группа остановлена раньше, чем сообщение отправлено
+func (g *ConsumerGroup) deadLetter(ctx context.Context, msg Message, attempts int, cause error) bool {
+	headers := make(map[string]string, len(msg.Headers)+6)
+	for key, value := range msg.Headers {
+		headers[key] = value
+	}
+	headers[HeaderDLQError] = cause.Error()
+	headers[HeaderDLQAttempts] = strconv.Itoa(attempts)
+	headers[HeaderDLQTopic] = msg.Topic
+	headers[HeaderDLQPartition] = strconv.Itoa(msg.Partition)
+	headers[HeaderDLQOffset] = strconv.FormatInt(msg.Offset, 10)
+	headers[HeaderDLQFailedAt] = time.Now().UTC().Format(time.RFC3339)
+
+	dead := Message{Topic: msg.Topic + DLQSuffix, Key: msg.Key, Value: msg.Value, Headers: headers}
+	for attempt := 1; ; attempt++ {
+		publishCtx, cancel := context.WithTimeout(ctx, kafkaTimeout)
+		err := g.dlq.Publish(publishCtx, dead)
+		cancel()
+		if err == nil {
+			metrics.KafkaMessagesDeadLetteredTotal.WithLabelValues(msg.Topic).Inc()
+			log.Printf("[KAFKA] Сообщение %s отправлено в %s, попыток обработки: %d: %v", describe(msg), dead.Topic, attempts, cause)
+			return true
+		}
+
+		log.Printf("[KAFKA] Ошибка отправки сообщения %s в %s: %v", describe(msg), dead.Topic, err)
+		if !sleep(ctx, g.retry.backoff(attempt)) {
+			return false
+		}
+	}
+}
+
+// worker возвращает номер обработчика, за которым закреплена партиция сообщения
+func worker(msg Message, workers int) int {
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%s/%d", msg.Topic, msg.Partition)
+	return int(h.Sum32() % uint32(workers))
+}
+
+// describe возвращает описание сообщения для журнала: топик, партиция, смещение и тип события
+func describe(msg Message) string {
+	description := fmt.Sprintf("%s/%d/%d", msg.Topic, msg.Partition, msg.Offset)
+	if eventType := msg.Headers[HeaderEventType]; eventType != "" {
+		description += " (" + eventType + ")"
+	}
+	return description
+}
+
+// sleep ждет delay и возвращает false, если раньше был отменен ctx
+func sleep(ctx context.Context, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,337 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeReader отдает сообщения из канала и запоминает зафиксированные
+type fakeReader struct {
+	messages chan Message
+
+	mu        sync.Mutex
+	committed []Message
+	closed    bool
+}
+
+func newFakeReader(messages ...Message) *fakeReader {
+	r := &fakeReader{messages: make(chan Message, len(messages)+1)}
+	for _, m := range messages {
+		r.messages <- m
+	}
+	return r
+}
+
+func (r *fakeReader) Fetch(ctx context.Context) (Message, error) {
+	select {
+	case m := <-r.messages:
+		return m, nil
+	case <-ctx.Done():
+		return Message{}, ctx.Err()
+	}
+}
+
+func (r *fakeReader) Commit(ctx context.Context, msg Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.committed = append(r.committed, msg)
+	return nil
+}
+
+func (r *fakeReader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	return nil
+}
+
+// offsets возвращает смещения зафиксированных сообщений
+func (r *fakeReader) offsets() []int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	offsets := make([]int64, len(r.committed))
+	for i, m := range r.committed {
+		offsets[i] = m.Offset
+	}
+	return offsets
+}
+
+// fakeDLQ запоминает сообщения, отправленные в DLQ
+type fakeDLQ struct {
+	mu       sync.Mutex
+	messages []Message
+	err      error
+}
+
+func (p *fakeDLQ) Publish(ctx context.Context, messages ...Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	p.messages = append(p.messages, messages...)
+	return nil
+}
+
+func (p *fakeDLQ) published() []Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Message(nil), p.messages...)
+}
+
+type testEvent struct {
+	ID int `json:"id"`
+}
+
+// event возвращает сообщение события eventType в партиции 0
+func event(topic, eventType string, offset int64, value string) Message {
+	return Message{
+		Topic:   topic,
+		Offset:  offset,
+		Value:   []byte(value),
+		Headers: map[string]string{HeaderEventType: eventType},
+	}
+}
+
+// runGroup запускает группу с заданным читателем и возвращает функцию остановки,
+// которая дожидается завершения Run
+func runGroup(t *testing.T, g *ConsumerGroup, reader *fakeReader) func() {
+	t.Helper()
+	g.subscribe = func([]string) (Subscription, error) { return reader, nil }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- g.Run(ctx) }()
+
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			cancel()
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Errorf("Run() error = %v", err)
+				}
+			case <-time.After(time.Second):
+				t.Error("Run() не завершился после отмены контекста")
+			}
+		})
+	}
+	t.Cleanup(stop)
+	return stop
+}
+
+// waitFor ждет выполнения условия
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("условие не выполнено за секунду")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestConsumerGroup_HandlesAndCommits(t *testing.T) {
+	reader := newFakeReader(
+		event("parcels", "ParcelRegistered", 0, `{"id":1}`),
+		event("parcels", "ParcelStatusChanged", 1, `{"id":1}`),
+		event("parcels", "ParcelRegistered", 2, `{"id":2}`),
+	)
+	dlq := &fakeDLQ{}
+	g := newConsumerGroup("test", dlq)
+
+	var mu sync.Mutex
+	var handled []int
+	Handle(g, "parcels", "ParcelRegistered", func(ctx context.Context, e testEvent) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, e.ID)
+		return nil
+	})
+	runGroup(t, g, reader)
+
+	// Событие без обработчика пропускается, но его смещение тоже фиксируется
+	waitFor(t, func() bool { return len(reader.offsets()) == 3 })
+	if got := reader.offsets(); got[0] != 0 || got[1] != 1 || got[2] != 2 {
+		t.Errorf("зафиксированы смещения %v, want [0 1 2]", got)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(handled) != 2 || handled[0] != 1 || handled[1] != 2 {
+		t.Errorf("обработаны события %v, want [1 2]", handled)
+	}
+	if len(dlq.published()) != 0 {
+		t.Errorf("в DLQ отправлено %d сообщений", len(dlq.published()))
+	}
+}
+
+func TestConsumerGroup_RetriesWithBackoff(t *testing.T) {
+	reader := newFakeReader(event("deliveries", "DeliveryCompleted", 5, `{"id":3}`))
+	g := newConsumerGroup("test", &fakeDLQ{}).
+		WithRetry(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond})
+
+	var mu sync.Mutex
+	calls := 0
+	g.HandleFunc("deliveries", "DeliveryCompleted", func(ctx context.Context, msg Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls < 3 {
+			return errors.New("платежный сервис недоступен")
+		}
+		return nil
+	})
+	runGroup(t, g, reader)
+
+	waitFor(t, func() bool { return len(reader.offsets()) == 1 })
+	mu.Lock()
+	defer mu.Unlock()
+	if calls != 3 {
+		t.Errorf("обработчик вызван %d раз, want 3", calls)
+	}
+}
+
+func TestConsumerGroup_DeadLetter(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		handler  error
+		attempts string
+	}{
+		{name: "попытки исчерпаны", value: `{"id":1}`, handler: errors.New("сбой"), attempts: "2"},
+		{name: "ошибка, которую не исправит повтор", value: `{"id":1}`, handler: Permanent(errors.New("заказ не найден")), attempts: "1"},
+		{name: "некорректное сообщение", value: `не json`, attempts: "1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := event("deliveries", "DeliveryCompleted", 9, tt.value)
+			msg.Partition = 2
+			msg.Key = []byte("3")
+			msg.Headers[HeaderEventID] = "42"
+			reader := newFakeReader(msg)
+			dlq := &fakeDLQ{}
+			g := newConsumerGroup("test", dlq).WithRetry(RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond})
+			Handle(g, "deliveries", "DeliveryCompleted", func(ctx context.Context, e testEvent) error {
+				return tt.handler
+			})
+			runGroup(t, g, reader)
+
+			waitFor(t, func() bool { return len(reader.offsets()) == 1 })
+			published := dlq.published()
+			if len(published) != 1 {
+				t.Fatalf("в DLQ отправлено %d сообщений, want 1", len(published))
+			}
+			dead := published[0]
+			if dead.Topic != "deliveries.dlq" || string(dead.Key) != "3" || string(dead.Value) != tt.value {
+				t.Errorf("сообщение DLQ = %s %s %s", dead.Topic, dead.Key, dead.Value)
+			}
+			want := map[string]string{
+				HeaderEventID:      "42",
+				HeaderEventType:    "DeliveryCompleted",
+				HeaderDLQAttempts:  tt.attempts,
+				HeaderDLQTopic:     "deliveries",
+				HeaderDLQPartition: "2",
+				HeaderDLQOffset:    "9",
+			}
+			for key, value := range want {
+				if dead.Headers[key] != value {
+					t.Errorf("заголовок %s = %q, want %q", key, dead.Headers[key], value)
+				}
+			}
+			if dead.Headers[HeaderDLQError] == "" || dead.Headers[HeaderDLQFailedAt] == "" {
+				t.Errorf("нет заголовков ошибки: %v", dead.Headers)
+			}
+		})
+	}
+}
+
+func TestConsumerGroup_DeadLetterUnavailable(t *testing.T) {
+	reader := newFakeReader(event("parcels", "ParcelRegistered", 0, `{"id":1}`))
+	dlq := &fakeDLQ{err: errors.New("kafka недоступна")}
+	g := newConsumerGroup("test", dlq).WithRetry(RetryPolicy{MaxAttempts: 1, InitialBackoff: time.Millisecond})
+	g.HandleFunc("parcels", "", func(ctx context.Context, msg Message) error {
+		return errors.New("сбой")
+	})
+	stop := runGroup(t, g, reader)
+
+	// Пока сообщение не отправлено в DLQ, его смещение не фиксируется
+	time.Sleep(50 * time.Millisecond)
+	stop()
+	if got := reader.offsets(); len(got) != 0 {
+		t.Errorf("зафиксированы смещения %v, want нет", got)
+	}
+}
+
+func TestConsumerGroup_Shutdown(t *testing.T) {
+	reader := newFakeReader(event("parcels", "ParcelRegistered", 0, `{"id":1}`))
+	g := newConsumerGroup("test", &fakeDLQ{})
+
+	started := make(chan struct{})
+	g.HandleFunc("parcels", "ParcelRegistered", func(ctx context.Context, msg Message) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	stop := runGroup(t, g, reader)
+
+	<-started
+	stop()
+	// Прерванная обработка не фиксируется: сообщение будет прочитано после перезапуска
+	if got := reader.offsets(); len(got) != 0 {
+		t.Errorf("зафиксированы смещения %v, want нет", got)
+	}
+	reader.mu.Lock()
+	defer reader.mu.Unlock()
+	if !reader.closed {
+		t.Error("читатель не закрыт после остановки")
+	}
+}
+
+func TestConsumerGroup_PartitionOrder(t *testing.T) {
+	var messages []Message
+	for offset := int64(0); offset < 20; offset++ {
+		messages = append(messages, event("parcels", "ParcelRegistered", offset, `{"id":1}`))
+	}
+	reader := newFakeReader(messages...)
+	g := newConsumerGroup("test", &fakeDLQ{}).WithWorkers(8)
+
+	var mu sync.Mutex
+	var handled []int64
+	g.HandleFunc("parcels", "ParcelRegistered", func(ctx context.Context, msg Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, msg.Offset)
+		return nil
+	})
+	runGroup(t, g, reader)
+
+	// Сообщения одной партиции обрабатываются и фиксируются по порядку
+	waitFor(t, func() bool { return len(reader.offsets()) == 20 })
+	mu.Lock()
+	defer mu.Unlock()
+	for i, offset := range reader.offsets() {
+		if handled[i] != int64(i) || offset != int64(i) {
+			t.Fatalf("обработаны %v, зафиксированы %v", handled, reader.offsets())
+		}
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for attempt, want := range map[int]time.Duration{
+		1: 100 * time.Millisecond,
+		2: 200 * time.Millisecond,
+		4: 800 * time.Millisecond,
+		5: time.Second,
+		9: time.Second,
+	} {
+		if got := policy.backoff(attempt); got != want {
+			t.Errorf("backoff(%d) = %s, want %s", attempt, got, want)
+		}
+	}
+}
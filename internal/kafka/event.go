@@ -0,0 +1,74 @@
+package kafka
+
+import (
+	"context"
+	"delivery/internal/events"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// HeaderEventVersion - заголовок с версией события
+const HeaderEventVersion = "event_version"
+
+// EventMessage возвращает сообщение с событием в конверте. Ключ сообщения - ID записи,
+// к которой относится событие: события одной посылки или доставки попадают в одну партицию
+// и читаются в порядке публикации
+func EventMessage(topic string, e events.Envelope) (Message, error) {
+	value, err := e.Encode()
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{
+		Topic: topic,
+		Key:   []byte(e.AggregateID),
+		Value: value,
+		Headers: map[string]string{
+			HeaderEventID:      e.ID,
+			HeaderEventType:    e.Type,
+			HeaderEventVersion: strconv.Itoa(e.Version),
+		},
+	}, nil
+}
+
+// decodeEvent извлекает из сообщения событие и приводит его к текущей версии реестра.
+// Сообщения, опубликованные до появления конверта, содержат только данные события:
+// они считаются событиями версии 1 с типом и номером из заголовков
+func decodeEvent(registry *events.Registry, msg Message) (events.Envelope, error) {
+	e, err := events.Decode(msg.Value)
+	if errors.Is(err, events.ErrNotEnvelope) && msg.Headers[HeaderEventType] != "" {
+		e, err = events.Envelope{
+			ID:          msg.Headers[HeaderEventID],
+			Type:        msg.Headers[HeaderEventType],
+			Version:     1,
+			AggregateID: string(msg.Key),
+			Payload:     msg.Value,
+		}, nil
+	}
+	if err != nil {
+		return events.Envelope{}, err
+	}
+	return registry.Upcast(e)
+}
+
+// Handle регистрирует обработчик событий eventType топика topic. Событие извлекается
+// из конверта, приводится к текущей версии реестра группы и декодируется в T; конверт
+// доступен обработчику через events.FromContext. Сообщение, которое не удалось декодировать
+// или событие неизвестной версии сразу отправляются в DLQ
+func Handle[T any](g *ConsumerGroup, topic, eventType string, handle func(ctx context.Context, event T) error) {
+	g.HandleFunc(topic, eventType, func(ctx context.Context, msg Message) error {
+		e, err := decodeEvent(g.registry, msg)
+		if err != nil {
+			return Permanent(err)
+		}
+		if e.Type != eventType {
+			return Permanent(fmt.Errorf("в сообщении событие %s, ожидалось %s", e.Type, eventType))
+		}
+
+		var event T
+		if err := e.DecodePayload(&event); err != nil {
+			return Permanent(err)
+		}
+		return handle(events.WithEnvelope(ctx, e), event)
+	})
+}
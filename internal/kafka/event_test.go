@@ -0,0 +1,128 @@
+package kafka
+
+import (
+	"context"
+	"delivery/internal/events"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+)
+
+type statusV1 struct {
+	Status string `json:"status"`
+}
+
+type statusV2 struct {
+	NewStatus string `json:"new_status"`
+}
+
+// newStatusRegistry возвращает реестр, в котором событие StatusChanged переименовало поле во второй версии
+func newStatusRegistry() *events.Registry {
+	return events.NewRegistry().
+		Register(events.Schema{Type: "StatusChanged", Version: 1, Topic: "statuses", Payload: statusV1{}}).
+		Register(events.Schema{Type: "StatusChanged", Version: 2, Topic: "statuses", Payload: statusV2{}}).
+		RegisterUpcaster("StatusChanged", 1, func(payload json.RawMessage) (json.RawMessage, error) {
+			var v1 statusV1
+			if err := json.Unmarshal(payload, &v1); err != nil {
+				return nil, err
+			}
+			return json.Marshal(statusV2{NewStatus: v1.Status})
+		})
+}
+
+// at возвращает сообщение, прочитанное со смещением offset
+func at(msg Message, offset int64) Message {
+	msg.Offset = offset
+	return msg
+}
+
+func TestEventMessage(t *testing.T) {
+	msg, err := EventMessage("statuses", events.Envelope{
+		ID: "10", Type: "StatusChanged", Version: 2, AggregateID: "7",
+		OccurredAt: time.Now().UTC(), Payload: json.RawMessage(`{"new_status":"sent"}`),
+	})
+	if err != nil {
+		t.Fatalf("EventMessage() error = %v", err)
+	}
+	if msg.Topic != "statuses" || string(msg.Key) != "7" {
+		t.Errorf("сообщение = %s, ключ %s", msg.Topic, msg.Key)
+	}
+	if msg.Headers[HeaderEventID] != "10" || msg.Headers[HeaderEventType] != "StatusChanged" || msg.Headers[HeaderEventVersion] != "2" {
+		t.Errorf("заголовки = %v", msg.Headers)
+	}
+	if e, err := events.Decode(msg.Value); err != nil || e.AggregateID != "7" {
+		t.Errorf("Decode() = %+v, %v", e, err)
+	}
+}
+
+func TestHandle_Upcast(t *testing.T) {
+	current, err := EventMessage("statuses", events.Envelope{
+		ID: "2", Type: "StatusChanged", Version: 2, AggregateID: "7", CorrelationID: "1",
+		Payload: json.RawMessage(`{"new_status":"delivered"}`),
+	})
+	if err != nil {
+		t.Fatalf("EventMessage() error = %v", err)
+	}
+	old, _ := EventMessage("statuses", events.Envelope{
+		ID: "1", Type: "StatusChanged", Version: 1, AggregateID: "7",
+		Payload: json.RawMessage(`{"status":"in_transit"}`),
+	})
+	// До появления конверта значением сообщения были данные события версии 1
+	legacy := event("statuses", "StatusChanged", 2, `{"status":"assigned"}`)
+	legacy.Key = []byte("7")
+
+	reader := newFakeReader(legacy, at(old, 0), at(current, 1))
+	dlq := &fakeDLQ{}
+	g := newConsumerGroup("test", dlq).WithRegistry(newStatusRegistry())
+
+	var mu sync.Mutex
+	var statuses, correlations []string
+	Handle(g, "statuses", "StatusChanged", func(ctx context.Context, e statusV2) error {
+		envelope, _ := events.FromContext(ctx)
+		mu.Lock()
+		defer mu.Unlock()
+		statuses = append(statuses, e.NewStatus)
+		correlations = append(correlations, envelope.CorrelationID)
+		return nil
+	})
+	runGroup(t, g, reader)
+
+	waitFor(t, func() bool { return len(reader.offsets()) == 3 })
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"assigned", "in_transit", "delivered"}
+	for i := range want {
+		if i >= len(statuses) || statuses[i] != want[i] {
+			t.Fatalf("обработаны статусы %v, want %v", statuses, want)
+		}
+	}
+	if correlations[2] != "1" {
+		t.Errorf("CorrelationID = %q, want 1", correlations[2])
+	}
+	if len(dlq.published()) != 0 {
+		t.Errorf("в DLQ отправлено %d сообщений", len(dlq.published()))
+	}
+}
+
+func TestHandle_UnsupportedVersion(t *testing.T) {
+	newer, _ := EventMessage("statuses", events.Envelope{
+		ID: "3", Type: "StatusChanged", Version: 3, AggregateID: "7",
+		Payload: json.RawMessage(`{"state":"delivered"}`),
+	})
+	reader := newFakeReader(at(newer, 0))
+	dlq := &fakeDLQ{}
+	g := newConsumerGroup("test", dlq).WithRegistry(newStatusRegistry())
+	Handle(g, "statuses", "StatusChanged", func(ctx context.Context, e statusV2) error {
+		t.Error("обработчик вызван для неизвестной версии")
+		return nil
+	})
+	runGroup(t, g, reader)
+
+	// Событие неизвестной версии сразу отправляется в DLQ, повтор его не исправит
+	waitFor(t, func() bool { return len(reader.offsets()) == 1 })
+	published := dlq.published()
+	if len(published) != 1 || published[0].Headers[HeaderDLQAttempts] != "1" || published[0].Headers[HeaderEventVersion] != "3" {
+		t.Errorf("в DLQ отправлено %+v", published)
+	}
+}
@@ -0,0 +1,332 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+)
+
+// DefaultMemoryPartitions - число партиций топика в брокере в памяти
+const DefaultMemoryPartitions = 4
+
+// topicPartition - партиция топика
+type topicPartition struct {
+	topic     string
+	partition int
+}
+
+// MemoryBroker - брокер сообщений в памяти процесса для тестов и локального запуска без Kafka.
+// Как и Kafka, распределяет сообщения по партициям по ключу, делит партиции между участниками
+// группы потребителей и хранит зафиксированные смещения групп; новая группа читает топики
+// с начала, а Replay позволяет группе прочитать топик повторно. Сообщения хранятся
+// до завершения процесса и доступны только ему, поэтому несколько экземпляров сервиса
+// с брокером в памяти не видят событий друг друга
+type MemoryBroker struct {
+	mu         sync.Mutex
+	partitions int
+	topics     map[string][][]Message // Топик -> партиции -> сообщения
+	groups     map[string]*memoryGroup
+	rr         map[string]int // Партиция следующего сообщения без ключа
+	changed    chan struct{}  // Закрывается при новых сообщениях, фиксации смещений и перераспределении партиций
+	closed     bool
+}
+
+// memoryGroup - группа потребителей: зафиксированные смещения и участники в порядке подключения
+type memoryGroup struct {
+	committed map[topicPartition]int64 // Смещение следующего необработанного сообщения
+	members   []*memorySubscription
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return NewMemoryBrokerWithPartitions(DefaultMemoryPartitions)
+}
+
+// NewMemoryBrokerWithPartitions создает брокер, топики которого делятся на partitions партиций
+func NewMemoryBrokerWithPartitions(partitions int) *MemoryBroker {
+	if partitions <= 0 {
+		partitions = 1
+	}
+	return &MemoryBroker{
+		partitions: partitions,
+		topics:     make(map[string][][]Message),
+		groups:     make(map[string]*memoryGroup),
+		rr:         make(map[string]int),
+		changed:    make(chan struct{}),
+	}
+}
+
+// Publish добавляет сообщения в конец партиций их топиков. Топик создается при первой записи
+func (b *MemoryBroker) Publish(ctx context.Context, messages ...Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return ErrBrokerClosed
+	}
+	for _, m := range messages {
+		if m.Topic == "" {
+			return errors.New("не указан топик сообщения")
+		}
+	}
+
+	for _, m := range messages {
+		partitions := b.topic(m.Topic)
+		p := b.partition(m)
+		m.Partition = p
+		m.Offset = int64(len(partitions[p]))
+		m.Headers = copyHeaders(m.Headers)
+		partitions[p] = append(partitions[p], m)
+	}
+	b.notify()
+	return nil
+}
+
+// Subscribe подключает к группе groupID участника, читающего топики topics. Партиции
+// перераспределяются между участниками группы, и каждый продолжает чтение своих партиций
+// с зафиксированного смещения
+func (b *MemoryBroker) Subscribe(groupID string, topics []string) (Subscription, error) {
+	if groupID == "" || len(topics) == 0 {
+		return nil, errors.New("не указаны группа или топики")
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, ErrBrokerClosed
+	}
+
+	group := b.groups[groupID]
+	if group == nil {
+		group = &memoryGroup{committed: make(map[topicPartition]int64)}
+		b.groups[groupID] = group
+	}
+	for _, topic := range topics {
+		b.topic(topic)
+	}
+
+	sub := &memorySubscription{broker: b, group: group, topics: append([]string(nil), topics...)}
+	group.members = append(group.members, sub)
+	b.rebalance(group)
+	return sub, nil
+}
+
+// Messages возвращает сообщения топика по партициям в порядке записи
+func (b *MemoryBroker) Messages(topic string) []Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var messages []Message
+	for _, partition := range b.topics[topic] {
+		for _, m := range partition {
+			m.Headers = copyHeaders(m.Headers)
+			messages = append(messages, m)
+		}
+	}
+	return messages
+}
+
+// Committed возвращает зафиксированное группой смещение партиции: смещение следующего
+// сообщения, которое прочитает группа
+func (b *MemoryBroker) Committed(groupID, topic string, partition int) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if group := b.groups[groupID]; group != nil {
+		return group.committed[topicPartition{topic: topic, partition: partition}]
+	}
+	return 0
+}
+
+// Replay сбрасывает смещения группы в топике на начало: участники группы прочитают
+// все сообщения топика повторно
+func (b *MemoryBroker) Replay(groupID, topic string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	group := b.groups[groupID]
+	if group == nil {
+		return
+	}
+	for tp := range group.committed {
+		if tp.topic == topic {
+			delete(group.committed, tp)
+		}
+	}
+	b.rebalance(group)
+}
+
+// Close закрывает брокер: чтение и публикация завершаются ошибкой ErrBrokerClosed
+func (b *MemoryBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	b.notify()
+	return nil
+}
+
+// topic возвращает партиции топика, создавая его при необходимости. Вызывается под блокировкой
+func (b *MemoryBroker) topic(name string) [][]Message {
+	partitions, ok := b.topics[name]
+	if !ok {
+		partitions = make([][]Message, b.partitions)
+		b.topics[name] = partitions
+	}
+	return partitions
+}
+
+// partition выбирает партицию сообщения: по ключу или по очереди для сообщений без ключа.
+// Вызывается под блокировкой
+func (b *MemoryBroker) partition(m Message) int {
+	if len(m.Key) == 0 {
+		p := b.rr[m.Topic]
+		b.rr[m.Topic] = (p + 1) % b.partitions
+		return p
+	}
+	h := fnv.New32a()
+	h.Write(m.Key)
+	return int(h.Sum32() % uint32(b.partitions))
+}
+
+// rebalance распределяет партиции топиков группы между участниками, которые их читают,
+// и возвращает участников к зафиксированным смещениям. Вызывается под блокировкой
+func (b *MemoryBroker) rebalance(group *memoryGroup) {
+	readers := make(map[string][]*memorySubscription)
+	for _, member := range group.members {
+		member.assigned = nil
+		member.position = make(map[topicPartition]int64)
+		for _, topic := range member.topics {
+			readers[topic] = append(readers[topic], member)
+		}
+	}
+
+	topics := make([]string, 0, len(readers))
+	for topic := range readers {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	for _, topic := range topics {
+		for p := range b.topics[topic] {
+			member := readers[topic][p%len(readers[topic])]
+			tp := topicPartition{topic: topic, partition: p}
+			member.assigned = append(member.assigned, tp)
+			member.position[tp] = group.committed[tp]
+		}
+	}
+	b.notify()
+}
+
+// notify будит участников, ожидающих сообщений. Вызывается под блокировкой
+func (b *MemoryBroker) notify() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// memorySubscription - участник группы потребителей брокера в памяти
+type memorySubscription struct {
+	broker   *MemoryBroker
+	group    *memoryGroup
+	topics   []string
+	assigned []topicPartition
+	position map[topicPartition]int64 // Смещение следующего сообщения для чтения
+	next     int                      // Партиция, с которой начинается поиск сообщения, чтобы читать партиции по очереди
+	closed   bool
+}
+
+// Fetch возвращает следующее сообщение назначенных участнику партиций, ожидая его появления
+func (s *memorySubscription) Fetch(ctx context.Context) (Message, error) {
+	b := s.broker
+	for {
+		b.mu.Lock()
+		if s.closed || b.closed {
+			b.mu.Unlock()
+			return Message{}, ErrBrokerClosed
+		}
+		if m, ok := s.take(); ok {
+			b.mu.Unlock()
+			return m, nil
+		}
+		wait := b.changed
+		b.mu.Unlock()
+
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return Message{}, ctx.Err()
+		}
+	}
+}
+
+// take возвращает следующее непрочитанное сообщение. Вызывается под блокировкой
+func (s *memorySubscription) take() (Message, bool) {
+	for i := range s.assigned {
+		tp := s.assigned[(s.next+i)%len(s.assigned)]
+		partition := s.broker.topics[tp.topic][tp.partition]
+		offset := s.position[tp]
+		if offset < int64(len(partition)) {
+			s.position[tp] = offset + 1
+			s.next = (s.next + i + 1) % len(s.assigned)
+			m := partition[offset]
+			m.Headers = copyHeaders(m.Headers)
+			return m, true
+		}
+	}
+	return Message{}, false
+}
+
+// Commit фиксирует смещение группы после сообщения msg. Смещение фиксирует только участник,
+// которому назначена партиция, и оно не сдвигается назад
+func (s *memorySubscription) Commit(ctx context.Context, msg Message) error {
+	b := s.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	tp := topicPartition{topic: msg.Topic, partition: msg.Partition}
+	if _, ok := s.position[tp]; !ok || s.closed {
+		return fmt.Errorf("партиция %s/%d не назначена участнику группы", msg.Topic, msg.Partition)
+	}
+	if msg.Offset+1 > s.group.committed[tp] {
+		s.group.committed[tp] = msg.Offset + 1
+	}
+	return nil
+}
+
+// Close отключает участника от группы, его партиции распределяются между остальными участниками
+func (s *memorySubscription) Close() error {
+	b := s.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	members := s.group.members[:0]
+	for _, member := range s.group.members {
+		if member != s {
+			members = append(members, member)
+		}
+	}
+	s.group.members = members
+	s.position = nil
+	b.rebalance(s.group)
+	return nil
+}
+
+// copyHeaders копирует заголовки, чтобы получатели не меняли сохраненные сообщения
+func copyHeaders(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+	copied := make(map[string]string, len(headers))
+	for key, value := range headers {
+		copied[key] = value
+	}
+	return copied
+}
@@ -0,0 +1,236 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fetch читает сообщение, не дожидаясь новых дольше секунды
+func fetch(t *testing.T, sub Subscription) Message {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	m, err := sub.Fetch(ctx)
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	return m
+}
+
+// drain читает все доступные сообщения
+func drain(sub Subscription) []Message {
+	var messages []Message
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		m, err := sub.Fetch(ctx)
+		cancel()
+		if err != nil {
+			return messages
+		}
+		messages = append(messages, m)
+	}
+}
+
+func subscribe(t *testing.T, b *MemoryBroker, groupID string, topics ...string) Subscription {
+	t.Helper()
+	sub, err := b.Subscribe(groupID, topics)
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	t.Cleanup(func() { sub.Close() })
+	return sub
+}
+
+func TestMemoryBroker_PartitionsByKey(t *testing.T) {
+	b := NewMemoryBrokerWithPartitions(3)
+	ctx := context.Background()
+	for _, key := range []string{"1", "2", "1", "1"} {
+		if err := b.Publish(ctx, Message{Topic: "parcels", Key: []byte(key), Value: []byte(key)}); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+	}
+
+	// Сообщения с одним ключом попадают в одну партицию и получают смещения по порядку
+	var keyed []Message
+	for _, m := range b.Messages("parcels") {
+		if string(m.Key) == "1" {
+			keyed = append(keyed, m)
+		}
+	}
+	if len(keyed) != 3 {
+		t.Fatalf("сообщений с ключом 1: %d, want 3", len(keyed))
+	}
+	for i := 1; i < len(keyed); i++ {
+		if keyed[i].Partition != keyed[0].Partition || keyed[i].Offset <= keyed[i-1].Offset {
+			t.Errorf("сообщения с ключом 1: %v", keyed)
+		}
+	}
+
+	// Сообщения без ключа распределяются по партициям по очереди
+	for i := 0; i < 3; i++ {
+		b.Publish(ctx, Message{Topic: "events"})
+	}
+	for i, m := range b.Messages("events") {
+		if m.Partition != i || m.Offset != 0 {
+			t.Errorf("сообщение %d записано в %d/%d, want %d/0", i, m.Partition, m.Offset, i)
+		}
+	}
+}
+
+func TestMemoryBroker_GroupReadsOnce(t *testing.T) {
+	b := NewMemoryBroker()
+	ctx := context.Background()
+	for i := 0; i < 8; i++ {
+		b.Publish(ctx, Message{Topic: "parcels", Value: []byte{byte(i)}})
+	}
+
+	first := subscribe(t, b, "dispatch", "parcels")
+	second := subscribe(t, b, "dispatch", "parcels")
+
+	// Участники одной группы делят партиции: каждое сообщение читает один из них
+	got := append(drain(first), drain(second)...)
+	if len(got) != 8 {
+		t.Errorf("группа прочитала %d сообщений, want 8", len(got))
+	}
+	seen := make(map[byte]bool)
+	for _, m := range got {
+		if seen[m.Value[0]] {
+			t.Errorf("сообщение %d прочитано дважды", m.Value[0])
+		}
+		seen[m.Value[0]] = true
+	}
+
+	// Другая группа читает топик независимо, с начала
+	if other := drain(subscribe(t, b, "audit", "parcels")); len(other) != 8 {
+		t.Errorf("новая группа прочитала %d сообщений, want 8", len(other))
+	}
+}
+
+func TestMemoryBroker_CommitAndRebalance(t *testing.T) {
+	b := NewMemoryBrokerWithPartitions(1)
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		b.Publish(ctx, Message{Topic: "deliveries", Value: []byte{byte(i)}})
+	}
+
+	first := subscribe(t, b, "payments", "deliveries")
+	m := fetch(t, first)
+	if err := first.Commit(ctx, m); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	fetch(t, first) // Прочитано, но не зафиксировано
+	second := subscribe(t, b, "payments", "deliveries")
+	if err := first.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// Партиция перешла ко второму участнику, и он читает ее с зафиксированного смещения
+	if got := fetch(t, second); got.Offset != 1 {
+		t.Errorf("после перераспределения прочитано смещение %d, want 1", got.Offset)
+	}
+	if got := b.Committed("payments", "deliveries", 0); got != 1 {
+		t.Errorf("Committed() = %d, want 1", got)
+	}
+	if err := first.Commit(ctx, m); err == nil {
+		t.Error("отключенный участник зафиксировал смещение")
+	}
+}
+
+func TestMemoryBroker_Replay(t *testing.T) {
+	b := NewMemoryBrokerWithPartitions(2)
+	ctx := context.Background()
+	for i := 0; i < 4; i++ {
+		b.Publish(ctx, Message{Topic: "parcels", Value: []byte{byte(i)}})
+	}
+
+	sub := subscribe(t, b, "dispatch", "parcels")
+	for _, m := range drain(sub) {
+		sub.Commit(ctx, m)
+	}
+	if got := drain(sub); len(got) != 0 {
+		t.Fatalf("после фиксации прочитано %d сообщений, want 0", len(got))
+	}
+
+	b.Replay("dispatch", "parcels")
+	if got := drain(sub); len(got) != 4 {
+		t.Errorf("после Replay прочитано %d сообщений, want 4", len(got))
+	}
+}
+
+func TestMemoryBroker_FetchWaits(t *testing.T) {
+	b := NewMemoryBroker()
+	sub := subscribe(t, b, "dispatch", "parcels")
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		b.Publish(context.Background(), Message{Topic: "parcels", Key: []byte("1"), Value: []byte("ok")})
+	}()
+	if m := fetch(t, sub); string(m.Value) != "ok" {
+		t.Errorf("прочитано %q, want ok", m.Value)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := sub.Fetch(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Fetch() без сообщений error = %v, want DeadlineExceeded", err)
+	}
+
+	b.Close()
+	if _, err := sub.Fetch(context.Background()); !errors.Is(err, ErrBrokerClosed) {
+		t.Errorf("Fetch() после Close error = %v, want ErrBrokerClosed", err)
+	}
+	if err := b.Publish(context.Background(), Message{Topic: "parcels"}); !errors.Is(err, ErrBrokerClosed) {
+		t.Errorf("Publish() после Close error = %v, want ErrBrokerClosed", err)
+	}
+}
+
+func TestMemoryBroker_ConsumerGroup(t *testing.T) {
+	b := NewMemoryBrokerWithPartitions(2)
+	g := NewConsumerGroup(b, "payments").
+		WithRetry(RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond})
+
+	var mu sync.Mutex
+	var handled []int
+	Handle(g, "deliveries", "DeliveryCompleted", func(ctx context.Context, e testEvent) error {
+		if e.ID == 0 {
+			return errors.New("сбой")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, e.ID)
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- g.Run(ctx) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	for _, value := range []string{`{"id":1}`, `{"id":0}`, `{"id":2}`} {
+		msg := event("deliveries", "DeliveryCompleted", 0, value)
+		msg.Key = []byte(value)
+		if err := b.Publish(context.Background(), msg); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+	}
+
+	committed := func() int64 {
+		return b.Committed("payments", "deliveries", 0) + b.Committed("payments", "deliveries", 1)
+	}
+	waitFor(t, func() bool { return committed() == 3 })
+	mu.Lock()
+	defer mu.Unlock()
+	if len(handled) != 2 {
+		t.Errorf("обработаны события %v, want 2 события", handled)
+	}
+	dead := b.Messages("deliveries" + DLQSuffix)
+	if len(dead) != 1 || string(dead[0].Value) != `{"id":0}` || dead[0].Headers[HeaderDLQAttempts] != "2" {
+		t.Errorf("сообщения DLQ = %v", dead)
+	}
+}
@@ -7,6 +7,18 @@ import (
 	"github.com/segmentio/kafka-go"
 )
 
+// Message - сообщение с ключом и заголовками. Сообщения с одинаковым ключом попадают в одну партицию
+type Message struct {
+	Topic   string
+	Key     []byte
+	Value   []byte
+	Headers map[string]string
+
+	// Партиция и смещение прочитанного сообщения, при публикации не используются
+	Partition int
+	Offset    int64
+}
+
 type Producer struct {
 	writer *kafka.Writer
 }
@@ -14,13 +26,18 @@ type Producer struct {
 func NewProducer(broker string) (*Producer, error) {
 	writer := &kafka.Writer{
 		Addr:         kafka.TCP(broker),
+		Balancer:     &kafka.Hash{}, // Партиция выбирается по ключу, сообщения без ключа распределяются по очереди
 		BatchTimeout: 5 * time.Millisecond,
 		RequiredAcks: kafka.RequireOne,
+		// Топики событий и DLQ создаются при первой записи
+		AllowAutoTopicCreation: true,
 	}
 
 	return &Producer{writer: writer}, nil
 }
 
+// Produce отправляет значение без ключа и заголовков. Доменные события публикуются
+// через Publish в конверте, см. EventMessage
 func (p *Producer) Produce(topic string, value []byte) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -33,6 +50,19 @@ func (p *Producer) Produce(topic string, value []byte) error {
 	)
 }
 
+// Publish отправляет сообщения одним пакетом и возвращает ошибку, если хотя бы одно из них не записано
+func (p *Producer) Publish(ctx context.Context, messages ...Message) error {
+	batch := make([]kafka.Message, 0, len(messages))
+	for _, m := range messages {
+		msg := kafka.Message{Topic: m.Topic, Key: m.Key, Value: m.Value}
+		for key, value := range m.Headers {
+			msg.Headers = append(msg.Headers, kafka.Header{Key: key, Value: []byte(value)})
+		}
+		batch = append(batch, msg)
+	}
+	return p.writer.WriteMessages(ctx, batch...)
+}
+
 func (p *Producer) Close() error {
 	return p.writer.Close()
 }
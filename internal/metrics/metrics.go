@@ -59,6 +59,15 @@ var (
 		[]string{"status"},
 	)
 
+	// DispatchAssignedTotal счетчик доставок, назначенных автоматическим распределением
+	DispatchAssignedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "dispatch_assigned_total",
+			Help: "Total number of deliveries assigned by the dispatcher",
+		},
+		[]string{"strategy"},
+	)
+
 	// PaymentProcessedTotal счетчик обработанных платежей
 	PaymentProcessedTotal = promauto.NewCounterVec(
 		prometheus.CounterOpts{
@@ -85,6 +94,32 @@ var (
 		[]string{"topic"},
 	)
 
+	// KafkaMessagesDeadLetteredTotal счетчик сообщений Kafka, отправленных в DLQ
+	KafkaMessagesDeadLetteredTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "kafka_messages_dead_lettered_total",
+			Help: "Total number of Kafka messages sent to a dead-letter topic",
+		},
+		[]string{"topic"},
+	)
+
+	// OutboxEventsPublishedTotal счетчик доменных событий, опубликованных из outbox
+	OutboxEventsPublishedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "outbox_events_published_total",
+			Help: "Total number of domain events published from the outbox",
+		},
+		[]string{"topic"},
+	)
+
+	// OutboxPublishErrorsTotal счетчик неудачных попыток публикации событий из outbox
+	OutboxPublishErrorsTotal = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "outbox_publish_errors_total",
+			Help: "Total number of failed outbox publish attempts",
+		},
+	)
+
 	// DatabaseQueryDuration гистограмма времени выполнения запросов к БД
 	DatabaseQueryDuration = promauto.NewHistogramVec(
 		prometheus.HistogramOpts{
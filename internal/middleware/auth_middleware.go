@@ -3,6 +3,7 @@ package middleware
 import (
 	"context"
 	"delivery/internal/auth"
+	"delivery/internal/business/models"
 	"delivery/internal/cache"
 	"net/http"
 	"strings"
@@ -64,19 +65,21 @@ func (am *AuthMiddleware) Middleware() mux.MiddlewareFunc {
 			tokenString := parts[1]
 
 			// Проверяем токен
-			userID, err := am.authService.ValidateToken(tokenString)
+			claims, err := am.authService.ParseToken(tokenString)
 			if err != nil {
 				// Токен недействителен
 				http.Error(w, "Недействительный токен", http.StatusUnauthorized)
 				return
 			}
 
-			// Определяем роль пользователя (в реальном приложении это должно быть получено из базы данных)
-			// TODO: Получить роль пользователя из базы данных
-			role := "client" // По умолчанию считаем, что пользователь - клиент
+			// Роль пользователя берется из claims токена, выпущенного при входе
+			role := claims.Role
+			if role == "" {
+				role = models.UserRoleClient // По умолчанию считаем, что пользователь - клиент
+			}
 
 			// Добавляем информацию о пользователе в контекст
-			ctx := context.WithValue(r.Context(), UserIDKey, userID)
+			ctx := context.WithValue(r.Context(), UserIDKey, claims.UserID)
 			ctx = context.WithValue(ctx, UserRoleKey, role)
 			ctx = context.WithValue(ctx, TokenKey, tokenString)
 
@@ -49,6 +49,12 @@ func (m *MockAuthService) ValidateToken(tokenString string) (int, error) {
 	return args.Int(0), args.Error(1)
 }
 
+func (m *MockAuthService) ParseToken(tokenString string) (*auth.Claims, error) {
+	args := m.Called(tokenString)
+	claims, _ := args.Get(0).(*auth.Claims)
+	return claims, args.Error(1)
+}
+
 func (m *MockAuthService) WithCache(cacheClient *cache.RedisClient) *auth.AuthService {
 	args := m.Called(cacheClient)
 	return args.Get(0).(*auth.AuthService)
@@ -65,7 +71,7 @@ func TestAuthMiddleware_Middleware(t *testing.T) {
 		mockAuthService := new(MockAuthService)
 
 		// Настраиваем ожидаемое поведение
-		mockAuthService.On("ValidateToken", "valid-token").Return(123, nil)
+		mockAuthService.On("ParseToken", "valid-token").Return(&auth.Claims{UserID: 123, Role: "client"}, nil)
 
 		// Создаем AuthMiddleware с мок-сервисом
 		authMiddleware := NewAuthMiddleware(mockAuthService)
@@ -166,7 +172,7 @@ func TestAuthMiddleware_Middleware(t *testing.T) {
 		mockAuthService := new(MockAuthService)
 
 		// Настраиваем ожидаемое поведение
-		mockAuthService.On("ValidateToken", "invalid-token").Return(0, errors.New("недействительный токен"))
+		mockAuthService.On("ParseToken", "invalid-token").Return(nil, errors.New("недействительный токен"))
 
 		// Создаем AuthMiddleware с мок-сервисом
 		authMiddleware := NewAuthMiddleware(mockAuthService)
@@ -236,7 +242,7 @@ func TestAuthMiddleware_WithRedis(t *testing.T) {
 		authMiddleware := NewAuthMiddleware(mockAuthService)
 
 		// Настраиваем ожидаемое поведение для сервиса аутентификации
-		mockAuthService.On("ValidateToken", "blacklisted-token").Return(0, errors.New("недействительный токен")).Once()
+		mockAuthService.On("ParseToken", "blacklisted-token").Return(nil, errors.New("недействительный токен")).Once()
 
 		// Создаем тестовый обработчик
 		testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -279,7 +285,7 @@ func TestAuthMiddleware_WithRedis(t *testing.T) {
 		mockAuthService.ExpectedCalls = nil
 
 		// Настраиваем ожидаемое поведение для сервиса аутентификации
-		mockAuthService.On("ValidateToken", "valid-token").Return(123, nil).Once()
+		mockAuthService.On("ParseToken", "valid-token").Return(&auth.Claims{UserID: 123, Role: "client"}, nil).Once()
 
 		// Создаем AuthMiddleware с мок-сервисом
 		authMiddleware := NewAuthMiddleware(mockAuthService)
@@ -1,7 +1,10 @@
 package middleware
 
 import (
+	"bufio"
 	"delivery/internal/metrics"
+	"fmt"
+	"net"
 	"net/http"
 	"strconv"
 	"time"
@@ -48,3 +51,18 @@ func (rw *ResponseWriter) WriteHeader(code int) {
 func (rw *ResponseWriter) Status() int {
 	return rw.statusCode
 }
+
+// Unwrap возвращает исходный http.ResponseWriter, чтобы http.ResponseController
+// мог сбрасывать буфер потоковых ответов (Server-Sent Events)
+func (rw *ResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// Hijack передает соединение обработчику, например для установки WebSocket соединения
+func (rw *ResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("ResponseWriter не поддерживает Hijack")
+	}
+	return hijacker.Hijack()
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMetricsMiddleware_Streaming(t *testing.T) {
+	var flushErr error
+	var hijackable bool
+	handler := MetricsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, hijackable = w.(http.Hijacker)
+		w.WriteHeader(http.StatusAccepted)
+		flushErr = http.NewResponseController(w).Flush()
+	}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/events/orders/1", nil))
+
+	if flushErr != nil || !recorder.Flushed {
+		t.Errorf("Flush() error = %v, flushed = %v", flushErr, recorder.Flushed)
+	}
+	if !hijackable {
+		t.Error("обертка не реализует http.Hijacker, WebSocket соединение не установится")
+	}
+	if recorder.Code != http.StatusAccepted {
+		t.Errorf("статус = %d, want %d", recorder.Code, http.StatusAccepted)
+	}
+}
@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Журнал изменений конфигурации Rate Limiting
+const (
+	rateLimitConfigHistoryKey = "rate_limit_config:history"
+	maxConfigHistory          = 100 // Сколько последних изменений хранить
+)
+
+// Типы заблокированных клиентов
+const (
+	BlockedTypeIP   = "ip"
+	BlockedTypeUser = "user"
+)
+
+// RateLimitConfigChange - запись журнала изменений конфигурации
+type RateLimitConfigChange struct {
+	ChangedBy int             `json:"changed_by"` // ID администратора
+	ChangedAt time.Time       `json:"changed_at"`
+	Previous  RateLimitConfig `json:"previous"`
+	Config    RateLimitConfig `json:"config"`
+}
+
+// BlockedClient - IP-адрес или пользователь, заблокированный за превышение лимита
+type BlockedClient struct {
+	Type      string `json:"type"` // ip или user
+	ID        string `json:"id"`
+	Reason    string `json:"reason"`
+	ExpiresIn int    `json:"expires_in"` // Оставшееся время блокировки в секундах
+}
+
+// UpdateConfigBy проверяет и применяет новую конфигурацию, записывая в журнал, кто и когда ее изменил.
+// Изменение записывается в журнал до применения: конфигурация, которую не удалось записать в журнал,
+// не применяется
+func (rl *RateLimiter) UpdateConfigBy(ctx context.Context, config RateLimitConfig, changedBy int) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	change := RateLimitConfigChange{
+		ChangedBy: changedBy,
+		ChangedAt: time.Now().UTC(),
+		Previous:  rl.Config(),
+		Config:    config,
+	}
+	if err := rl.recordConfigChange(ctx, change); err != nil {
+		return err
+	}
+
+	if err := rl.UpdateConfig(config); err != nil {
+		return err
+	}
+	log.Printf("[RATE_LIMIT_CONFIG] Пользователь %d изменил конфигурацию Rate Limiting", changedBy)
+	return nil
+}
+
+// recordConfigChange добавляет запись в журнал изменений, сохраняя только последние maxConfigHistory записей.
+// Журнал - список Redis от новых записей к старым; добавление и обрезка выполняются атомарно,
+// поэтому одновременные изменения не теряют записи
+func (rl *RateLimiter) recordConfigChange(ctx context.Context, change RateLimitConfigChange) error {
+	data, err := json.Marshal(change)
+	if err != nil {
+		return fmt.Errorf("ошибка при сериализации изменения конфигурации: %w", err)
+	}
+
+	if err := rl.redisClient.LPushTrim(ctx, rateLimitConfigHistoryKey, string(data), maxConfigHistory); err != nil {
+		return fmt.Errorf("ошибка при записи изменения конфигурации в журнал: %w", err)
+	}
+	return nil
+}
+
+// ConfigHistory возвращает журнал изменений конфигурации, от старых к новым
+func (rl *RateLimiter) ConfigHistory(ctx context.Context) ([]RateLimitConfigChange, error) {
+	entries, err := rl.redisClient.LRange(ctx, rateLimitConfigHistoryKey, 0, maxConfigHistory-1)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении журнала изменений конфигурации: %w", err)
+	}
+
+	history := make([]RateLimitConfigChange, len(entries))
+	for i, entry := range entries {
+		// В списке новые записи идут первыми
+		if err := json.Unmarshal([]byte(entry), &history[len(entries)-1-i]); err != nil {
+			return nil, fmt.Errorf("ошибка при разборе журнала изменений конфигурации: %w", err)
+		}
+	}
+	return history, nil
+}
+
+// ListBlocked возвращает заблокированные IP-адреса и пользователей
+func (rl *RateLimiter) ListBlocked(ctx context.Context) ([]BlockedClient, error) {
+	keys, err := rl.redisClient.Keys(ctx, rateLimitBlockPrefix+"*")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении заблокированных клиентов: %w", err)
+	}
+
+	blocked := make([]BlockedClient, 0, len(keys))
+	for _, key := range keys {
+		entry := BlockedClient{Type: BlockedTypeIP, ID: strings.TrimPrefix(key, rateLimitBlockPrefix)}
+		if strings.HasPrefix(key, rateLimitUserBlockPrefix) {
+			entry = BlockedClient{Type: BlockedTypeUser, ID: strings.TrimPrefix(key, rateLimitUserBlockPrefix)}
+		}
+
+		// Ключ мог истечь между Keys и Get
+		reason, err := rl.redisClient.Get(ctx, key)
+		if err != nil {
+			continue
+		}
+		entry.Reason = reason
+
+		if ttl, err := rl.redisClient.TTL(ctx, key); err == nil && ttl > 0 {
+			entry.ExpiresIn = int(ttl.Seconds())
+		}
+
+		blocked = append(blocked, entry)
+	}
+
+	return blocked, nil
+}
+
+// UnblockIP снимает блокировку с IP-адреса и сбрасывает его счетчик запросов
+func (rl *RateLimiter) UnblockIP(ctx context.Context, ip string) error {
+	return rl.unblock(ctx, rateLimitBlockPrefix+ip, fmt.Sprintf("rate_limit:unauth:%s:count", ip))
+}
+
+// UnblockUser снимает блокировку с пользователя и сбрасывает его счетчики запросов
+func (rl *RateLimiter) UnblockUser(ctx context.Context, userID int) error {
+	keys := []string{rateLimitUserBlockPrefix + strconv.Itoa(userID)}
+
+	// Счетчик хранится под ключом роли пользователя, поэтому сбрасываем счетчики всех ролей
+	for role := range rl.Config().AuthenticatedLimits {
+		keys = append(keys, fmt.Sprintf("rate_limit:auth:%s:%d:count", role, userID))
+	}
+
+	return rl.unblock(ctx, keys...)
+}
+
+func (rl *RateLimiter) unblock(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		if err := rl.redisClient.Delete(ctx, key); err != nil {
+			return fmt.Errorf("ошибка при снятии блокировки: %w", err)
+		}
+	}
+	log.Printf("[RATE_LIMIT_UNBLOCK] Блокировка снята: %s", keys[0])
+	return nil
+}
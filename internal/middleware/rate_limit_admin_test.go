@@ -0,0 +1,189 @@
+package middleware
+
+import (
+	"context"
+	"delivery/internal/cache"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRateLimiter_UserBlocking(t *testing.T) {
+	okHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.RemoteAddr = "127.0.0.1:1234"
+		ctx := context.WithValue(req.Context(), UserIDKey, 123)
+		ctx = context.WithValue(ctx, UserRoleKey, "client")
+		return req.WithContext(ctx)
+	}
+
+	t.Run("Пользователь превышает лимит и блокируется", func(t *testing.T) {
+		mockRedis := new(MockRedisClient)
+		rateLimiter := NewRateLimiter(mockRedis, DefaultRateLimitConfig())
+
+		mockRedis.On("Get", mock.Anything, "rate_limit:block:127.0.0.1").Return("", cache.ErrKeyNotFound).Once()
+		mockRedis.On("Get", mock.Anything, "rate_limit:block:user:123").Return("", cache.ErrKeyNotFound).Once()
+		mockRedis.On("Get", mock.Anything, "rate_limit:auth:client:123:count").Return("30", nil).Once()
+		mockRedis.On("Set", mock.Anything, "rate_limit:auth:client:123:count", "31", time.Duration(0)).Return(nil).Once()
+		mockRedis.On("Set", mock.Anything, "rate_limit:block:user:123", mock.Anything, time.Minute).Return(nil).Once()
+
+		rr := httptest.NewRecorder()
+		rateLimiter.Middleware()(okHandler).ServeHTTP(rr, newRequest())
+
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		mockRedis.AssertExpectations(t)
+	})
+
+	t.Run("Заблокированный пользователь", func(t *testing.T) {
+		mockRedis := new(MockRedisClient)
+		rateLimiter := NewRateLimiter(mockRedis, DefaultRateLimitConfig())
+
+		mockRedis.On("Get", mock.Anything, "rate_limit:block:127.0.0.1").Return("", cache.ErrKeyNotFound).Once()
+		mockRedis.On("Get", mock.Anything, "rate_limit:block:user:123").Return("blocked", nil).Once()
+
+		rr := httptest.NewRecorder()
+		rateLimiter.Middleware()(okHandler).ServeHTTP(rr, newRequest())
+
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.Equal(t, "60", rr.Header().Get("Retry-After"))
+		mockRedis.AssertExpectations(t)
+	})
+}
+
+func TestRateLimiter_UpdateConfigBy(t *testing.T) {
+	newConfig := RateLimitConfig{
+		AuthenticatedLimits:  map[string]int{"client": 50},
+		UnauthenticatedLimit: 20,
+		BlockDuration:        2,
+	}
+
+	t.Run("Изменение записывается в журнал", func(t *testing.T) {
+		mockRedis := new(MockRedisClient)
+		rateLimiter := NewRateLimiter(mockRedis, DefaultRateLimitConfig())
+
+		var saved RateLimitConfigChange
+		mockRedis.On("LPushTrim", mock.Anything, "rate_limit_config:history", mock.Anything, int64(maxConfigHistory)).
+			Run(func(args mock.Arguments) {
+				assert.NoError(t, json.Unmarshal([]byte(args.String(2)), &saved))
+			}).Return(nil).Once()
+		mockRedis.On("Set", mock.Anything, "rate_limit_config", mock.Anything, time.Duration(0)).Return(nil).Once()
+
+		err := rateLimiter.UpdateConfigBy(context.Background(), newConfig, 7)
+		assert.NoError(t, err)
+
+		assert.Equal(t, 50, rateLimiter.Config().AuthenticatedLimits["client"])
+		assert.Equal(t, 7, saved.ChangedBy)
+		assert.Equal(t, 20, saved.Config.UnauthenticatedLimit)
+		assert.Equal(t, 15, saved.Previous.UnauthenticatedLimit)
+		assert.False(t, saved.ChangedAt.IsZero())
+		mockRedis.AssertExpectations(t)
+	})
+
+	t.Run("Ошибка журнала", func(t *testing.T) {
+		mockRedis := new(MockRedisClient)
+		rateLimiter := NewRateLimiter(mockRedis, DefaultRateLimitConfig())
+
+		mockRedis.On("LPushTrim", mock.Anything, "rate_limit_config:history", mock.Anything, mock.Anything).
+			Return(errors.New("redis недоступен")).Once()
+
+		err := rateLimiter.UpdateConfigBy(context.Background(), newConfig, 7)
+		assert.Error(t, err)
+		// Неучтенное в журнале изменение не применяется
+		assert.Equal(t, 15, rateLimiter.Config().UnauthenticatedLimit)
+		mockRedis.AssertNotCalled(t, "Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Некорректная конфигурация", func(t *testing.T) {
+		mockRedis := new(MockRedisClient)
+		rateLimiter := NewRateLimiter(mockRedis, DefaultRateLimitConfig())
+
+		invalid := newConfig
+		invalid.BlockDuration = 0
+
+		err := rateLimiter.UpdateConfigBy(context.Background(), invalid, 7)
+		assert.Error(t, err)
+		assert.Equal(t, 1, rateLimiter.Config().BlockDuration)
+		mockRedis.AssertNotCalled(t, "Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestRateLimiter_ConfigHistory(t *testing.T) {
+	mockRedis := new(MockRedisClient)
+	rateLimiter := NewRateLimiter(mockRedis, DefaultRateLimitConfig())
+
+	mockRedis.On("LRange", mock.Anything, "rate_limit_config:history", int64(0), int64(maxConfigHistory-1)).
+		Return([]string{`{"changed_by":2}`, `{"changed_by":1}`}, nil).Once()
+
+	history, err := rateLimiter.ConfigHistory(context.Background())
+	assert.NoError(t, err)
+	if assert.Len(t, history, 2) {
+		assert.Equal(t, 1, history[0].ChangedBy)
+		assert.Equal(t, 2, history[1].ChangedBy)
+	}
+
+	// Пустой журнал
+	mockRedis.On("LRange", mock.Anything, "rate_limit_config:history", mock.Anything, mock.Anything).Return([]string{}, nil).Once()
+	history, err = rateLimiter.ConfigHistory(context.Background())
+	assert.NoError(t, err)
+	assert.NotNil(t, history)
+	assert.Empty(t, history)
+	mockRedis.AssertExpectations(t)
+}
+
+func TestRateLimiter_ListBlocked(t *testing.T) {
+	mockRedis := new(MockRedisClient)
+	rateLimiter := NewRateLimiter(mockRedis, DefaultRateLimitConfig())
+
+	mockRedis.On("Keys", mock.Anything, "rate_limit:block:*").
+		Return([]string{"rate_limit:block:10.0.0.1", "rate_limit:block:user:42", "rate_limit:block:10.0.0.2"}, nil).Once()
+	mockRedis.On("Get", mock.Anything, "rate_limit:block:10.0.0.1").Return("blocked:count=16", nil).Once()
+	mockRedis.On("Get", mock.Anything, "rate_limit:block:user:42").Return("blocked:count=31", nil).Once()
+	// Блокировка истекла между Keys и Get
+	mockRedis.On("Get", mock.Anything, "rate_limit:block:10.0.0.2").Return("", cache.ErrKeyNotFound).Once()
+	mockRedis.On("TTL", mock.Anything, "rate_limit:block:10.0.0.1").Return(30*time.Second, nil).Once()
+	mockRedis.On("TTL", mock.Anything, "rate_limit:block:user:42").Return(45*time.Second, nil).Once()
+
+	blocked, err := rateLimiter.ListBlocked(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []BlockedClient{
+		{Type: BlockedTypeIP, ID: "10.0.0.1", Reason: "blocked:count=16", ExpiresIn: 30},
+		{Type: BlockedTypeUser, ID: "42", Reason: "blocked:count=31", ExpiresIn: 45},
+	}, blocked)
+	mockRedis.AssertExpectations(t)
+}
+
+func TestRateLimiter_Unblock(t *testing.T) {
+	t.Run("IP", func(t *testing.T) {
+		mockRedis := new(MockRedisClient)
+		rateLimiter := NewRateLimiter(mockRedis, DefaultRateLimitConfig())
+
+		mockRedis.On("Delete", mock.Anything, "rate_limit:block:10.0.0.1").Return(nil).Once()
+		mockRedis.On("Delete", mock.Anything, "rate_limit:unauth:10.0.0.1:count").Return(nil).Once()
+
+		assert.NoError(t, rateLimiter.UnblockIP(context.Background(), "10.0.0.1"))
+		mockRedis.AssertExpectations(t)
+	})
+
+	t.Run("Пользователь", func(t *testing.T) {
+		mockRedis := new(MockRedisClient)
+		rateLimiter := NewRateLimiter(mockRedis, DefaultRateLimitConfig())
+
+		mockRedis.On("Delete", mock.Anything, "rate_limit:block:user:42").Return(nil).Once()
+		for _, role := range []string{"client", "courier", "dispatcher", "admin"} {
+			mockRedis.On("Delete", mock.Anything, "rate_limit:auth:"+role+":42:count").Return(nil).Once()
+		}
+
+		assert.NoError(t, rateLimiter.UnblockUser(context.Background(), 42))
+		mockRedis.AssertExpectations(t)
+	})
+}
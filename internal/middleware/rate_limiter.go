@@ -10,11 +10,19 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
 )
 
+// Ключи Redis, используемые RateLimiter
+const (
+	rateLimitConfigKey       = "rate_limit_config"
+	rateLimitBlockPrefix     = "rate_limit:block:"
+	rateLimitUserBlockPrefix = "rate_limit:block:user:"
+)
+
 // RateLimitConfig содержит конфигурацию для ограничения частоты запросов
 type RateLimitConfig struct {
 	// Лимиты для аутентифицированных пользователей
@@ -25,12 +33,43 @@ type RateLimitConfig struct {
 	BlockDuration int `json:"block_duration"`
 }
 
+// Validate проверяет, что все лимиты и время блокировки положительны
+func (c RateLimitConfig) Validate() error {
+	if c.UnauthenticatedLimit <= 0 {
+		return fmt.Errorf("лимит для неаутентифицированных пользователей должен быть положительным")
+	}
+	if c.BlockDuration <= 0 {
+		return fmt.Errorf("время блокировки должно быть положительным")
+	}
+	if len(c.AuthenticatedLimits) == 0 {
+		return fmt.Errorf("не заданы лимиты для аутентифицированных пользователей")
+	}
+	for role, limit := range c.AuthenticatedLimits {
+		if limit <= 0 {
+			return fmt.Errorf("лимит для роли %s должен быть положительным", role)
+		}
+	}
+	return nil
+}
+
+// clone возвращает копию конфигурации, не разделяющую карту лимитов с оригиналом
+func (c RateLimitConfig) clone() RateLimitConfig {
+	limits := make(map[string]int, len(c.AuthenticatedLimits))
+	for role, limit := range c.AuthenticatedLimits {
+		limits[role] = limit
+	}
+	c.AuthenticatedLimits = limits
+	return c
+}
+
 // DefaultRateLimitConfig возвращает конфигурацию по умолчанию
 func DefaultRateLimitConfig() RateLimitConfig {
 	return RateLimitConfig{
 		AuthenticatedLimits: map[string]int{
-			"client":  30,  // Клиенты: 30 запросов в минуту
-			"courier": 137, // Курьеры: 137 запросов в минуту
+			"client":     30,  // Клиенты: 30 запросов в минуту
+			"courier":    137, // Курьеры: 137 запросов в минуту
+			"dispatcher": 300, // Диспетчеры: 300 запросов в минуту
+			"admin":      300, // Администраторы: 300 запросов в минуту
 		},
 		UnauthenticatedLimit: 15, // Неаутентифицированные: 15 запросов в минуту
 		BlockDuration:        1,  // Блокировка на 1 минуту при превышении лимита
@@ -40,6 +79,7 @@ func DefaultRateLimitConfig() RateLimitConfig {
 // RateLimiter представляет middleware для ограничения частоты запросов
 type RateLimiter struct {
 	redisClient cache.RedisClientInterface
+	mu          sync.RWMutex // Защищает config: конфигурация меняется через админский API во время работы
 	config      RateLimitConfig
 }
 
@@ -53,7 +93,7 @@ func NewRateLimiter(redisClient cache.RedisClientInterface, config RateLimitConf
 
 // LoadConfigFromRedis загружает конфигурацию из Redis
 func (rl *RateLimiter) LoadConfigFromRedis(ctx context.Context) error {
-	configJSON, err := rl.redisClient.Get(ctx, "rate_limit_config")
+	configJSON, err := rl.redisClient.Get(ctx, rateLimitConfigKey)
 	if err != nil {
 		// Если конфигурация не найдена, используем значения по умолчанию
 		log.Println("Конфигурация Rate Limiting не найдена в Redis, используем значения по умолчанию")
@@ -65,19 +105,21 @@ func (rl *RateLimiter) LoadConfigFromRedis(ctx context.Context) error {
 		return fmt.Errorf("ошибка при десериализации конфигурации Rate Limiting: %w", err)
 	}
 
+	rl.mu.Lock()
 	rl.config = config
+	rl.mu.Unlock()
 	log.Println("Конфигурация Rate Limiting загружена из Redis")
 	return nil
 }
 
 // SaveConfigToRedis сохраняет конфигурацию в Redis
 func (rl *RateLimiter) SaveConfigToRedis(ctx context.Context) error {
-	configJSON, err := json.Marshal(rl.config)
+	configJSON, err := json.Marshal(rl.Config())
 	if err != nil {
 		return fmt.Errorf("ошибка при сериализации конфигурации Rate Limiting: %w", err)
 	}
 
-	if err := rl.redisClient.Set(ctx, "rate_limit_config", string(configJSON), 0); err != nil {
+	if err := rl.redisClient.Set(ctx, rateLimitConfigKey, string(configJSON), 0); err != nil {
 		return fmt.Errorf("ошибка при сохранении конфигурации Rate Limiting в Redis: %w", err)
 	}
 
@@ -85,9 +127,18 @@ func (rl *RateLimiter) SaveConfigToRedis(ctx context.Context) error {
 	return nil
 }
 
+// Config возвращает копию текущей конфигурации
+func (rl *RateLimiter) Config() RateLimitConfig {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+	return rl.config.clone()
+}
+
 // UpdateConfig обновляет конфигурацию и сохраняет ее в Redis
 func (rl *RateLimiter) UpdateConfig(config RateLimitConfig) error {
-	rl.config = config
+	rl.mu.Lock()
+	rl.config = config.clone()
+	rl.mu.Unlock()
 	ctx := context.Background()
 	return rl.SaveConfigToRedis(ctx)
 }
@@ -113,13 +164,13 @@ func getClientIP(r *http.Request) string {
 
 // getUserID получает ID пользователя из контекста запроса
 func getUserID(r *http.Request) (int, bool) {
-	userID, ok := r.Context().Value("user_id").(int)
+	userID, ok := r.Context().Value(UserIDKey).(int)
 	return userID, ok
 }
 
 // getUserRole получает роль пользователя из контекста запроса
 func getUserRole(r *http.Request) (string, bool) {
-	role, ok := r.Context().Value("user_role").(string)
+	role, ok := r.Context().Value(UserRoleKey).(string)
 	return role, ok
 }
 
@@ -134,16 +185,17 @@ func (rl *RateLimiter) Middleware() mux.MiddlewareFunc {
 			}
 
 			ctx := r.Context()
+			config := rl.Config()
 
 			// Получаем IP-адрес клиента
 			clientIP := getClientIP(r)
 
 			// Проверяем, не заблокирован ли IP
-			blockKey := fmt.Sprintf("rate_limit:block:%s", clientIP)
+			blockKey := rateLimitBlockPrefix + clientIP
 			blockValue, err := rl.redisClient.Get(ctx, blockKey)
 			if err == nil {
 				// IP заблокирован
-				w.Header().Set("Retry-After", strconv.Itoa(rl.config.BlockDuration*60))
+				w.Header().Set("Retry-After", strconv.Itoa(config.BlockDuration*60))
 				http.Error(w, "Слишком много запросов. Пожалуйста, повторите попытку позже.", http.StatusTooManyRequests)
 
 				// Логируем блокировку с дополнительной информацией
@@ -159,29 +211,40 @@ func (rl *RateLimiter) Middleware() mux.MiddlewareFunc {
 
 			userID, authenticated := getUserID(r)
 			if authenticated {
+				// Проверяем, не заблокирован ли пользователь
+				userBlockKey := rateLimitUserBlockPrefix + strconv.Itoa(userID)
+				if blockValue, err := rl.redisClient.Get(ctx, userBlockKey); err == nil {
+					w.Header().Set("Retry-After", strconv.Itoa(config.BlockDuration*60))
+					http.Error(w, "Слишком много запросов. Пожалуйста, повторите попытку позже.", http.StatusTooManyRequests)
+
+					log.Printf("[RATE_LIMIT_BLOCKED] Пользователь %d заблокирован, значение: %s, URL: %s, метод: %s",
+						userID, blockValue, r.URL.Path, r.Method)
+					return
+				}
+
 				// Пользователь аутентифицирован
 				role, hasRole := getUserRole(r)
 				if hasRole {
 					// Используем лимит в зависимости от роли
-					if roleLimit, ok := rl.config.AuthenticatedLimits[role]; ok {
+					if roleLimit, ok := config.AuthenticatedLimits[role]; ok {
 						limit = roleLimit
 						keyPrefix = fmt.Sprintf("rate_limit:auth:%s:%d", role, userID)
 						userInfo = fmt.Sprintf("пользователь %d с ролью %s", userID, role)
 					} else {
 						// Если роль не найдена, используем лимит для клиентов
-						limit = rl.config.AuthenticatedLimits["client"]
+						limit = config.AuthenticatedLimits["client"]
 						keyPrefix = fmt.Sprintf("rate_limit:auth:client:%d", userID)
 						userInfo = fmt.Sprintf("пользователь %d с неизвестной ролью (используем client)", userID)
 					}
 				} else {
 					// Если роль не определена, используем лимит для клиентов
-					limit = rl.config.AuthenticatedLimits["client"]
+					limit = config.AuthenticatedLimits["client"]
 					keyPrefix = fmt.Sprintf("rate_limit:auth:client:%d", userID)
 					userInfo = fmt.Sprintf("пользователь %d без роли", userID)
 				}
 			} else {
 				// Пользователь не аутентифицирован
-				limit = rl.config.UnauthenticatedLimit
+				limit = config.UnauthenticatedLimit
 				keyPrefix = fmt.Sprintf("rate_limit:unauth:%s", clientIP)
 				userInfo = fmt.Sprintf("неаутентифицированный IP %s", clientIP)
 			}
@@ -225,11 +288,11 @@ func (rl *RateLimiter) Middleware() mux.MiddlewareFunc {
 
 			// Проверяем, не превышен ли лимит
 			if count > limit {
-				// Если пользователь не аутентифицирован, блокируем IP на указанное время
+				// Блокируем IP неаутентифицированного клиента или пользователя на указанное время
+				blockDuration := time.Duration(config.BlockDuration) * time.Minute
+				blockValue := fmt.Sprintf("blocked:count=%d:limit=%d:time=%s",
+					count, limit, time.Now().Format(time.RFC3339))
 				if !authenticated {
-					blockDuration := time.Duration(rl.config.BlockDuration) * time.Minute
-					blockValue := fmt.Sprintf("blocked:count=%d:limit=%d:time=%s",
-						count, limit, time.Now().Format(time.RFC3339))
 					err = rl.redisClient.Set(ctx, blockKey, blockValue, blockDuration)
 					if err != nil {
 						log.Printf("[RATE_LIMIT_ERROR] Ошибка при блокировке IP: %v", err)
@@ -240,6 +303,12 @@ func (rl *RateLimiter) Middleware() mux.MiddlewareFunc {
 				} else {
 					log.Printf("[RATE_LIMIT_EXCEEDED] %s превысил лимит: %d/%d запросов, URL: %s, метод: %s",
 						userInfo, count, limit, r.URL.Path, r.Method)
+					err = rl.redisClient.Set(ctx, rateLimitUserBlockPrefix+strconv.Itoa(userID), blockValue, blockDuration)
+					if err != nil {
+						log.Printf("[RATE_LIMIT_ERROR] Ошибка при блокировке пользователя: %v", err)
+					} else {
+						log.Printf("[RATE_LIMIT_BLOCK] Пользователь %d заблокирован на %v", userID, blockDuration)
+					}
 				}
 
 				// Возвращаем ошибку 429 Too Many Requests
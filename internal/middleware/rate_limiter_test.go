@@ -41,6 +41,28 @@ func (m *MockRedisClient) Delete(ctx context.Context, key string) error {
 	return args.Error(0)
 }
 
+func (m *MockRedisClient) Keys(ctx context.Context, pattern string) ([]string, error) {
+	args := m.Called(ctx, pattern)
+	keys, _ := args.Get(0).([]string)
+	return keys, args.Error(1)
+}
+
+func (m *MockRedisClient) TTL(ctx context.Context, key string) (time.Duration, error) {
+	args := m.Called(ctx, key)
+	return args.Get(0).(time.Duration), args.Error(1)
+}
+
+func (m *MockRedisClient) LPushTrim(ctx context.Context, key string, value interface{}, maxLen int64) error {
+	args := m.Called(ctx, key, value, maxLen)
+	return args.Error(0)
+}
+
+func (m *MockRedisClient) LRange(ctx context.Context, key string, start, stop int64) ([]string, error) {
+	args := m.Called(ctx, key, start, stop)
+	entries, _ := args.Get(0).([]string)
+	return entries, args.Error(1)
+}
+
 func (m *MockRedisClient) Close() error {
 	args := m.Called()
 	return args.Error(0)
@@ -83,6 +105,25 @@ func (m *MockRedisClient) ScheduleRedisCleanup(interval time.Duration) chan bool
 	return args.Get(0).(chan bool)
 }
 
+// Publish отправляет сообщение подписчикам канала Redis Pub/Sub
+func (m *MockRedisClient) Publish(ctx context.Context, channel string, message []byte) error {
+	args := m.Called(ctx, channel, message)
+	return args.Error(0)
+}
+
+// Subscribe подписывается на канал Redis Pub/Sub
+func (m *MockRedisClient) Subscribe(ctx context.Context, channel string) (<-chan []byte, error) {
+	args := m.Called(ctx, channel)
+	messages, _ := args.Get(0).(<-chan []byte)
+	return messages, args.Error(1)
+}
+
+// Incr увеличивает счетчик на 1
+func (m *MockRedisClient) Incr(ctx context.Context, key string) (int64, error) {
+	args := m.Called(ctx, key)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 func TestRateLimiter_Middleware(t *testing.T) {
 	// Создаем мок для RedisClient
 	mockRedis := new(MockRedisClient)
@@ -208,6 +249,9 @@ func TestRateLimiter_Middleware(t *testing.T) {
 		// Настраиваем мок для проверки блокировки IP
 		mockRedis.On("Get", mock.Anything, "rate_limit:block:127.0.0.1").Return("", cache.ErrKeyNotFound).Once()
 
+		// Настраиваем мок для проверки блокировки пользователя
+		mockRedis.On("Get", mock.Anything, "rate_limit:block:user:123").Return("", cache.ErrKeyNotFound).Once()
+
 		// Настраиваем мок для получения счетчика запросов для аутентифицированного пользователя
 		mockRedis.On("Get", mock.Anything, "rate_limit:auth:client:123:count").Return("", cache.ErrKeyNotFound).Once()
 
@@ -220,8 +264,8 @@ func TestRateLimiter_Middleware(t *testing.T) {
 		req.RemoteAddr = "127.0.0.1:1234"
 
 		// Добавляем информацию о пользователе в контекст
-		ctx := context.WithValue(req.Context(), "user_id", 123)
-		ctx = context.WithValue(ctx, "user_role", "client")
+		ctx := context.WithValue(req.Context(), TestUserIDKey, 123)
+		ctx = context.WithValue(ctx, TestUserRoleKey, "client")
 		req = req.WithContext(ctx)
 
 		// Создаем ResponseRecorder для записи ответа
@@ -255,6 +299,9 @@ func TestRateLimiter_Middleware(t *testing.T) {
 		// Настраиваем мок для проверки блокировки IP
 		mockRedis.On("Get", mock.Anything, "rate_limit:block:127.0.0.1").Return("", cache.ErrKeyNotFound).Once()
 
+		// Настраиваем мок для проверки блокировки пользователя
+		mockRedis.On("Get", mock.Anything, "rate_limit:block:user:456").Return("", cache.ErrKeyNotFound).Once()
+
 		// Настраиваем мок для получения счетчика запросов для аутентифицированного пользователя
 		mockRedis.On("Get", mock.Anything, "rate_limit:auth:courier:456:count").Return("", cache.ErrKeyNotFound).Once()
 
@@ -267,8 +314,8 @@ func TestRateLimiter_Middleware(t *testing.T) {
 		req.RemoteAddr = "127.0.0.1:1234"
 
 		// Добавляем информацию о пользователе в контекст
-		ctx := context.WithValue(req.Context(), "user_id", 456)
-		ctx = context.WithValue(ctx, "user_role", "courier")
+		ctx := context.WithValue(req.Context(), TestUserIDKey, 456)
+		ctx = context.WithValue(ctx, TestUserRoleKey, "courier")
 		req = req.WithContext(ctx)
 
 		// Создаем ResponseRecorder для записи ответа
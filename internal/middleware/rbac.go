@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"delivery/internal/business/models"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// RequireRoles возвращает middleware, пропускающий только пользователей с одной из указанных ролей.
+// Администратор имеет доступ ко всем маршрутам. Пустой список ролей означает,
+// что достаточно быть аутентифицированным.
+//
+// Должен применяться после AuthMiddleware, который кладет ID и роль пользователя в контекст.
+func RequireRoles(roles ...string) mux.MiddlewareFunc {
+	allowed := make(map[string]bool, len(roles)+1)
+	for _, role := range roles {
+		allowed[role] = true
+	}
+	allowed[models.UserRoleAdmin] = true
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, authenticated := getUserID(r)
+			if !authenticated {
+				http.Error(w, "Требуется аутентификация", http.StatusUnauthorized)
+				return
+			}
+
+			if len(roles) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			role, _ := getUserRole(r)
+			if !allowed[role] {
+				log.Printf("[ACCESS_DENIED] Пользователь %d с ролью %s не имеет доступа к %s %s",
+					userID, role, r.Method, r.URL.Path)
+				http.Error(w, "Недостаточно прав", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireRoles(t *testing.T) {
+	okHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name          string
+		roles         []string
+		authenticated bool
+		role          string
+		wantStatus    int
+	}{
+		{"Без аутентификации", []string{"courier"}, false, "", http.StatusUnauthorized},
+		{"Разрешенная роль", []string{"courier"}, true, "courier", http.StatusOK},
+		{"Запрещенная роль", []string{"courier"}, true, "client", http.StatusForbidden},
+		{"Администратор имеет доступ всегда", []string{"courier"}, true, "admin", http.StatusOK},
+		{"Любой аутентифицированный", nil, true, "client", http.StatusOK},
+		{"Любой аутентифицированный без токена", nil, false, "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/test", nil)
+			if tt.authenticated {
+				ctx := context.WithValue(req.Context(), UserIDKey, 1)
+				ctx = context.WithValue(ctx, UserRoleKey, tt.role)
+				req = req.WithContext(ctx)
+			}
+
+			rr := httptest.NewRecorder()
+			RequireRoles(tt.roles...)(okHandler).ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.wantStatus, rr.Code)
+		})
+	}
+}
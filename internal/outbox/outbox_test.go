@@ -0,0 +1,188 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"delivery/internal/business/models"
+	"delivery/internal/events"
+	"delivery/internal/kafka"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePublisher запоминает опубликованные сообщения или возвращает ошибку err
+type fakePublisher struct {
+	messages []kafka.Message
+	err      error
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, messages ...kafka.Message) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.messages = append(p.messages, messages...)
+	return nil
+}
+
+var outboxColumns = []string{"id", "topic", "event_key", "event_type", "event_version", "payload", "created_at", "attempts", "correlation_id"}
+
+func expectLock(mock sqlmock.Sqlmock, locked bool) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_try_advisory_xact_lock($1)")).
+		WithArgs(relayLockKey).
+		WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(locked))
+}
+
+func TestInTx(t *testing.T) {
+	t.Run("Изменение и события в одной транзакции", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE parcels").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO outbox (topic, event_key, event_type, event_version, payload, created_at, correlation_id)")).
+			WithArgs(models.TopicParcels, "7", models.EventParcelStatusChanged, 1, []byte(`{"parcel_id":7,"status":"sent","changed_at":"0001-01-01T00:00:00Z"}`), sqlmock.AnyArg(), nil).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err = InTx(NewStore(db), func(tx *sql.Tx) ([]models.OutboxEvent, error) {
+			require.NotNil(t, tx)
+			if _, err := tx.Exec("UPDATE parcels SET status = 'sent' WHERE id = 7"); err != nil {
+				return nil, err
+			}
+			return Event(models.TopicParcels, 7, models.EventParcelStatusChanged, models.ParcelStatusChangedEvent{ParcelID: 7, Status: "sent"})
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Ошибка изменения отменяет транзакцию", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		changeErr := errors.New("посылка не найдена")
+		err = InTx(NewStore(db), func(tx *sql.Tx) ([]models.OutboxEvent, error) {
+			return nil, changeErr
+		})
+		assert.ErrorIs(t, err, changeErr)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Незарегистрированное событие", func(t *testing.T) {
+		_, err := Event(models.TopicParcels, 7, "ParcelLost", nil)
+		assert.ErrorIs(t, err, events.ErrUnknownType)
+
+		_, err = Event(models.TopicDeliveries, 7, models.EventParcelRegistered, nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("Без outbox", func(t *testing.T) {
+		called := false
+		err := InTx(nil, func(tx *sql.Tx) ([]models.OutboxEvent, error) {
+			called = true
+			assert.Nil(t, tx)
+			return Event(models.TopicParcels, 7, models.EventParcelStatusChanged, nil)
+		})
+		assert.NoError(t, err)
+		assert.True(t, called)
+	})
+}
+
+func TestRelay_RelayOnce(t *testing.T) {
+	t.Run("Публикует и отмечает события", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		createdAt := time.Now().UTC()
+		mock.ExpectBegin()
+		expectLock(mock, true)
+		mock.ExpectQuery("SELECT id, topic, event_key, event_type, event_version, payload, created_at, attempts, correlation_id FROM outbox").
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows(outboxColumns).
+				AddRow(1, models.TopicParcels, "7", models.EventParcelRegistered, 1, []byte(`{"parcel_id":7}`), createdAt, 0, nil).
+				AddRow(2, models.TopicDeliveries, "3", models.EventDeliveryAssigned, 1, []byte(`{"delivery_id":3}`), createdAt, 1, "1"))
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE outbox SET sent_at = $1, last_error = NULL WHERE id = ANY($2)")).
+			WithArgs(sqlmock.AnyArg(), pq.Array([]int64{1, 2})).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+
+		publisher := &fakePublisher{}
+		n, err := NewRelay(NewStore(db), publisher).WithBatchSize(2).RelayOnce(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+
+		require.Len(t, publisher.messages, 2)
+		msg := publisher.messages[0]
+		assert.Equal(t, models.TopicParcels, msg.Topic)
+		assert.Equal(t, []byte("7"), msg.Key)
+		assert.Equal(t, map[string]string{"event_id": "1", "event_type": models.EventParcelRegistered, "event_version": "1"}, msg.Headers)
+
+		envelope, err := events.Decode(msg.Value)
+		require.NoError(t, err)
+		assert.Equal(t, "1", envelope.ID)
+		assert.Equal(t, models.EventParcelRegistered, envelope.Type)
+		assert.Equal(t, 1, envelope.Version)
+		assert.Equal(t, "7", envelope.AggregateID)
+		assert.Equal(t, "1", envelope.CorrelationID)
+		assert.True(t, createdAt.Equal(envelope.OccurredAt))
+		assert.JSONEq(t, `{"parcel_id":7}`, string(envelope.Payload))
+		assert.Equal(t, models.TopicDeliveries, publisher.messages[1].Topic)
+
+		// Событие, записанное в цепочке, публикуется с ее CorrelationID
+		envelope, err = events.Decode(publisher.messages[1].Value)
+		require.NoError(t, err)
+		assert.Equal(t, "2", envelope.ID)
+		assert.Equal(t, "1", envelope.CorrelationID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Ошибка Kafka", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		expectLock(mock, true)
+		mock.ExpectQuery("SELECT id, topic, event_key, event_type, event_version, payload, created_at, attempts, correlation_id FROM outbox").
+			WillReturnRows(sqlmock.NewRows(outboxColumns).
+				AddRow(5, models.TopicCouriers, "4", models.EventCourierRegistered, 1, []byte(`{}`), time.Now().UTC(), 0, nil))
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE outbox SET attempts = attempts + 1, last_error = $1 WHERE id = ANY($2)")).
+			WithArgs("kafka недоступна", pq.Array([]int64{5})).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		n, err := NewRelay(NewStore(db), &fakePublisher{err: errors.New("kafka недоступна")}).RelayOnce(context.Background())
+		assert.Error(t, err)
+		assert.Zero(t, n)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Публикует другой экземпляр", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		expectLock(mock, false)
+		mock.ExpectRollback()
+
+		publisher := &fakePublisher{}
+		n, err := NewRelay(NewStore(db), publisher).RelayOnce(context.Background())
+		assert.NoError(t, err)
+		assert.Zero(t, n)
+		assert.Empty(t, publisher.messages)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,191 @@
+package outbox
+
+import (
+	"context"
+	"delivery/internal/events"
+	"delivery/internal/kafka"
+	"delivery/internal/metrics"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultInterval - период публикации событий по умолчанию
+	DefaultInterval = time.Second
+	// DefaultBatchSize - сколько событий публикуется за один раз по умолчанию
+	DefaultBatchSize = 100
+	// DefaultRetention - сколько хранятся опубликованные события
+	DefaultRetention = 7 * 24 * time.Hour
+
+	// publishTimeout ограничивает ожидание Kafka при публикации одного пакета
+	publishTimeout = 10 * time.Second
+	// cleanupInterval - как часто удаляются устаревшие опубликованные события
+	cleanupInterval = time.Hour
+)
+
+// Publisher публикует сообщения в брокер: Kafka или брокер в памяти процесса
+type Publisher interface {
+	Publish(ctx context.Context, messages ...kafka.Message) error
+}
+
+// Relay публикует события из outbox в Kafka и отмечает их опубликованными. Событие отмечается
+// только после подтверждения Kafka, поэтому при сбое оно публикуется повторно (at-least-once):
+// получатели должны пропускать повторы по заголовку event_id
+type Relay struct {
+	store     *Store
+	publisher Publisher
+	interval  time.Duration
+	batchSize int
+	retention time.Duration
+}
+
+func NewRelay(store *Store, publisher Publisher) *Relay {
+	return &Relay{
+		store:     store,
+		publisher: publisher,
+		interval:  DefaultInterval,
+		batchSize: DefaultBatchSize,
+		retention: DefaultRetention,
+	}
+}
+
+// WithInterval задает период публикации событий
+func (r *Relay) WithInterval(interval time.Duration) *Relay {
+	if interval > 0 {
+		r.interval = interval
+	}
+	return r
+}
+
+// WithBatchSize задает, сколько событий публикуется за один раз
+func (r *Relay) WithBatchSize(size int) *Relay {
+	if size > 0 {
+		r.batchSize = size
+	}
+	return r
+}
+
+// WithRetention задает, сколько хранятся опубликованные события
+func (r *Relay) WithRetention(retention time.Duration) *Relay {
+	if retention > 0 {
+		r.retention = retention
+	}
+	return r
+}
+
+// Start публикует накопившиеся события каждые interval, пока не отменен ctx
+func (r *Relay) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		cleanup := time.NewTicker(cleanupInterval)
+		defer cleanup.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-cleanup.C:
+				r.cleanup()
+				continue
+			case <-ctx.Done():
+				return
+			}
+
+			// Публикуем пакетами, пока не опубликованы все накопившиеся события
+			for {
+				n, err := r.RelayOnce(ctx)
+				if err != nil {
+					log.Printf("[OUTBOX] %v", err)
+					break
+				}
+				if n < r.batchSize {
+					break
+				}
+			}
+		}
+	}()
+	log.Printf("[OUTBOX] Публикация событий запущена: период %s, пакет %d", r.interval, r.batchSize)
+}
+
+// RelayOnce публикует один пакет событий в порядке записи и возвращает количество опубликованных.
+// Пока пакет публикует другой экземпляр, ничего не делает
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	tx, err := r.store.BeginTx()
+	if err != nil {
+		return 0, fmt.Errorf("ошибка при начале транзакции: %w", err)
+	}
+	defer tx.Rollback() // После Commit не выполняет никаких действий
+
+	locked, err := r.store.LockTx(tx)
+	if err != nil || !locked {
+		return 0, err
+	}
+
+	pending, err := r.store.PendingTx(tx, r.batchSize)
+	if err != nil || len(pending) == 0 {
+		return 0, err
+	}
+
+	ids := make([]int64, len(pending))
+	messages := make([]kafka.Message, len(pending))
+	for i, e := range pending {
+		ids[i] = e.ID
+		id := strconv.FormatInt(e.ID, 10)
+		// Событие без цепочки начинает новую
+		correlationID := e.CorrelationID
+		if correlationID == "" {
+			correlationID = id
+		}
+		messages[i], err = kafka.EventMessage(e.Topic, events.Envelope{
+			ID:            id,
+			Type:          e.Type,
+			Version:       e.Version,
+			OccurredAt:    e.CreatedAt,
+			AggregateID:   e.Key,
+			CorrelationID: correlationID,
+			Payload:       e.Payload,
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	publishCtx, cancel := context.WithTimeout(ctx, publishTimeout)
+	err = r.publisher.Publish(publishCtx, messages...)
+	cancel()
+	if err != nil {
+		metrics.OutboxPublishErrorsTotal.Inc()
+		// Пакет останется неопубликованным и будет отправлен целиком при следующей попытке
+		if markErr := r.store.MarkFailedTx(tx, ids, err); markErr == nil {
+			tx.Commit()
+		}
+		return 0, fmt.Errorf("ошибка при публикации %d событий: %w", len(pending), err)
+	}
+
+	// Если отметить события не удалось, они будут опубликованы повторно
+	if err := r.store.MarkSentTx(tx, ids, time.Now().UTC()); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("ошибка при отметке опубликованных событий: %w", err)
+	}
+
+	for _, e := range pending {
+		metrics.OutboxEventsPublishedTotal.WithLabelValues(e.Topic).Inc()
+	}
+	return len(pending), nil
+}
+
+// cleanup удаляет события, опубликованные раньше срока хранения
+func (r *Relay) cleanup() {
+	deleted, err := r.store.DeleteSent(time.Now().UTC().Add(-r.retention))
+	if err != nil {
+		log.Printf("[OUTBOX] %v", err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("[OUTBOX] Удалено %d опубликованных событий", deleted)
+	}
+}
@@ -0,0 +1,110 @@
+package outbox
+
+import (
+	"database/sql"
+	"delivery/internal/business/models"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// relayLockKey - ключ advisory-блокировки PostgreSQL, которую держит публикующий экземпляр.
+// Публикует один экземпляр за раз, поэтому события уходят в Kafka в порядке записи
+const relayLockKey = 7_400_501
+
+// Store - таблица outbox: события записываются в транзакции изменения и хранятся до публикации
+type Store struct {
+	db        *sql.DB
+	tableName string
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		db:        db,
+		tableName: "outbox",
+	}
+}
+
+// BeginTx начинает транзакцию, в которой изменение записывается вместе с событиями
+func (s *Store) BeginTx() (*sql.Tx, error) {
+	return s.db.Begin()
+}
+
+// AddTx записывает событие в транзакции tx
+func (s *Store) AddTx(tx *sql.Tx, e models.OutboxEvent) error {
+	query := fmt.Sprintf(`INSERT INTO %s (topic, event_key, event_type, event_version, payload, created_at, correlation_id)
+              VALUES ($1, $2, $3, $4, $5, $6, $7)`, s.tableName)
+	correlationID := sql.NullString{String: e.CorrelationID, Valid: e.CorrelationID != ""}
+	if _, err := tx.Exec(query, e.Topic, e.Key, e.Type, e.Version, []byte(e.Payload), e.CreatedAt, correlationID); err != nil {
+		return fmt.Errorf("ошибка при записи события %s в outbox: %w", e.Type, err)
+	}
+	return nil
+}
+
+// LockTx пытается занять публикацию до конца транзакции tx. Возвращает false,
+// если события сейчас публикует другой экземпляр
+func (s *Store) LockTx(tx *sql.Tx) (bool, error) {
+	var locked bool
+	if err := tx.QueryRow(`SELECT pg_try_advisory_xact_lock($1)`, relayLockKey).Scan(&locked); err != nil {
+		return false, fmt.Errorf("ошибка при блокировке outbox: %w", err)
+	}
+	return locked, nil
+}
+
+// PendingTx возвращает до limit неопубликованных событий в порядке записи
+func (s *Store) PendingTx(tx *sql.Tx, limit int) ([]models.OutboxEvent, error) {
+	query := fmt.Sprintf(`SELECT id, topic, event_key, event_type, event_version, payload, created_at, attempts, correlation_id FROM %s
+              WHERE sent_at IS NULL ORDER BY id LIMIT $1`, s.tableName)
+	rows, err := tx.Query(query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении событий outbox: %w", err)
+	}
+	defer rows.Close()
+
+	var events []models.OutboxEvent
+	for rows.Next() {
+		var e models.OutboxEvent
+		var payload []byte
+		var correlationID sql.NullString
+		if err := rows.Scan(&e.ID, &e.Topic, &e.Key, &e.Type, &e.Version, &payload, &e.CreatedAt, &e.Attempts, &correlationID); err != nil {
+			return nil, fmt.Errorf("ошибка при сканировании события outbox: %w", err)
+		}
+		e.Payload = payload
+		e.CorrelationID = correlationID.String
+		events = append(events, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке результатов: %w", err)
+	}
+	return events, nil
+}
+
+// MarkSentTx отмечает события опубликованными
+func (s *Store) MarkSentTx(tx *sql.Tx, ids []int64, sentAt time.Time) error {
+	query := fmt.Sprintf(`UPDATE %s SET sent_at = $1, last_error = NULL WHERE id = ANY($2)`, s.tableName)
+	if _, err := tx.Exec(query, sentAt, pq.Array(ids)); err != nil {
+		return fmt.Errorf("ошибка при отметке опубликованных событий: %w", err)
+	}
+	return nil
+}
+
+// MarkFailedTx увеличивает счетчик попыток публикации событий и сохраняет последнюю ошибку
+func (s *Store) MarkFailedTx(tx *sql.Tx, ids []int64, publishErr error) error {
+	query := fmt.Sprintf(`UPDATE %s SET attempts = attempts + 1, last_error = $1 WHERE id = ANY($2)`, s.tableName)
+	if _, err := tx.Exec(query, publishErr.Error(), pq.Array(ids)); err != nil {
+		return fmt.Errorf("ошибка при сохранении ошибки публикации: %w", err)
+	}
+	return nil
+}
+
+// DeleteSent удаляет события, опубликованные раньше before, и возвращает их количество
+func (s *Store) DeleteSent(before time.Time) (int64, error) {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE sent_at IS NOT NULL AND sent_at < $1`, s.tableName)
+	result, err := s.db.Exec(query, before)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка при удалении опубликованных событий: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,78 @@
+package outbox
+
+import (
+	"database/sql"
+	"delivery/internal/business/models"
+	"delivery/internal/events"
+	"fmt"
+)
+
+// Writer записывает события в транзакции изменения. Реализуется Store
+type Writer interface {
+	BeginTx() (*sql.Tx, error)
+	AddTx(tx *sql.Tx, event models.OutboxEvent) error
+}
+
+// InTx выполняет change в транзакции и записывает в outbox события, которые change вернула:
+// изменение и события сохраняются вместе или не сохраняются вовсе.
+// Без outbox (w == nil) change выполняется без транзакции с tx == nil, а события отбрасываются
+func InTx(w Writer, change func(tx *sql.Tx) ([]models.OutboxEvent, error)) error {
+	if w == nil {
+		_, err := change(nil)
+		return err
+	}
+
+	tx, err := w.BeginTx()
+	if err != nil {
+		return fmt.Errorf("ошибка при начале транзакции: %w", err)
+	}
+	defer tx.Rollback() // После Commit не выполняет никаких действий
+
+	events, err := change(tx)
+	if err != nil {
+		return err
+	}
+	for _, event := range events {
+		if err := w.AddTx(tx, event); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ошибка при сохранении изменений: %w", err)
+	}
+	return nil
+}
+
+// Correlate относит события к цепочке correlationID. События, у которых цепочка уже указана,
+// и пустой correlationID ничего не меняют
+func Correlate(events []models.OutboxEvent, correlationID string) []models.OutboxEvent {
+	if correlationID == "" {
+		return events
+	}
+	for i := range events {
+		if events[i].CorrelationID == "" {
+			events[i].CorrelationID = correlationID
+		}
+	}
+	return events
+}
+
+// Event возвращает для InTx одно событие типа eventType записи с ID key. Событие записывается
+// в текущей версии из реестра events.Domain и должно публиковаться в зарегистрированный топик
+func Event(topic string, key int, eventType string, payload interface{}) ([]models.OutboxEvent, error) {
+	schema, ok := events.Domain.Current(eventType)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", events.ErrUnknownType, eventType)
+	}
+	if schema.Topic != topic {
+		return nil, fmt.Errorf("событие %s публикуется в топик %s, а не %s", eventType, schema.Topic, topic)
+	}
+
+	event, err := models.NewOutboxEvent(topic, key, eventType, payload)
+	if err != nil {
+		return nil, err
+	}
+	event.Version = schema.Version
+	return []models.OutboxEvent{event}, nil
+}
@@ -0,0 +1,348 @@
+// Package paysim - симулятор платежного шлюза для тестов и локального запуска без реального
+// провайдера. Симулятор хранит платежи в памяти и отвечает по сценарию: успешная оплата,
+// отказ, недоступность шлюза или подтверждение 3-D Secure, при необходимости с задержкой
+package paysim
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Scenario - поведение шлюза при создании платежа
+type Scenario string
+
+const (
+	ScenarioSuccess Scenario = "success" // Средства блокируются сразу
+	ScenarioDecline Scenario = "decline" // Платеж отклоняется
+	ScenarioError   Scenario = "error"   // Шлюз недоступен: запросы к платежам завершаются ошибкой 503
+	ScenarioThreeDS Scenario = "3ds"     // Платеж ожидает подтверждения 3-D Secure на странице redirect_url
+)
+
+// Статусы платежа в шлюзе
+const (
+	StatusRequiresAction = "requires_action"
+	StatusAuthorized     = "authorized"
+	StatusCaptured       = "captured"
+	StatusVoided         = "voided"
+	StatusRefunded       = "refunded"
+	StatusFailed         = "failed"
+)
+
+// DeclineMessage - причина отказа в сценарии ScenarioDecline
+const DeclineMessage = "Платеж отклонен банком-эмитентом"
+
+// Payment - платеж в шлюзе
+type Payment struct {
+	ID             string    `json:"id"`
+	Reference      string    `json:"reference"`
+	Amount         float64   `json:"amount"`
+	Currency       string    `json:"currency"`
+	Method         string    `json:"method,omitempty"`
+	Description    string    `json:"description,omitempty"`
+	Status         string    `json:"status"`
+	RefundedAmount float64   `json:"refunded_amount"`
+	RedirectURL    string    `json:"redirect_url,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Settings - сценарий шлюза и задержка ответов на запросы к платежам
+type Settings struct {
+	Scenario Scenario `json:"scenario"`
+	DelayMs  int      `json:"delay_ms"`
+}
+
+// Simulator - симулятор платежного шлюза. API платежей совпадает с API, который ожидает
+// payment.HTTPProvider; сценарий меняется через PUT /scenario или SetScenario
+type Simulator struct {
+	mu       sync.Mutex
+	settings Settings
+	payments map[string]*Payment
+	seq      int
+}
+
+func NewSimulator() *Simulator {
+	return &Simulator{
+		settings: Settings{Scenario: ScenarioSuccess},
+		payments: make(map[string]*Payment),
+	}
+}
+
+// SetScenario задает сценарий и задержку ответов на запросы к платежам
+func (s *Simulator) SetScenario(scenario Scenario, delay time.Duration) error {
+	switch scenario {
+	case ScenarioSuccess, ScenarioDecline, ScenarioError, ScenarioThreeDS:
+	default:
+		return fmt.Errorf("неизвестный сценарий платежного шлюза: %s", scenario)
+	}
+	if delay < 0 {
+		return fmt.Errorf("задержка не может быть отрицательной: %s", delay)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.settings = Settings{Scenario: scenario, DelayMs: int(delay / time.Millisecond)}
+	return nil
+}
+
+// Handler возвращает HTTP-обработчик шлюза
+func (s *Simulator) Handler() http.Handler {
+	r := mux.NewRouter()
+
+	api := r.PathPrefix("/payments").Subrouter()
+	api.Use(s.scenarioMiddleware)
+	api.HandleFunc("", s.authorize).Methods("POST")
+	api.HandleFunc("/{id}", s.get).Methods("GET")
+	api.HandleFunc("/{id}/capture", s.transition(s.capture)).Methods("POST")
+	api.HandleFunc("/{id}/void", s.transition(s.void)).Methods("POST")
+	api.HandleFunc("/{id}/refund", s.transition(s.refund)).Methods("POST")
+
+	// Страница подтверждения 3-D Secure, на которую перенаправляется покупатель
+	r.HandleFunc("/3ds/{id}", s.threeDSPage).Methods("GET")
+	r.HandleFunc("/3ds/{id}", s.confirmThreeDS).Methods("POST")
+
+	r.HandleFunc("/scenario", s.getScenario).Methods("GET")
+	r.HandleFunc("/scenario", s.putScenario).Methods("PUT")
+	return r
+}
+
+// scenarioMiddleware задерживает ответ и имитирует недоступность шлюза
+func (s *Simulator) scenarioMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		settings := s.settings
+		s.mu.Unlock()
+
+		if settings.DelayMs > 0 {
+			select {
+			case <-time.After(time.Duration(settings.DelayMs) * time.Millisecond):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if settings.Scenario == ScenarioError {
+			writeError(w, http.StatusServiceUnavailable, "платежный шлюз временно недоступен")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Simulator) authorize(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Reference   string  `json:"reference"`
+		Amount      float64 `json:"amount"`
+		Currency    string  `json:"currency"`
+		Method      string  `json:"method"`
+		Description string  `json:"description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "неверный формат запроса: "+err.Error())
+		return
+	}
+	if req.Amount <= 0 || req.Currency == "" {
+		writeError(w, http.StatusBadRequest, "не указаны сумма или валюта")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	p := &Payment{
+		ID:          fmt.Sprintf("sim_%d", s.seq),
+		Reference:   req.Reference,
+		Amount:      req.Amount,
+		Currency:    req.Currency,
+		Method:      req.Method,
+		Description: req.Description,
+		Status:      StatusAuthorized,
+		CreatedAt:   time.Now(),
+	}
+	switch s.settings.Scenario {
+	case ScenarioDecline:
+		p.Status = StatusFailed
+		p.Error = DeclineMessage
+	case ScenarioThreeDS:
+		p.Status = StatusRequiresAction
+		p.RedirectURL = fmt.Sprintf("%s/3ds/%s", baseURL(r), p.ID)
+	}
+	s.payments[p.ID] = p
+	log.Printf("[PAYSIM] Платеж %s (%s) на сумму %.2f %s: %s", p.ID, p.Reference, p.Amount, p.Currency, p.Status)
+
+	writeJSON(w, http.StatusCreated, p)
+}
+
+func (s *Simulator) get(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.payments[mux.Vars(r)["id"]]
+	if !ok {
+		writeError(w, http.StatusNotFound, "платеж не найден")
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+// transition возвращает обработчик, который меняет статус платежа функцией change.
+// Ошибка change означает, что операция недопустима в текущем статусе платежа
+func (s *Simulator) transition(change func(p *Payment, r *http.Request) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		p, ok := s.payments[mux.Vars(r)["id"]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "платеж не найден")
+			return
+		}
+		if err := change(p, r); err != nil {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		log.Printf("[PAYSIM] Платеж %s (%s): %s", p.ID, p.Reference, p.Status)
+		writeJSON(w, http.StatusOK, p)
+	}
+}
+
+// capture списывает заблокированные средства
+func (s *Simulator) capture(p *Payment, r *http.Request) error {
+	if p.Status != StatusAuthorized {
+		return fmt.Errorf("нельзя списать платеж в статусе %s", p.Status)
+	}
+	p.Status = StatusCaptured
+	return nil
+}
+
+// void снимает блокировку средств или отменяет платеж, ожидающий 3-D Secure
+func (s *Simulator) void(p *Payment, r *http.Request) error {
+	if p.Status != StatusAuthorized && p.Status != StatusRequiresAction {
+		return fmt.Errorf("нельзя отменить платеж в статусе %s", p.Status)
+	}
+	p.Status = StatusVoided
+	p.RedirectURL = ""
+	return nil
+}
+
+// refund возвращает списанные средства. Сумма не больше 0 означает возврат остатка
+func (s *Simulator) refund(p *Payment, r *http.Request) error {
+	var req struct {
+		Amount float64 `json:"amount"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return fmt.Errorf("неверный формат запроса: %w", err)
+		}
+	}
+	if p.Status != StatusCaptured && p.Status != StatusRefunded {
+		return fmt.Errorf("нельзя вернуть платеж в статусе %s", p.Status)
+	}
+
+	remaining := p.Amount - p.RefundedAmount
+	amount := req.Amount
+	if amount <= 0 {
+		amount = remaining
+	}
+	if amount > remaining+0.005 || remaining < 0.005 {
+		return fmt.Errorf("сумма возврата %.2f больше остатка платежа %.2f", amount, remaining)
+	}
+	p.RefundedAmount = math.Round((p.RefundedAmount+amount)*100) / 100
+	p.Status = StatusRefunded
+	return nil
+}
+
+var threeDSPage = template.Must(template.New("3ds").Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head><meta charset="utf-8"><title>3-D Secure</title></head>
+<body>
+<h1>Подтверждение платежа</h1>
+<p>Платеж {{.ID}} на сумму {{printf "%.2f" .Amount}} {{.Currency}}</p>
+<form method="post"><input type="hidden" name="result" value="success"><button>Подтвердить</button></form>
+<form method="post"><input type="hidden" name="result" value="failure"><button>Отклонить</button></form>
+</body>
+</html>`))
+
+// threeDSPage показывает страницу подтверждения платежа
+func (s *Simulator) threeDSPage(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	p, ok := s.payments[mux.Vars(r)["id"]]
+	var payment Payment
+	if ok {
+		payment = *p
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		http.Error(w, "платеж не найден", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	threeDSPage.Execute(w, payment)
+}
+
+// confirmThreeDS принимает результат подтверждения 3-D Secure: result=success блокирует
+// средства, любой другой результат отклоняет платеж
+func (s *Simulator) confirmThreeDS(w http.ResponseWriter, r *http.Request) {
+	s.transition(func(p *Payment, r *http.Request) error {
+		if p.Status != StatusRequiresAction {
+			return fmt.Errorf("платеж в статусе %s не ожидает подтверждения", p.Status)
+		}
+		p.RedirectURL = ""
+		if r.FormValue("result") == "success" {
+			p.Status = StatusAuthorized
+			return nil
+		}
+		p.Status = StatusFailed
+		p.Error = "Платеж не подтвержден 3-D Secure"
+		return nil
+	})(w, r)
+}
+
+func (s *Simulator) getScenario(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	settings := s.settings
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, settings)
+}
+
+func (s *Simulator) putScenario(w http.ResponseWriter, r *http.Request) {
+	var settings Settings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		writeError(w, http.StatusBadRequest, "неверный формат запроса: "+err.Error())
+		return
+	}
+	if err := s.SetScenario(settings.Scenario, time.Duration(settings.DelayMs)*time.Millisecond); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	log.Printf("[PAYSIM] Сценарий %s, задержка %d мс", settings.Scenario, settings.DelayMs)
+	writeJSON(w, http.StatusOK, settings)
+}
+
+// baseURL возвращает адрес шлюза, по которому к нему обратился клиент
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + strings.TrimSuffix(r.Host, "/")
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
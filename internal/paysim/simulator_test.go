@@ -0,0 +1,143 @@
+package paysim
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// call выполняет запрос к симулятору и декодирует ответ в out
+func call(t *testing.T, server *httptest.Server, method, path string, body interface{}, out interface{}) int {
+	t.Helper()
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		require.NoError(t, err)
+	}
+	req, err := http.NewRequest(method, server.URL+path, bytes.NewReader(data))
+	require.NoError(t, err)
+	resp, err := server.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	if out != nil {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(out))
+	}
+	return resp.StatusCode
+}
+
+func newServer(t *testing.T, scenario Scenario) (*Simulator, *httptest.Server) {
+	sim := NewSimulator()
+	require.NoError(t, sim.SetScenario(scenario, 0))
+	server := httptest.NewServer(sim.Handler())
+	t.Cleanup(server.Close)
+	return sim, server
+}
+
+var paymentRequest = map[string]interface{}{"reference": "pay_1", "amount": 100.0, "currency": "RUB"}
+
+func TestSimulator_CaptureAndRefund(t *testing.T) {
+	_, server := newServer(t, ScenarioSuccess)
+
+	var p Payment
+	assert.Equal(t, http.StatusCreated, call(t, server, "POST", "/payments", paymentRequest, &p))
+	assert.Equal(t, StatusAuthorized, p.Status)
+
+	assert.Equal(t, http.StatusOK, call(t, server, "POST", "/payments/"+p.ID+"/capture", nil, &p))
+	assert.Equal(t, StatusCaptured, p.Status)
+
+	// Повторное списание недопустимо
+	assert.Equal(t, http.StatusConflict, call(t, server, "POST", "/payments/"+p.ID+"/capture", nil, nil))
+
+	assert.Equal(t, http.StatusOK, call(t, server, "POST", "/payments/"+p.ID+"/refund", map[string]float64{"amount": 40}, &p))
+	assert.Equal(t, StatusRefunded, p.Status)
+	assert.Equal(t, 40.0, p.RefundedAmount)
+
+	// Возврат больше остатка
+	assert.Equal(t, http.StatusConflict, call(t, server, "POST", "/payments/"+p.ID+"/refund", map[string]float64{"amount": 70}, nil))
+
+	// Возврат остатка
+	assert.Equal(t, http.StatusOK, call(t, server, "POST", "/payments/"+p.ID+"/refund", nil, &p))
+	assert.Equal(t, 100.0, p.RefundedAmount)
+
+	assert.Equal(t, http.StatusNotFound, call(t, server, "GET", "/payments/sim_404", nil, nil))
+}
+
+func TestSimulator_Void(t *testing.T) {
+	_, server := newServer(t, ScenarioSuccess)
+
+	var p Payment
+	call(t, server, "POST", "/payments", paymentRequest, &p)
+	assert.Equal(t, http.StatusOK, call(t, server, "POST", "/payments/"+p.ID+"/void", nil, &p))
+	assert.Equal(t, StatusVoided, p.Status)
+	assert.Equal(t, http.StatusConflict, call(t, server, "POST", "/payments/"+p.ID+"/capture", nil, nil))
+}
+
+func TestSimulator_Decline(t *testing.T) {
+	_, server := newServer(t, ScenarioDecline)
+
+	var p Payment
+	assert.Equal(t, http.StatusCreated, call(t, server, "POST", "/payments", paymentRequest, &p))
+	assert.Equal(t, StatusFailed, p.Status)
+	assert.Equal(t, DeclineMessage, p.Error)
+}
+
+func TestSimulator_Error(t *testing.T) {
+	_, server := newServer(t, ScenarioError)
+
+	var resp map[string]string
+	assert.Equal(t, http.StatusServiceUnavailable, call(t, server, "POST", "/payments", paymentRequest, &resp))
+	assert.NotEmpty(t, resp["error"])
+}
+
+func TestSimulator_ThreeDS(t *testing.T) {
+	_, server := newServer(t, ScenarioThreeDS)
+
+	for result, want := range map[string]string{"success": StatusAuthorized, "failure": StatusFailed} {
+		var p Payment
+		call(t, server, "POST", "/payments", paymentRequest, &p)
+		assert.Equal(t, StatusRequiresAction, p.Status)
+		require.True(t, strings.HasPrefix(p.RedirectURL, server.URL+"/3ds/"), p.RedirectURL)
+
+		page, err := server.Client().Get(p.RedirectURL)
+		require.NoError(t, err)
+		page.Body.Close()
+		assert.Equal(t, http.StatusOK, page.StatusCode)
+
+		resp, err := server.Client().PostForm(p.RedirectURL, url.Values{"result": {result}})
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		var confirmed Payment
+		call(t, server, "GET", "/payments/"+p.ID, nil, &confirmed)
+		assert.Equal(t, want, confirmed.Status, result)
+		assert.Empty(t, confirmed.RedirectURL)
+	}
+}
+
+func TestSimulator_Scenario(t *testing.T) {
+	sim, server := newServer(t, ScenarioSuccess)
+
+	var settings Settings
+	assert.Equal(t, http.StatusOK, call(t, server, "PUT", "/scenario", Settings{Scenario: ScenarioDecline, DelayMs: 50}, &settings))
+	call(t, server, "GET", "/scenario", nil, &settings)
+	assert.Equal(t, Settings{Scenario: ScenarioDecline, DelayMs: 50}, settings)
+
+	assert.Equal(t, http.StatusBadRequest, call(t, server, "PUT", "/scenario", Settings{Scenario: "unknown"}, nil))
+	assert.Error(t, sim.SetScenario(ScenarioSuccess, -time.Second))
+
+	// Запросы к платежам отвечают с задержкой
+	start := time.Now()
+	var p Payment
+	call(t, server, "POST", "/payments", paymentRequest, &p)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	assert.Equal(t, StatusFailed, p.Status)
+}
@@ -8,10 +8,20 @@ import (
 	"delivery/internal/business/courier"
 	"delivery/internal/business/customer"
 	"delivery/internal/business/delivery"
+	"delivery/internal/business/dispatch"
+	"delivery/internal/business/models"
 	"delivery/internal/business/parcel"
+	"delivery/internal/business/payment"
+	"delivery/internal/business/route"
+	"delivery/internal/business/zone"
 	"delivery/internal/cache"
+	"delivery/internal/controllers"
 	"delivery/internal/db"
+	"delivery/internal/geo"
 	"delivery/internal/kafka"
+	"delivery/internal/outbox"
+	"delivery/internal/paysim"
+	"errors"
 	"log"
 	"net/http"
 	"os"
@@ -42,27 +52,16 @@ func main() {
 		log.Println("Не удалось инициализировать Redis, продолжаем без кэширования")
 	}
 
-	// Инициализация Kafka
-	kafkaClient, err := kafka.NewClient()
+	// Инициализация брокера сообщений: Kafka или брокер в памяти процесса для локального запуска
+	broker, err := kafka.NewBroker(config.Broker.Type)
+	if errors.Is(err, kafka.ErrUnknownBroker) {
+		log.Fatalf("Ошибка настройки брокера сообщений: %v", err)
+	}
 	if err != nil {
-		log.Printf("Предупреждение: Не удалось инициализировать клиент Kafka: %v", err)
+		log.Printf("Предупреждение: Не удалось инициализировать брокер сообщений: %v", err)
 	} else {
-		defer kafkaClient.Close()
-		log.Println("Клиент Kafka успешно инициализирован")
-
-		// Запускаем тестовый consumer и отправляем тестовое сообщение
-		err = kafkaClient.StartTestConsumer("test-topic", "test-group")
-		if err != nil {
-			log.Printf("Предупреждение: Не удалось запустить тестовый consumer: %v", err)
-		}
-
-		// Отправляем тестовое сообщение через некоторое время
-		go func() {
-			time.Sleep(2 * time.Second)
-			if err := kafkaClient.SendTestMessage("test-topic", "Привет, Kafka!"); err != nil {
-				log.Printf("Предупреждение: Не удалось отправить тестовое сообщение: %v", err)
-			}
-		}()
+		defer broker.Close()
+		log.Printf("Брокер сообщений %s успешно инициализирован", config.Broker.Type)
 	}
 
 	// Инициализация хранилищ
@@ -70,26 +69,66 @@ func main() {
 	parcelStore := parcel.NewParcelStore(database.DB)
 	deliveryStore := delivery.NewDeliveryStore(database.DB)
 	courierStore := courier.NewCourierStore(database.DB)
+	locationStore := courier.NewLocationStore(database.DB)
+	zoneStore := zone.NewZoneStore(database.DB)
 	userStore := auth.NewUserStore(database.DB)
+	outboxStore := outbox.NewStore(database.DB)
 
 	// Инициализация WebSocket менеджера
 	wsManager := api.NewWebSocketManager()
 	go wsManager.Run()
 
+	// Уведомления пересылаются через Redis Pub/Sub, чтобы их получали клиенты всех экземпляров.
+	// Без Redis уведомления доставляются только клиентам этого экземпляра
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	defer stopRelay()
+	if redisClient != nil {
+		if err := wsManager.WithBroker(redisClient).StartRelay(relayCtx); err != nil {
+			log.Printf("Предупреждение: %v", err)
+		}
+	}
+
 	// Инициализация сервисов
 	customerService := customer.NewCustomerService(customerStore)
 	parcelService := parcel.NewParcelService(parcelStore)
 	deliveryService := delivery.NewDeliveryService(deliveryStore)
-	courierService := courier.NewCourierService(courierStore)
+	courierService := courier.NewCourierService(courierStore).WithDeliveries(deliveryStore)
+	locationService := courier.NewLocationService(locationStore)
+	zoneService := zone.NewZoneService(zoneStore)
 	authService := auth.NewAuthService(userStore)
 
 	// Закрываем ресурсы authService при завершении
 	defer authService.Close()
 
+	// Первый администратор назначается из конфигурации, дальше роли меняются через API
+	if config.Auth.AdminEmail != "" {
+		if err := authService.EnsureAdmin(config.Auth.AdminEmail); err != nil {
+			log.Printf("Предупреждение: Не удалось назначить администратора: %v", err)
+		}
+	}
+
+	// Доменные события записываются в outbox в одной транзакции с изменениями
+	customerService.WithOutbox(outboxStore)
+	parcelService.WithOutbox(outboxStore)
+	deliveryService.WithOutbox(outboxStore)
+	courierService.WithOutbox(outboxStore)
+
+	// События из outbox публикуются в брокер; пока он недоступен, они накапливаются в outbox
+	if broker != nil {
+		outboxCtx, stopOutbox := context.WithCancel(context.Background())
+		defer stopOutbox()
+		outbox.NewRelay(outboxStore, broker).
+			WithInterval(time.Duration(config.Outbox.Interval) * time.Second).
+			WithBatchSize(config.Outbox.BatchSize).
+			WithRetention(time.Duration(config.Outbox.RetentionHours) * time.Hour).
+			Start(outboxCtx)
+	}
+
 	// Добавляем кэширование к сервисам, если Redis доступен
 	if redisClient != nil {
 		deliveryService.WithCache(redisClient)
 		authService.WithCache(redisClient)
+		locationService.WithCache(redisClient)
 		// Для других сервисов можно добавить аналогично, когда они будут поддерживать кэширование
 		// parcelService.WithCache(redisClient)
 		// courierService.WithCache(redisClient)
@@ -98,11 +137,141 @@ func main() {
 	// Добавляем WebSocket к сервису доставки
 	deliveryService.WithWebSocket(wsManager)
 
+	// Адрес посылки геокодируется по локальному справочнику, если он задан в конфигурации
+	if config.Geocoder.Gazetteer != "" {
+		gazetteer, err := geo.LoadGazetteer(config.Geocoder.Gazetteer)
+		if err != nil {
+			log.Fatalf("Ошибка загрузки справочника адресов: %v", err)
+		}
+		// Геокодированная посылка относится к зоне доставки, в которую попадает ее адрес
+		parcelService.WithGeocoder(gazetteer).WithZones(zoneService)
+	}
+
+	// Статус посылки следует за статусом доставки, а назначение блокирует посылку и курьера в одной транзакции
+	deliveryService.WithParcels(parcelService).WithAssignmentStores(courierStore, parcelStore)
+
+	// Маршрут курьера и ожидаемое время доставки рассчитываются по скорости его типа транспорта
+	planner := route.NewPlanner(deliveryService, parcelService, locationService).
+		WithSpeeds(courierService, route.Speeds{Default: config.ETA.DefaultSpeed, ByVehicle: config.ETA.Speeds}).
+		WithStopDuration(time.Duration(config.ETA.StopMinutes) * time.Minute)
+
+	// Ожидаемое время пересчитывается при каждой отметке местоположения и изменении статуса доставок курьера
+	estimator := route.NewEstimator(planner, deliveryService).
+		WithNotifier(wsManager).
+		WithThreshold(time.Duration(config.ETA.Threshold) * time.Second)
+	locationService.WithListener(estimator)
+	deliveryService.WithETA(estimator)
+
+	etaCtx, stopETA := context.WithCancel(context.Background())
+	defer stopETA()
+	estimator.Start(etaCtx)
+
+	// Автоматическое распределение посылок между доступными курьерами
+	var dispatcher *dispatch.Dispatcher
+	if config.Dispatch.Enabled {
+		strategy, err := dispatch.NewStrategy(config.Dispatch.Strategy, deliveryService, dispatch.NewFleetLocator(locationService))
+		if err != nil {
+			log.Fatalf("Ошибка настройки распределения посылок: %v", err)
+		}
+
+		dispatchCtx, stopDispatch := context.WithCancel(context.Background())
+		defer stopDispatch()
+
+		dispatcher = dispatch.NewDispatcher(parcelService, courierService, deliveryService, strategy).
+			WithInterval(time.Duration(config.Dispatch.Interval) * time.Second).
+			WithZones(courierService)
+		// Новая посылка распределяется сразу, не дожидаясь очередного прохода. С брокером сообщений
+		// распределение запускает событие ParcelRegistered, без него - регистрация посылки на этом экземпляре
+		if broker == nil {
+			parcelService.WithRegistrationListener(dispatcher)
+		}
+		dispatcher.Start(dispatchCtx)
+	}
+
+	// Платежи хранятся в базе данных и завершаются после доставки заказа. С платежным провайдером
+	// средства блокируются у него при создании платежа и списываются после доставки
+	paymentStore := payment.NewPgPaymentStore(database.DB)
+	var paymentService payment.Processor = paymentStore
+	gatewayURL := config.Payments.GatewayURL
+	switch config.Payments.Provider {
+	case "":
+	case "http":
+		if gatewayURL == "" {
+			log.Fatalf("Не задан адрес платежного шлюза payments.gateway_url")
+		}
+	case "simulator":
+		sim := paysim.NewSimulator()
+		err := sim.SetScenario(paysim.Scenario(config.Payments.Simulator.Scenario),
+			time.Duration(config.Payments.Simulator.DelayMs)*time.Millisecond)
+		if err != nil {
+			log.Fatalf("Ошибка настройки симулятора платежного шлюза: %v", err)
+		}
+		simServer := &http.Server{Addr: config.Payments.Simulator.Addr, Handler: sim.Handler()}
+		go func() {
+			log.Printf("Симулятор платежного шлюза запущен на %s", simServer.Addr)
+			if err := simServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Ошибка запуска симулятора платежного шлюза: %v", err)
+			}
+		}()
+		defer simServer.Close()
+		gatewayURL = "http://" + simServer.Addr
+	default:
+		log.Fatalf("Неизвестный платежный провайдер: %s", config.Payments.Provider)
+	}
+	if config.Payments.Provider != "" {
+		providerService := payment.NewProviderPaymentService(paymentStore, payment.NewHTTPProvider(gatewayURL)).
+			WithTimeout(time.Duration(config.Payments.Timeout) * time.Second)
+		log.Printf("Платежи проводятся через платежный шлюз %s", gatewayURL)
+
+		// Платеж, подтвержденный после доставки заказа, списывается периодической сверкой со шлюзом
+		reconcileCtx, stopReconcile := context.WithCancel(context.Background())
+		defer stopReconcile()
+		providerService.StartReconciler(reconcileCtx, time.Duration(config.Payments.ReconcileInterval)*time.Second)
+		paymentService = providerService
+	}
+
+	// Потребители доменных событий: оплата списывается после доставки, новая посылка распределяется сразу
+	if broker != nil {
+		consumers := kafka.NewConsumerGroup(broker, config.Consumers.GroupID).
+			WithWorkers(config.Consumers.Workers).
+			WithRetry(kafka.RetryPolicy{MaxAttempts: config.Consumers.MaxAttempts})
+		kafka.Handle(consumers, models.TopicDeliveries, models.EventDeliveryCompleted, payment.NewSettlement(paymentService).OnDeliveryCompleted)
+		if dispatcher != nil {
+			kafka.Handle(consumers, models.TopicParcels, models.EventParcelRegistered, dispatcher.OnParcelRegistered)
+		}
+
+		consumersCtx, stopConsumers := context.WithCancel(context.Background())
+		consumersDone := make(chan struct{})
+		go func() {
+			defer close(consumersDone)
+			if err := consumers.Run(consumersCtx); err != nil {
+				log.Printf("Ошибка потребителей событий: %v", err)
+			}
+		}()
+		// Перед закрытием брокера потребители завершают обработку текущих сообщений
+		defer func() {
+			stopConsumers()
+			<-consumersDone
+		}()
+	}
+
+	// Проверка владельца записей: клиенты и курьеры работают только со своими данными
+	ownership := api.NewOwnership(customerService, courierService, parcelService, deliveryService)
+
+	// WebSocket подключения требуют токен, а подписка - доступа к заказу, курьеру или зоне
+	wsManager.WithAuth(authService, ownership).WithAllowedOrigins(config.WebSocket.AllowedOrigins)
+
 	// Инициализация обработчиков
-	customerHandler := api.NewCustomerHandler(customerService)
-	parcelHandler := api.NewParcelHandler(parcelService)
-	deliveryHandler := api.NewDeliveryHandler(deliveryService)
-	courierHandler := api.NewCourierHandler(courierService)
+	customerHandler := api.NewCustomerHandler(customerService).WithOwnership(ownership)
+	parcelHandler := api.NewParcelHandler(parcelService).WithOwnership(ownership)
+	deliveryHandler := api.NewDeliveryHandler(deliveryService).WithOwnership(ownership)
+	courierHandler := api.NewCourierHandler(courierService).
+		WithOwnership(ownership).
+		WithLocations(locationService).
+		WithRoutes(planner)
+	authHandler := api.NewAuthHandler(authService)
+	trackingHandler := api.NewTrackingHandler(parcelService, deliveryService)
+	zoneHandler := api.NewZoneHandler(zoneService).WithOwnership(ownership)
 
 	// Создание маршрутизатора
 	r := api.NewRouter(
@@ -110,6 +279,10 @@ func main() {
 		customerHandler,
 		deliveryHandler,
 		courierHandler,
+		authHandler,
+		trackingHandler,
+		zoneHandler,
+		controllers.NewPaymentController(paymentService).WithOwnership(ownership),
 		authService,
 		redisClient,
 		wsManager,